@@ -1,67 +1,853 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"expvar"
 	"flag"
+	"io"
+	"math"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/hostinger/neigh2route/internal/api"
+	"github.com/hostinger/neigh2route/internal/config"
+	"github.com/hostinger/neigh2route/internal/eventsocket"
+	"github.com/hostinger/neigh2route/internal/grpc"
 	"github.com/hostinger/neigh2route/internal/logger"
 	"github.com/hostinger/neigh2route/internal/neighbor"
 	"github.com/hostinger/neigh2route/internal/sniffer"
+	"github.com/hostinger/neigh2route/internal/watchdog"
+	"github.com/hostinger/neigh2route/pkg/netutils"
 )
 
 var (
 	snifferMode     = flag.Bool("sniffer", false, "Enable NA sniffer mode for tap interfaces")
-	listenInterface = flag.String("interface", "", "Interface to monitor for neighbor updates")
+	bpfFilter       = flag.String("bpf-filter", sniffer.DefaultNAFilter, "BPF filter for Neighbor Advertisements in --sniffer mode")
+	snifferScan     = flag.Duration("sniffer-scan-interval", sniffer.DefaultScanInterval, "How often to rescan for tap interfaces in --sniffer mode")
+	pcapDumpPath    = flag.String("pcap-dump", "", "Write every packet the NA sniffers receive to this pcap file, rotated on SIGHUP (requires --sniffer)")
+	snifferFlags    = flag.String("sniffer-neigh-flags", "none", "Flags of the neighbor entries added in --sniffer mode: none, ext_learned or router")
+	snifferIPv4     = flag.Bool("sniffer-ipv4", false, "Also sniff ARP replies on tap interfaces to learn IPv4 neighbors (requires --sniffer)")
+	arpBPFFilter    = flag.String("arp-bpf-filter", sniffer.DefaultARPFilter, "BPF filter for ARP replies in --sniffer-ipv4 mode")
 	apiAddress      = flag.String("port", "127.0.0.1:54321", "Port for the API server")
+	grpcAddress     = flag.String("grpc-port", "", "Also serve the gRPC NeighborService on this port (on 127.0.0.1) or host:port, with the API's --api-token and TLS settings")
 	debugMode       = flag.Bool("debug", false, "Enable debug logging")
+	logFormat       = flag.String("log-format", string(logger.FormatText), "Log output format: text or json")
+	jsonLogs        = flag.Bool("json-logs", false, "Deprecated: use --log-format=json")
+	syslogMode      = flag.Bool("syslog", false, "Also send logs to syslog under --syslog-facility")
+	logOutput       = flag.String("log-output", logger.OutputStderr, "Where to write logs: stderr, stdout, syslog or a file path (files are rotated, or reopened after logrotate moved them, on SIGHUP)")
+	syslogFacility  = flag.String("syslog-facility", "daemon", "Syslog facility for --syslog and --log-output=syslog, such as daemon or local0")
+	routeRetries    = flag.Int("route-retries", neighbor.DefaultRouteRetries, "Number of attempts when adding a route fails")
+	routeBackoff    = flag.Duration("route-retry-backoff", neighbor.DefaultRouteRetryBackoff, "Initial delay between route add attempts, doubled after each failure")
+	routeMetric     = flag.Uint64("route-metric", 0, "Metric (priority) for installed routes, 0-4294967295 (0 uses the kernel default)")
+	routeTable      = flag.Int("route-table", 254, "Routing table ID to install routes into (254 is the main table)")
+	routeProto      = flag.Int("route-proto", 0, "Protocol number to mark installed routes with, e.g. 252 (0 uses the kernel default)")
+	routeScope      = flag.String("route-scope", "link", "Scope of installed routes: link, host or universe")
+	ipv4PrefixLen   = flag.Int("ipv4-prefix-len", 32, "Prefix length of routes to IPv4 neighbors, 1-32 (e.g. 31 for point-to-point links)")
+	ipv6Gateway     = flag.Bool("ipv6-gateway", false, "Route global IPv6 neighbors via their link-local address, when the kernel knows it, instead of on-link")
+	ipv6PrefixLen   = flag.Int("ipv6-prefix-len", 128, "Prefix length of routes to IPv6 neighbors, 1-128 (e.g. 126 for point-to-point links)")
+	noCleanup       = flag.Bool("no-cleanup", false, "Keep the installed routes on shutdown instead of removing them")
+	cleanupOnStart  = flag.Bool("cleanup-on-start", false, "Flush existing host routes on the interface before initializing the neighbor table")
+	dryRun          = flag.Bool("dry-run", false, "Log the routes that would be added or removed without changing the routing table")
+	vlanAware       = flag.Bool("vlan-aware", false, "Track the same neighbor IP separately on each 802.1Q VLAN")
+	failedHold      = flag.Int("failed-hold-seconds", 0, "Wait this many seconds before removing the route of a FAILED neighbor, keeping it if the neighbor recovers (0 removes immediately)")
+	migrationGrace  = flag.Int("migration-grace-ms", 0, "When a neighbor moves links, add the new route first and remove the old one after this many milliseconds (0 removes the old route first)")
+	ecmp            = flag.Bool("ecmp", false, "Keep a neighbor seen on several links reachable over all of them with a multipath route instead of moving it to the latest link")
+	maxNeighbors    = flag.Int("max-neighbors", 0, "Maximum number of tracked neighbors (0 is unlimited)")
+	evictPolicy     = flag.String("evict-policy", string(neighbor.EvictNone), "What to do with a new neighbor once --max-neighbors is reached: none (drop it) or lru (evict the least recently updated neighbor)")
+	noBuiltinExcl   = flag.Bool("no-builtin-excludes", false, "Do not exclude link-local and multicast prefixes (fe80::/10, ff00::/8, 169.254.0.0/16, 224.0.0.0/4) by default")
+	neighborStates  = flag.String("neighbor-states", "reachable,stale", "Comma-separated kernel neighbor states that get a route: reachable, stale, permanent, noarp")
+	initWorkers     = flag.Int("init-workers", neighbor.DefaultInitWorkers, "Number of neighbors added in parallel when the neighbor table is initialized at startup")
+	addRateLimit    = flag.Float64("add-rate-limit", neighbor.DefaultAddRateLimit, "Maximum new routes per second added from kernel neighbor updates; excess updates are dropped")
+	addBurst        = flag.Int("add-burst", neighbor.DefaultAddBurst, "Burst size for --add-rate-limit")
+	pingInterval    = flag.Duration("ping-interval", neighbor.DefaultPingInterval, "How often to ping each neighbor")
+	pingTimeout     = flag.Duration("ping-timeout", neighbor.DefaultPingTimeout, "How long to wait for a reply to a neighbor ping")
+	pingBackoff     = flag.Bool("ping-backoff", false, "Double a neighbor's ping interval after each consecutive failure, up to 10x --ping-interval")
+	useNS           = flag.Bool("use-ns", false, "Refresh IPv6 neighbors with Neighbor Solicitations instead of ICMP echo (IPv4 neighbors are always pinged)")
+	routeAudit      = flag.Bool("route-audit", true, "Periodically re-add neighbor routes that were removed outside neigh2route")
+	routeAuditEvery = flag.Duration("route-audit-interval", neighbor.DefaultRouteAuditInterval, "How often --route-audit checks the installed routes")
+	maxPauseBuffer  = flag.Int("max-pause-buffer", neighbor.DefaultMaxPauseBuffer, "Maximum number of neighbor updates buffered while processing is paused")
+	dumpFile        = flag.String("dump-file", "", "Write the neighbor table as JSON to this path on SIGUSR1 instead of stderr")
+	exportPath      = flag.String("export-on-shutdown", "", "Write the neighbor table as JSON to this path before exiting")
+	arpTable        = flag.Bool("arp-table", false, "Also pin each neighbor as a permanent entry in the kernel ARP/ND table")
+	stateFile       = flag.String("state-file", "", "Write the neighbor table as JSON to this path on every change and periodically, and restore it on startup")
+	auditLogPath    = flag.String("audit-log", "", "Append one JSON line per route added or removed to this file")
+	netlinkNotify   = flag.Bool("netlink-notify", false, "Also announce every route added or removed as RTM_NEWROUTE/RTM_DELROUTE on the rtnetlink route multicast groups")
+	ifaceAliases    = flag.String("interface-aliases", "", "JSON object mapping interface names to display names shown by /sniffers, re-read on SIGHUP")
+	seedFile        = flag.String("seed-file", "", "JSON array of {\"ip\", \"mac\", \"interface\"} neighbors to inject into the kernel neighbor table on startup")
+	stateInterval   = flag.Duration("state-interval", neighbor.DefaultPersistInterval, "How often to write --state-file")
+	eventHistory    = flag.Int("event-history-size", neighbor.DefaultEventHistorySize, "Number of neighbor add/remove events kept for /events and /neighbors/{ip}/history")
+	eventBusCap     = flag.Int("event-bus-capacity", neighbor.DefaultEventBusCapacity, "Buffered events per neighbor event subscriber before events are dropped")
+	eventSocket     = flag.String("event-socket", "", "Stream neighbor add/remove events as JSON lines to clients of this Unix socket")
+	tlsCert         = flag.String("tls-cert", "", "Serve the API over HTTPS with this certificate (requires --tls-key)")
+	tlsKey          = flag.String("tls-key", "", "Private key for --tls-cert")
+	tlsClientCA     = flag.String("tls-client-ca", "", "Require API clients to present a certificate signed by a CA in this PEM file (requires --tls-cert)")
+	apiToken        = flag.String("api-token", "", "Require API requests to send \"Authorization: Bearer <token>\"")
+	logRequestBody  = flag.Bool("log-request-body", false, "Log API request bodies at debug level")
+	maxRequestSize  = flag.Int64("max-request-size", api.DefaultMaxRequestSize, "Maximum API request body size in bytes")
+	statsInterval   = flag.Duration("stats-interval", 0, "Log an operational statistics summary at this interval (0 disables)")
+	metricsEnabled  = flag.Bool("metrics", false, "Expose Prometheus metrics on /metrics")
+	expvarEnabled   = flag.Bool("expvar", false, "Publish neighbor and sniffer counts with expvar and expose them on /debug/vars")
+	configPath      = flag.String("config", "", "YAML file with debug, ping_interval, route_metric and prefixes settings, re-read on SIGHUP")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "How long to wait for background work to stop on shutdown before exiting anyway")
+	httpTimeout     = flag.Duration("http-shutdown-timeout", 5*time.Second, "How long to wait for in-flight API and gRPC requests to finish on shutdown")
+	healthStaleness = flag.Duration("health-staleness", api.DefaultHealthStaleness, "Report /health as unavailable when no neighbor update arrived for this long")
+	maxSnapshots    = flag.Int("max-snapshots", api.DefaultMaxSnapshots, "Number of /neighbors/snapshot snapshots kept for /neighbors/diff, evicting the oldest")
+	snapshotTTL     = flag.Duration("snapshot-ttl", api.DefaultSnapshotTTL, "How long a /neighbors/snapshot snapshot is kept")
+	goroutineMax    = flag.Int("watchdog-goroutine-max", 0, "Warn when the goroutine count exceeds this value and exit after three consecutive checks (0 disables)")
+	goroutineEvery  = flag.Duration("watchdog-goroutine-interval", watchdog.DefaultInterval, "How often the goroutine watchdog checks the goroutine count")
 )
 
+// prefixList is a repeatable flag of CIDR prefixes.
+type prefixList []*net.IPNet
+
+func (p *prefixList) String() string {
+	prefixes := make([]string, 0, len(*p))
+	for _, prefix := range *p {
+		prefixes = append(prefixes, prefix.String())
+	}
+	return strings.Join(prefixes, ",")
+}
+
+func (p *prefixList) Set(value string) error {
+	_, prefix, err := net.ParseCIDR(value)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, prefix)
+	return nil
+}
+
+var (
+	prefixes        prefixList
+	excludePrefixes prefixList
+)
+
+// interfaceList is a repeatable, comma-separated flag of interface names.
+type interfaceList []string
+
+func (l *interfaceList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *interfaceList) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*l = append(*l, name)
+		}
+	}
+	return nil
+}
+
+var interfaces interfaceList
+
+// patternList is a repeatable flag of regular expressions.
+type patternList []*regexp.Regexp
+
+func (l *patternList) String() string {
+	patterns := make([]string, 0, len(*l))
+	for _, pattern := range *l {
+		patterns = append(patterns, pattern.String())
+	}
+	return strings.Join(patterns, ",")
+}
+
+func (l *patternList) Set(value string) error {
+	pattern, err := regexp.Compile(value)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, pattern)
+	return nil
+}
+
+var tapPatterns patternList
+
+func init() {
+	flag.Var(&prefixes, "prefix", "Only track neighbors inside this CIDR prefix (repeatable, default all)")
+	flag.Var(&excludePrefixes, "exclude-prefix", "Never track neighbors inside this CIDR prefix, even if a --prefix matches (repeatable)")
+	flag.Var(&interfaces, "interface", "Interface to monitor for neighbor updates (repeatable or comma-separated, default all)")
+	flag.Var(&tapPatterns, "tap-pattern", "Regular expression selecting the interfaces to sniff in --sniffer mode (repeatable, default "+sniffer.DefaultTapPattern+")")
+}
+
+type exporter interface {
+	ExportJSON(w io.Writer) error
+}
+
+// exportToFile writes through a temp file in the same directory and renames
+// it into place, so readers never see a partial export.
+func exportToFile(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// waitTimeout waits for wg and reports whether it finished within timeout.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func waitForShutdown(c <-chan os.Signal, exportPath string, e exporter, cleanup func()) {
+	sig := <-c
+	logger.Info("Received signal: %s. Cleaning up and exiting...", sig)
+
+	if exportPath != "" {
+		if err := exportToFile(exportPath, e.ExportJSON); err != nil {
+			logger.Error("Failed to export neighbor table to %s: %v", exportPath, err)
+		} else {
+			logger.Info("Exported neighbor table to %s", exportPath)
+		}
+	}
+
+	cleanup()
+}
+
+type dumper interface {
+	DumpJSON(w io.Writer) error
+}
+
+// dumpOutput receives SIGUSR1 dumps when no --dump-file is set.
+var dumpOutput io.Writer = os.Stderr
+
+// dumpOnSignal writes the neighbor table to path, or to dumpOutput when path
+// is empty, for every signal received on c until ctx is cancelled.
+func dumpOnSignal(ctx context.Context, c <-chan os.Signal, path string, d dumper) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c:
+			if path == "" {
+				if err := d.DumpJSON(dumpOutput); err != nil {
+					logger.Error("Failed to dump neighbor table: %v", err)
+				}
+				continue
+			}
+			if err := exportToFile(path, d.DumpJSON); err != nil {
+				logger.Error("Failed to dump neighbor table to %s: %v", path, err)
+				continue
+			}
+			logger.Info("Dumped neighbor table to %s", path)
+		}
+	}
+}
+
+// rotateOnSignal rotates dump for every signal received on c and closes it
+// once ctx is cancelled.
+func rotateOnSignal(ctx context.Context, c <-chan os.Signal, dump *sniffer.PcapDump) {
+	for {
+		select {
+		case <-ctx.Done():
+			if err := dump.Close(); err != nil {
+				logger.Error("Failed to close packet dump: %v", err)
+			}
+			return
+		case <-c:
+			if err := dump.Rotate(); err != nil {
+				logger.Error("Failed to rotate packet dump: %v", err)
+			}
+		}
+	}
+}
+
+// rotateLogOnSignal rotates the log file for every signal received on c
+// until ctx is cancelled.
+func rotateLogOnSignal(ctx context.Context, c <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c:
+			if err := logger.Rotate(); err != nil {
+				logger.Error("Failed to rotate log file: %v", err)
+			}
+		}
+	}
+}
+
+type statsSource interface {
+	Stats() neighbor.Stats
+}
+
+func logStats(src statsSource, activeSniffers func() int) {
+	s := src.Stats()
+	logger.Info("event=stats total_neighbors=%d v4=%d v6=%d routes_added=%d routes_removed=%d ping_failures=%d active_sniffers=%d",
+		s.TotalNeighbors, s.IPv4Neighbors, s.IPv6Neighbors, s.RoutesAdded, s.RoutesRemoved, s.PingFailures, activeSniffers())
+}
+
+func reportStats(ctx context.Context, interval time.Duration, src statsSource, activeSniffers func() int) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			logStats(src, activeSniffers)
+		}
+	}
+}
+
+// reloader is the part of NeighborManager a config reload changes.
+type reloader interface {
+	SetPingInterval(interval time.Duration)
+	SetRouteMetric(metric uint32)
+	Policy() neighbor.NeighborPolicy
+	ApplyPolicy(policy neighbor.NeighborPolicy) error
+}
+
+// reloadConfig re-reads the config file at path and applies its mutable
+// settings. Changed immutable settings are logged and ignored.
+func reloadConfig(path string, r reloader, format logger.Format) error {
+	f, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	warnRestartSettings(path, f)
+
+	if f.Debug != nil {
+		logger.Init(*f.Debug, format)
+	}
+	if f.PingInterval != nil {
+		r.SetPingInterval(*f.PingInterval)
+	}
+	if f.RouteMetric != nil {
+		r.SetRouteMetric(*f.RouteMetric)
+	}
+
+	allowed, err := f.ParsePrefixes()
+	if err != nil {
+		return err
+	}
+	if allowed != nil {
+		policy := r.Policy()
+		policy.AllowPrefixes = allowed
+		if err := r.ApplyPolicy(policy); err != nil {
+			return err
+		}
+	}
+
+	logger.Info("Applied configuration from %s", path)
+	return nil
+}
+
+// warnRestartSettings logs the settings in f that differ from the flags and
+// only take effect on restart.
+func warnRestartSettings(path string, f *config.File) {
+	if f.Interface != nil && strings.Join(f.Interface, ",") != strings.Join(interfaces, ",") {
+		logger.Warn("Ignoring interface change in %s, restart to apply it", path)
+	}
+	if f.Port != "" && f.Port != *apiAddress {
+		logger.Warn("Ignoring port change in %s, restart to apply it", path)
+	}
+}
+
+// reloadOnSignal calls reload for every signal received on c until ctx is
+// cancelled.
+func reloadOnSignal(ctx context.Context, c <-chan os.Signal, reload func() error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c:
+			if err := reload(); err != nil {
+				logger.Error("Failed to reload configuration: %v", err)
+			}
+		}
+	}
+}
+
 func main() {
 	flag.Parse()
-	logger.Init(*debugMode)
 
+	format, err := logger.ParseFormat(*logFormat)
+	if err != nil {
+		logger.Fatal("Invalid --log-format: %v", err)
+	}
+	if *jsonLogs {
+		format = logger.FormatJSON
+	}
+	logger.Init(*debugMode, format)
+
+	facility, err := logger.ParseFacility(*syslogFacility)
+	if err != nil {
+		logger.Fatal("Invalid --syslog-facility: %v", err)
+	}
+	logger.SetSyslogFacility(facility)
+
+	if err := logger.SetDestination(*logOutput); err != nil {
+		logger.Fatal("Invalid --log-output: %v", err)
+	}
+
+	if *syslogMode && *logOutput != logger.OutputSyslog {
+		if err := logger.EnableSyslog("", ""); err != nil {
+			logger.Error("Failed to connect to syslog: %v", err)
+		}
+	}
+
+	// The config file overrides its flags from the start; SIGHUP re-reads
+	// it later.
+	var configFile *config.File
+	if *configPath != "" {
+		configFile, err = config.Load(*configPath)
+		if err != nil {
+			logger.Fatal("Failed to load configuration: %v", err)
+		}
+		warnRestartSettings(*configPath, configFile)
+		if configFile.Debug != nil {
+			logger.Init(*configFile.Debug, format)
+		}
+		logger.Info("Loaded configuration from %s", *configPath)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	// wg tracks every long-running goroutine so shutdown can wait for them.
+	var wg sync.WaitGroup
+	goWithContext := func(run func(context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			run(ctx)
+		}()
+	}
+
+	goWithContext(watchdog.New(*goroutineMax, *goroutineEvery).Run)
+	goWithContext(func(ctx context.Context) {
+		if err := netutils.WatchInterfaceCache(ctx); err != nil {
+			logger.Error("Interface cache can go stale after link changes: %v", err)
+		}
+	})
+
+	switch *logOutput {
+	case logger.OutputStderr, logger.OutputStdout, logger.OutputSyslog:
+	default:
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		goWithContext(func(ctx context.Context) {
+			rotateLogOnSignal(ctx, hup)
+		})
+	}
+
+	if *snifferIPv4 && !*snifferMode {
+		logger.Fatal("--sniffer-ipv4 requires --sniffer")
+	}
+	if *pcapDumpPath != "" && !*snifferMode {
+		logger.Fatal("--pcap-dump requires --sniffer")
+	}
+
+	var sniffers *sniffer.SnifferManager
 	if *snifferMode {
-		if *listenInterface == "" {
-			logger.Fatal("You must specify --interface when using --sniffer")
+		if len(interfaces) != 1 {
+			logger.Fatal("You must specify exactly one --interface when using --sniffer")
+		}
+		sniffers = sniffer.NewSnifferManager(interfaces[0])
+		sniffers.IPv4 = *snifferIPv4
+
+		if *snifferScan <= 0 {
+			logger.Fatal("--sniffer-scan-interval must be positive, got %s", *snifferScan)
+		}
+		sniffers.ScanInterval = *snifferScan
+		sniffers.TapPatterns = tapPatterns
+
+		if err := sniffer.ValidateBPFFilter(*bpfFilter); err != nil {
+			logger.Fatal("Invalid --bpf-filter %q: %v", *bpfFilter, err)
 		}
-		go sniffer.StartSnifferManager(*listenInterface)
+		sniffers.BPFFilter = *bpfFilter
+
+		if *snifferIPv4 {
+			if err := sniffer.ValidateBPFFilter(*arpBPFFilter); err != nil {
+				logger.Fatal("Invalid --arp-bpf-filter %q: %v", *arpBPFFilter, err)
+			}
+			sniffers.ARPFilter = *arpBPFFilter
+		}
+
+		neighFlags, err := sniffer.ParseNeighFlags(*snifferFlags)
+		if err != nil {
+			logger.Fatal("Invalid --sniffer-neigh-flags: %v", err)
+		}
+		sniffers.NeighFlags = neighFlags
+
+		if *pcapDumpPath != "" {
+			dump, err := sniffer.NewPcapDump(*pcapDumpPath)
+			if err != nil {
+				logger.Fatal("Failed to create --pcap-dump file: %v", err)
+			}
+			sniffers.PcapDump = dump
+
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			goWithContext(func(ctx context.Context) {
+				rotateOnSignal(ctx, hup, dump)
+			})
+		}
+		goWithContext(sniffers.Run)
+	}
+
+	if *routeMetric > math.MaxUint32 {
+		logger.Fatal("--route-metric must be between 0 and %d, got %d", uint32(math.MaxUint32), *routeMetric)
+	}
+
+	if *routeProto < 0 || *routeProto > math.MaxUint8 {
+		logger.Fatal("--route-proto must be between 0 and %d, got %d", math.MaxUint8, *routeProto)
+	}
+
+	scope, err := netutils.ParseScope(*routeScope)
+	if err != nil {
+		logger.Fatal("Invalid --route-scope: %v", err)
+	}
+
+	if *ipv4PrefixLen < 1 || *ipv4PrefixLen > 32 {
+		logger.Fatal("--ipv4-prefix-len must be between 1 and 32, got %d", *ipv4PrefixLen)
+	}
+	if *ipv6PrefixLen < 1 || *ipv6PrefixLen > 128 {
+		logger.Fatal("--ipv6-prefix-len must be between 1 and 128, got %d", *ipv6PrefixLen)
 	}
 
-	nm, err := neighbor.NewNeighborManager(*listenInterface)
+	evict, err := neighbor.ParseEvictPolicy(*evictPolicy)
+	if err != nil {
+		logger.Fatal("Invalid --evict-policy: %v", err)
+	}
+	stateMask, err := neighbor.ParseNeighborStates(*neighborStates)
+	if err != nil {
+		logger.Fatal("Invalid --neighbor-states: %v", err)
+	}
+	if !*noBuiltinExcl {
+		excludePrefixes = append(neighbor.BuiltinExcludePrefixes(), excludePrefixes...)
+	}
+	if *ecmp && *migrationGrace > 0 {
+		logger.Fatal("--ecmp and --migration-grace-ms cannot be used together")
+	}
+	if *pingTimeout <= 0 {
+		logger.Fatal("--ping-timeout must be positive, got %s", *pingTimeout)
+	}
+	if *initWorkers < 1 {
+		logger.Fatal("--init-workers must be at least 1, got %d", *initWorkers)
+	}
+	if *maxSnapshots < 1 {
+		logger.Fatal("--max-snapshots must be at least 1, got %d", *maxSnapshots)
+	}
+	if *snapshotTTL <= 0 {
+		logger.Fatal("--snapshot-ttl must be positive, got %s", *snapshotTTL)
+	}
+	if *maxNeighbors < 0 {
+		logger.Fatal("--max-neighbors must not be negative, got %d", *maxNeighbors)
+	}
+
+	metric, interval, allowPrefixes := uint32(*routeMetric), *pingInterval, prefixes
+	if configFile != nil {
+		if configFile.RouteMetric != nil {
+			metric = *configFile.RouteMetric
+		}
+		if configFile.PingInterval != nil {
+			interval = *configFile.PingInterval
+		}
+		// config.Load has validated the prefixes.
+		if allowed, _ := configFile.ParsePrefixes(); allowed != nil {
+			allowPrefixes = allowed
+		}
+	}
+
+	// Only the sniffer's own ext_learned entries are routed, not those of
+	// EVPN or another control plane.
+	var extLearnedOwner neighbor.ExtLearnedOwner
+	if sniffers != nil && sniffers.ExtLearned() {
+		extLearnedOwner = sniffers
+	}
+
+	var auditLog *neighbor.AuditLog
+	if *auditLogPath != "" {
+		auditLog, err = neighbor.OpenAuditLog(*auditLogPath)
+		if err != nil {
+			logger.Fatal("Failed to open audit log: %v", err)
+		}
+	}
+
+	var routeNotifier *netutils.RouteNotifier
+	if *netlinkNotify {
+		routeNotifier, err = netutils.NewRouteNotifier()
+		if err != nil {
+			logger.Fatal("Failed to open netlink socket for --netlink-notify: %v", err)
+		}
+	}
+
+	nm, err := neighbor.NewNeighborManagerFromConfig(neighbor.Config{
+		Context:           ctx,
+		TargetInterfaces:  interfaces,
+		RouteRetries:      *routeRetries,
+		RouteRetryBackoff: *routeBackoff,
+		RouteMetric:       metric,
+		RouteTable:        *routeTable,
+		RouteProtocol:     *routeProto,
+		RouteScope:        &scope,
+		IPv4PrefixLen:     *ipv4PrefixLen,
+		IPv6PrefixLen:     *ipv6PrefixLen,
+		IPv6Gateway:       *ipv6Gateway,
+		InitWorkers:       *initWorkers,
+		MaxPauseBuffer:    *maxPauseBuffer,
+		CleanupOnStart:    *cleanupOnStart,
+		DryRun:            *dryRun,
+		VLANAware:         *vlanAware,
+		FailedHold:        time.Duration(*failedHold) * time.Second,
+		MigrationGrace:    time.Duration(*migrationGrace) * time.Millisecond,
+		ECMP:              *ecmp,
+		MaxNeighbors:      *maxNeighbors,
+		EvictPolicy:       evict,
+		AddRateLimit:      *addRateLimit,
+		AddBurst:          *addBurst,
+		PingInterval:      interval,
+		PingTimeout:       *pingTimeout,
+		PingBackoff:       *pingBackoff,
+		UseNS:             *useNS,
+		ARPTable:          *arpTable,
+		StateFile:         *stateFile,
+		EventHistorySize:  *eventHistory,
+		EventBusCapacity:  *eventBusCap,
+		AuditLog:          auditLog,
+		RouteNotifier:     routeNotifier,
+		ExtLearnedOwner:   extLearnedOwner,
+		Policy:            neighbor.NeighborPolicy{AllowPrefixes: allowPrefixes, ExcludePrefixes: excludePrefixes, StateMask: stateMask},
+	})
 	if err != nil {
 		logger.Fatal("Failed to initialize neighbor manager: %v", err)
 	}
 
+	if *seedFile != "" {
+		seeds, err := neighbor.LoadSeeds(*seedFile)
+		if err != nil {
+			logger.Fatal("Invalid --seed-file: %v", err)
+		}
+		nm.ApplySeeds(seeds)
+		_, errs := neighbor.SeedKernel(seeds)
+		for _, err := range errs {
+			logger.Error("Failed to seed neighbor: %v", err)
+		}
+	}
+
+	if err := nm.RestoreState(); err != nil {
+		logger.Error("Failed to restore neighbor state from %s: %v", *stateFile, err)
+	}
+
 	if err := nm.InitializeNeighborTable(); err != nil {
 		logger.Error("Failed to initialize neighbor table: %v", err)
 	}
 
-	api := &api.API{NM: nm}
-	http.HandleFunc("/neighbors", api.ListNeighborsHandler)
-	http.HandleFunc("/sniffed-interfaces", api.ListSniffedInterfacesHandler)
+	if *configPath != "" {
+		reload := func() error {
+			return reloadConfig(*configPath, nm, format)
+		}
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		goWithContext(func(ctx context.Context) {
+			reloadOnSignal(ctx, hup, reload)
+		})
+	}
+
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	goWithContext(func(ctx context.Context) {
+		dumpOnSignal(ctx, usr1, *dumpFile, nm)
+	})
+
+	srv := &api.API{
+		NM:              nm,
+		Sniffers:        sniffers,
+		LogRequestBody:  *logRequestBody,
+		MaxRequestSize:  *maxRequestSize,
+		HealthStaleness: *healthStaleness,
+		Token:           *apiToken,
+		Snapshots:       api.NewSnapshotStore(*maxSnapshots, *snapshotTTL),
+	}
+	if *ifaceAliases != "" {
+		aliases, err := api.LoadInterfaceAliases(*ifaceAliases)
+		if err != nil {
+			logger.Fatal("Failed to load interface aliases: %v", err)
+		}
+		srv.Aliases = aliases
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		goWithContext(func(ctx context.Context) {
+			reloadOnSignal(ctx, hup, aliases.Reload)
+		})
+	}
+	// A mux of our own, since importing expvar registers /debug/vars on
+	// http.DefaultServeMux.
+	mux := http.NewServeMux()
+	srv.Server = &http.Server{Addr: *apiAddress, Handler: srv.Handler(mux), ConnState: srv.TrackConnState}
+	mux.Handle("/health", api.NewRateLimitedHandler(srv.HealthHandler, 50, 100))
+	mux.Handle("/stats", api.NewRateLimitedHandler(srv.StatsHandler, 20, 40))
+	mux.Handle("/neighbors", api.NewRateLimitedHandler(srv.ListNeighborsHandler, 50, 100))
+	mux.Handle("/neighbors/{ip}", api.NewRateLimitedHandler(srv.NeighborHandler, 20, 40).WithMethodLimit(http.MethodDelete, 5, 10))
+	mux.Handle("/neighbors/batch-delete", api.NewRateLimitedHandler(srv.BatchDeleteNeighborsHandler, 5, 10))
+	mux.Handle("/neighbors/watch", api.NewRateLimitedHandler(srv.WatchNeighborsHandler, 5, 10))
+	mux.Handle("/neighbors/snapshot", api.NewRateLimitedHandler(srv.NeighborSnapshotHandler, 5, 10))
+	mux.Handle("/neighbors/diff", api.NewRateLimitedHandler(srv.NeighborDiffHandler, 20, 40))
+	mux.Handle("/events", api.NewRateLimitedHandler(srv.EventsHandler, 20, 40))
+	mux.Handle("/neighbors/{ip}/history", api.NewRateLimitedHandler(srv.NeighborHistoryHandler, 20, 40))
+	mux.Handle("/neighbors/{ip}/traceroute", api.NewRateLimitedHandler(srv.NeighborTracerouteHandler, 1, 2))
+	mux.Handle("/neighbors/{ip}/ping", api.NewRateLimitedHandler(srv.NeighborPingHandler, 5, 10))
+	mux.Handle("/self-test", api.NewRateLimitedHandler(srv.SelfTestHandler, 1, 1))
+	mux.Handle("/sniffed-interfaces", api.NewRateLimitedHandler(srv.ListSniffedInterfacesHandler, 50, 100))
+	mux.Handle("/sniffed-interfaces/reload", api.NewRateLimitedHandler(srv.ReloadSniffersHandler, 1, 2))
+	mux.Handle("/sniffed-interfaces/{iface}/pause", api.NewRateLimitedHandler(srv.PauseSnifferHandler, 5, 10))
+	mux.Handle("/sniffed-interfaces/{iface}/resume", api.NewRateLimitedHandler(srv.ResumeSnifferHandler, 5, 10))
+	if *metricsEnabled {
+		mux.Handle("/metrics", srv.MetricsHandler())
+	}
+	if *expvarEnabled {
+		srv.PublishExpvars()
+		mux.Handle("/debug/vars", expvar.Handler())
+	}
+
+	useTLS := *tlsCert != "" || *tlsKey != ""
+	if useTLS {
+		if *tlsCert == "" || *tlsKey == "" {
+			logger.Fatal("--tls-cert and --tls-key must be set together")
+		}
+
+		tlsConfig, err := api.NewTLSConfig(*tlsClientCA)
+		if err != nil {
+			logger.Fatal("Failed to configure TLS: %v", err)
+		}
+		srv.Server.TLSConfig = tlsConfig
+	} else if *tlsClientCA != "" {
+		logger.Fatal("--tls-client-ca requires --tls-cert and --tls-key")
+	}
 
 	go func() {
-		logger.Info("API server listening on %s", *apiAddress)
-		if err := http.ListenAndServe(*apiAddress, nil); err != nil {
+		var err error
+		if useTLS {
+			logger.Info("API server listening on %s (TLS)", *apiAddress)
+			err = srv.Server.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			logger.Info("API server listening on %s", *apiAddress)
+			err = srv.Server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Error("HTTP server failed: %v", err)
 		}
 	}()
 
+	goWithContext(func(ctx context.Context) {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), *httpTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Failed to shut down API server: %v", err)
+		}
+	})
+	if *grpcAddress != "" {
+		grpcServer := grpc.New(*grpcAddress, nm)
+		grpcServer.ShutdownTimeout = *httpTimeout
+		grpcServer.Token = *apiToken
+		if useTLS {
+			cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+			if err != nil {
+				logger.Fatal("Failed to load --tls-cert and --tls-key for the gRPC server: %v", err)
+			}
+			grpcServer.TLSConfig = srv.Server.TLSConfig.Clone()
+			grpcServer.TLSConfig.Certificates = []tls.Certificate{cert}
+		}
+		goWithContext(func(ctx context.Context) {
+			if err := grpcServer.Run(ctx); err != nil {
+				logger.Error("gRPC server failed: %v", err)
+			}
+		})
+	}
+	goWithContext(func(ctx context.Context) {
+		nm.PersistRoutes(ctx, *stateInterval)
+	})
+	goWithContext(func(ctx context.Context) {
+		reportStats(ctx, *statsInterval, nm, func() int {
+			if sniffers == nil {
+				return 0
+			}
+			return len(sniffers.ListActiveSniffers())
+		})
+	})
+	if *eventSocket != "" {
+		goWithContext(func(ctx context.Context) {
+			if err := eventsocket.New(*eventSocket, nm).Run(ctx); err != nil {
+				logger.Error("Event socket %s failed: %v", *eventSocket, err)
+			}
+		})
+	}
+	if *routeAudit {
+		goWithContext(func(ctx context.Context) {
+			nm.AuditRoutes(ctx, *routeAuditEvery)
+		})
+	}
+	goWithContext(nm.SendPings)
+	goWithContext(func(ctx context.Context) {
+		if err := nm.MonitorNeighbors(ctx); err != nil {
+			logger.Fatal("Failed to monitor neighbors: %v", err)
+		}
+	})
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
-	go func() {
-		sig := <-c
-		logger.Info("Received signal: %s. Cleaning up and exiting...", sig)
-		nm.Cleanup()
-		os.Exit(0)
-	}()
+	waitForShutdown(c, *exportPath, nm, func() {
+		stop()
+
+		if !waitTimeout(&wg, *shutdownTimeout) {
+			logger.Warn("Timed out after %s waiting for background work to stop, exiting anyway", *shutdownTimeout)
+		}
 
-	go nm.SendPings()
+		if *noCleanup {
+			nm.KeepRoutes()
+		} else {
+			nm.Cleanup()
+		}
 
-	nm.MonitorNeighbors()
+		if auditLog != nil {
+			if err := auditLog.Close(); err != nil {
+				logger.Error("Failed to close audit log: %v", err)
+			}
+		}
+		if routeNotifier != nil {
+			routeNotifier.Close()
+		}
+	})
 }
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+	"github.com/hostinger/neigh2route/internal/neighbor"
+)
+
+// mockExporter writes a fixed table and records whether it was called
+type mockExporter struct {
+	called bool
+	err    error
+}
+
+func (m *mockExporter) ExportJSON(w io.Writer) error {
+	m.called = true
+	if m.err != nil {
+		return m.err
+	}
+	_, err := io.WriteString(w, `[{"ip":"10.0.0.1","mac":"00:11:22:33:44:55","link_index":2}]`)
+	return err
+}
+
+func TestWaitForShutdownExportsBeforeCleanup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "neighbors.json")
+	exp := &mockExporter{}
+
+	cleanedUp := false
+	cleanup := func() {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected export to exist before cleanup, got %v", err)
+		}
+		cleanedUp = true
+	}
+
+	c := make(chan os.Signal, 1)
+	c <- syscall.SIGTERM
+	waitForShutdown(c, path, exp, cleanup)
+
+	if !exp.called || !cleanedUp {
+		t.Fatalf("Expected export and cleanup to run, got export=%v cleanup=%v", exp.called, cleanedUp)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read export: %v", err)
+	}
+
+	var records []map[string]interface{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatalf("Export is not valid JSON: %v", err)
+	}
+
+	if len(records) != 1 || records[0]["ip"] != "10.0.0.1" {
+		t.Errorf("Unexpected export content: %s", data)
+	}
+
+	leftovers, _ := filepath.Glob(path + ".tmp-*")
+	if len(leftovers) != 0 {
+		t.Errorf("Expected no temp files, got %v", leftovers)
+	}
+}
+
+func TestWaitForShutdownExportFailureStillCleansUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "neighbors.json")
+	exp := &mockExporter{err: errors.New("boom")}
+
+	cleanedUp := false
+	c := make(chan os.Signal, 1)
+	c <- syscall.SIGINT
+	waitForShutdown(c, path, exp, func() { cleanedUp = true })
+
+	if !cleanedUp {
+		t.Fatalf("Expected cleanup to run after failed export")
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no export file, got %v", err)
+	}
+}
+
+func TestWaitForShutdownWithoutExport(t *testing.T) {
+	exp := &mockExporter{}
+	c := make(chan os.Signal, 1)
+	c <- syscall.SIGTERM
+	waitForShutdown(c, "", exp, func() {})
+
+	if exp.called {
+		t.Errorf("Expected no export when path is empty")
+	}
+}
+
+type mockStats struct {
+	stats neighbor.Stats
+}
+
+func (m *mockStats) Stats() neighbor.Stats {
+	return m.stats
+}
+
+func TestLogStatsFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	defer logger.SetOutput(os.Stderr)
+
+	src := &mockStats{stats: neighbor.Stats{
+		TotalNeighbors: 5,
+		IPv4Neighbors:  3,
+		IPv6Neighbors:  2,
+		RoutesAdded:    7,
+		RoutesRemoved:  2,
+		PingFailures:   1,
+	}}
+
+	logStats(src, func() int { return 4 })
+
+	want := "level=INFO msg=\"event=stats total_neighbors=5 v4=3 v6=2 routes_added=7 routes_removed=2 ping_failures=1 active_sniffers=4"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Expected %q in output, got %q", want, buf.String())
+	}
+}
+
+func TestReportStatsDisabled(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		reportStats(context.Background(), 0, &mockStats{}, func() int { return 0 })
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected reportStats to return when disabled")
+	}
+}
+
+func TestPrefixListFlag(t *testing.T) {
+	var p prefixList
+
+	for _, value := range []string{"10.0.0.0/8", "2001:db8::/32"} {
+		if err := p.Set(value); err != nil {
+			t.Fatalf("Set(%q) failed: %v", value, err)
+		}
+	}
+
+	if got := p.String(); got != "10.0.0.0/8,2001:db8::/32" {
+		t.Errorf("Unexpected prefix list %q", got)
+	}
+
+	if err := p.Set("10.0.0.1"); err == nil {
+		t.Errorf("Expected error for a bare IP")
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		wg.Done()
+	}()
+
+	if !waitTimeout(&wg, time.Second) {
+		t.Errorf("Expected wait to finish before the timeout")
+	}
+
+	wg.Add(1)
+	defer wg.Done()
+
+	start := time.Now()
+	if waitTimeout(&wg, 50*time.Millisecond) {
+		t.Errorf("Expected wait to time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected timeout after ~50ms, took %s", elapsed)
+	}
+}
+
+func TestInterfaceListFlag(t *testing.T) {
+	var l interfaceList
+
+	for _, value := range []string{"br0", "br1, br2", ""} {
+		if err := l.Set(value); err != nil {
+			t.Fatalf("Set(%q) failed: %v", value, err)
+		}
+	}
+
+	if got := l.String(); got != "br0,br1,br2" {
+		t.Errorf("Unexpected interface list %q", got)
+	}
+}
+
+func TestPatternListFlag(t *testing.T) {
+	var l patternList
+
+	for _, value := range []string{`^tap\d+$`, `^vnet\d+$`} {
+		if err := l.Set(value); err != nil {
+			t.Fatalf("Set(%q) failed: %v", value, err)
+		}
+	}
+
+	if got := l.String(); got != `^tap\d+$,^vnet\d+$` {
+		t.Errorf("Unexpected pattern list %q", got)
+	}
+
+	if err := l.Set("tap("); err == nil {
+		t.Errorf("Expected error for an invalid pattern")
+	}
+}
+
+// mockReloader records the settings a config reload applies
+type mockReloader struct {
+	pingInterval time.Duration
+	routeMetric  uint32
+	policy       neighbor.NeighborPolicy
+	applied      bool
+}
+
+func (m *mockReloader) SetPingInterval(interval time.Duration) { m.pingInterval = interval }
+func (m *mockReloader) SetRouteMetric(metric uint32)           { m.routeMetric = metric }
+func (m *mockReloader) Policy() neighbor.NeighborPolicy        { return m.policy }
+
+func (m *mockReloader) ApplyPolicy(policy neighbor.NeighborPolicy) error {
+	m.policy = policy
+	m.applied = true
+	return nil
+}
+
+func TestReloadConfig(t *testing.T) {
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	defer logger.SetOutput(os.Stderr)
+	defer logger.Init(false, logger.FormatText)
+
+	path := filepath.Join(t.TempDir(), "neigh2route.yaml")
+	content := "ping_interval: 5s\nroute_metric: 300\nprefixes: [10.0.0.0/8]\nport: 0.0.0.0:1\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	r := &mockReloader{policy: neighbor.NeighborPolicy{StateMask: neighbor.DefaultStateMask}}
+	if err := reloadConfig(path, r, logger.FormatText); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if r.pingInterval != 5*time.Second || r.routeMetric != 300 {
+		t.Errorf("Expected ping interval 5s and metric 300, got %s and %d", r.pingInterval, r.routeMetric)
+	}
+
+	if !r.applied || len(r.policy.AllowPrefixes) != 1 || r.policy.StateMask != neighbor.DefaultStateMask {
+		t.Errorf("Expected the prefixes applied on top of the current policy, got %+v", r.policy)
+	}
+
+	if !strings.Contains(buf.String(), "Ignoring port change") {
+		t.Errorf("Expected a warning about the port change, got %q", buf.String())
+	}
+}
+
+func TestReloadConfigInvalidKeepsSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "neigh2route.yaml")
+	if err := os.WriteFile(path, []byte("route_metric: 10\nprefixes: [bogus]\n"), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	r := &mockReloader{}
+	if err := reloadConfig(path, r, logger.FormatText); err == nil {
+		t.Fatal("Expected an error for an invalid prefix")
+	}
+
+	if r.routeMetric != 0 || r.applied {
+		t.Errorf("Expected nothing applied from an invalid file, got %+v", r)
+	}
+}
+
+func TestReloadOnSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan os.Signal, 1)
+	reloads := make(chan struct{}, 1)
+
+	done := make(chan struct{})
+	go func() {
+		reloadOnSignal(ctx, c, func() error {
+			reloads <- struct{}{}
+			return nil
+		})
+		close(done)
+	}()
+
+	c <- syscall.SIGHUP
+	select {
+	case <-reloads:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a reload after SIGHUP")
+	}
+
+	cancel()
+	<-done
+}
+
+// mockDumper writes a fixed table
+type mockDumper struct{}
+
+func (mockDumper) DumpJSON(w io.Writer) error {
+	_, err := io.WriteString(w, `[{"ip":"10.0.0.1","first_seen":"2024-01-01T00:00:00Z"}]`)
+	return err
+}
+
+func TestDumpOnSignal(t *testing.T) {
+	var stderr bytes.Buffer
+	orig := dumpOutput
+	dumpOutput = &stderr
+	t.Cleanup(func() { dumpOutput = orig })
+
+	path := filepath.Join(t.TempDir(), "dump.json")
+	for _, target := range []string{"", path} {
+		ctx, cancel := context.WithCancel(context.Background())
+		c := make(chan os.Signal)
+		done := make(chan struct{})
+		go func() {
+			dumpOnSignal(ctx, c, target, mockDumper{})
+			close(done)
+		}()
+
+		// The unbuffered send returns once the dump loop has taken the
+		// signal; the second one once the first dump is written.
+		c <- syscall.SIGUSR1
+		c <- syscall.SIGUSR1
+		cancel()
+		<-done
+	}
+
+	if !strings.Contains(stderr.String(), `"ip":"10.0.0.1"`) {
+		t.Errorf("Expected the table on stderr without --dump-file, got %q", stderr.String())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read dump: %v", err)
+	}
+	if !strings.Contains(string(data), `"first_seen"`) {
+		t.Errorf("Expected the table in %s, got %q", path, data)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+)
+
+// InterfaceAliases maps interface names to the display names shown by
+// /sniffers, read from a JSON object such as {"tap12345": "vm-web-01"}.
+type InterfaceAliases struct {
+	path    string
+	aliases atomic.Pointer[map[string]string]
+}
+
+// LoadInterfaceAliases reads the alias file at path.
+func LoadInterfaceAliases(path string) (*InterfaceAliases, error) {
+	a := &InterfaceAliases{path: path}
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Reload re-reads the alias file. The previous aliases are kept if it
+// cannot be read.
+func (a *InterfaceAliases) Reload() error {
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return err
+	}
+
+	var aliases map[string]string
+	if err := json.Unmarshal(data, &aliases); err != nil {
+		return fmt.Errorf("%s: %w", a.path, err)
+	}
+
+	a.aliases.Store(&aliases)
+	logger.Info("Loaded %d interface aliases from %s", len(aliases), a.path)
+	return nil
+}
+
+// Lookup returns the alias of iface, or "" if it has none.
+func (a *InterfaceAliases) Lookup(iface string) string {
+	if a == nil {
+		return ""
+	}
+	if aliases := a.aliases.Load(); aliases != nil {
+		return (*aliases)[iface]
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterfaceAliases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.json")
+	if err := os.WriteFile(path, []byte(`{"tap12345": "vm-web-01"}`), 0o644); err != nil {
+		t.Fatalf("failed to write aliases: %v", err)
+	}
+
+	aliases, err := LoadInterfaceAliases(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := aliases.Lookup("tap12345"); got != "vm-web-01" {
+		t.Errorf("Expected alias vm-web-01, got %q", got)
+	}
+	if got := aliases.Lookup("tap99999"); got != "" {
+		t.Errorf("Expected no alias, got %q", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"tap99999": "vm-db-01"}`), 0o644); err != nil {
+		t.Fatalf("failed to write aliases: %v", err)
+	}
+	if err := aliases.Reload(); err != nil {
+		t.Fatalf("Expected no error on reload, got %v", err)
+	}
+	if aliases.Lookup("tap12345") != "" || aliases.Lookup("tap99999") != "vm-db-01" {
+		t.Errorf("Expected the reloaded aliases to replace the old ones")
+	}
+
+	if err := os.WriteFile(path, []byte(`["not", "an", "object"]`), 0o644); err != nil {
+		t.Fatalf("failed to write aliases: %v", err)
+	}
+	if err := aliases.Reload(); err == nil {
+		t.Errorf("Expected an error for a malformed alias file")
+	}
+	if aliases.Lookup("tap99999") != "vm-db-01" {
+		t.Errorf("Expected a failed reload to keep the previous aliases")
+	}
+}
+
+func TestInterfaceAliasesNil(t *testing.T) {
+	var aliases *InterfaceAliases
+	if got := aliases.Lookup("tap12345"); got != "" {
+		t.Errorf("Expected no alias without an alias file, got %q", got)
+	}
+}
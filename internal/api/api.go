@@ -1,18 +1,131 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/hostinger/neigh2route/internal/logger"
 	"github.com/hostinger/neigh2route/internal/neighbor"
 	"github.com/hostinger/neigh2route/internal/sniffer"
+	"github.com/hostinger/neigh2route/pkg/netutils"
+)
+
+const (
+	defaultTracerouteHops = 30
+	maxTracerouteHops     = 64
+	selfTestTimeout       = 10 * time.Second
+)
+
+const DefaultHealthStaleness = 5 * time.Minute
+
+var (
+	traceroute       = netutils.TracerouteHops
+	ping             = netutils.PingOnce
+	interfaceByIndex = netutils.InterfaceByIndex
+	testConnectivity = (*neighbor.NeighborManager).TestConnectivity
+	startTime        = time.Now()
 )
 
 type API struct {
-	NM *neighbor.NeighborManager
+	NM             *neighbor.NeighborManager
+	Server         *http.Server
+	Sniffers       *sniffer.SnifferManager
+	LogRequestBody bool
+	MaxRequestSize int64
+
+	// Token, when set, is required as "Authorization: Bearer <token>" on
+	// every request.
+	Token string
+
+	// HealthStaleness is how long /health tolerates no neighbor updates
+	// before reporting unhealthy. Zero uses DefaultHealthStaleness.
+	HealthStaleness time.Duration
+
+	// Aliases, when set, supplies the display names of sniffed interfaces.
+	Aliases *InterfaceAliases
+
+	// Snapshots holds the neighbor table snapshots of /neighbors/snapshot.
+	// A nil store is replaced by one with the default limits on first use.
+	Snapshots    *SnapshotStore
+	snapshotOnce sync.Once
+
+	// watchCtx is cancelled by Shutdown to end the /neighbors/watch
+	// streams, which would otherwise keep Server.Shutdown waiting.
+	watchOnce sync.Once
+	watchCtx  context.Context
+	stopWatch context.CancelFunc
+
+	// connStates holds the state of every open connection reported to
+	// TrackConnState.
+	connMu     sync.Mutex
+	connStates map[net.Conn]http.ConnState
+}
+
+// TrackConnState is meant as the Server's ConnState hook, so Shutdown can
+// report how many connections it drained.
+func (a *API) TrackConnState(c net.Conn, state http.ConnState) {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+
+	if a.connStates == nil {
+		a.connStates = make(map[net.Conn]http.ConnState)
+	}
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(a.connStates, c)
+	default:
+		a.connStates[c] = state
+	}
+}
+
+// activeConns returns how many tracked connections are serving a request.
+func (a *API) activeConns() int {
+	a.connMu.Lock()
+	defer a.connMu.Unlock()
+
+	active := 0
+	for _, state := range a.connStates {
+		if state == http.StateActive {
+			active++
+		}
+	}
+	return active
+}
+
+func (a *API) watchContext() context.Context {
+	a.watchOnce.Do(func() {
+		a.watchCtx, a.stopWatch = context.WithCancel(context.Background())
+	})
+	return a.watchCtx
+}
+
+// Shutdown stops accepting new connections and waits for in-flight requests
+// to finish, or for ctx to expire, whichever comes first.
+func (a *API) Shutdown(ctx context.Context) error {
+	if a.Server == nil {
+		return nil
+	}
+
+	logger.Info("Shutting down API server")
+	a.watchContext()
+	a.stopWatch()
+
+	active := a.activeConns()
+	if err := a.Server.Shutdown(ctx); err != nil {
+		logger.Warn("API server shutdown interrupted with %d of %d connections still active", a.activeConns(), active)
+		return err
+	}
+	logger.Info("API server drained %d active connections", active)
+	return nil
 }
 
 type ErrorResponse struct {
@@ -42,44 +155,138 @@ func writeJSONResponse(w http.ResponseWriter, data interface{}) {
 	}
 }
 
+type NeighborView struct {
+	IP           string    `json:"ip"`
+	LinkIndex    int       `json:"link_index"`
+	Interface    string    `json:"interface"`
+	HardwareAddr string    `json:"hwAddr"`
+	Afi          string    `json:"afi"`
+	Permanent    bool      `json:"permanent,omitempty"`
+	PingTimeout  int64     `json:"ping_timeout_ms,omitempty"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastUpdated  time.Time `json:"last_updated"`
+}
+
+func newNeighborView(n neighbor.Neighbor) NeighborView {
+	afi := "v4"
+	if n.IP.To4() == nil {
+		afi = "v6"
+	}
+
+	// An interface that is gone leaves the name empty.
+	var ifaceName string
+	if iface, err := interfaceByIndex(n.LinkIndex); err == nil {
+		ifaceName = iface.Name
+	}
+
+	return NeighborView{
+		IP:           n.IP.String(),
+		LinkIndex:    n.LinkIndex,
+		Interface:    ifaceName,
+		HardwareAddr: n.HardwareAddr.String(),
+		Afi:          afi,
+		Permanent:    n.Permanent,
+		PingTimeout:  n.PingTimeout.Milliseconds(),
+		FirstSeen:    n.FirstSeen,
+		LastUpdated:  n.LastUpdated,
+	}
+}
+
+// neighborOrders are the orderings accepted by the sort parameter of
+// /neighbors. Ties fall back to the IP order.
+var neighborOrders = map[string]func(a, b NeighborView) bool{
+	"ip": lessIP,
+	"age": func(a, b NeighborView) bool {
+		return a.FirstSeen.Before(b.FirstSeen)
+	},
+	"interface": func(a, b NeighborView) bool {
+		return a.Interface < b.Interface
+	},
+}
+
+// lessIP orders IPv4 addresses before IPv6 ones and each family
+// numerically.
+func lessIP(a, b NeighborView) bool {
+	ipA, ipB := net.ParseIP(a.IP), net.ParseIP(b.IP)
+	if v4A, v4B := ipA.To4() != nil, ipB.To4() != nil; v4A != v4B {
+		return v4A
+	}
+	return bytes.Compare(ipA.To16(), ipB.To16()) < 0
+}
+
 func (a *API) ListNeighborsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only GET method is allowed")
 		return
 	}
 
-	type NeighborView struct {
-		IP           string `json:"ip"`
-		LinkIndex    int    `json:"link_index"`
-		HardwareAddr string `json:"hwAddr"`
-		Afi          string `json:"afi"`
-	}
-
 	type NeighborsResponse struct {
 		Neighbors []NeighborView `json:"neighbors"`
 		Count     int            `json:"count"`
 		Timestamp time.Time      `json:"timestamp"`
 	}
 
-	neighbors := a.NM.ListNeighbors()
+	var neighbors []neighbor.Neighbor
+	if raw := r.URL.Query().Get("link_index"); raw != "" {
+		linkIndex, err := strconv.Atoi(raw)
+		if err != nil || linkIndex <= 0 {
+			writeErrorResponse(w, http.StatusBadRequest, "invalid_link_index", "link_index must be a positive integer")
+			return
+		}
+		neighbors = a.NM.ListByLinkIndex(linkIndex)
+	} else {
+		for _, n := range a.NM.ListNeighbors() {
+			neighbors = append(neighbors, n)
+		}
+	}
+
+	var permanentOnly bool
+	if raw := r.URL.Query().Get("permanent"); raw != "" {
+		var err error
+		if permanentOnly, err = strconv.ParseBool(raw); err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, "invalid_permanent", "permanent must be true or false")
+			return
+		}
+	}
+
+	afi := r.URL.Query().Get("afi")
+	if afi != "" && afi != "v4" && afi != "v6" {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid_afi", "afi must be v4 or v6")
+		return
+	}
+
+	order := r.URL.Query().Get("sort")
+	if order == "" {
+		order = "ip"
+	}
+	less, ok := neighborOrders[order]
+	if !ok {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid_sort", "sort must be ip, age or interface")
+		return
+	}
+
 	var output []NeighborView
 
 	for _, n := range neighbors {
-		afi := "v4"
-		if n.IP.To4() == nil {
-			afi = "v6"
+		if permanentOnly && !n.Permanent {
+			continue
 		}
 
-		output = append(output, NeighborView{
-			IP:           n.IP.String(),
-			LinkIndex:    n.LinkIndex,
-			HardwareAddr: n.HardwareAddr.String(),
-			Afi:          afi,
-		})
+		view := newNeighborView(n)
+		if afi != "" && view.Afi != afi {
+			continue
+		}
+		output = append(output, view)
 	}
 
 	sort.Slice(output, func(i, j int) bool {
-		return output[i].IP < output[j].IP
+		if less(output[i], output[j]) {
+			return true
+		}
+		if less(output[j], output[i]) {
+			return false
+		}
+		return lessIP(output[i], output[j])
 	})
 
 	response := NeighborsResponse{
@@ -91,16 +298,504 @@ func (a *API) ListNeighborsHandler(w http.ResponseWriter, r *http.Request) {
 	writeJSONResponse(w, response)
 }
 
+// StatsHandler serves GET /stats with the daemon's counters since start.
+func (a *API) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only GET method is allowed")
+		return
+	}
+
+	type StatsResponse struct {
+		UptimeSeconds          float64 `json:"uptime_seconds"`
+		NeighborsTotal         int     `json:"neighbors_total"`
+		RoutesAddedTotal       uint64  `json:"routes_added_total"`
+		RoutesRemovedTotal     uint64  `json:"routes_removed_total"`
+		PingFailuresTotal      uint64  `json:"ping_failures_total"`
+		NetlinkReconnectsTotal uint64  `json:"netlink_reconnects_total"`
+		ActiveSniffers         int     `json:"active_sniffers"`
+		PacketsSniffedTotal    uint64  `json:"packets_sniffed_total"`
+	}
+
+	stats := a.NM.Stats()
+	response := StatsResponse{
+		UptimeSeconds:          time.Since(startTime).Seconds(),
+		NeighborsTotal:         stats.TotalNeighbors,
+		RoutesAddedTotal:       stats.RoutesAdded,
+		RoutesRemovedTotal:     stats.RoutesRemoved,
+		PingFailuresTotal:      stats.PingFailures,
+		NetlinkReconnectsTotal: stats.NetlinkReconnects,
+	}
+
+	if a.Sniffers != nil {
+		response.ActiveSniffers = len(a.Sniffers.ListActiveSniffers())
+		response.PacketsSniffedTotal = a.Sniffers.PacketsReceivedTotal()
+	}
+
+	writeJSONResponse(w, response)
+}
+
+func (a *API) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only GET method is allowed")
+		return
+	}
+
+	type HealthResponse struct {
+		Status        string  `json:"status"`
+		Neighbors     int     `json:"neighbors"`
+		UptimeSeconds float64 `json:"uptime_seconds"`
+	}
+
+	staleness := a.HealthStaleness
+	if staleness <= 0 {
+		staleness = DefaultHealthStaleness
+	}
+
+	response := HealthResponse{
+		Status:        "ok",
+		Neighbors:     len(a.NM.ListNeighbors()),
+		UptimeSeconds: time.Since(startTime).Seconds(),
+	}
+
+	if time.Since(a.NM.LastUpdate()) > staleness {
+		response.Status = "stale"
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			logger.Error("Failed to encode JSON response: %v", err)
+		}
+		return
+	}
+
+	writeJSONResponse(w, response)
+}
+
+// NeighborHandler serves a single neighbor at /neighbors/{ip}.
+func (a *API) NeighborHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete && r.Method != http.MethodPatch {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only GET, PATCH and DELETE methods are allowed")
+		return
+	}
+
+	ip := net.ParseIP(r.PathValue("ip"))
+	if ip == nil {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid_ip", "Path parameter must be a valid IP address")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		a.deleteNeighbor(w, ip)
+		return
+	}
+	if r.Method == http.MethodPatch {
+		a.patchNeighbor(w, r, ip)
+		return
+	}
+
+	n, ok := a.NM.GetNeighbor(ip)
+	if !ok {
+		writeErrorResponse(w, http.StatusNotFound, "not_found", "Neighbor "+ip.String()+" not found")
+		return
+	}
+
+	writeJSONResponse(w, newNeighborView(n))
+}
+
+func (a *API) deleteNeighbor(w http.ResponseWriter, ip net.IP) {
+	if _, ok := a.NM.GetNeighbor(ip); !ok {
+		writeErrorResponse(w, http.StatusNotFound, "not_found", "Neighbor "+ip.String()+" not found")
+		return
+	}
+
+	a.NM.RemoveNeighborAllLinks(ip)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// patchNeighbor applies the per-neighbor settings in the request body. A
+// ping_timeout_ms of 0 drops the override.
+func (a *API) patchNeighbor(w http.ResponseWriter, r *http.Request, ip net.IP) {
+	var request struct {
+		PingTimeoutMs *int `json:"ping_timeout_ms"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, a.maxRequestSize())).Decode(&request); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid_body", "Request body must be JSON like {\"ping_timeout_ms\":500}")
+		return
+	}
+	if request.PingTimeoutMs == nil || *request.PingTimeoutMs < 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid_body", "ping_timeout_ms must be a non-negative integer")
+		return
+	}
+
+	if _, ok := a.NM.GetNeighbor(ip); !ok {
+		writeErrorResponse(w, http.StatusNotFound, "not_found", "Neighbor "+ip.String()+" not found")
+		return
+	}
+
+	a.NM.SetPingTimeout(ip, time.Duration(*request.PingTimeoutMs)*time.Millisecond)
+
+	n, _ := a.NM.GetNeighbor(ip)
+	writeJSONResponse(w, newNeighborView(n))
+}
+
+func (a *API) BatchDeleteNeighborsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only POST method is allowed")
+		return
+	}
+
+	type BatchDeleteRequest struct {
+		IPs []string `json:"ips"`
+	}
+
+	type BatchDeleteResponse struct {
+		Removed   int       `json:"removed"`
+		Errors    []string  `json:"errors"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+
+	var request BatchDeleteRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, a.maxRequestSize())).Decode(&request); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid_body", "Request body must be JSON like {\"ips\":[\"10.0.0.1\"]}")
+		return
+	}
+
+	if len(request.IPs) == 0 {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid_body", "ips must not be empty")
+		return
+	}
+
+	errorsOut := []string{}
+	ips := make([]net.IP, 0, len(request.IPs))
+	for _, raw := range request.IPs {
+		ip := net.ParseIP(raw)
+		if ip == nil {
+			errorsOut = append(errorsOut, "invalid IP address: "+raw)
+			continue
+		}
+		ips = append(ips, ip)
+	}
+
+	removed, errs := a.NM.BatchRemoveNeighbors(ips)
+	for _, err := range errs {
+		errorsOut = append(errorsOut, err.Error())
+	}
+
+	response := BatchDeleteResponse{
+		Removed:   removed,
+		Errors:    errorsOut,
+		Timestamp: time.Now(),
+	}
+
+	writeJSONResponse(w, response)
+}
+
+func (a *API) SelfTestHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only POST method is allowed")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), selfTestTimeout)
+	defer cancel()
+
+	if err := testConnectivity(a.NM, ctx); err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, "self_test_failed", err.Error())
+		return
+	}
+
+	type SelfTestResponse struct {
+		Success   bool      `json:"success"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+
+	writeJSONResponse(w, SelfTestResponse{Success: true, Timestamp: time.Now()})
+}
+
+type EventView struct {
+	Type         string    `json:"type"`
+	IP           string    `json:"ip"`
+	LinkIndex    int       `json:"link_index"`
+	HardwareAddr string    `json:"hwAddr"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+func newEventView(e neighbor.Event) EventView {
+	return EventView{
+		Type:         string(e.Type),
+		IP:           e.Neighbor.IP.String(),
+		LinkIndex:    e.Neighbor.LinkIndex,
+		HardwareAddr: e.Neighbor.HardwareAddr.String(),
+		Timestamp:    e.Timestamp,
+	}
+}
+
+// EventsHandler returns the most recent neighbor events across every
+// neighbor, newest first, optionally filtered by ?ip=.
+func (a *API) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only GET method is allowed")
+		return
+	}
+
+	type EventsResponse struct {
+		Events    []EventView `json:"events"`
+		Count     int         `json:"count"`
+		Timestamp time.Time   `json:"timestamp"`
+	}
+
+	var ip string
+	if v := r.URL.Query().Get("ip"); v != "" {
+		parsed := net.ParseIP(v)
+		if parsed == nil {
+			writeErrorResponse(w, http.StatusBadRequest, "invalid_ip", "ip must be a valid IP address")
+			return
+		}
+		ip = parsed.String()
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeErrorResponse(w, http.StatusBadRequest, "invalid_limit", "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	events := a.NM.Events(ip, limit)
+	output := make([]EventView, 0, len(events))
+	for _, e := range events {
+		output = append(output, newEventView(e))
+	}
+
+	writeJSONResponse(w, EventsResponse{
+		Events:    output,
+		Count:     len(output),
+		Timestamp: time.Now(),
+	})
+}
+
+// WatchNeighborsHandler streams neighbor add and remove events as
+// Server-Sent Events until the client disconnects.
+func (a *API) WatchNeighborsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only GET method is allowed")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeErrorResponse(w, http.StatusInternalServerError, "streaming_unsupported", "Streaming is not supported")
+		return
+	}
+
+	events, unsubscribe := a.NM.WatchNeighbors()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	stop := a.watchContext()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stop.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(newEventView(e))
+			if err != nil {
+				logger.Error("Failed to encode neighbor event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (a *API) NeighborHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only GET method is allowed")
+		return
+	}
+
+	type HistoryResponse struct {
+		IP        string      `json:"ip"`
+		Events    []EventView `json:"events"`
+		Count     int         `json:"count"`
+		Timestamp time.Time   `json:"timestamp"`
+	}
+
+	ip := net.ParseIP(r.PathValue("ip"))
+	if ip == nil {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid_ip", "Path parameter must be a valid IP address")
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeErrorResponse(w, http.StatusBadRequest, "invalid_limit", "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	events := a.NM.History(ip.String(), limit)
+	if len(events) == 0 {
+		writeErrorResponse(w, http.StatusNotFound, "not_found", "No history for "+ip.String())
+		return
+	}
+
+	output := make([]EventView, 0, len(events))
+	for _, e := range events {
+		output = append(output, newEventView(e))
+	}
+
+	response := HistoryResponse{
+		IP:        ip.String(),
+		Events:    output,
+		Count:     len(output),
+		Timestamp: time.Now(),
+	}
+
+	writeJSONResponse(w, response)
+}
+
+func (a *API) NeighborTracerouteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only GET method is allowed")
+		return
+	}
+
+	type HopView struct {
+		TTL       int     `json:"ttl"`
+		IP        string  `json:"ip"`
+		LatencyMs float64 `json:"latency_ms"`
+	}
+
+	type TracerouteResponse struct {
+		Hops []HopView `json:"hops"`
+	}
+
+	ip := net.ParseIP(r.PathValue("ip"))
+	if ip == nil {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid_ip", "Path parameter must be a valid IP address")
+		return
+	}
+
+	maxHops := defaultTracerouteHops
+	if v := r.URL.Query().Get("max_hops"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTracerouteHops {
+			writeErrorResponse(w, http.StatusBadRequest, "invalid_max_hops", "max_hops must be between 1 and 64")
+			return
+		}
+		maxHops = n
+	}
+
+	if _, ok := a.NM.GetNeighbor(ip); !ok {
+		writeErrorResponse(w, http.StatusNotFound, "not_found", "Neighbor "+ip.String()+" not found")
+		return
+	}
+
+	hops, err := traceroute(ip, maxHops)
+	if err != nil {
+		logger.Error("Traceroute to %s failed: %v", ip.String(), err)
+		writeErrorResponse(w, http.StatusInternalServerError, "traceroute_failed", err.Error())
+		return
+	}
+
+	output := make([]HopView, 0, len(hops))
+	for _, hop := range hops {
+		view := HopView{TTL: hop.TTL}
+		if hop.IP != nil {
+			view.IP = hop.IP.String()
+			view.LatencyMs = float64(hop.Latency.Microseconds()) / 1000
+		}
+		output = append(output, view)
+	}
+
+	writeJSONResponse(w, TracerouteResponse{Hops: output})
+}
+
+// NeighborPingHandler pings a known neighbor at /neighbors/{ip}/ping and
+// reports whether it replied within its ping timeout.
+func (a *API) NeighborPingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only POST method is allowed")
+		return
+	}
+
+	type PingResponse struct {
+		Reachable bool    `json:"reachable"`
+		LatencyMs float64 `json:"latency_ms"`
+	}
+
+	ip := net.ParseIP(r.PathValue("ip"))
+	if ip == nil {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid_ip", "Path parameter must be a valid IP address")
+		return
+	}
+
+	n, ok := a.NM.GetNeighbor(ip)
+	if !ok {
+		writeErrorResponse(w, http.StatusNotFound, "not_found", "Neighbor "+ip.String()+" not found")
+		return
+	}
+
+	timeout := n.PingTimeout
+	if timeout <= 0 {
+		timeout = a.NM.PingTimeout
+	}
+	if timeout <= 0 {
+		timeout = neighbor.DefaultPingTimeout
+	}
+	start := time.Now()
+	if err := ping(ip.String(), timeout); err != nil {
+		logger.Debug("On-demand ping to %s failed: %v", ip.String(), err)
+		writeJSONResponse(w, PingResponse{})
+		return
+	}
+
+	writeJSONResponse(w, PingResponse{
+		Reachable: true,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	})
+}
+
 func (a *API) ListSniffedInterfacesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only GET method is allowed")
 		return
 	}
 
+	type SnifferStatsView struct {
+		PacketsReceived uint64 `json:"packets_received"`
+		PacketsMatched  uint64 `json:"packets_matched"`
+		PacketsSkipped  uint64 `json:"packets_skipped"`
+		NeighborsAdded  uint64 `json:"neighbors_added"`
+	}
+
 	type SniffedInterface struct {
-		Interface string        `json:"interface"`
-		StartedAt time.Time     `json:"started_at"`
-		Uptime    time.Duration `json:"uptime_seconds"`
+		Interface string           `json:"interface"`
+		Alias     string           `json:"alias"`
+		StartedAt time.Time        `json:"started_at"`
+		Uptime    time.Duration    `json:"uptime_seconds"`
+		Paused    bool             `json:"paused"`
+		PausedAt  *time.Time       `json:"paused_at,omitempty"`
+		Stats     SnifferStatsView `json:"stats"`
 	}
 
 	type SniffersResponse struct {
@@ -112,12 +807,25 @@ func (a *API) ListSniffedInterfacesHandler(w http.ResponseWriter, r *http.Reques
 	now := time.Now()
 	var sniffed []SniffedInterface
 
-	for iface, started := range sniffer.ListActiveSniffers() {
-		sniffed = append(sniffed, SniffedInterface{
+	var statuses map[string]sniffer.SnifferStatus
+	if a.Sniffers != nil {
+		statuses = a.Sniffers.ListSnifferStatus()
+	}
+
+	for iface, status := range statuses {
+		entry := SniffedInterface{
 			Interface: iface,
-			StartedAt: started,
-			Uptime:    now.Sub(started),
-		})
+			Alias:     a.Aliases.Lookup(iface),
+			StartedAt: status.StartedAt,
+			Uptime:    now.Sub(status.StartedAt),
+			Paused:    status.Paused,
+			Stats:     SnifferStatsView(status.Stats),
+		}
+		if status.Paused {
+			pausedAt := status.PausedAt
+			entry.PausedAt = &pausedAt
+		}
+		sniffed = append(sniffed, entry)
 	}
 
 	sort.Slice(sniffed, func(i, j int) bool {
@@ -132,3 +840,85 @@ func (a *API) ListSniffedInterfacesHandler(w http.ResponseWriter, r *http.Reques
 
 	writeJSONResponse(w, response)
 }
+
+func (a *API) PauseSnifferHandler(w http.ResponseWriter, r *http.Request) {
+	a.toggleSniffer(w, r, (*sniffer.SnifferManager).Pause)
+}
+
+func (a *API) ResumeSnifferHandler(w http.ResponseWriter, r *http.Request) {
+	a.toggleSniffer(w, r, (*sniffer.SnifferManager).Resume)
+}
+
+func (a *API) toggleSniffer(w http.ResponseWriter, r *http.Request, toggle func(*sniffer.SnifferManager, string) error) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only POST method is allowed")
+		return
+	}
+
+	if a.Sniffers == nil {
+		writeErrorResponse(w, http.StatusServiceUnavailable, "sniffer_disabled", "Sniffer mode is not enabled")
+		return
+	}
+
+	iface := r.PathValue("iface")
+	if iface == "" {
+		writeErrorResponse(w, http.StatusBadRequest, "invalid_interface", "Interface name is required")
+		return
+	}
+
+	if err := toggle(a.Sniffers, iface); err != nil {
+		switch {
+		case errors.Is(err, sniffer.ErrSnifferNotFound):
+			writeErrorResponse(w, http.StatusNotFound, "not_found", "No sniffer running on "+iface)
+		case errors.Is(err, sniffer.ErrSnifferPaused):
+			writeErrorResponse(w, http.StatusConflict, "already_paused", "Sniffer on "+iface+" is already paused")
+		case errors.Is(err, sniffer.ErrSnifferNotPaused):
+			writeErrorResponse(w, http.StatusConflict, "not_paused", "Sniffer on "+iface+" is not paused")
+		case errors.Is(err, sniffer.ErrSnifferStopped):
+			writeErrorResponse(w, http.StatusServiceUnavailable, "sniffer_stopped", "Sniffer manager is shutting down")
+		default:
+			writeErrorResponse(w, http.StatusInternalServerError, "internal_error", err.Error())
+		}
+		return
+	}
+
+	type ToggleResponse struct {
+		Interface string    `json:"interface"`
+		Paused    bool      `json:"paused"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+
+	response := ToggleResponse{
+		Interface: iface,
+		Paused:    a.Sniffers.ListSnifferStatus()[iface].Paused,
+		Timestamp: time.Now(),
+	}
+
+	writeJSONResponse(w, response)
+}
+
+func (a *API) ReloadSniffersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only POST method is allowed")
+		return
+	}
+
+	if a.Sniffers == nil {
+		writeErrorResponse(w, http.StatusServiceUnavailable, "sniffer_disabled", "Sniffer mode is not enabled")
+		return
+	}
+
+	type ReloadResponse struct {
+		Started   int       `json:"started"`
+		Stopped   int       `json:"stopped"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+
+	started, stopped := a.Sniffers.ReloadInterfaces()
+
+	writeJSONResponse(w, ReloadResponse{
+		Started:   started,
+		Stopped:   stopped,
+		Timestamp: time.Now(),
+	})
+}
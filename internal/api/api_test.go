@@ -1,15 +1,22 @@
 package api
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/hostinger/neigh2route/internal/neighbor"
+	"github.com/hostinger/neigh2route/internal/sniffer"
+	"github.com/hostinger/neigh2route/pkg/netutils"
+	"github.com/vishvananda/netlink"
 )
 
 // Helper function to parse hardware address
@@ -18,6 +25,20 @@ func parseMAC(s string) net.HardwareAddr {
 	return mac
 }
 
+// Helper function to resolve link indexes to the given interface names only
+func stubInterfaces(t *testing.T, names map[int]string) {
+	orig := interfaceByIndex
+	t.Cleanup(func() { interfaceByIndex = orig })
+
+	interfaceByIndex = func(linkIndex int) (*net.Interface, error) {
+		name, ok := names[linkIndex]
+		if !ok {
+			return nil, errors.New("no such network interface")
+		}
+		return &net.Interface{Index: linkIndex, Name: name}, nil
+	}
+}
+
 // Helper function to create API with populated neighbor manager
 func createAPIWithNeighbors(neighbors map[string]neighbor.Neighbor) *API {
 	nm, _ := neighbor.NewNeighborManager("lo")
@@ -180,7 +201,7 @@ func TestListNeighborsHandler_MethodNotAllowed(t *testing.T) {
 }
 
 func TestListSniffedInterfacesHandler_Success(t *testing.T) {
-	// Since sniffer.ListActiveSniffers() is a global function, we test with real implementation
+	// Without a sniffer manager there is nothing to list
 	api := &API{NM: nil} // We don't need NM for this test
 
 	req := httptest.NewRequest("GET", "/sniffers", nil)
@@ -360,3 +381,1150 @@ func TestWriteJSONResponse_Nil(t *testing.T) {
 		t.Errorf("Expected 'null\\n', got %s", body)
 	}
 }
+
+func TestNeighborHistoryHandler(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+	defer api.NM.Cleanup()
+
+	ip := net.ParseIP("10.10.40.1")
+	api.NM.AddNeighbor(ip, 1, nil)
+	api.NM.RemoveNeighbor(ip, 1)
+	api.NM.AddNeighbor(ip, 1, nil)
+	api.NM.AddNeighbor(net.ParseIP("10.10.40.2"), 1, nil)
+
+	req := httptest.NewRequest("GET", "/neighbors/10.10.40.1/history?limit=2", nil)
+	req.SetPathValue("ip", "10.10.40.1")
+	rr := httptest.NewRecorder()
+
+	api.NeighborHistoryHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response struct {
+		IP     string `json:"ip"`
+		Events []struct {
+			Type      string    `json:"type"`
+			IP        string    `json:"ip"`
+			Timestamp time.Time `json:"timestamp"`
+		} `json:"events"`
+		Count int `json:"count"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+
+	if response.Count != 2 {
+		t.Fatalf("Expected count 2, got %d", response.Count)
+	}
+
+	if response.Events[0].Type != "add" || response.Events[1].Type != "remove" {
+		t.Errorf("Expected [add remove], got [%s %s]", response.Events[0].Type, response.Events[1].Type)
+	}
+
+	for _, e := range response.Events {
+		if e.IP != "10.10.40.1" {
+			t.Errorf("Expected only events for 10.10.40.1, got %s", e.IP)
+		}
+	}
+}
+
+func TestNeighborHistoryHandler_Errors(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+
+	testCases := []struct {
+		ip     string
+		query  string
+		status int
+	}{
+		{"not-an-ip", "", http.StatusBadRequest},
+		{"10.10.40.9", "?limit=0", http.StatusBadRequest},
+		{"10.10.40.9", "", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "/neighbors/"+tc.ip+"/history"+tc.query, nil)
+		req.SetPathValue("ip", tc.ip)
+		rr := httptest.NewRecorder()
+
+		api.NeighborHistoryHandler(rr, req)
+
+		if status := rr.Code; status != tc.status {
+			t.Errorf("Expected %d for %s%s, got %d", tc.status, tc.ip, tc.query, status)
+		}
+	}
+}
+
+func TestNeighborTracerouteHandler(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{
+		"127.0.0.1": {IP: net.ParseIP("127.0.0.1"), LinkIndex: 1},
+	})
+
+	traceroute = func(dst net.IP, maxHops int) ([]netutils.Hop, error) {
+		if maxHops != 5 {
+			t.Errorf("Expected max hops 5, got %d", maxHops)
+		}
+		return []netutils.Hop{
+			{TTL: 1},
+			{TTL: 2, IP: dst, Latency: 1500 * time.Microsecond},
+		}, nil
+	}
+	defer func() { traceroute = netutils.TracerouteHops }()
+
+	req := httptest.NewRequest("GET", "/neighbors/127.0.0.1/traceroute?max_hops=5", nil)
+	req.SetPathValue("ip", "127.0.0.1")
+	rr := httptest.NewRecorder()
+
+	api.NeighborTracerouteHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response struct {
+		Hops []struct {
+			TTL       int     `json:"ttl"`
+			IP        string  `json:"ip"`
+			LatencyMs float64 `json:"latency_ms"`
+		} `json:"hops"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+
+	if len(response.Hops) != 2 {
+		t.Fatalf("Expected 2 hops, got %d", len(response.Hops))
+	}
+
+	if response.Hops[0].IP != "" || response.Hops[1].IP != "127.0.0.1" {
+		t.Errorf("Unexpected hop addresses: %+v", response.Hops)
+	}
+
+	if response.Hops[1].LatencyMs != 1.5 {
+		t.Errorf("Expected latency 1.5ms, got %v", response.Hops[1].LatencyMs)
+	}
+}
+
+func TestNeighborTracerouteHandler_Errors(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+
+	testCases := []struct {
+		ip     string
+		query  string
+		status int
+	}{
+		{"not-an-ip", "", http.StatusBadRequest},
+		{"127.0.0.1", "?max_hops=100", http.StatusBadRequest},
+		{"127.0.0.1", "", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "/neighbors/"+tc.ip+"/traceroute"+tc.query, nil)
+		req.SetPathValue("ip", tc.ip)
+		rr := httptest.NewRecorder()
+
+		api.NeighborTracerouteHandler(rr, req)
+
+		if status := rr.Code; status != tc.status {
+			t.Errorf("Expected %d for %s%s, got %d", tc.status, tc.ip, tc.query, status)
+		}
+	}
+}
+
+func TestNeighborPingHandler(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{
+		"127.0.0.1": {IP: net.ParseIP("127.0.0.1"), LinkIndex: 1},
+		"127.0.0.2": {IP: net.ParseIP("127.0.0.2"), LinkIndex: 1, PingTimeout: 50 * time.Millisecond},
+	})
+
+	ping = func(ip string, timeout time.Duration) error {
+		if ip == "127.0.0.2" {
+			if timeout != 50*time.Millisecond {
+				t.Errorf("Expected the neighbor's 50ms ping timeout, got %s", timeout)
+			}
+			return errors.New("no reply")
+		}
+		return nil
+	}
+	defer func() { ping = netutils.PingOnce }()
+
+	for ip, want := range map[string]bool{"127.0.0.1": true, "127.0.0.2": false} {
+		req := httptest.NewRequest("POST", "/neighbors/"+ip+"/ping", nil)
+		req.SetPathValue("ip", ip)
+		rr := httptest.NewRecorder()
+
+		api.NeighborPingHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("%s: handler returned wrong status code: got %v want %v", ip, status, http.StatusOK)
+		}
+
+		var response struct {
+			Reachable bool    `json:"reachable"`
+			LatencyMs float64 `json:"latency_ms"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Could not unmarshal response: %v", err)
+		}
+		if response.Reachable != want {
+			t.Errorf("%s: expected reachable=%v, got %+v", ip, want, response)
+		}
+	}
+}
+
+func TestNeighborPingHandler_Errors(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+
+	testCases := []struct {
+		method string
+		ip     string
+		status int
+	}{
+		{"GET", "127.0.0.1", http.StatusMethodNotAllowed},
+		{"POST", "not-an-ip", http.StatusBadRequest},
+		{"POST", "127.0.0.1", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest(tc.method, "/neighbors/"+tc.ip+"/ping", nil)
+		req.SetPathValue("ip", tc.ip)
+		rr := httptest.NewRecorder()
+
+		api.NeighborPingHandler(rr, req)
+
+		if status := rr.Code; status != tc.status {
+			t.Errorf("Expected %d for %s %s, got %d", tc.status, tc.method, tc.ip, status)
+		}
+	}
+}
+
+func TestBatchDeleteNeighborsHandler(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+	api.NM.AddNeighbor(net.ParseIP("10.10.60.1"), 1, nil)
+	api.NM.AddNeighbor(net.ParseIP("10.10.60.2"), 1, nil)
+	defer api.NM.Cleanup()
+
+	body := `{"ips":["10.10.60.1","10.10.60.9","bogus"]}`
+	req := httptest.NewRequest("POST", "/neighbors/batch-delete", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	api.BatchDeleteNeighborsHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response struct {
+		Removed int      `json:"removed"`
+		Errors  []string `json:"errors"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+
+	if response.Removed != 1 {
+		t.Errorf("Expected 1 removed, got %d", response.Removed)
+	}
+
+	if len(response.Errors) != 2 {
+		t.Errorf("Expected 2 errors, got %v", response.Errors)
+	}
+
+	if _, ok := api.NM.GetNeighbor(net.ParseIP("10.10.60.2")); !ok {
+		t.Errorf("Expected 10.10.60.2 to remain")
+	}
+}
+
+func TestBatchDeleteNeighborsHandler_BadRequest(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+
+	for _, body := range []string{"not json", `{"ips":[]}`} {
+		req := httptest.NewRequest("POST", "/neighbors/batch-delete", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+
+		api.BatchDeleteNeighborsHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected %d for %q, got %d", http.StatusBadRequest, body, status)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/neighbors/batch-delete", nil)
+	rr := httptest.NewRecorder()
+	api.BatchDeleteNeighborsHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusMethodNotAllowed {
+		t.Errorf("Expected %d, got %d", http.StatusMethodNotAllowed, status)
+	}
+}
+
+// Helper function to serve handler on a loopback listener through API.Server
+func startTestServer(t *testing.T, handler http.Handler) (*API, string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+
+	api := &API{}
+	api.Server = &http.Server{Handler: handler, ConnState: api.TrackConnState}
+	go api.Server.Serve(ln)
+
+	return api, "http://" + ln.Addr().String()
+}
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	var finished atomic.Bool
+
+	api, url := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		finished.Store(true)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	result := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			result <- 0
+			return
+		}
+		resp.Body.Close()
+		result <- resp.StatusCode
+	}()
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := api.Shutdown(ctx); err != nil {
+		t.Fatalf("Expected clean shutdown, got %v", err)
+	}
+
+	if !finished.Load() {
+		t.Errorf("Shutdown returned before the in-flight request finished")
+	}
+
+	if status := <-result; status != http.StatusOK {
+		t.Errorf("Expected in-flight request to succeed, got status %d", status)
+	}
+
+	if _, err := http.Get(url); err == nil {
+		t.Errorf("Expected new requests to fail after shutdown")
+	}
+}
+
+func TestTrackConnStateCountsActiveConnections(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	api, url := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	go func() {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	if active := api.activeConns(); active != 1 {
+		t.Errorf("Expected 1 active connection, got %d", active)
+	}
+
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := api.Shutdown(ctx); err != nil {
+		t.Fatalf("Expected clean shutdown, got %v", err)
+	}
+	if active := api.activeConns(); active != 0 {
+		t.Errorf("Expected no active connections after shutdown, got %d", active)
+	}
+}
+
+func TestShutdownRespectsDeadline(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	api, url := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	go http.Get(url)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := api.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestShutdownWithoutServer(t *testing.T) {
+	api := &API{}
+	if err := api.Shutdown(context.Background()); err != nil {
+		t.Errorf("Expected nil error without a server, got %v", err)
+	}
+}
+
+func TestToggleSnifferHandler_Errors(t *testing.T) {
+	api := &API{NM: nil, Sniffers: sniffer.NewSnifferManager("lo")}
+
+	req := httptest.NewRequest("POST", "/sniffed-interfaces/tap-missing/pause", nil)
+	req.SetPathValue("iface", "tap-missing")
+	rr := httptest.NewRecorder()
+	api.PauseSnifferHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Expected %d for unknown sniffer, got %d", http.StatusNotFound, status)
+	}
+
+	req = httptest.NewRequest("GET", "/sniffed-interfaces/tap-missing/resume", nil)
+	req.SetPathValue("iface", "tap-missing")
+	rr = httptest.NewRecorder()
+	api.ResumeSnifferHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusMethodNotAllowed {
+		t.Errorf("Expected %d for GET, got %d", http.StatusMethodNotAllowed, status)
+	}
+}
+
+func TestSnifferHandlers_Disabled(t *testing.T) {
+	api := &API{NM: nil}
+
+	req := httptest.NewRequest("POST", "/sniffed-interfaces/tap0/pause", nil)
+	req.SetPathValue("iface", "tap0")
+	rr := httptest.NewRecorder()
+	api.PauseSnifferHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("Expected %d without a sniffer manager, got %d", http.StatusServiceUnavailable, status)
+	}
+
+	req = httptest.NewRequest("POST", "/sniffed-interfaces/reload", nil)
+	rr = httptest.NewRecorder()
+	api.ReloadSniffersHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("Expected %d without a sniffer manager, got %d", http.StatusServiceUnavailable, status)
+	}
+}
+
+func TestListNeighborsHandler_LinkIndexFilter(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{
+		"192.168.1.10": {IP: net.ParseIP("192.168.1.10"), LinkIndex: 2, HardwareAddr: parseMAC("00:11:22:33:44:55")},
+		"192.168.1.20": {IP: net.ParseIP("192.168.1.20"), LinkIndex: 3, HardwareAddr: parseMAC("aa:bb:cc:dd:ee:ff")},
+		"192.168.1.30": {IP: net.ParseIP("192.168.1.30"), LinkIndex: 2, HardwareAddr: parseMAC("11:22:33:44:55:66")},
+	})
+
+	req := httptest.NewRequest("GET", "/neighbors?link_index=2", nil)
+	rr := httptest.NewRecorder()
+
+	api.ListNeighborsHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response struct {
+		Neighbors []struct {
+			IP        string `json:"ip"`
+			LinkIndex int    `json:"link_index"`
+		} `json:"neighbors"`
+		Count int `json:"count"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+
+	if response.Count != 2 {
+		t.Fatalf("Expected 2 neighbors, got %d", response.Count)
+	}
+
+	for _, n := range response.Neighbors {
+		if n.LinkIndex != 2 {
+			t.Errorf("Expected only link_index 2, got %d for %s", n.LinkIndex, n.IP)
+		}
+	}
+}
+
+func TestListNeighborsHandler_InvalidLinkIndex(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+
+	for _, value := range []string{"abc", "0", "-1"} {
+		req := httptest.NewRequest("GET", "/neighbors?link_index="+value, nil)
+		rr := httptest.NewRecorder()
+
+		api.ListNeighborsHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected %d for link_index=%s, got %d", http.StatusBadRequest, value, status)
+		}
+	}
+}
+
+func TestSelfTestHandler(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+	defer func() { testConnectivity = (*neighbor.NeighborManager).TestConnectivity }()
+
+	testCases := []struct {
+		name    string
+		err     error
+		status  int
+		success bool
+		message string
+	}{
+		{"passes", nil, http.StatusOK, true, ""},
+		{"fails", errors.New("no reply from 127.0.0.1"), http.StatusInternalServerError, false, "no reply from 127.0.0.1"},
+	}
+
+	for _, tc := range testCases {
+		testConnectivity = func(nm *neighbor.NeighborManager, ctx context.Context) error {
+			if nm != api.NM {
+				t.Errorf("%s: expected the API's neighbor manager", tc.name)
+			}
+			if _, ok := ctx.Deadline(); !ok {
+				t.Errorf("%s: expected the self-test to be bounded by a timeout", tc.name)
+			}
+			return tc.err
+		}
+
+		rr := httptest.NewRecorder()
+		api.SelfTestHandler(rr, httptest.NewRequest("POST", "/self-test", nil))
+
+		if rr.Code != tc.status {
+			t.Fatalf("%s: expected status %d, got %d: %s", tc.name, tc.status, rr.Code, rr.Body.String())
+		}
+
+		if tc.success {
+			var response struct {
+				Success bool `json:"success"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("%s: could not unmarshal response: %v", tc.name, err)
+			}
+			if !response.Success {
+				t.Errorf("%s: expected success, got %s", tc.name, rr.Body.String())
+			}
+			continue
+		}
+
+		var errorResponse ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &errorResponse); err != nil {
+			t.Fatalf("%s: could not unmarshal error response: %v", tc.name, err)
+		}
+		if errorResponse.Error != "self_test_failed" || errorResponse.Message != tc.message {
+			t.Errorf("%s: expected self_test_failed with %q, got %+v", tc.name, tc.message, errorResponse)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	api.SelfTestHandler(rr, httptest.NewRequest("GET", "/self-test", nil))
+
+	if status := rr.Code; status != http.StatusMethodNotAllowed {
+		t.Errorf("Expected %d, got %d", http.StatusMethodNotAllowed, status)
+	}
+}
+
+func TestListNeighborsHandler_PermanentFilter(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{
+		"192.168.1.10": {IP: net.ParseIP("192.168.1.10"), LinkIndex: 2, HardwareAddr: parseMAC("00:11:22:33:44:55"), Permanent: true},
+		"192.168.1.20": {IP: net.ParseIP("192.168.1.20"), LinkIndex: 2, HardwareAddr: parseMAC("aa:bb:cc:dd:ee:ff")},
+	})
+
+	req := httptest.NewRequest("GET", "/neighbors?permanent=true", nil)
+	rr := httptest.NewRecorder()
+
+	api.ListNeighborsHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response struct {
+		Neighbors []struct {
+			IP        string `json:"ip"`
+			Permanent bool   `json:"permanent"`
+		} `json:"neighbors"`
+		Count int `json:"count"`
+	}
+
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+
+	if response.Count != 1 || response.Neighbors[0].IP != "192.168.1.10" || !response.Neighbors[0].Permanent {
+		t.Errorf("Expected only the permanent neighbor, got %+v", response.Neighbors)
+	}
+
+	req = httptest.NewRequest("GET", "/neighbors?permanent=maybe", nil)
+	rr = httptest.NewRecorder()
+	api.ListNeighborsHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Expected %d for invalid permanent value, got %d", http.StatusBadRequest, status)
+	}
+}
+
+func TestListNeighborsHandler_AfiFilter(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{
+		"192.168.1.10": {IP: net.ParseIP("192.168.1.10"), LinkIndex: 2},
+		"2001:db8::10": {IP: net.ParseIP("2001:db8::10"), LinkIndex: 2},
+		"2001:db8::20": {IP: net.ParseIP("2001:db8::20"), LinkIndex: 2},
+	})
+
+	for afi, want := range map[string][]string{
+		"v4": {"192.168.1.10"},
+		"v6": {"2001:db8::10", "2001:db8::20"},
+	} {
+		req := httptest.NewRequest("GET", "/neighbors?afi="+afi, nil)
+		rr := httptest.NewRecorder()
+
+		api.ListNeighborsHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("afi=%s: handler returned wrong status code: got %v want %v", afi, status, http.StatusOK)
+		}
+
+		var response struct {
+			Neighbors []struct {
+				IP  string `json:"ip"`
+				Afi string `json:"afi"`
+			} `json:"neighbors"`
+			Count int `json:"count"`
+		}
+
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Could not unmarshal response: %v", err)
+		}
+
+		if response.Count != len(want) {
+			t.Fatalf("afi=%s: expected %d neighbors, got %d", afi, len(want), response.Count)
+		}
+
+		for i, n := range response.Neighbors {
+			if n.IP != want[i] || n.Afi != afi {
+				t.Errorf("afi=%s: expected %s, got %s (%s)", afi, want[i], n.IP, n.Afi)
+			}
+		}
+	}
+}
+
+func TestListNeighborsHandler_InvalidAfi(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+
+	for _, value := range []string{"v5", "ipv4", "V4"} {
+		req := httptest.NewRequest("GET", "/neighbors?afi="+value, nil)
+		rr := httptest.NewRecorder()
+
+		api.ListNeighborsHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("Expected %d for afi=%s, got %d", http.StatusBadRequest, value, status)
+			continue
+		}
+
+		var errorResponse ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &errorResponse); err != nil {
+			t.Fatalf("Could not unmarshal error response: %v", err)
+		}
+		if errorResponse.Error != "invalid_afi" {
+			t.Errorf("Expected error 'invalid_afi', got %s", errorResponse.Error)
+		}
+	}
+}
+
+func TestListNeighborsHandler_Sort(t *testing.T) {
+	stubInterfaces(t, map[int]string{2: "eth1", 3: "eth0"})
+
+	now := time.Now()
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{
+		"10.0.0.9":     {IP: net.ParseIP("10.0.0.9"), LinkIndex: 2, FirstSeen: now.Add(-time.Minute)},
+		"10.0.0.10":    {IP: net.ParseIP("10.0.0.10"), LinkIndex: 3, FirstSeen: now},
+		"2001:db8::1":  {IP: net.ParseIP("2001:db8::1"), LinkIndex: 3, FirstSeen: now.Add(-time.Hour)},
+		"192.168.1.10": {IP: net.ParseIP("192.168.1.10"), LinkIndex: 2, FirstSeen: now.Add(-2 * time.Minute)},
+	})
+
+	for query, want := range map[string][]string{
+		"":                {"10.0.0.9", "10.0.0.10", "192.168.1.10", "2001:db8::1"},
+		"?sort=ip":        {"10.0.0.9", "10.0.0.10", "192.168.1.10", "2001:db8::1"},
+		"?sort=age":       {"2001:db8::1", "192.168.1.10", "10.0.0.9", "10.0.0.10"},
+		"?sort=interface": {"10.0.0.10", "2001:db8::1", "10.0.0.9", "192.168.1.10"},
+	} {
+		req := httptest.NewRequest("GET", "/neighbors"+query, nil)
+		rr := httptest.NewRecorder()
+
+		api.ListNeighborsHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("%q: handler returned wrong status code: got %v want %v", query, status, http.StatusOK)
+		}
+
+		var response struct {
+			Neighbors []struct {
+				IP string `json:"ip"`
+			} `json:"neighbors"`
+		}
+
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Could not unmarshal response: %v", err)
+		}
+
+		if len(response.Neighbors) != len(want) {
+			t.Fatalf("%q: expected %d neighbors, got %d", query, len(want), len(response.Neighbors))
+		}
+
+		for i, n := range response.Neighbors {
+			if n.IP != want[i] {
+				t.Errorf("%q: expected %s at position %d, got %s", query, want[i], i, n.IP)
+			}
+		}
+	}
+}
+
+func TestListNeighborsHandler_InvalidSort(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+
+	req := httptest.NewRequest("GET", "/neighbors?sort=mac", nil)
+	rr := httptest.NewRecorder()
+
+	api.ListNeighborsHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("Expected %d, got %d", http.StatusBadRequest, status)
+	}
+
+	var errorResponse ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &errorResponse); err != nil {
+		t.Fatalf("Could not unmarshal error response: %v", err)
+	}
+	if errorResponse.Error != "invalid_sort" {
+		t.Errorf("Expected error 'invalid_sort', got %s", errorResponse.Error)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{
+		"192.168.1.10": {IP: net.ParseIP("192.168.1.10"), LinkIndex: 2},
+	})
+
+	rr := httptest.NewRecorder()
+	api.HealthHandler(rr, httptest.NewRequest("GET", "/health", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if body["status"] != "ok" || body["neighbors"] != float64(1) {
+		t.Errorf("Unexpected health response: %v", body)
+	}
+	if _, ok := body["uptime_seconds"].(float64); !ok {
+		t.Errorf("Expected numeric uptime_seconds, got %v", body["uptime_seconds"])
+	}
+
+	api.HealthStaleness = time.Nanosecond
+	time.Sleep(time.Millisecond)
+
+	rr = httptest.NewRecorder()
+	api.HealthHandler(rr, httptest.NewRequest("GET", "/health", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d when stale, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"status":"stale"`) {
+		t.Errorf("Expected stale status in body, got %s", rr.Body.String())
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{
+		"192.168.1.10": {IP: net.ParseIP("192.168.1.10"), LinkIndex: 2},
+		"2001:db8::10": {IP: net.ParseIP("2001:db8::10"), LinkIndex: 2},
+	})
+
+	rr := httptest.NewRecorder()
+	api.StatsHandler(rr, httptest.NewRequest("GET", "/stats", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if body["neighbors_total"] != float64(2) {
+		t.Errorf("Expected neighbors_total 2, got %v", body["neighbors_total"])
+	}
+	if body["active_sniffers"] != float64(0) || body["packets_sniffed_total"] != float64(0) {
+		t.Errorf("Expected no sniffer activity without sniffers, got %v", body)
+	}
+	for _, key := range []string{"uptime_seconds", "routes_added_total", "routes_removed_total", "ping_failures_total", "netlink_reconnects_total"} {
+		if _, ok := body[key].(float64); !ok {
+			t.Errorf("Expected numeric %s, got %v", key, body[key])
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	api.StatsHandler(rr, httptest.NewRequest("POST", "/stats", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
+
+func TestNeighborHandler_Delete(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+	api.NM.AddNeighbor(net.ParseIP("10.10.61.1"), 1, nil)
+	defer api.NM.Cleanup()
+
+	req := httptest.NewRequest("DELETE", "/neighbors/10.10.61.1", nil)
+	req.SetPathValue("ip", "10.10.61.1")
+	rr := httptest.NewRecorder()
+
+	api.NeighborHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusNoContent {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusNoContent)
+	}
+
+	if rr.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got %q", rr.Body.String())
+	}
+
+	if _, ok := api.NM.GetNeighbor(net.ParseIP("10.10.61.1")); ok {
+		t.Errorf("Expected 10.10.61.1 to be removed")
+	}
+}
+
+// Helper function to create a bridge link that is deleted when the test ends
+func addBridgeLink(t *testing.T, name string) int {
+	link := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: name}}
+	if err := netlink.LinkAdd(link); err != nil {
+		t.Skipf("cannot create bridge link: %v", err)
+	}
+	t.Cleanup(func() { netlink.LinkDel(link) })
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		t.Fatalf("failed to bring up %s: %v", name, err)
+	}
+	created, err := netlink.LinkByName(name)
+	if err != nil {
+		t.Fatalf("failed to look up %s: %v", name, err)
+	}
+	return created.Attrs().Index
+}
+
+func TestNeighborHandler_DeleteECMP(t *testing.T) {
+	first := addBridgeLink(t, "n2r-apiecmp0")
+	second := addBridgeLink(t, "n2r-apiecmp1")
+
+	nm, _ := neighbor.NewNeighborManagerFromConfig(neighbor.Config{ECMP: true})
+	defer nm.Cleanup()
+	api := &API{NM: nm}
+
+	ip := net.ParseIP("10.10.61.3")
+	nm.AddNeighbor(ip, first, nil)
+	nm.AddNeighbor(ip, second, nil)
+
+	req := httptest.NewRequest("DELETE", "/neighbors/10.10.61.3", nil)
+	req.SetPathValue("ip", "10.10.61.3")
+	rr := httptest.NewRecorder()
+
+	api.NeighborHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusNoContent {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusNoContent)
+	}
+	if _, ok := nm.GetNeighbor(ip); ok {
+		t.Errorf("Expected 10.10.61.3 to be removed from every link")
+	}
+
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{
+		Dst: &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)},
+	}, netlink.RT_FILTER_DST)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+	if len(routes) != 0 {
+		t.Errorf("Expected no routes left for 10.10.61.3, got %+v", routes)
+	}
+}
+
+func TestNeighborHandler_PatchPingTimeout(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+	api.NM.AddNeighbor(net.ParseIP("10.10.61.2"), 1, nil)
+	defer api.NM.Cleanup()
+
+	tests := []struct {
+		ip   string
+		body string
+		want int
+	}{
+		{"10.10.61.2", `{"ping_timeout_ms": 500}`, http.StatusOK},
+		{"10.10.61.2", `{"ping_timeout_ms": -1}`, http.StatusBadRequest},
+		{"10.10.61.2", `{}`, http.StatusBadRequest},
+		{"10.10.61.2", `not json`, http.StatusBadRequest},
+		{"10.10.61.9", `{"ping_timeout_ms": 500}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("PATCH", "/neighbors/"+tt.ip, strings.NewReader(tt.body))
+		req.SetPathValue("ip", tt.ip)
+		rr := httptest.NewRecorder()
+
+		api.NeighborHandler(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.ip, tt.body, tt.want, rr.Code)
+		}
+	}
+
+	n, _ := api.NM.GetNeighbor(net.ParseIP("10.10.61.2"))
+	if n.PingTimeout != 500*time.Millisecond {
+		t.Errorf("Expected a 500ms ping timeout, got %s", n.PingTimeout)
+	}
+}
+
+func TestNeighborHandler_Errors(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+
+	tests := []struct {
+		method string
+		ip     string
+		want   int
+	}{
+		{"DELETE", "bogus", http.StatusBadRequest},
+		{"DELETE", "10.10.61.9", http.StatusNotFound},
+		{"PUT", "10.10.61.9", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/neighbors/"+tt.ip, nil)
+		req.SetPathValue("ip", tt.ip)
+		rr := httptest.NewRecorder()
+
+		api.NeighborHandler(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.ip, tt.want, rr.Code)
+		}
+	}
+}
+
+func TestNeighborHandler_Get(t *testing.T) {
+	stubInterfaces(t, map[int]string{4: "eth4"})
+
+	firstSeen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	lastUpdated := firstSeen.Add(90 * time.Second)
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{
+		"2001:db8::1": {
+			IP:           net.ParseIP("2001:db8::1"),
+			LinkIndex:    4,
+			HardwareAddr: parseMAC("11:22:33:44:55:66"),
+			FirstSeen:    firstSeen,
+			LastUpdated:  lastUpdated,
+		},
+	})
+
+	req := httptest.NewRequest("GET", "/neighbors/2001:db8::1", nil)
+	req.SetPathValue("ip", "2001:db8::1")
+	rr := httptest.NewRecorder()
+
+	api.NeighborHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var view NeighborView
+	if err := json.Unmarshal(rr.Body.Bytes(), &view); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+
+	want := NeighborView{IP: "2001:db8::1", LinkIndex: 4, Interface: "eth4", HardwareAddr: "11:22:33:44:55:66", Afi: "v6", FirstSeen: firstSeen, LastUpdated: lastUpdated}
+	if view != want {
+		t.Errorf("Expected %+v, got %+v", want, view)
+	}
+
+	req = httptest.NewRequest("GET", "/neighbors/2001:db8::2", nil)
+	req.SetPathValue("ip", "2001:db8::2")
+	rr = httptest.NewRecorder()
+
+	api.NeighborHandler(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Expected %d for unknown neighbor, got %d", http.StatusNotFound, status)
+	}
+
+	var errResp ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &errResp); err != nil || errResp.Error != "not_found" {
+		t.Errorf("Expected not_found error response, got %s", rr.Body.String())
+	}
+}
+
+func TestListNeighborsHandler_InterfaceName(t *testing.T) {
+	stubInterfaces(t, map[int]string{2: "eth0"})
+
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{
+		"192.168.1.10": {IP: net.ParseIP("192.168.1.10"), LinkIndex: 2},
+		"192.168.1.20": {IP: net.ParseIP("192.168.1.20"), LinkIndex: 9},
+	})
+
+	req := httptest.NewRequest("GET", "/neighbors", nil)
+	rr := httptest.NewRecorder()
+
+	api.ListNeighborsHandler(rr, req)
+
+	var response struct {
+		Neighbors []NeighborView `json:"neighbors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Could not unmarshal response: %v", err)
+	}
+
+	if len(response.Neighbors) != 2 {
+		t.Fatalf("Expected 2 neighbors, got %d", len(response.Neighbors))
+	}
+
+	if got := response.Neighbors[0].Interface; got != "eth0" {
+		t.Errorf("Expected interface eth0 for link 2, got %q", got)
+	}
+
+	if got := response.Neighbors[1].Interface; got != "" {
+		t.Errorf("Expected an empty interface for an unknown link, got %q", got)
+	}
+}
+
+func TestEventsHandler(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+	defer api.NM.Cleanup()
+
+	api.NM.AddNeighbor(net.ParseIP("10.10.41.1"), 1, nil)
+	api.NM.AddNeighbor(net.ParseIP("10.10.41.2"), 1, nil)
+	api.NM.RemoveNeighbor(net.ParseIP("10.10.41.1"), 1)
+
+	testCases := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"remove 10.10.41.1", "add 10.10.41.2", "add 10.10.41.1"}},
+		{"?limit=2", []string{"remove 10.10.41.1", "add 10.10.41.2"}},
+		{"?ip=10.10.41.1", []string{"remove 10.10.41.1", "add 10.10.41.1"}},
+		{"?ip=10.10.41.9", []string{}},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "/events"+tc.query, nil)
+		rr := httptest.NewRecorder()
+
+		api.EventsHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("%s: handler returned wrong status code: got %v want %v", tc.query, status, http.StatusOK)
+		}
+
+		var response struct {
+			Events []EventView `json:"events"`
+			Count  int         `json:"count"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: could not unmarshal response: %v", tc.query, err)
+		}
+
+		got := []string{}
+		for _, e := range response.Events {
+			got = append(got, e.Type+" "+e.IP)
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") || response.Count != len(tc.want) {
+			t.Errorf("%s: expected %v, got %v (count %d)", tc.query, tc.want, got, response.Count)
+		}
+	}
+}
+
+func TestWatchNeighborsHandler(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+	defer api.NM.Cleanup()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	api.Server = &http.Server{Handler: http.HandlerFunc(api.WatchNeighborsHandler)}
+	go api.Server.Serve(ln)
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/neighbors/watch")
+	if err != nil {
+		t.Fatalf("Could not connect: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %s", ct)
+	}
+
+	api.NM.AddNeighbor(net.ParseIP("10.10.41.5"), 1, nil)
+	api.NM.RemoveNeighbor(net.ParseIP("10.10.41.5"), 1)
+
+	reader := bufio.NewReader(resp.Body)
+	for _, want := range []string{"add", "remove"} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Could not read event: %v", err)
+		}
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+		if !ok {
+			t.Fatalf("Expected a data line, got %q", line)
+		}
+
+		var e EventView
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatalf("Could not unmarshal event: %v", err)
+		}
+		if e.Type != want || e.IP != "10.10.41.5" {
+			t.Errorf("Expected %s 10.10.41.5, got %s %s", want, e.Type, e.IP)
+		}
+
+		if blank, _ := reader.ReadString('\n'); blank != "\n" {
+			t.Errorf("Expected a blank line after the event, got %q", blank)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := api.Shutdown(ctx); err != nil {
+		t.Errorf("Expected Shutdown to end the stream, got %v", err)
+	}
+}
+
+func TestEventsHandler_Errors(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+
+	for _, query := range []string{"?limit=0", "?limit=abc", "?ip=not-an-ip"} {
+		req := httptest.NewRequest("GET", "/events"+query, nil)
+		rr := httptest.NewRecorder()
+
+		api.EventsHandler(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", query, http.StatusBadRequest, status)
+		}
+	}
+}
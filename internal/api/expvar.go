@@ -0,0 +1,20 @@
+package api
+
+import (
+	"expvar"
+)
+
+// PublishExpvars publishes the neighbor and sniffer counts as expvar
+// variables for binaries that embed neigh2route. expvar names are global,
+// so it may be called once per process.
+func (a *API) PublishExpvars() {
+	expvar.Publish("neigh2route.neighbor_count", expvar.Func(func() any {
+		return a.NM.Stats().TotalNeighbors
+	}))
+	expvar.Publish("neigh2route.active_sniffers", expvar.Func(func() any {
+		if a.Sniffers == nil {
+			return 0
+		}
+		return len(a.Sniffers.ListActiveSniffers())
+	}))
+}
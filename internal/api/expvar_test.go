@@ -0,0 +1,31 @@
+package api
+
+import (
+	"expvar"
+	"net"
+	"testing"
+
+	"github.com/hostinger/neigh2route/internal/neighbor"
+)
+
+func TestPublishExpvars(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{
+		"192.168.1.10": {IP: net.ParseIP("192.168.1.10"), LinkIndex: 2},
+		"2001:db8::10": {IP: net.ParseIP("2001:db8::10"), LinkIndex: 2},
+	})
+	api.PublishExpvars()
+
+	for name, want := range map[string]string{
+		"neigh2route.neighbor_count":  "2",
+		"neigh2route.active_sniffers": "0",
+	} {
+		v := expvar.Get(name)
+		if v == nil {
+			t.Errorf("Expected %s to be published", name)
+			continue
+		}
+		if got := v.String(); got != want {
+			t.Errorf("Expected %s to be %s, got %s", name, want, got)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/hostinger/neigh2route/pkg/netutils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsHandler serves Prometheus metrics read from the neighbor manager's
+// stats at scrape time. It uses its own registry so only neigh2route
+// metrics are exposed.
+func (a *API) MetricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+
+	registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "neigh2route_reachable_neighbors_total",
+			Help: "Number of neighbors currently tracked.",
+		}, func() float64 {
+			return float64(a.NM.Stats().TotalNeighbors)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "neigh2route_active_sniffers_total",
+			Help: "Number of running NA sniffers.",
+		}, func() float64 {
+			if a.Sniffers == nil {
+				return 0
+			}
+			return float64(len(a.Sniffers.ListActiveSniffers()))
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "neigh2route_routes_added_total",
+			Help: "Routes installed since startup.",
+		}, func() float64 {
+			return float64(a.NM.Stats().RoutesAdded)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "neigh2route_routes_removed_total",
+			Help: "Routes removed since startup.",
+		}, func() float64 {
+			return float64(a.NM.Stats().RoutesRemoved)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "neigh2route_ping_failures_total",
+			Help: "Failed neighbor pings since startup.",
+		}, func() float64 {
+			return float64(a.NM.Stats().PingFailures)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "neigh2route_netlink_reconnects_total",
+			Help: "Neighbor update subscription retries since startup.",
+		}, func() float64 {
+			return float64(a.NM.Stats().NetlinkReconnects)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "neigh2route_neighbors_rejected_total",
+			Help: "Neighbors dropped because the table reached --max-neighbors.",
+		}, func() float64 {
+			return float64(a.NM.Stats().NeighborsRejected)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "neigh2route_neighbors_evicted_total",
+			Help: "Neighbors evicted to make room under --max-neighbors.",
+		}, func() float64 {
+			return float64(a.NM.Stats().NeighborsEvicted)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "neigh2route_mac_changes_total",
+			Help: "Known neighbors seen with a different MAC address since startup.",
+		}, func() float64 {
+			return float64(a.NM.Stats().MACChanges)
+		}),
+		routeLatencyCollector{},
+	)
+
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+var routeLatencyDesc = prometheus.NewDesc(
+	"neigh2route_route_operation_duration_seconds",
+	"Duration of kernel route add and delete calls. Quantiles cover the last 1000 calls of each operation.",
+	[]string{"operation"}, nil,
+)
+
+// routeLatencyCollector exposes the netutils route latency samples as
+// summaries.
+type routeLatencyCollector struct{}
+
+func (routeLatencyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- routeLatencyDesc
+}
+
+func (routeLatencyCollector) Collect(ch chan<- prometheus.Metric) {
+	for operation, snap := range map[string]netutils.LatencySnapshot{
+		"add":    netutils.RouteAddLatency(),
+		"remove": netutils.RouteRemoveLatency(),
+	} {
+		ch <- prometheus.MustNewConstSummary(routeLatencyDesc, snap.Count, snap.Sum, snap.Quantiles, operation)
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hostinger/neigh2route/internal/neighbor"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{})
+	api.NM.AddNeighbor(net.ParseIP("10.10.95.1"), 1, parseMAC("02:00:00:00:95:01"))
+	api.NM.AddNeighbor(net.ParseIP("10.10.95.1"), 1, parseMAC("02:00:00:00:95:02"))
+	api.NM.AddNeighbor(net.ParseIP("10.10.95.2"), 1, nil)
+	api.NM.RemoveNeighbor(net.ParseIP("10.10.95.2"), 1)
+	defer api.NM.Cleanup()
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	rr := httptest.NewRecorder()
+
+	api.MetricsHandler().ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{
+		"neigh2route_reachable_neighbors_total 1",
+		"neigh2route_active_sniffers_total 0",
+		"neigh2route_routes_added_total 2",
+		"neigh2route_routes_removed_total 1",
+		"neigh2route_ping_failures_total 0",
+		"neigh2route_netlink_reconnects_total 0",
+		"neigh2route_neighbors_rejected_total 0",
+		"neigh2route_neighbors_evicted_total 0",
+		"neigh2route_mac_changes_total 1",
+		"# TYPE neigh2route_routes_added_total counter",
+		"# TYPE neigh2route_route_operation_duration_seconds summary",
+		`neigh2route_route_operation_duration_seconds{operation="add",quantile="0.99"}`,
+		`neigh2route_route_operation_duration_seconds_count{operation="remove"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in metrics output:\n%s", want, body)
+		}
+	}
+}
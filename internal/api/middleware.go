@@ -0,0 +1,66 @@
+package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+)
+
+const DefaultMaxRequestSize = 1 << 20
+
+func (a *API) maxRequestSize() int64 {
+	if a.MaxRequestSize <= 0 {
+		return DefaultMaxRequestSize
+	}
+	return a.MaxRequestSize
+}
+
+// Handler wraps next with request logging. With LogRequestBody set, up to
+// MaxRequestSize bytes of the body are logged at debug level and put back in
+// front of the remaining body so next can still read all of it. With Token
+// set, every request must carry it as a bearer token.
+func (a *API) Handler(next http.Handler) http.Handler {
+	if a.Token != "" {
+		next = requireBearerToken(a.Token, next.ServeHTTP)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Debug("API request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+
+		if a.LogRequestBody && r.Body != nil && r.Body != http.NoBody {
+			data, err := io.ReadAll(io.LimitReader(r.Body, a.maxRequestSize()))
+			if err != nil {
+				writeErrorResponse(w, http.StatusBadRequest, "invalid_body", "Failed to read request body")
+				return
+			}
+
+			logger.Debug("API request body: %s %s: %s", r.Method, r.URL.Path, data)
+
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewBuffer(data), r.Body), r.Body}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireBearerToken rejects requests whose Authorization header does not
+// carry token as a bearer token.
+func requireBearerToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeErrorResponse(w, http.StatusUnauthorized, "unauthorized", "Missing or invalid bearer token")
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+)
+
+// Helper function to capture debug log output for the duration of a test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	logger.Init(true, logger.FormatText)
+	t.Cleanup(func() {
+		logger.SetOutput(os.Stderr)
+		logger.Init(false, logger.FormatText)
+	})
+	return &buf
+}
+
+func TestHandlerLogsRequestBodyOnce(t *testing.T) {
+	logs := captureLogs(t)
+	body := `{"ips":["10.0.0.1","10.0.0.2"]}`
+
+	var seen string
+	api := &API{LogRequestBody: true}
+	h := api.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		seen = string(data)
+	}))
+
+	req := httptest.NewRequest("POST", "/neighbors/batch-delete", strings.NewReader(body))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if seen != body {
+		t.Errorf("Expected handler to read %q, got %q", body, seen)
+	}
+
+	// The text log handler quotes messages, escaping the JSON body.
+	logged := strings.Trim(strconv.Quote(body), `"`)
+	if n := strings.Count(logs.String(), logged); n != 1 {
+		t.Errorf("Expected body to be logged once, got %d times:\n%s", n, logs.String())
+	}
+}
+
+func TestHandlerLogsBodyUpToMaxRequestSize(t *testing.T) {
+	logs := captureLogs(t)
+	body := strings.Repeat("a", 8) + strings.Repeat("b", 8)
+
+	var seen string
+	api := &API{LogRequestBody: true, MaxRequestSize: 8}
+	h := api.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		seen = string(data)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/self-test", strings.NewReader(body)))
+
+	if seen != body {
+		t.Errorf("Expected handler to read the full body, got %q", seen)
+	}
+
+	if !strings.Contains(logs.String(), strings.Repeat("a", 8)) || strings.Contains(logs.String(), strings.Repeat("a", 8)+"b") {
+		t.Errorf("Expected logged body to be truncated to 8 bytes:\n%s", logs.String())
+	}
+}
+
+func TestHandlerSkipsBodyWhenDisabled(t *testing.T) {
+	logs := captureLogs(t)
+	body := `{"secret":"value"}`
+
+	api := &API{}
+	h := api.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/neighbors/batch-delete", strings.NewReader(body)))
+
+	if strings.Contains(logs.String(), "secret") {
+		t.Errorf("Expected body not to be logged when disabled")
+	}
+}
+
+func TestRequireBearerToken(t *testing.T) {
+	handler := requireBearerToken("s3cret", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	testCases := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"valid", "Bearer s3cret", http.StatusNoContent},
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer wrong", http.StatusUnauthorized},
+		{"wrong scheme", "Basic s3cret", http.StatusUnauthorized},
+		{"token prefix", "Bearer s3cre", http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		req := httptest.NewRequest("GET", "/neighbors", nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		rr := httptest.NewRecorder()
+
+		handler(rr, req)
+
+		if rr.Code != tc.status {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.status, rr.Code)
+		}
+
+		if tc.status != http.StatusUnauthorized {
+			continue
+		}
+
+		var errResp ErrorResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &errResp); err != nil || errResp.Error != "unauthorized" || errResp.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected an unauthorized error response, got %s", tc.name, rr.Body.String())
+		}
+	}
+}
+
+func TestHandlerRequiresTokenWhenSet(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest("GET", "/neighbors", nil)
+	rr := httptest.NewRecorder()
+	(&API{}).Handler(next).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected %d without a configured token, got %d", http.StatusNoContent, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	(&API{Token: "s3cret"}).Handler(next).ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected %d without a bearer token, got %d", http.StatusUnauthorized, rr.Code)
+	}
+}
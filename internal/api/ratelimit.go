@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitedHandler rejects requests with 429 once Limiter runs out of
+// tokens. Each route gets its own limiter so expensive endpoints can be
+// throttled harder than cheap ones. A nil Limiter disables limiting.
+type RateLimitedHandler struct {
+	Handler http.Handler
+	Limiter *rate.Limiter
+
+	// MethodLimiters replace Limiter for requests with their method, so a
+	// route can throttle writes harder than reads.
+	MethodLimiters map[string]*rate.Limiter
+}
+
+func NewRateLimitedHandler(h http.HandlerFunc, perSecond float64, burst int) *RateLimitedHandler {
+	return &RateLimitedHandler{
+		Handler: h,
+		Limiter: rate.NewLimiter(rate.Limit(perSecond), burst),
+	}
+}
+
+// WithMethodLimit limits requests with method separately from the other
+// methods of the route.
+func (h *RateLimitedHandler) WithMethodLimit(method string, perSecond float64, burst int) *RateLimitedHandler {
+	if h.MethodLimiters == nil {
+		h.MethodLimiters = make(map[string]*rate.Limiter)
+	}
+	h.MethodLimiters[method] = rate.NewLimiter(rate.Limit(perSecond), burst)
+	return h
+}
+
+func (h *RateLimitedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	limiter := h.Limiter
+	if methodLimiter, ok := h.MethodLimiters[r.Method]; ok {
+		limiter = methodLimiter
+	}
+
+	if limiter != nil && !limiter.Allow() {
+		w.Header().Set("Retry-After", "1")
+		writeErrorResponse(w, http.StatusTooManyRequests, "rate_limited", "Too many requests, retry later")
+		return
+	}
+
+	h.Handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitedHandlerExhaustsBurst(t *testing.T) {
+	calls := 0
+	h := NewRateLimitedHandler(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusOK)
+	}, 0.001, 2)
+
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/neighbors/10.0.0.1/traceroute", nil))
+		codes = append(codes, rr.Code)
+
+		if i == 2 {
+			var errorResponse ErrorResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &errorResponse); err != nil {
+				t.Fatalf("Could not unmarshal error response: %v", err)
+			}
+			if errorResponse.Error != "rate_limited" {
+				t.Errorf("Expected error 'rate_limited', got %s", errorResponse.Error)
+			}
+			if rr.Header().Get("Retry-After") == "" {
+				t.Errorf("Expected Retry-After header on 429")
+			}
+		}
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("Expected [200 200 429], got %v", codes)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected wrapped handler to run twice, got %d", calls)
+	}
+}
+
+func TestRateLimitedHandlerNilLimiter(t *testing.T) {
+	h := &RateLimitedHandler{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})}
+
+	for i := 0; i < 10; i++ {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/neighbors", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200 without a limiter, got %d", rr.Code)
+		}
+	}
+}
+
+func TestRateLimitedHandlerMethodLimit(t *testing.T) {
+	h := NewRateLimitedHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, 0.001, 3).WithMethodLimit(http.MethodDelete, 0.001, 1)
+
+	serve := func(method string) int {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, "/neighbors/10.0.0.1", nil))
+		return rr.Code
+	}
+
+	if code := serve("DELETE"); code != http.StatusOK {
+		t.Errorf("Expected the first DELETE to pass, got %d", code)
+	}
+	if code := serve("DELETE"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the second DELETE to be limited, got %d", code)
+	}
+	for i := 0; i < 3; i++ {
+		if code := serve("GET"); code != http.StatusOK {
+			t.Errorf("Expected GET %d to use its own limit, got %d", i+1, code)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxSnapshots = 5
+	DefaultSnapshotTTL  = 10 * time.Minute
+)
+
+// NeighborSnapshot is a copy of the neighbor table taken by
+// /neighbors/snapshot, keyed like the manager's table.
+type NeighborSnapshot struct {
+	ID        string
+	Taken     time.Time
+	Neighbors map[string]NeighborView
+}
+
+// SnapshotStore keeps the most recent neighbor snapshots in memory. Once it
+// holds max snapshots the oldest is evicted, and snapshots older than ttl
+// expire.
+type SnapshotStore struct {
+	max int
+	ttl time.Duration
+
+	mu        sync.Mutex
+	seq       uint64
+	snapshots []NeighborSnapshot
+}
+
+// NewSnapshotStore returns a store of at most max snapshots that expire after
+// ttl. Non-positive values use DefaultMaxSnapshots and DefaultSnapshotTTL.
+func NewSnapshotStore(max int, ttl time.Duration) *SnapshotStore {
+	if max <= 0 {
+		max = DefaultMaxSnapshots
+	}
+	if ttl <= 0 {
+		ttl = DefaultSnapshotTTL
+	}
+	return &SnapshotStore{max: max, ttl: ttl}
+}
+
+// Add stores neighbors as a new snapshot and returns it.
+func (s *SnapshotStore) Add(neighbors map[string]NeighborView) NeighborSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.expireLocked(now)
+
+	s.seq++
+	snap := NeighborSnapshot{
+		ID:        strconv.FormatUint(s.seq, 10),
+		Taken:     now,
+		Neighbors: neighbors,
+	}
+	s.snapshots = append(s.snapshots, snap)
+	if len(s.snapshots) > s.max {
+		s.snapshots = s.snapshots[len(s.snapshots)-s.max:]
+	}
+	return snap
+}
+
+// Get returns the snapshot with id, unless it was evicted or expired.
+func (s *SnapshotStore) Get(id string) (NeighborSnapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(time.Now())
+	for _, snap := range s.snapshots {
+		if snap.ID == id {
+			return snap, true
+		}
+	}
+	return NeighborSnapshot{}, false
+}
+
+// expireLocked drops the snapshots taken more than ttl before now. Snapshots
+// are kept in the order they were taken.
+func (s *SnapshotStore) expireLocked(now time.Time) {
+	i := 0
+	for i < len(s.snapshots) && now.Sub(s.snapshots[i].Taken) > s.ttl {
+		i++
+	}
+	s.snapshots = s.snapshots[i:]
+}
+
+// TTL returns how long a snapshot is kept.
+func (s *SnapshotStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// NeighborChange is a neighbor present in both snapshots whose link or MAC
+// changed between them.
+type NeighborChange struct {
+	IP   string       `json:"ip"`
+	From NeighborView `json:"from"`
+	To   NeighborView `json:"to"`
+}
+
+// NeighborDiff lists what changed in the neighbor table between two
+// snapshots.
+type NeighborDiff struct {
+	Added   []NeighborView   `json:"added"`
+	Removed []NeighborView   `json:"removed"`
+	Changed []NeighborChange `json:"changed"`
+}
+
+// diffSnapshots compares the neighbors of from and to. A neighbor counts as
+// changed when its link index or hardware address differs.
+func diffSnapshots(from, to NeighborSnapshot) NeighborDiff {
+	diff := NeighborDiff{
+		Added:   []NeighborView{},
+		Removed: []NeighborView{},
+		Changed: []NeighborChange{},
+	}
+
+	for key, after := range to.Neighbors {
+		before, ok := from.Neighbors[key]
+		if !ok {
+			diff.Added = append(diff.Added, after)
+			continue
+		}
+		if before.LinkIndex != after.LinkIndex || before.HardwareAddr != after.HardwareAddr {
+			diff.Changed = append(diff.Changed, NeighborChange{IP: after.IP, From: before, To: after})
+		}
+	}
+	for key, before := range from.Neighbors {
+		if _, ok := to.Neighbors[key]; !ok {
+			diff.Removed = append(diff.Removed, before)
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return lessIP(diff.Added[i], diff.Added[j]) })
+	sort.Slice(diff.Removed, func(i, j int) bool { return lessIP(diff.Removed[i], diff.Removed[j]) })
+	sort.Slice(diff.Changed, func(i, j int) bool { return lessIP(diff.Changed[i].To, diff.Changed[j].To) })
+	return diff
+}
+
+func (a *API) snapshotStore() *SnapshotStore {
+	a.snapshotOnce.Do(func() {
+		if a.Snapshots == nil {
+			a.Snapshots = NewSnapshotStore(DefaultMaxSnapshots, DefaultSnapshotTTL)
+		}
+	})
+	return a.Snapshots
+}
+
+// NeighborSnapshotHandler serves GET /neighbors/snapshot, storing a copy of
+// the neighbor table for a later /neighbors/diff.
+func (a *API) NeighborSnapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only GET method is allowed")
+		return
+	}
+
+	type SnapshotResponse struct {
+		ID        string    `json:"id"`
+		Count     int       `json:"count"`
+		Timestamp time.Time `json:"timestamp"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	neighbors := a.NM.ListNeighbors()
+	views := make(map[string]NeighborView, len(neighbors))
+	for key, n := range neighbors {
+		views[key] = newNeighborView(n)
+	}
+
+	store := a.snapshotStore()
+	snap := store.Add(views)
+
+	writeJSONResponse(w, SnapshotResponse{
+		ID:        snap.ID,
+		Count:     len(snap.Neighbors),
+		Timestamp: snap.Taken,
+		ExpiresAt: snap.Taken.Add(store.TTL()),
+	})
+}
+
+// NeighborDiffHandler serves GET /neighbors/diff?from=<id>&to=<id>,
+// comparing two snapshots taken by /neighbors/snapshot.
+func (a *API) NeighborDiffHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "method_not_allowed", "Only GET method is allowed")
+		return
+	}
+
+	fromID, toID := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if fromID == "" || toID == "" {
+		writeErrorResponse(w, http.StatusBadRequest, "missing_snapshot", "Both from and to snapshot IDs are required")
+		return
+	}
+
+	store := a.snapshotStore()
+	from, ok := store.Get(fromID)
+	if !ok {
+		writeErrorResponse(w, http.StatusNotFound, "snapshot_not_found", "Snapshot "+fromID+" not found or expired")
+		return
+	}
+	to, ok := store.Get(toID)
+	if !ok {
+		writeErrorResponse(w, http.StatusNotFound, "snapshot_not_found", "Snapshot "+toID+" not found or expired")
+		return
+	}
+
+	writeJSONResponse(w, diffSnapshots(from, to))
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/neighbor"
+)
+
+func takeSnapshot(t *testing.T, api *API) string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	api.NeighborSnapshotHandler(rr, httptest.NewRequest("GET", "/neighbors/snapshot", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	return body.ID
+}
+
+func TestNeighborDiffHandler(t *testing.T) {
+	api := createAPIWithNeighbors(map[string]neighbor.Neighbor{
+		"192.168.1.10": {IP: net.ParseIP("192.168.1.10"), LinkIndex: 2, HardwareAddr: parseMAC("00:11:22:33:44:55")},
+		"192.168.1.20": {IP: net.ParseIP("192.168.1.20"), LinkIndex: 2, HardwareAddr: parseMAC("00:11:22:33:44:66")},
+		"192.168.1.30": {IP: net.ParseIP("192.168.1.30"), LinkIndex: 2, HardwareAddr: parseMAC("00:11:22:33:44:77")},
+	})
+	from := takeSnapshot(t, api)
+
+	delete(api.NM.ReachableNeighbors, "192.168.1.20")
+	api.NM.ReachableNeighbors["192.168.1.30"] = neighbor.Neighbor{IP: net.ParseIP("192.168.1.30"), LinkIndex: 3, HardwareAddr: parseMAC("00:11:22:33:44:77")}
+	api.NM.ReachableNeighbors["192.168.1.40"] = neighbor.Neighbor{IP: net.ParseIP("192.168.1.40"), LinkIndex: 2, HardwareAddr: parseMAC("00:11:22:33:44:88")}
+	to := takeSnapshot(t, api)
+
+	rr := httptest.NewRecorder()
+	api.NeighborDiffHandler(rr, httptest.NewRequest("GET", "/neighbors/diff?from="+from+"&to="+to, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var diff NeighborDiff
+	if err := json.Unmarshal(rr.Body.Bytes(), &diff); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].IP != "192.168.1.40" {
+		t.Errorf("Expected 192.168.1.40 added, got %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].IP != "192.168.1.20" {
+		t.Errorf("Expected 192.168.1.20 removed, got %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].IP != "192.168.1.30" ||
+		diff.Changed[0].From.LinkIndex != 2 || diff.Changed[0].To.LinkIndex != 3 {
+		t.Errorf("Expected 192.168.1.30 moved from link 2 to 3, got %+v", diff.Changed)
+	}
+}
+
+func TestNeighborDiffHandler_Errors(t *testing.T) {
+	api := createAPIWithNeighbors(nil)
+	id := takeSnapshot(t, api)
+
+	tests := []struct {
+		query string
+		code  int
+	}{
+		{"from=" + id, http.StatusBadRequest},
+		{"from=" + id + "&to=999", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		api.NeighborDiffHandler(rr, httptest.NewRequest("GET", "/neighbors/diff?"+tt.query, nil))
+		if rr.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.query, tt.code, rr.Code)
+		}
+	}
+}
+
+func TestSnapshotStoreEvictsAndExpires(t *testing.T) {
+	store := NewSnapshotStore(2, time.Minute)
+	first := store.Add(nil)
+	store.Add(nil)
+	last := store.Add(nil)
+
+	if _, ok := store.Get(first.ID); ok {
+		t.Errorf("Expected the oldest snapshot to be evicted")
+	}
+	if _, ok := store.Get(last.ID); !ok {
+		t.Errorf("Expected the latest snapshot to be kept")
+	}
+
+	store = NewSnapshotStore(2, time.Nanosecond)
+	snap := store.Add(nil)
+	time.Sleep(time.Millisecond)
+	if _, ok := store.Get(snap.ID); ok {
+		t.Errorf("Expected the snapshot to expire")
+	}
+}
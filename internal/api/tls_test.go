@@ -0,0 +1,136 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Helper function to issue a certificate, self-signed when parent is nil
+func issueCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+func TestNewTLSConfigWithoutClientCA(t *testing.T) {
+	cfg, err := NewTLSConfig("")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.ClientAuth != tls.NoClientCert {
+		t.Errorf("Expected no client authentication, got %v", cfg.ClientAuth)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected TLS request to succeed, got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.TLS == nil || resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected a %d response over TLS, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+}
+
+func TestNewTLSConfigRequiresClientCert(t *testing.T) {
+	ca, caKey := issueCert(t, "neigh2route-test-ca", nil, nil)
+	client, clientKey := issueCert(t, "neigh2route-client", ca, caKey)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0o600); err != nil {
+		t.Fatalf("failed to write CA: %v", err)
+	}
+
+	cfg, err := NewTLSConfig(caFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	srv.TLS.ClientCAs = cfg.ClientCAs
+	srv.TLS.ClientAuth = cfg.ClientAuth
+
+	if resp, err := srv.Client().Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Errorf("Expected a request without a client certificate to fail, got %d", resp.StatusCode)
+	}
+
+	withCert := srv.Client()
+	withCert.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{{
+		Certificate: [][]byte{client.Raw},
+		PrivateKey:  clientKey,
+	}}
+	withCert.Transport.(*http.Transport).CloseIdleConnections()
+
+	resp, err := withCert.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected a request with a client certificate to succeed, got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected %d, got %d", http.StatusNoContent, resp.StatusCode)
+	}
+}
+
+func TestNewTLSConfigInvalidClientCA(t *testing.T) {
+	if _, err := NewTLSConfig(filepath.Join(t.TempDir(), "missing.pem")); err == nil {
+		t.Error("Expected an error for a missing client CA")
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("failed to write CA: %v", err)
+	}
+	if _, err := NewTLSConfig(empty); err == nil {
+		t.Error("Expected an error for a client CA without certificates")
+	}
+}
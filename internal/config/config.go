@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is the YAML file given with --config. Absent keys leave the current
+// setting unchanged; an empty prefixes list clears the prefix filter.
+//
+// debug, ping_interval, route_metric and prefixes are applied on SIGHUP.
+// interface and port only take effect on restart.
+type File struct {
+	Debug        *bool          `yaml:"debug"`
+	PingInterval *time.Duration `yaml:"ping_interval"`
+	RouteMetric  *uint32        `yaml:"route_metric"`
+	Prefixes     []string       `yaml:"prefixes"`
+
+	Interface []string `yaml:"interface"`
+	Port      string   `yaml:"port"`
+}
+
+// Load reads and validates the config file at path.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f File
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if f.PingInterval != nil && *f.PingInterval <= 0 {
+		return nil, fmt.Errorf("ping_interval must be positive, got %s", *f.PingInterval)
+	}
+
+	if _, err := f.ParsePrefixes(); err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// ParsePrefixes returns the prefixes as networks. It returns nil when the
+// file does not set prefixes.
+func (f *File) ParsePrefixes() ([]*net.IPNet, error) {
+	if f.Prefixes == nil {
+		return nil, nil
+	}
+
+	prefixes := make([]*net.IPNet, 0, len(f.Prefixes))
+	for _, value := range f.Prefixes {
+		_, prefix, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %q: %w", value, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
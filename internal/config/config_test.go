@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "neigh2route.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+debug: true
+ping_interval: 45s
+route_metric: 200
+prefixes:
+  - 10.0.0.0/8
+  - 2001:db8::/32
+interface: [eth1]
+port: 127.0.0.1:9000
+`)
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if f.Debug == nil || !*f.Debug {
+		t.Errorf("Expected debug to be true, got %v", f.Debug)
+	}
+	if f.PingInterval == nil || *f.PingInterval != 45*time.Second {
+		t.Errorf("Expected ping_interval 45s, got %v", f.PingInterval)
+	}
+	if f.RouteMetric == nil || *f.RouteMetric != 200 {
+		t.Errorf("Expected route_metric 200, got %v", f.RouteMetric)
+	}
+
+	prefixes, err := f.ParsePrefixes()
+	if err != nil || len(prefixes) != 2 || prefixes[1].String() != "2001:db8::/32" {
+		t.Errorf("Expected two prefixes, got %v, %v", prefixes, err)
+	}
+
+	if len(f.Interface) != 1 || f.Interface[0] != "eth1" || f.Port != "127.0.0.1:9000" {
+		t.Errorf("Expected interface eth1 and port 127.0.0.1:9000, got %v %q", f.Interface, f.Port)
+	}
+}
+
+func TestLoadAbsentKeys(t *testing.T) {
+	f, err := Load(writeConfig(t, "debug: false\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if f.PingInterval != nil || f.RouteMetric != nil || f.Prefixes != nil {
+		t.Errorf("Expected unset settings to stay nil, got %+v", f)
+	}
+
+	f, err = Load(writeConfig(t, "prefixes: []\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if prefixes, _ := f.ParsePrefixes(); prefixes == nil || len(prefixes) != 0 {
+		t.Errorf("Expected an empty prefix list to clear the filter, got %v", prefixes)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"syntax":        "debug: [",
+		"prefix":        "prefixes: [not-a-prefix]\n",
+		"ping_interval": "ping_interval: 0s\n",
+		"route_metric":  "route_metric: -1\n",
+	} {
+		if _, err := Load(writeConfig(t, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
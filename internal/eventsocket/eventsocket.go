@@ -0,0 +1,183 @@
+package eventsocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+	"github.com/hostinger/neigh2route/internal/neighbor"
+)
+
+// clientBuffer is how many encoded events may queue for one client before
+// further events for it are dropped.
+const clientBuffer = 256
+
+type watcher interface {
+	WatchNeighbors() (<-chan neighbor.Event, func())
+}
+
+type eventLine struct {
+	Type      neighbor.EventType `json:"type"`
+	IP        string             `json:"ip"`
+	LinkIndex int                `json:"link_index"`
+	Timestamp time.Time          `json:"ts"`
+}
+
+// Server writes every neighbor add/remove event as a line of JSON to each
+// client connected to a Unix stream socket at Path.
+type Server struct {
+	Path   string
+	Source watcher
+
+	mu      sync.Mutex
+	clients map[net.Conn]chan []byte
+	wg      sync.WaitGroup
+}
+
+func New(path string, source watcher) *Server {
+	return &Server{
+		Path:    path,
+		Source:  source,
+		clients: make(map[net.Conn]chan []byte),
+	}
+}
+
+// Run listens on Path and streams events until ctx is cancelled, then
+// disconnects every client and removes the socket file.
+func (s *Server) Run(ctx context.Context) error {
+	if err := os.Remove(s.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	ln, err := net.Listen("unix", s.Path)
+	if err != nil {
+		return err
+	}
+
+	events, unsubscribe := s.Source.WatchNeighbors()
+	defer unsubscribe()
+
+	logger.Info("Streaming neighbor events on %s", s.Path)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.accept(ln)
+	}()
+
+	defer func() {
+		ln.Close()
+		s.closeAll()
+		s.wg.Wait()
+		os.Remove(s.Path)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			s.broadcast(e)
+		}
+	}
+}
+
+func (s *Server) accept(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logger.Error("Failed to accept event socket client: %v", err)
+			}
+			return
+		}
+
+		queue := make(chan []byte, clientBuffer)
+		s.mu.Lock()
+		s.clients[conn] = queue
+		s.mu.Unlock()
+
+		logger.Debug("Event socket client connected")
+
+		s.wg.Add(2)
+		go func() {
+			defer s.wg.Done()
+			s.serve(conn, queue)
+		}()
+		// Clients only read; EOF here means the client went away.
+		go func() {
+			defer s.wg.Done()
+			io.Copy(io.Discard, conn)
+			s.remove(conn)
+		}()
+	}
+}
+
+// serve writes queued events to conn until the queue is closed or a write
+// fails.
+func (s *Server) serve(conn net.Conn, queue <-chan []byte) {
+	defer s.remove(conn)
+
+	for line := range queue {
+		if _, err := conn.Write(line); err != nil {
+			logger.Debug("Event socket client disconnected: %v", err)
+			return
+		}
+	}
+}
+
+func (s *Server) remove(conn net.Conn) {
+	s.mu.Lock()
+	if queue, ok := s.clients[conn]; ok {
+		delete(s.clients, conn)
+		close(queue)
+	}
+	s.mu.Unlock()
+
+	conn.Close()
+}
+
+func (s *Server) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn, queue := range s.clients {
+		delete(s.clients, conn)
+		close(queue)
+		conn.Close()
+	}
+}
+
+func (s *Server) broadcast(e neighbor.Event) {
+	line, err := json.Marshal(eventLine{
+		Type:      e.Type,
+		IP:        e.Neighbor.IP.String(),
+		LinkIndex: e.Neighbor.LinkIndex,
+		Timestamp: e.Timestamp,
+	})
+	if err != nil {
+		logger.Error("Failed to encode neighbor event: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, queue := range s.clients {
+		select {
+		case queue <- line:
+		default:
+			logger.Warn("Event socket client too slow, dropping %s event for %s", e.Type, e.Neighbor.IP)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package eventsocket
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/neighbor"
+)
+
+type fakeSource struct {
+	events chan neighbor.Event
+}
+
+func (f *fakeSource) WatchNeighbors() (<-chan neighbor.Event, func()) {
+	return f.events, func() {}
+}
+
+// Helper function to start a server and wait until its socket accepts clients
+func startServer(t *testing.T) (*Server, *fakeSource, string, context.CancelFunc, chan error) {
+	path := filepath.Join(t.TempDir(), "events.sock")
+	src := &fakeSource{events: make(chan neighbor.Event)}
+	s := New(path, src)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("socket %s was not created", path)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return s, src, path, cancel, done
+}
+
+func waitClients(t *testing.T, s *Server, n int) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		got := len(s.clients)
+		s.mu.Unlock()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d clients, got %d", n, got)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServerStreamsEventsToClients(t *testing.T) {
+	s, src, path, cancel, done := startServer(t)
+	defer cancel()
+
+	var readers []*bufio.Reader
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		defer conn.Close()
+		readers = append(readers, bufio.NewReader(conn))
+	}
+	waitClients(t, s, 2)
+
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	src.events <- neighbor.Event{
+		Type:      neighbor.EventAdd,
+		Neighbor:  neighbor.Neighbor{IP: net.ParseIP("10.0.0.1"), LinkIndex: 3},
+		Timestamp: ts,
+	}
+
+	for i, r := range readers {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("client %d: failed to read event: %v", i, err)
+		}
+
+		var got map[string]interface{}
+		if err := json.Unmarshal(line, &got); err != nil {
+			t.Fatalf("client %d: invalid JSON %q: %v", i, line, err)
+		}
+		if got["type"] != "add" || got["ip"] != "10.0.0.1" || got["link_index"] != float64(3) || got["ts"] != "2024-01-02T03:04:05Z" {
+			t.Errorf("client %d: unexpected event %v", i, got)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected clean shutdown, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected socket file to be removed, got %v", err)
+	}
+}
+
+func TestServerRemovesDisconnectedClients(t *testing.T) {
+	s, _, path, cancel, _ := startServer(t)
+	defer cancel()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	waitClients(t, s, 1)
+
+	conn.Close()
+	waitClients(t, s, 0)
+}
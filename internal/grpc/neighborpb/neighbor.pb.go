@@ -0,0 +1,609 @@
+// NeighborService mirrors the read side of the HTTP API: GET /neighbors and
+// GET /neighbors/watch. Generate the Go code with `make proto`.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v5.27.1
+// source: internal/grpc/neighbor.proto
+
+package neighborpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type NeighborEvent_Type int32
+
+const (
+	NeighborEvent_TYPE_UNSPECIFIED NeighborEvent_Type = 0
+	NeighborEvent_TYPE_ADD         NeighborEvent_Type = 1
+	NeighborEvent_TYPE_REMOVE      NeighborEvent_Type = 2
+)
+
+// Enum value maps for NeighborEvent_Type.
+var (
+	NeighborEvent_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "TYPE_ADD",
+		2: "TYPE_REMOVE",
+	}
+	NeighborEvent_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"TYPE_ADD":         1,
+		"TYPE_REMOVE":      2,
+	}
+)
+
+func (x NeighborEvent_Type) Enum() *NeighborEvent_Type {
+	p := new(NeighborEvent_Type)
+	*p = x
+	return p
+}
+
+func (x NeighborEvent_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (NeighborEvent_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_internal_grpc_neighbor_proto_enumTypes[0].Descriptor()
+}
+
+func (NeighborEvent_Type) Type() protoreflect.EnumType {
+	return &file_internal_grpc_neighbor_proto_enumTypes[0]
+}
+
+func (x NeighborEvent_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use NeighborEvent_Type.Descriptor instead.
+func (NeighborEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return file_internal_grpc_neighbor_proto_rawDescGZIP(), []int{4, 0}
+}
+
+type Neighbor struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ip        string `protobuf:"bytes,1,opt,name=ip,proto3" json:"ip,omitempty"`
+	LinkIndex int32  `protobuf:"varint,2,opt,name=link_index,json=linkIndex,proto3" json:"link_index,omitempty"`
+	Interface string `protobuf:"bytes,3,opt,name=interface,proto3" json:"interface,omitempty"`
+	HwAddr    string `protobuf:"bytes,4,opt,name=hw_addr,json=hwAddr,proto3" json:"hw_addr,omitempty"`
+	// "v4" or "v6".
+	Afi           string                 `protobuf:"bytes,5,opt,name=afi,proto3" json:"afi,omitempty"`
+	Permanent     bool                   `protobuf:"varint,6,opt,name=permanent,proto3" json:"permanent,omitempty"`
+	PingTimeoutMs int64                  `protobuf:"varint,7,opt,name=ping_timeout_ms,json=pingTimeoutMs,proto3" json:"ping_timeout_ms,omitempty"`
+	FirstSeen     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=first_seen,json=firstSeen,proto3" json:"first_seen,omitempty"`
+	LastUpdated   *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=last_updated,json=lastUpdated,proto3" json:"last_updated,omitempty"`
+}
+
+func (x *Neighbor) Reset() {
+	*x = Neighbor{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_grpc_neighbor_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Neighbor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Neighbor) ProtoMessage() {}
+
+func (x *Neighbor) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpc_neighbor_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Neighbor.ProtoReflect.Descriptor instead.
+func (*Neighbor) Descriptor() ([]byte, []int) {
+	return file_internal_grpc_neighbor_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Neighbor) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *Neighbor) GetLinkIndex() int32 {
+	if x != nil {
+		return x.LinkIndex
+	}
+	return 0
+}
+
+func (x *Neighbor) GetInterface() string {
+	if x != nil {
+		return x.Interface
+	}
+	return ""
+}
+
+func (x *Neighbor) GetHwAddr() string {
+	if x != nil {
+		return x.HwAddr
+	}
+	return ""
+}
+
+func (x *Neighbor) GetAfi() string {
+	if x != nil {
+		return x.Afi
+	}
+	return ""
+}
+
+func (x *Neighbor) GetPermanent() bool {
+	if x != nil {
+		return x.Permanent
+	}
+	return false
+}
+
+func (x *Neighbor) GetPingTimeoutMs() int64 {
+	if x != nil {
+		return x.PingTimeoutMs
+	}
+	return 0
+}
+
+func (x *Neighbor) GetFirstSeen() *timestamppb.Timestamp {
+	if x != nil {
+		return x.FirstSeen
+	}
+	return nil
+}
+
+func (x *Neighbor) GetLastUpdated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastUpdated
+	}
+	return nil
+}
+
+type ListNeighborsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Optional filters, as the query parameters of GET /neighbors.
+	Afi           string `protobuf:"bytes,1,opt,name=afi,proto3" json:"afi,omitempty"`
+	PermanentOnly bool   `protobuf:"varint,2,opt,name=permanent_only,json=permanentOnly,proto3" json:"permanent_only,omitempty"`
+}
+
+func (x *ListNeighborsRequest) Reset() {
+	*x = ListNeighborsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_grpc_neighbor_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListNeighborsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListNeighborsRequest) ProtoMessage() {}
+
+func (x *ListNeighborsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpc_neighbor_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListNeighborsRequest.ProtoReflect.Descriptor instead.
+func (*ListNeighborsRequest) Descriptor() ([]byte, []int) {
+	return file_internal_grpc_neighbor_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListNeighborsRequest) GetAfi() string {
+	if x != nil {
+		return x.Afi
+	}
+	return ""
+}
+
+func (x *ListNeighborsRequest) GetPermanentOnly() bool {
+	if x != nil {
+		return x.PermanentOnly
+	}
+	return false
+}
+
+type ListNeighborsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Neighbors []*Neighbor `protobuf:"bytes,1,rep,name=neighbors,proto3" json:"neighbors,omitempty"`
+}
+
+func (x *ListNeighborsResponse) Reset() {
+	*x = ListNeighborsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_grpc_neighbor_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListNeighborsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListNeighborsResponse) ProtoMessage() {}
+
+func (x *ListNeighborsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpc_neighbor_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListNeighborsResponse.ProtoReflect.Descriptor instead.
+func (*ListNeighborsResponse) Descriptor() ([]byte, []int) {
+	return file_internal_grpc_neighbor_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListNeighborsResponse) GetNeighbors() []*Neighbor {
+	if x != nil {
+		return x.Neighbors
+	}
+	return nil
+}
+
+type WatchRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_grpc_neighbor_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpc_neighbor_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_internal_grpc_neighbor_proto_rawDescGZIP(), []int{3}
+}
+
+type NeighborEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type      NeighborEvent_Type     `protobuf:"varint,1,opt,name=type,proto3,enum=neigh2route.v1.NeighborEvent_Type" json:"type,omitempty"`
+	Ip        string                 `protobuf:"bytes,2,opt,name=ip,proto3" json:"ip,omitempty"`
+	LinkIndex int32                  `protobuf:"varint,3,opt,name=link_index,json=linkIndex,proto3" json:"link_index,omitempty"`
+	HwAddr    string                 `protobuf:"bytes,4,opt,name=hw_addr,json=hwAddr,proto3" json:"hw_addr,omitempty"`
+	Timestamp *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (x *NeighborEvent) Reset() {
+	*x = NeighborEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_internal_grpc_neighbor_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NeighborEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NeighborEvent) ProtoMessage() {}
+
+func (x *NeighborEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpc_neighbor_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NeighborEvent.ProtoReflect.Descriptor instead.
+func (*NeighborEvent) Descriptor() ([]byte, []int) {
+	return file_internal_grpc_neighbor_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *NeighborEvent) GetType() NeighborEvent_Type {
+	if x != nil {
+		return x.Type
+	}
+	return NeighborEvent_TYPE_UNSPECIFIED
+}
+
+func (x *NeighborEvent) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *NeighborEvent) GetLinkIndex() int32 {
+	if x != nil {
+		return x.LinkIndex
+	}
+	return 0
+}
+
+func (x *NeighborEvent) GetHwAddr() string {
+	if x != nil {
+		return x.HwAddr
+	}
+	return ""
+}
+
+func (x *NeighborEvent) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+var File_internal_grpc_neighbor_proto protoreflect.FileDescriptor
+
+var file_internal_grpc_neighbor_proto_rawDesc = []byte{
+	0x0a, 0x1c, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x2f,
+	0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0e,
+	0x6e, 0x65, 0x69, 0x67, 0x68, 0x32, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x1a, 0x1f,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f,
+	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
+	0xc2, 0x02, 0x0a, 0x08, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x12, 0x0e, 0x0a, 0x02,
+	0x69, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x70, 0x12, 0x1d, 0x0a, 0x0a,
+	0x6c, 0x69, 0x6e, 0x6b, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x09, 0x6c, 0x69, 0x6e, 0x6b, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x1c, 0x0a, 0x09, 0x69,
+	0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x12, 0x17, 0x0a, 0x07, 0x68, 0x77, 0x5f,
+	0x61, 0x64, 0x64, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x68, 0x77, 0x41, 0x64,
+	0x64, 0x72, 0x12, 0x10, 0x0a, 0x03, 0x61, 0x66, 0x69, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x61, 0x66, 0x69, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x65, 0x72, 0x6d, 0x61, 0x6e, 0x65, 0x6e,
+	0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x70, 0x65, 0x72, 0x6d, 0x61, 0x6e, 0x65,
+	0x6e, 0x74, 0x12, 0x26, 0x0a, 0x0f, 0x70, 0x69, 0x6e, 0x67, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x6f,
+	0x75, 0x74, 0x5f, 0x6d, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x70, 0x69, 0x6e,
+	0x67, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x4d, 0x73, 0x12, 0x39, 0x0a, 0x0a, 0x66, 0x69,
+	0x72, 0x73, 0x74, 0x5f, 0x73, 0x65, 0x65, 0x6e, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x66, 0x69, 0x72, 0x73,
+	0x74, 0x53, 0x65, 0x65, 0x6e, 0x12, 0x3d, 0x0a, 0x0c, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x75, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0b, 0x6c, 0x61, 0x73, 0x74, 0x55, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x64, 0x22, 0x4f, 0x0a, 0x14, 0x4c, 0x69, 0x73, 0x74, 0x4e, 0x65, 0x69, 0x67,
+	0x68, 0x62, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10, 0x0a, 0x03,
+	0x61, 0x66, 0x69, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x61, 0x66, 0x69, 0x12, 0x25,
+	0x0a, 0x0e, 0x70, 0x65, 0x72, 0x6d, 0x61, 0x6e, 0x65, 0x6e, 0x74, 0x5f, 0x6f, 0x6e, 0x6c, 0x79,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0d, 0x70, 0x65, 0x72, 0x6d, 0x61, 0x6e, 0x65, 0x6e,
+	0x74, 0x4f, 0x6e, 0x6c, 0x79, 0x22, 0x4f, 0x0a, 0x15, 0x4c, 0x69, 0x73, 0x74, 0x4e, 0x65, 0x69,
+	0x67, 0x68, 0x62, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x36,
+	0x0a, 0x09, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x18, 0x2e, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x32, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x2e,
+	0x76, 0x31, 0x2e, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x52, 0x09, 0x6e, 0x65, 0x69,
+	0x67, 0x68, 0x62, 0x6f, 0x72, 0x73, 0x22, 0x0e, 0x0a, 0x0c, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x86, 0x02, 0x0a, 0x0d, 0x4e, 0x65, 0x69, 0x67, 0x68,
+	0x62, 0x6f, 0x72, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x36, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x22, 0x2e, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x32, 0x72,
+	0x6f, 0x75, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72,
+	0x45, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65,
+	0x12, 0x0e, 0x0a, 0x02, 0x69, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x70,
+	0x12, 0x1d, 0x0a, 0x0a, 0x6c, 0x69, 0x6e, 0x6b, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x09, 0x6c, 0x69, 0x6e, 0x6b, 0x49, 0x6e, 0x64, 0x65, 0x78, 0x12,
+	0x17, 0x0a, 0x07, 0x68, 0x77, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x68, 0x77, 0x41, 0x64, 0x64, 0x72, 0x12, 0x38, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x22, 0x3b, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x14, 0x0a, 0x10, 0x54, 0x59,
+	0x50, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00,
+	0x12, 0x0c, 0x0a, 0x08, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x41, 0x44, 0x44, 0x10, 0x01, 0x12, 0x0f,
+	0x0a, 0x0b, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x52, 0x45, 0x4d, 0x4f, 0x56, 0x45, 0x10, 0x02, 0x32,
+	0xc0, 0x01, 0x0a, 0x0f, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x53, 0x65, 0x72, 0x76,
+	0x69, 0x63, 0x65, 0x12, 0x5c, 0x0a, 0x0d, 0x4c, 0x69, 0x73, 0x74, 0x4e, 0x65, 0x69, 0x67, 0x68,
+	0x62, 0x6f, 0x72, 0x73, 0x12, 0x24, 0x2e, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x32, 0x72, 0x6f, 0x75,
+	0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62,
+	0x6f, 0x72, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x25, 0x2e, 0x6e, 0x65, 0x69,
+	0x67, 0x68, 0x32, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74,
+	0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x4f, 0x0a, 0x0e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62,
+	0x6f, 0x72, 0x73, 0x12, 0x1c, 0x2e, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x32, 0x72, 0x6f, 0x75, 0x74,
+	0x65, 0x2e, 0x76, 0x31, 0x2e, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1d, 0x2e, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x32, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x2e,
+	0x76, 0x31, 0x2e, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x45, 0x76, 0x65, 0x6e, 0x74,
+	0x30, 0x01, 0x42, 0x3b, 0x5a, 0x39, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2f, 0x68, 0x6f, 0x73, 0x74, 0x69, 0x6e, 0x67, 0x65, 0x72, 0x2f, 0x6e, 0x65, 0x69, 0x67, 0x68,
+	0x32, 0x72, 0x6f, 0x75, 0x74, 0x65, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f,
+	0x67, 0x72, 0x70, 0x63, 0x2f, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x70, 0x62, 0x62,
+	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_internal_grpc_neighbor_proto_rawDescOnce sync.Once
+	file_internal_grpc_neighbor_proto_rawDescData = file_internal_grpc_neighbor_proto_rawDesc
+)
+
+func file_internal_grpc_neighbor_proto_rawDescGZIP() []byte {
+	file_internal_grpc_neighbor_proto_rawDescOnce.Do(func() {
+		file_internal_grpc_neighbor_proto_rawDescData = protoimpl.X.CompressGZIP(file_internal_grpc_neighbor_proto_rawDescData)
+	})
+	return file_internal_grpc_neighbor_proto_rawDescData
+}
+
+var file_internal_grpc_neighbor_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_internal_grpc_neighbor_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_internal_grpc_neighbor_proto_goTypes = []any{
+	(NeighborEvent_Type)(0),       // 0: neigh2route.v1.NeighborEvent.Type
+	(*Neighbor)(nil),              // 1: neigh2route.v1.Neighbor
+	(*ListNeighborsRequest)(nil),  // 2: neigh2route.v1.ListNeighborsRequest
+	(*ListNeighborsResponse)(nil), // 3: neigh2route.v1.ListNeighborsResponse
+	(*WatchRequest)(nil),          // 4: neigh2route.v1.WatchRequest
+	(*NeighborEvent)(nil),         // 5: neigh2route.v1.NeighborEvent
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+}
+var file_internal_grpc_neighbor_proto_depIdxs = []int32{
+	6, // 0: neigh2route.v1.Neighbor.first_seen:type_name -> google.protobuf.Timestamp
+	6, // 1: neigh2route.v1.Neighbor.last_updated:type_name -> google.protobuf.Timestamp
+	1, // 2: neigh2route.v1.ListNeighborsResponse.neighbors:type_name -> neigh2route.v1.Neighbor
+	0, // 3: neigh2route.v1.NeighborEvent.type:type_name -> neigh2route.v1.NeighborEvent.Type
+	6, // 4: neigh2route.v1.NeighborEvent.timestamp:type_name -> google.protobuf.Timestamp
+	2, // 5: neigh2route.v1.NeighborService.ListNeighbors:input_type -> neigh2route.v1.ListNeighborsRequest
+	4, // 6: neigh2route.v1.NeighborService.WatchNeighbors:input_type -> neigh2route.v1.WatchRequest
+	3, // 7: neigh2route.v1.NeighborService.ListNeighbors:output_type -> neigh2route.v1.ListNeighborsResponse
+	5, // 8: neigh2route.v1.NeighborService.WatchNeighbors:output_type -> neigh2route.v1.NeighborEvent
+	7, // [7:9] is the sub-list for method output_type
+	5, // [5:7] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_internal_grpc_neighbor_proto_init() }
+func file_internal_grpc_neighbor_proto_init() {
+	if File_internal_grpc_neighbor_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_internal_grpc_neighbor_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*Neighbor); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_grpc_neighbor_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*ListNeighborsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_grpc_neighbor_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*ListNeighborsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_grpc_neighbor_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*WatchRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_internal_grpc_neighbor_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*NeighborEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_internal_grpc_neighbor_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_internal_grpc_neighbor_proto_goTypes,
+		DependencyIndexes: file_internal_grpc_neighbor_proto_depIdxs,
+		EnumInfos:         file_internal_grpc_neighbor_proto_enumTypes,
+		MessageInfos:      file_internal_grpc_neighbor_proto_msgTypes,
+	}.Build()
+	File_internal_grpc_neighbor_proto = out.File
+	file_internal_grpc_neighbor_proto_rawDesc = nil
+	file_internal_grpc_neighbor_proto_goTypes = nil
+	file_internal_grpc_neighbor_proto_depIdxs = nil
+}
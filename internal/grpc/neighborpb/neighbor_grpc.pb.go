@@ -0,0 +1,185 @@
+// NeighborService mirrors the read side of the HTTP API: GET /neighbors and
+// GET /neighbors/watch. Generate the Go code with `make proto`.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             v5.27.1
+// source: internal/grpc/neighbor.proto
+
+package neighborpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	NeighborService_ListNeighbors_FullMethodName  = "/neigh2route.v1.NeighborService/ListNeighbors"
+	NeighborService_WatchNeighbors_FullMethodName = "/neigh2route.v1.NeighborService/WatchNeighbors"
+)
+
+// NeighborServiceClient is the client API for NeighborService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NeighborServiceClient interface {
+	// ListNeighbors returns every known neighbor.
+	ListNeighbors(ctx context.Context, in *ListNeighborsRequest, opts ...grpc.CallOption) (*ListNeighborsResponse, error)
+	// WatchNeighbors streams neighbor add and remove events until the client
+	// cancels the call or the server shuts down.
+	WatchNeighbors(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (NeighborService_WatchNeighborsClient, error)
+}
+
+type neighborServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNeighborServiceClient(cc grpc.ClientConnInterface) NeighborServiceClient {
+	return &neighborServiceClient{cc}
+}
+
+func (c *neighborServiceClient) ListNeighbors(ctx context.Context, in *ListNeighborsRequest, opts ...grpc.CallOption) (*ListNeighborsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListNeighborsResponse)
+	err := c.cc.Invoke(ctx, NeighborService_ListNeighbors_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *neighborServiceClient) WatchNeighbors(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (NeighborService_WatchNeighborsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NeighborService_ServiceDesc.Streams[0], NeighborService_WatchNeighbors_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &neighborServiceWatchNeighborsClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type NeighborService_WatchNeighborsClient interface {
+	Recv() (*NeighborEvent, error)
+	grpc.ClientStream
+}
+
+type neighborServiceWatchNeighborsClient struct {
+	grpc.ClientStream
+}
+
+func (x *neighborServiceWatchNeighborsClient) Recv() (*NeighborEvent, error) {
+	m := new(NeighborEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NeighborServiceServer is the server API for NeighborService service.
+// All implementations must embed UnimplementedNeighborServiceServer
+// for forward compatibility
+type NeighborServiceServer interface {
+	// ListNeighbors returns every known neighbor.
+	ListNeighbors(context.Context, *ListNeighborsRequest) (*ListNeighborsResponse, error)
+	// WatchNeighbors streams neighbor add and remove events until the client
+	// cancels the call or the server shuts down.
+	WatchNeighbors(*WatchRequest, NeighborService_WatchNeighborsServer) error
+	mustEmbedUnimplementedNeighborServiceServer()
+}
+
+// UnimplementedNeighborServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedNeighborServiceServer struct {
+}
+
+func (UnimplementedNeighborServiceServer) ListNeighbors(context.Context, *ListNeighborsRequest) (*ListNeighborsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListNeighbors not implemented")
+}
+func (UnimplementedNeighborServiceServer) WatchNeighbors(*WatchRequest, NeighborService_WatchNeighborsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchNeighbors not implemented")
+}
+func (UnimplementedNeighborServiceServer) mustEmbedUnimplementedNeighborServiceServer() {}
+
+// UnsafeNeighborServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NeighborServiceServer will
+// result in compilation errors.
+type UnsafeNeighborServiceServer interface {
+	mustEmbedUnimplementedNeighborServiceServer()
+}
+
+func RegisterNeighborServiceServer(s grpc.ServiceRegistrar, srv NeighborServiceServer) {
+	s.RegisterService(&NeighborService_ServiceDesc, srv)
+}
+
+func _NeighborService_ListNeighbors_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNeighborsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NeighborServiceServer).ListNeighbors(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NeighborService_ListNeighbors_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NeighborServiceServer).ListNeighbors(ctx, req.(*ListNeighborsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NeighborService_WatchNeighbors_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NeighborServiceServer).WatchNeighbors(m, &neighborServiceWatchNeighborsServer{ServerStream: stream})
+}
+
+type NeighborService_WatchNeighborsServer interface {
+	Send(*NeighborEvent) error
+	grpc.ServerStream
+}
+
+type neighborServiceWatchNeighborsServer struct {
+	grpc.ServerStream
+}
+
+func (x *neighborServiceWatchNeighborsServer) Send(m *NeighborEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// NeighborService_ServiceDesc is the grpc.ServiceDesc for NeighborService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NeighborService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "neigh2route.v1.NeighborService",
+	HandlerType: (*NeighborServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListNeighbors",
+			Handler:    _NeighborService_ListNeighbors_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchNeighbors",
+			Handler:       _NeighborService_WatchNeighbors_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "internal/grpc/neighbor.proto",
+}
@@ -0,0 +1,252 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/grpc/neighborpb"
+	"github.com/hostinger/neigh2route/internal/logger"
+	"github.com/hostinger/neigh2route/internal/neighbor"
+	"github.com/hostinger/neigh2route/pkg/netutils"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultShutdownTimeout is how long Run waits for in-flight calls to finish
+// once its context is cancelled.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// interfaceByIndex resolves the interface name of a neighbor; tests
+// replace it.
+var interfaceByIndex = netutils.InterfaceByIndex
+
+type source interface {
+	ListNeighbors() map[string]neighbor.Neighbor
+	WatchNeighbors() (<-chan neighbor.Event, func())
+}
+
+// Server serves NeighborService on Address, mirroring GET /neighbors and
+// GET /neighbors/watch of the HTTP API.
+type Server struct {
+	neighborpb.UnimplementedNeighborServiceServer
+
+	// Address is host:port, or a bare port to listen on 127.0.0.1 like the
+	// default of the HTTP API.
+	Address         string
+	Source          source
+	ShutdownTimeout time.Duration
+
+	// Token, when set, is required as "authorization: Bearer <token>"
+	// metadata on every call, as --api-token is on the HTTP API.
+	Token string
+	// TLSConfig, when set, serves over TLS with its certificates and client
+	// CA settings.
+	TLSConfig *tls.Config
+
+	// done is closed when Run's context is cancelled, ending the
+	// WatchNeighbors streams so the server can stop gracefully.
+	done chan struct{}
+}
+
+func New(address string, source source) *Server {
+	return &Server{
+		Address:         address,
+		Source:          source,
+		ShutdownTimeout: DefaultShutdownTimeout,
+		done:            make(chan struct{}),
+	}
+}
+
+// Run listens on Address and serves until ctx is cancelled.
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", listenAddress(s.Address))
+	if err != nil {
+		return err
+	}
+	logger.Info("gRPC server listening on %s", ln.Addr())
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is cancelled, then ends the watch streams and
+// waits up to ShutdownTimeout for the remaining calls before closing them.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	var opts []gogrpc.ServerOption
+	if s.TLSConfig != nil {
+		opts = append(opts, gogrpc.Creds(credentials.NewTLS(s.TLSConfig)))
+	}
+	if s.Token != "" {
+		opts = append(opts,
+			gogrpc.UnaryInterceptor(s.authorizeUnary),
+			gogrpc.StreamInterceptor(s.authorizeStream))
+	}
+	srv := gogrpc.NewServer(opts...)
+	neighborpb.RegisterNeighborServiceServer(srv, s)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	close(s.done)
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(s.ShutdownTimeout):
+		logger.Warn("Timed out after %s waiting for gRPC calls to finish, closing them", s.ShutdownTimeout)
+		srv.Stop()
+	}
+	return nil
+}
+
+// listenAddress turns a bare port into an address on 127.0.0.1.
+func listenAddress(address string) string {
+	if _, err := strconv.ParseUint(address, 10, 16); err == nil {
+		return net.JoinHostPort("127.0.0.1", address)
+	}
+	return address
+}
+
+// authorize rejects calls whose metadata does not carry Token as a bearer
+// token.
+func (s *Server) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		presented, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(presented), []byte(s.Token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+}
+
+func (s *Server) authorizeUnary(ctx context.Context, req interface{}, _ *gogrpc.UnaryServerInfo, handler gogrpc.UnaryHandler) (interface{}, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authorizeStream(srv interface{}, stream gogrpc.ServerStream, _ *gogrpc.StreamServerInfo, handler gogrpc.StreamHandler) error {
+	if err := s.authorize(stream.Context()); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// ListNeighbors returns the known neighbors sorted by IP, IPv4 first,
+// filtered like the afi and permanent query parameters of GET /neighbors.
+func (s *Server) ListNeighbors(ctx context.Context, req *neighborpb.ListNeighborsRequest) (*neighborpb.ListNeighborsResponse, error) {
+	afi := req.GetAfi()
+	if afi != "" && afi != "v4" && afi != "v6" {
+		return nil, status.Error(codes.InvalidArgument, "afi must be v4 or v6")
+	}
+
+	resp := &neighborpb.ListNeighborsResponse{}
+	for _, n := range s.Source.ListNeighbors() {
+		if req.GetPermanentOnly() && !n.Permanent {
+			continue
+		}
+		pb := newNeighbor(n)
+		if afi != "" && pb.Afi != afi {
+			continue
+		}
+		resp.Neighbors = append(resp.Neighbors, pb)
+	}
+
+	sort.Slice(resp.Neighbors, func(i, j int) bool {
+		a, b := net.ParseIP(resp.Neighbors[i].Ip), net.ParseIP(resp.Neighbors[j].Ip)
+		if v4A, v4B := a.To4() != nil, b.To4() != nil; v4A != v4B {
+			return v4A
+		}
+		return bytes.Compare(a.To16(), b.To16()) < 0
+	})
+	return resp, nil
+}
+
+// WatchNeighbors streams neighbor add and remove events until the client
+// cancels the call or the server shuts down. Events are dropped while the
+// client falls behind, as for GET /neighbors/watch.
+func (s *Server) WatchNeighbors(_ *neighborpb.WatchRequest, stream neighborpb.NeighborService_WatchNeighborsServer) error {
+	events, unsubscribe := s.Source.WatchNeighbors()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.done:
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(newNeighborEvent(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func newNeighbor(n neighbor.Neighbor) *neighborpb.Neighbor {
+	afi := "v4"
+	if n.IP.To4() == nil {
+		afi = "v6"
+	}
+
+	// An interface that is gone leaves the name empty.
+	var ifaceName string
+	if iface, err := interfaceByIndex(n.LinkIndex); err == nil {
+		ifaceName = iface.Name
+	}
+
+	return &neighborpb.Neighbor{
+		Ip:            n.IP.String(),
+		LinkIndex:     int32(n.LinkIndex),
+		Interface:     ifaceName,
+		HwAddr:        n.HardwareAddr.String(),
+		Afi:           afi,
+		Permanent:     n.Permanent,
+		PingTimeoutMs: n.PingTimeout.Milliseconds(),
+		FirstSeen:     timestamppb.New(n.FirstSeen),
+		LastUpdated:   timestamppb.New(n.LastUpdated),
+	}
+}
+
+func newNeighborEvent(e neighbor.Event) *neighborpb.NeighborEvent {
+	eventType := neighborpb.NeighborEvent_TYPE_UNSPECIFIED
+	switch e.Type {
+	case neighbor.EventAdd:
+		eventType = neighborpb.NeighborEvent_TYPE_ADD
+	case neighbor.EventRemove:
+		eventType = neighborpb.NeighborEvent_TYPE_REMOVE
+	}
+
+	return &neighborpb.NeighborEvent{
+		Type:      eventType,
+		Ip:        e.Neighbor.IP.String(),
+		LinkIndex: int32(e.Neighbor.LinkIndex),
+		HwAddr:    e.Neighbor.HardwareAddr.String(),
+		Timestamp: timestamppb.New(e.Timestamp),
+	}
+}
@@ -0,0 +1,219 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/grpc/neighborpb"
+	"github.com/hostinger/neigh2route/internal/neighbor"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeSource struct {
+	neighbors map[string]neighbor.Neighbor
+	events    chan neighbor.Event
+}
+
+func (f *fakeSource) ListNeighbors() map[string]neighbor.Neighbor {
+	return f.neighbors
+}
+
+func (f *fakeSource) WatchNeighbors() (<-chan neighbor.Event, func()) {
+	return f.events, func() {}
+}
+
+// Helper function to serve src over an in-memory listener and connect a
+// client to it
+func startServer(t *testing.T, src *fakeSource) (neighborpb.NeighborServiceClient, context.CancelFunc, chan error) {
+	return startServerWith(t, New("", src))
+}
+
+// Helper function to run s over an in-memory listener and connect a client
+// to it
+func startServerWith(t *testing.T, s *Server, opts ...gogrpc.DialOption) (neighborpb.NeighborServiceClient, context.CancelFunc, chan error) {
+	orig := interfaceByIndex
+	t.Cleanup(func() { interfaceByIndex = orig })
+	interfaceByIndex = func(linkIndex int) (*net.Interface, error) {
+		return &net.Interface{Index: linkIndex, Name: "eth0"}, nil
+	}
+
+	ln := bufconn.Listen(1 << 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+	t.Cleanup(cancel)
+
+	opts = append(opts,
+		gogrpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		gogrpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := gogrpc.NewClient("passthrough:///bufconn", opts...)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return neighborpb.NewNeighborServiceClient(conn), cancel, done
+}
+
+func TestListNeighbors(t *testing.T) {
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	client, _, _ := startServer(t, &fakeSource{neighbors: map[string]neighbor.Neighbor{
+		"2001:db8::1":  {IP: net.ParseIP("2001:db8::1"), LinkIndex: 2, HardwareAddr: mac},
+		"192.168.1.20": {IP: net.ParseIP("192.168.1.20"), LinkIndex: 2, HardwareAddr: mac, Permanent: true},
+		"192.168.1.3":  {IP: net.ParseIP("192.168.1.3"), LinkIndex: 3, HardwareAddr: mac},
+	}})
+
+	tests := []struct {
+		name string
+		req  *neighborpb.ListNeighborsRequest
+		want []string
+	}{
+		{"all", &neighborpb.ListNeighborsRequest{}, []string{"192.168.1.3", "192.168.1.20", "2001:db8::1"}},
+		{"v6", &neighborpb.ListNeighborsRequest{Afi: "v6"}, []string{"2001:db8::1"}},
+		{"permanent", &neighborpb.ListNeighborsRequest{PermanentOnly: true}, []string{"192.168.1.20"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.ListNeighbors(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("ListNeighbors failed: %v", err)
+			}
+			var got []string
+			for _, n := range resp.Neighbors {
+				got = append(got, n.Ip)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Expected %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+
+	resp, err := client.ListNeighbors(context.Background(), &neighborpb.ListNeighborsRequest{Afi: "v4"})
+	if err != nil {
+		t.Fatalf("ListNeighbors failed: %v", err)
+	}
+	n := resp.Neighbors[0]
+	if n.LinkIndex != 3 || n.Interface != "eth0" || n.HwAddr != "00:11:22:33:44:55" || n.Afi != "v4" {
+		t.Errorf("Unexpected neighbor %+v", n)
+	}
+
+	_, err = client.ListNeighbors(context.Background(), &neighborpb.ListNeighborsRequest{Afi: "v5"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an invalid afi, got %v", err)
+	}
+}
+
+func TestWatchNeighborsEndsOnShutdown(t *testing.T) {
+	src := &fakeSource{events: make(chan neighbor.Event)}
+	client, cancel, done := startServer(t, src)
+
+	stream, err := client.WatchNeighbors(context.Background(), &neighborpb.WatchRequest{})
+	if err != nil {
+		t.Fatalf("WatchNeighbors failed: %v", err)
+	}
+
+	now := time.Now()
+	src.events <- neighbor.Event{Type: neighbor.EventRemove, Neighbor: neighbor.Neighbor{IP: net.ParseIP("10.0.0.1"), LinkIndex: 4}, Timestamp: now}
+
+	e, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive event: %v", err)
+	}
+	if e.Type != neighborpb.NeighborEvent_TYPE_REMOVE || e.Ip != "10.0.0.1" || e.LinkIndex != 4 || !e.Timestamp.AsTime().Equal(now) {
+		t.Errorf("Unexpected event %+v", e)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the server to stop without waiting for the watch stream")
+	}
+	if _, err := stream.Recv(); err == nil {
+		t.Error("Expected the watch stream to end on shutdown")
+	}
+}
+
+type bearerToken string
+
+func (b bearerToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(b)}, nil
+}
+
+func (bearerToken) RequireTransportSecurity() bool {
+	return false
+}
+
+func TestTokenRequired(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		code  codes.Code
+	}{
+		{"missing", "", codes.Unauthenticated},
+		{"wrong", "wrong", codes.Unauthenticated},
+		{"valid", "secret", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &fakeSource{events: make(chan neighbor.Event)}
+			s := New("", src)
+			s.Token = "secret"
+
+			var opts []gogrpc.DialOption
+			if tt.token != "" {
+				opts = append(opts, gogrpc.WithPerRPCCredentials(bearerToken(tt.token)))
+			}
+			client, _, _ := startServerWith(t, s, opts...)
+
+			_, err := client.ListNeighbors(context.Background(), &neighborpb.ListNeighborsRequest{})
+			if status.Code(err) != tt.code {
+				t.Errorf("ListNeighbors: expected %s, got %v", tt.code, err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stream, err := client.WatchNeighbors(ctx, &neighborpb.WatchRequest{})
+			if err != nil {
+				t.Fatalf("WatchNeighbors failed: %v", err)
+			}
+			if tt.code == codes.OK {
+				src.events <- neighbor.Event{Type: neighbor.EventAdd, Neighbor: neighbor.Neighbor{IP: net.ParseIP("10.0.0.1")}}
+			}
+			if _, err := stream.Recv(); status.Code(err) != tt.code {
+				t.Errorf("WatchNeighbors: expected %s, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestListenAddress(t *testing.T) {
+	tests := map[string]string{
+		"54322":          "127.0.0.1:54322",
+		":54322":         ":54322",
+		"0.0.0.0:54322":  "0.0.0.0:54322",
+		"[::1]:54322":    "[::1]:54322",
+		"localhost:5432": "localhost:5432",
+	}
+	for address, want := range tests {
+		if got := listenAddress(address); got != want {
+			t.Errorf("listenAddress(%q) = %q, want %q", address, got, want)
+		}
+	}
+}
@@ -1,41 +1,244 @@
 package logger
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"log/syslog"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-var debugEnabled bool = false
+// LevelFatal is logged by Fatal right before the process exits.
+const LevelFatal = slog.Level(12)
 
-func Init(debug bool) {
-	debugEnabled = debug
+// Format selects how log lines are written.
+type Format string
+
+const (
+	// FormatText writes logfmt lines such as level=INFO msg="...".
+	FormatText Format = "text"
+	// FormatJSON writes one JSON object per line with level, msg and ts
+	// (RFC3339 with nanoseconds) keys plus any fields.
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the Format named s.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatText, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown log format %q, expected %q or %q", s, FormatText, FormatJSON)
+}
+
+var (
+	mu     sync.Mutex
+	output io.Writer = os.Stderr
+	debug  bool
+	format = FormatText
+
+	slogger atomic.Pointer[slog.Logger]
+)
+
+var syslogWriter *syslog.Writer
+
+func init() {
+	rebuild()
+}
+
+// Init configures the package logger. Debug messages are only written when
+// debugMode is set, and logFormat selects text or JSON output.
+func Init(debugMode bool, logFormat Format) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	debug = debugMode
+	format = logFormat
+	rebuild()
+}
+
+// SetOutput redirects log output, which defaults to stderr.
+func SetOutput(w io.Writer) {
+	setOutputFile(w, nil)
+}
+
+// rebuild swaps in a logger for the current settings. Callers hold mu,
+// except init.
+func rebuild() {
+	opts := &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey && a.Value.Any() == LevelFatal {
+				a.Value = slog.StringValue("FATAL")
+			}
+			return a
+		},
+	}
+	if debug {
+		opts.Level = slog.LevelDebug
+	}
+
+	var handler slog.Handler
+	if format == FormatJSON {
+		replace := opts.ReplaceAttr
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.String("ts", a.Value.Time().Format(time.RFC3339Nano))
+			}
+			return replace(groups, a)
+		}
+		handler = slog.NewJSONHandler(output, opts)
+	} else {
+		handler = slog.NewTextHandler(output, opts)
+	}
+
+	slogger.Store(slog.New(handler))
+}
+
+// EnableSyslog sends every log line to syslog under the facility set with
+// SetSyslogFacility in addition to the log output. An empty network and
+// raddr use the local syslog daemon.
+func EnableSyslog(network, raddr string) error {
+	w, err := syslog.Dial(network, raddr, syslogFacility|syslog.LOG_INFO, "neigh2route")
+	if err != nil {
+		return err
+	}
+
+	syslogWriter = w
+	return nil
+}
+
+func writeSyslog(level string, msg string) {
+	if syslogWriter == nil {
+		return
+	}
+
+	line := fmt.Sprintf("level=%s msg=%s", level, msg)
+
+	var err error
+	switch level {
+	case "debug":
+		err = syslogWriter.Debug(line)
+	case "warn":
+		err = syslogWriter.Warning(line)
+	case "error":
+		err = syslogWriter.Err(line)
+	case "fatal":
+		err = syslogWriter.Crit(line)
+	default:
+		err = syslogWriter.Info(line)
+	}
+
+	if err != nil {
+		log.Printf("level=error msg=Failed to write to syslog: %v", err)
+	}
 }
 
-func logWithLevel(level string, format string, v ...interface{}) {
+func logWithLevel(level slog.Level, name string, format string, v ...interface{}) {
+	l := slogger.Load()
+	if !l.Enabled(context.Background(), level) {
+		return
+	}
+
 	msg := fmt.Sprintf(format, v...)
-	log.Printf("level=%s msg=%s", level, msg)
+	l.Log(context.Background(), level, msg)
+	writeSyslog(name, msg)
 }
 
-func Debug(format string, v ...interface{}) {
-	if debugEnabled {
-		logWithLevel("debug", format, v...)
+// Fields logs msg at level with alternating key/value fields, which become
+// separate keys in JSON output.
+func Fields(level slog.Level, msg string, keysAndValues ...interface{}) {
+	l := slogger.Load()
+	if !l.Enabled(context.Background(), level) {
+		return
+	}
+
+	l.Log(context.Background(), level, msg, keysAndValues...)
+	if syslogWriter != nil {
+		var b strings.Builder
+		b.WriteString(msg)
+		for i := 0; i+1 < len(keysAndValues); i += 2 {
+			fmt.Fprintf(&b, " %v=%v", keysAndValues[i], keysAndValues[i+1])
+		}
+		writeSyslog(levelName(level), b.String())
+	}
+}
+
+// logFields logs msg with fields sorted by key so that text output is
+// stable between calls.
+func logFields(level slog.Level, msg string, fields map[string]interface{}) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	keysAndValues := make([]interface{}, 0, 2*len(keys))
+	for _, k := range keys {
+		keysAndValues = append(keysAndValues, k, fields[k])
 	}
+	Fields(level, msg, keysAndValues...)
+}
+
+func levelName(level slog.Level) string {
+	switch {
+	case level >= LevelFatal:
+		return "fatal"
+	case level >= slog.LevelError:
+		return "error"
+	case level >= slog.LevelWarn:
+		return "warn"
+	case level >= slog.LevelInfo:
+		return "info"
+	}
+	return "debug"
+}
+
+func Debug(format string, v ...interface{}) {
+	logWithLevel(slog.LevelDebug, "debug", format, v...)
 }
 
 func Info(format string, v ...interface{}) {
-	logWithLevel("info", format, v...)
+	logWithLevel(slog.LevelInfo, "info", format, v...)
 }
 
 func Warn(format string, v ...interface{}) {
-	logWithLevel("warn", format, v...)
+	logWithLevel(slog.LevelWarn, "warn", format, v...)
 }
 
 func Error(format string, v ...interface{}) {
-	logWithLevel("error", format, v...)
+	logWithLevel(slog.LevelError, "error", format, v...)
 }
 
 func Fatal(format string, v ...interface{}) {
-	logWithLevel("fatal", format, v...)
+	logWithLevel(LevelFatal, "fatal", format, v...)
 	os.Exit(1)
 }
+
+// DebugFields logs msg with fields as separate keys in JSON output and as
+// key=value pairs in text output.
+func DebugFields(msg string, fields map[string]interface{}) {
+	logFields(slog.LevelDebug, msg, fields)
+}
+
+// InfoFields is DebugFields at info level.
+func InfoFields(msg string, fields map[string]interface{}) {
+	logFields(slog.LevelInfo, msg, fields)
+}
+
+// WarnFields is DebugFields at warn level.
+func WarnFields(msg string, fields map[string]interface{}) {
+	logFields(slog.LevelWarn, msg, fields)
+}
+
+// ErrorFields is DebugFields at error level.
+func ErrorFields(msg string, fields map[string]interface{}) {
+	logFields(slog.LevelError, msg, fields)
+}
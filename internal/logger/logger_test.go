@@ -0,0 +1,193 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startFakeSyslog listens on a unixgram socket and returns received datagrams
+func startFakeSyslog(t *testing.T) (string, <-chan string) {
+	path := filepath.Join(t.TempDir(), "syslog.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("failed to listen on fake syslog socket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	messages := make(chan string, 16)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			messages <- string(buf[:n])
+		}
+	}()
+
+	return path, messages
+}
+
+func TestSyslogPriorities(t *testing.T) {
+	path, messages := startFakeSyslog(t)
+
+	SetOutput(io.Discard)
+	defer SetOutput(os.Stderr)
+	Init(true, FormatText)
+	defer Init(false, FormatText)
+
+	if err := EnableSyslog("unixgram", path); err != nil {
+		t.Fatalf("failed to enable syslog: %v", err)
+	}
+	defer func() {
+		syslogWriter.Close()
+		syslogWriter = nil
+	}()
+
+	testCases := []struct {
+		logFn    func(string, ...interface{})
+		priority string
+	}{
+		{Debug, "<31>"},
+		{Info, "<30>"},
+		{Warn, "<28>"},
+		{Error, "<27>"},
+	}
+
+	for _, tc := range testCases {
+		tc.logFn("hello %s", "syslog")
+
+		select {
+		case msg := <-messages:
+			if !strings.HasPrefix(msg, tc.priority) {
+				t.Errorf("Expected priority %s, got %q", tc.priority, msg)
+			}
+			if !strings.Contains(msg, "msg=hello syslog") {
+				t.Errorf("Expected message body, got %q", msg)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for syslog message with priority %s", tc.priority)
+		}
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stderr)
+	Init(false, FormatJSON)
+	defer Init(false, FormatText)
+
+	Debug("hidden %d", 1)
+	Info("added route for %s", "10.0.0.1")
+	Warn("retrying")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 JSON lines, got %d: %q", len(lines), buf.String())
+	}
+
+	for i, want := range []struct{ level, msg string }{
+		{"INFO", "added route for 10.0.0.1"},
+		{"WARN", "retrying"},
+	} {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			t.Fatalf("Line %d is not valid JSON: %v: %q", i, err, lines[i])
+		}
+		if entry["level"] != want.level || entry["msg"] != want.msg {
+			t.Errorf("Expected level=%s msg=%q, got %v", want.level, want.msg, entry)
+		}
+		ts, ok := entry["ts"].(string)
+		if !ok {
+			t.Fatalf("Expected a ts field, got %v", entry)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+			t.Errorf("Expected an RFC3339 ts, got %q: %v", ts, err)
+		}
+	}
+}
+
+func TestTextOutputDebug(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stderr)
+	Init(true, FormatText)
+	defer Init(false, FormatText)
+
+	Debug("checking %s", "neighbor")
+
+	if !strings.Contains(buf.String(), `level=DEBUG msg="checking neighbor"`) {
+		t.Errorf("Expected text debug line, got %q", buf.String())
+	}
+}
+
+func TestJSONOutputFields(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stderr)
+	Init(false, FormatJSON)
+	defer Init(false, FormatText)
+
+	Fields(slog.LevelInfo, "route added", "ip", "10.0.0.1", "link_index", 4)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Output is not valid JSON: %v: %q", err, buf.String())
+	}
+	if entry["msg"] != "route added" || entry["ip"] != "10.0.0.1" || entry["link_index"] != float64(4) {
+		t.Errorf("Expected msg and fields as keys, got %v", entry)
+	}
+}
+
+func TestInfoFields(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer SetOutput(os.Stderr)
+
+	Init(false, FormatText)
+	InfoFields("route added", map[string]interface{}{"link_index": 4, "ip": "10.0.0.1"})
+	if !strings.Contains(buf.String(), `msg="route added" ip=10.0.0.1 link_index=4`) {
+		t.Errorf("Expected sorted key=value pairs, got %q", buf.String())
+	}
+
+	buf.Reset()
+	Init(false, FormatJSON)
+	defer Init(false, FormatText)
+	WarnFields("route missing", map[string]interface{}{"ip": "10.0.0.2"})
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Output is not valid JSON: %v: %q", err, buf.String())
+	}
+	if entry["level"] != "WARN" || entry["msg"] != "route missing" || entry["ip"] != "10.0.0.2" {
+		t.Errorf("Expected level, msg and fields as keys, got %v", entry)
+	}
+
+	buf.Reset()
+	DebugFields("hidden", map[string]interface{}{"ip": "10.0.0.3"})
+	if buf.Len() != 0 {
+		t.Errorf("Expected debug fields to be dropped without debug mode, got %q", buf.String())
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"text", "json"} {
+		if f, err := ParseFormat(s); err != nil || string(f) != s {
+			t.Errorf("ParseFormat(%q) = %q, %v", s, f, err)
+		}
+	}
+
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,179 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Destinations accepted by SetDestination besides a file path.
+const (
+	OutputStderr = "stderr"
+	OutputStdout = "stdout"
+	OutputSyslog = "syslog"
+)
+
+var facilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+var (
+	syslogFacility = syslog.LOG_DAEMON
+
+	// logFile is the file output was sent to by SetDestination, if any.
+	// Guarded by mu.
+	logFile *fileWriter
+)
+
+// fileWriter is the log output for a file destination. Rotate swaps the
+// file under it, so a logger that was built before the swap writes to the
+// new file instead of the closed one.
+type fileWriter struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// A closed writer drops what a stale logger still writes to it.
+	if w.f == nil {
+		return len(p), nil
+	}
+	return w.f.Write(p)
+}
+
+// swap replaces the file, nil closing the writer, and closes the old one.
+func (w *fileWriter) swap(f *os.File) {
+	w.mu.Lock()
+	old := w.f
+	w.f = f
+	w.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+}
+
+// ParseFacility returns the syslog facility named s, such as daemon or
+// local0.
+func ParseFacility(s string) (syslog.Priority, error) {
+	if f, ok := facilities[s]; ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("unknown syslog facility %q", s)
+}
+
+// SetSyslogFacility selects the facility EnableSyslog logs under. It
+// defaults to daemon.
+func SetSyslogFacility(f syslog.Priority) {
+	syslogFacility = f
+}
+
+// SetDestination sends log output to stderr, stdout, the local syslog
+// daemon or, for any other value, appends it to the file at dest.
+func SetDestination(dest string) error {
+	switch dest {
+	case "", OutputStderr:
+		setOutputFile(os.Stderr, nil)
+	case OutputStdout:
+		setOutputFile(os.Stdout, nil)
+	case OutputSyslog:
+		if err := EnableSyslog("", ""); err != nil {
+			return err
+		}
+		setOutputFile(io.Discard, nil)
+	default:
+		f, err := openLogFile(dest)
+		if err != nil {
+			return err
+		}
+		w := &fileWriter{path: dest, f: f}
+		setOutputFile(w, w)
+	}
+	return nil
+}
+
+func openLogFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+}
+
+// setOutputFile switches output to w and closes the previous log file, if
+// any. A logger built before the switch may still write to the old file's
+// writer; those writes are dropped.
+func setOutputFile(w io.Writer, f *fileWriter) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	old := logFile
+	output, logFile = w, f
+	rebuild()
+
+	if old != nil && old != f {
+		old.swap(nil)
+	}
+}
+
+// Rotate reopens the log file at its path. If the file is still there it
+// is first moved aside with a timestamp suffix; if it is gone or replaced,
+// e.g. renamed by logrotate before it sent SIGHUP, logging just continues
+// in a new file at the path. It does nothing unless SetDestination sent
+// output to a file.
+func Rotate() error {
+	mu.Lock()
+	w := logFile
+	mu.Unlock()
+
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	current := w.f
+	w.mu.Unlock()
+	if current == nil {
+		return nil
+	}
+
+	var rotated string
+	if ours, err := current.Stat(); err == nil {
+		if st, err := os.Stat(w.path); err == nil && os.SameFile(ours, st) {
+			rotated = w.path + "." + time.Now().Format("20060102T150405.000000000")
+			if err := os.Rename(w.path, rotated); err != nil {
+				return err
+			}
+		}
+	}
+
+	f, err := openLogFile(w.path)
+	if err != nil {
+		return err
+	}
+	w.swap(f)
+
+	if rotated != "" {
+		Info("Rotated log file to %s", rotated)
+	} else {
+		Info("Reopened log file %s", w.path)
+	}
+	return nil
+}
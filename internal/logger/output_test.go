@@ -0,0 +1,144 @@
+package logger
+
+import (
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileDestinationRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "neigh2route.log")
+	if err := SetDestination(path); err != nil {
+		t.Fatalf("failed to set file destination: %v", err)
+	}
+	defer SetOutput(os.Stderr)
+
+	Info("before rotation")
+	if err := Rotate(); err != nil {
+		t.Fatalf("failed to rotate: %v", err)
+	}
+	Info("after rotation")
+
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 1 {
+		t.Fatalf("Expected 1 rotated file, got %v", rotated)
+	}
+	old, _ := os.ReadFile(rotated[0])
+	if !strings.Contains(string(old), "before rotation") || strings.Contains(string(old), "after rotation") {
+		t.Errorf("Unexpected rotated file content %q", old)
+	}
+
+	current, _ := os.ReadFile(path)
+	if !strings.Contains(string(current), "after rotation") || strings.Contains(string(current), "before rotation") {
+		t.Errorf("Unexpected current file content %q", current)
+	}
+}
+
+func TestRotateAfterExternalRename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "neigh2route.log")
+	if err := SetDestination(path); err != nil {
+		t.Fatalf("failed to set file destination: %v", err)
+	}
+	defer SetOutput(os.Stderr)
+
+	Info("before rotation")
+	// logrotate renames the file, then sends SIGHUP.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatalf("failed to rename log file: %v", err)
+	}
+	if err := Rotate(); err != nil {
+		t.Fatalf("failed to reopen: %v", err)
+	}
+	Info("after rotation")
+
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 2 {
+		t.Errorf("Expected only the current and the renamed file, got %v", files)
+	}
+	old, _ := os.ReadFile(path + ".1")
+	if !strings.Contains(string(old), "before rotation") || strings.Contains(string(old), "after rotation") {
+		t.Errorf("Unexpected renamed file content %q", old)
+	}
+	current, _ := os.ReadFile(path)
+	if !strings.Contains(string(current), "after rotation") || strings.Contains(string(current), "before rotation") {
+		t.Errorf("Unexpected current file content %q", current)
+	}
+}
+
+func TestStaleFileWriterAfterSwitch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "neigh2route.log")
+	if err := SetDestination(path); err != nil {
+		t.Fatalf("failed to set file destination: %v", err)
+	}
+	defer SetOutput(os.Stderr)
+
+	mu.Lock()
+	stale := logFile
+	mu.Unlock()
+
+	if err := Rotate(); err != nil {
+		t.Fatalf("failed to rotate: %v", err)
+	}
+	if _, err := stale.Write([]byte("late line\n")); err != nil {
+		t.Errorf("Expected a write through the rotated writer to succeed, got %v", err)
+	}
+	if current, _ := os.ReadFile(path); !strings.Contains(string(current), "late line") {
+		t.Errorf("Expected the late line in the new file, got %q", current)
+	}
+
+	SetOutput(&strings.Builder{})
+	if _, err := stale.Write([]byte("dropped\n")); err != nil {
+		t.Errorf("Expected a write to a replaced destination to be dropped, got %v", err)
+	}
+}
+
+func TestRotateWithoutFileIsNoop(t *testing.T) {
+	if err := SetDestination(OutputStdout); err != nil {
+		t.Fatalf("failed to set stdout destination: %v", err)
+	}
+	defer SetOutput(os.Stderr)
+
+	if err := Rotate(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestParseFacility(t *testing.T) {
+	if f, err := ParseFacility("local3"); err != nil || f != syslog.LOG_LOCAL3 {
+		t.Errorf("ParseFacility(local3) = %v, %v", f, err)
+	}
+	if _, err := ParseFacility("local9"); err == nil {
+		t.Error("Expected an error for an unknown facility")
+	}
+}
+
+func TestSyslogFacility(t *testing.T) {
+	path, messages := startFakeSyslog(t)
+
+	SetSyslogFacility(syslog.LOG_LOCAL0)
+	defer SetSyslogFacility(syslog.LOG_DAEMON)
+
+	if err := EnableSyslog("unixgram", path); err != nil {
+		t.Fatalf("failed to enable syslog: %v", err)
+	}
+	defer func() {
+		syslogWriter.Close()
+		syslogWriter = nil
+	}()
+
+	SetOutput(&strings.Builder{})
+	defer SetOutput(os.Stderr)
+	Info("hello local0")
+
+	select {
+	case msg := <-messages:
+		if !strings.HasPrefix(msg, "<134>") {
+			t.Errorf("Expected local0.info priority <134>, got %q", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for syslog message")
+	}
+}
@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+type Logger interface {
+	Debug(format string, v ...interface{})
+	Info(format string, v ...interface{})
+	Warn(format string, v ...interface{})
+	Error(format string, v ...interface{})
+}
+
+type defaultLogger struct{}
+
+func (defaultLogger) Debug(format string, v ...interface{}) { Debug(format, v...) }
+func (defaultLogger) Info(format string, v ...interface{})  { Info(format, v...) }
+func (defaultLogger) Warn(format string, v ...interface{})  { Warn(format, v...) }
+func (defaultLogger) Error(format string, v ...interface{}) { Error(format, v...) }
+
+// Default returns a Logger backed by the package-level log functions.
+func Default() Logger {
+	return defaultLogger{}
+}
+
+// Sampler rate-limits messages sharing the same format string to at most
+// perSecond per second. The number of dropped messages is reported with the
+// next message that gets through.
+type Sampler struct {
+	next      Logger
+	perSecond int
+
+	mu   sync.Mutex
+	keys map[string]*sampledKey
+}
+
+type sampledKey struct {
+	limiter    *rate.Limiter
+	suppressed int
+}
+
+func NewSampler(next Logger, perSecond int) *Sampler {
+	if perSecond < 1 {
+		perSecond = 1
+	}
+
+	return &Sampler{
+		next:      next,
+		perSecond: perSecond,
+		keys:      make(map[string]*sampledKey),
+	}
+}
+
+func (s *Sampler) allow(format string) (bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[format]
+	if !ok {
+		key = &sampledKey{limiter: rate.NewLimiter(rate.Limit(s.perSecond), s.perSecond)}
+		s.keys[format] = key
+	}
+
+	if !key.limiter.Allow() {
+		key.suppressed++
+		return false, 0
+	}
+
+	suppressed := key.suppressed
+	key.suppressed = 0
+	return true, suppressed
+}
+
+func (s *Sampler) log(logFn func(string, ...interface{}), format string, v ...interface{}) {
+	allowed, suppressed := s.allow(format)
+	if !allowed {
+		return
+	}
+
+	if suppressed > 0 {
+		logFn("suppressed %d messages like %q", suppressed, format)
+	}
+	logFn(format, v...)
+}
+
+func (s *Sampler) Debug(format string, v ...interface{}) { s.log(s.next.Debug, format, v...) }
+func (s *Sampler) Info(format string, v ...interface{})  { s.log(s.next.Info, format, v...) }
+func (s *Sampler) Warn(format string, v ...interface{})  { s.log(s.next.Warn, format, v...) }
+func (s *Sampler) Error(format string, v ...interface{}) { s.log(s.next.Error, format, v...) }
@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingLogger collects formatted lines instead of writing them
+type recordingLogger struct {
+	lines []string
+}
+
+func (r *recordingLogger) record(format string, v ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprintf(format, v...))
+}
+
+func (r *recordingLogger) Debug(format string, v ...interface{}) { r.record(format, v...) }
+func (r *recordingLogger) Info(format string, v ...interface{})  { r.record(format, v...) }
+func (r *recordingLogger) Warn(format string, v ...interface{})  { r.record(format, v...) }
+func (r *recordingLogger) Error(format string, v ...interface{}) { r.record(format, v...) }
+
+func TestSamplerLimitsBurst(t *testing.T) {
+	rec := &recordingLogger{}
+	s := NewSampler(rec, 10)
+
+	for i := 0; i < 1000; i++ {
+		s.Debug("Skipping link-local neighbor with IP=%s", "fe80::1")
+	}
+
+	if len(rec.lines) > 20 {
+		t.Errorf("Expected at most 20 lines for a burst of 1000, got %d", len(rec.lines))
+	}
+
+	if len(rec.lines) == 0 {
+		t.Errorf("Expected some lines to be logged, got none")
+	}
+}
+
+func TestSamplerReportsSuppressed(t *testing.T) {
+	rec := &recordingLogger{}
+	s := NewSampler(rec, 10)
+
+	for i := 0; i < 100; i++ {
+		s.Info("neighbor %d", i)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	before := len(rec.lines)
+	s.Info("neighbor %d", 100)
+
+	if len(rec.lines) != before+2 {
+		t.Fatalf("Expected summary and message to be logged, got %v", rec.lines[before:])
+	}
+
+	if !strings.HasPrefix(rec.lines[before], "suppressed ") {
+		t.Errorf("Expected suppressed summary, got %s", rec.lines[before])
+	}
+}
+
+func TestSamplerKeysByFormat(t *testing.T) {
+	rec := &recordingLogger{}
+	s := NewSampler(rec, 1)
+
+	s.Warn("first %d", 1)
+	s.Warn("first %d", 2)
+	s.Warn("second %d", 1)
+
+	if len(rec.lines) != 2 {
+		t.Errorf("Expected 2 lines, got %v", rec.lines)
+	}
+}
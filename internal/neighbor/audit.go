@@ -0,0 +1,112 @@
+package neighbor
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+	"github.com/hostinger/neigh2route/pkg/netutils"
+)
+
+const DefaultRouteAuditInterval = 60 * time.Second
+
+var listHostRoutes = netutils.HostRoutes
+
+// AuditRoutes calls auditRoutes every interval until ctx is cancelled,
+// re-adding the routes of neighbors that were removed behind our back.
+func (nm *NeighborManager) AuditRoutes(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultRouteAuditInterval
+	}
+
+	logger.Info("Auditing neighbor routes every %s", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			nm.auditRoutes()
+		}
+	}
+}
+
+// auditRoutes compares the host routes in RouteTable against the known
+// neighbors and re-adds every missing route. With RouteProtocol set only
+// routes marked with it count, so routes of other daemons are left alone.
+// It returns how many routes were restored. Dry runs install no routes, so
+// there is nothing to audit.
+func (nm *NeighborManager) auditRoutes() int {
+	if nm.DryRun {
+		return 0
+	}
+
+	linkIndexes := nm.linkIndexes()
+	if nm.ECMP {
+		// Multipath routes have no single link to filter them by.
+		linkIndexes = []int{0}
+	}
+
+	present := make(map[string]bool)
+	for _, linkIndex := range linkIndexes {
+		routes, err := listHostRoutes(linkIndex, nm.routeOptions()...)
+		if err != nil {
+			logger.Error("Failed to list routes in table %d for audit: %v", nm.RouteTable, err)
+			return 0
+		}
+		for _, route := range routes {
+			present[routeKey(route.Dst.IP.String(), route.LinkIndex)] = true
+			for _, nexthop := range route.MultiPath {
+				present[routeKey(route.Dst.IP.String(), nexthop.LinkIndex)] = true
+			}
+		}
+	}
+
+	restored := 0
+	for _, n := range nm.ListNeighbors() {
+		dst := n.IP.String()
+		if routeDst, err := netutils.RouteDst(n.IP, nm.routeOptions()...); err == nil {
+			dst = routeDst.IP.String()
+		}
+
+		if len(n.LinkIndexes) > 1 {
+			var missing []int
+			for _, linkIndex := range n.LinkIndexes {
+				if !present[routeKey(dst, linkIndex)] {
+					missing = append(missing, linkIndex)
+				}
+			}
+			if len(missing) == 0 {
+				continue
+			}
+
+			logger.Warn("ECMP route for neighbor %s is missing links %v, re-adding them", n.IP.String(), missing)
+			if err := nm.addNexthops(n, missing); err != nil {
+				logger.Error("Failed to re-add route for neighbor %s: %v", n.IP.String(), err)
+				continue
+			}
+			restored++
+			continue
+		}
+
+		if present[routeKey(dst, n.LinkIndex)] {
+			continue
+		}
+
+		logger.Warn("Route for neighbor %s on link %d is missing, re-adding it", n.IP.String(), n.LinkIndex)
+		if err := nm.addRoute(n.IP, n.LinkIndex, nm.gatewayOptions(n.IP, n.LinkIndex, n.HardwareAddr)...); err != nil {
+			logger.Error("Failed to re-add route for neighbor %s: %v", n.IP.String(), err)
+			continue
+		}
+		restored++
+	}
+	return restored
+}
+
+func routeKey(ip string, linkIndex int) string {
+	return ip + "%" + strconv.Itoa(linkIndex)
+}
@@ -0,0 +1,75 @@
+package neighbor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/hostinger/neigh2route/pkg/netutils"
+	"github.com/vishvananda/netlink"
+)
+
+func TestAuditRoutesReaddsMissingRoute(t *testing.T) {
+	ip := "192.168.100.160"
+	nm, _ := NewNeighborManager("lo")
+	nm.AddNeighbor(net.ParseIP(ip), 1, nil)
+	defer nm.RemoveNeighbor(net.ParseIP(ip), 1)
+
+	if err := netutils.RemoveRoute(context.Background(), net.ParseIP(ip), 1); err != nil {
+		t.Fatalf("failed to remove route: %v", err)
+	}
+
+	if got := nm.auditRoutes(); got != 1 {
+		t.Errorf("Expected 1 route restored, got %d", got)
+	}
+
+	if !routeOnLoopbackExists(t, ip) {
+		t.Errorf("Expected route %s to be re-added", ip)
+	}
+
+	if got := nm.auditRoutes(); got != 0 {
+		t.Errorf("Expected no routes restored on second audit, got %d", got)
+	}
+}
+
+func TestAuditRoutesKeepsPresentIPv6Route(t *testing.T) {
+	ip := net.ParseIP("2001:db8:100::160")
+	nm, _ := NewNeighborManager("lo")
+	nm.AddNeighbor(ip, 1, nil)
+	defer nm.RemoveNeighbor(ip, 1)
+
+	for i := 0; i < 2; i++ {
+		if got := nm.auditRoutes(); got != 0 {
+			t.Fatalf("Expected no routes restored while the IPv6 route is present, got %d", got)
+		}
+	}
+	if got := nm.Stats().RoutesAdded; got != 1 {
+		t.Errorf("Expected 1 route added, got %d", got)
+	}
+
+	if err := netutils.RemoveRoute(context.Background(), ip, 1); err != nil {
+		t.Fatalf("failed to remove route: %v", err)
+	}
+	if got := nm.auditRoutes(); got != 1 {
+		t.Errorf("Expected 1 route restored, got %d", got)
+	}
+	if got := routeLinks(t, ip.String()); len(got) != 1 || got[0] != 1 {
+		t.Errorf("Expected the IPv6 route to be re-added on link 1, got %v", got)
+	}
+}
+
+func TestAuditRoutesListError(t *testing.T) {
+	orig := listHostRoutes
+	defer func() { listHostRoutes = orig }()
+	listHostRoutes = func(int, ...netutils.RouteOption) ([]netlink.Route, error) {
+		return nil, errors.New("netlink failure")
+	}
+
+	nm, _ := NewNeighborManager("lo")
+	nm.ReachableNeighbors["192.168.100.161"] = Neighbor{IP: net.ParseIP("192.168.100.161"), LinkIndex: 1}
+
+	if got := nm.auditRoutes(); got != 0 {
+		t.Errorf("Expected no routes restored when listing fails, got %d", got)
+	}
+}
@@ -0,0 +1,92 @@
+package neighbor
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+)
+
+const (
+	AuditOpAdd    = "add"
+	AuditOpRemove = "remove"
+)
+
+// AuditRecord is one line of the route audit log.
+type AuditRecord struct {
+	Time      time.Time `json:"ts"`
+	Op        string    `json:"op"`
+	IP        string    `json:"ip"`
+	LinkIndex int       `json:"link_index"`
+	Table     int       `json:"table"`
+}
+
+// AuditLog appends one JSON line per route operation to a file. It is
+// independent of the logger, so log levels never suppress it.
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// OpenAuditLog opens path for appending, creating it if needed.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{file: file, w: bufio.NewWriter(file)}, nil
+}
+
+// Record writes rec and flushes it to the file.
+func (a *AuditLog) Record(rec AuditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := a.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return a.w.Flush()
+}
+
+// Close flushes and closes the file.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.w.Flush(); err != nil {
+		a.file.Close()
+		return err
+	}
+	return a.file.Close()
+}
+
+// auditRoute records a route operation when an audit log is configured and
+// announces it with notifyRoute. Dry runs change nothing, so they are not
+// recorded.
+func (nm *NeighborManager) auditRoute(op string, ip net.IP, linkIndex int) {
+	nm.notifyRoute(op == AuditOpAdd, ip, linkIndex)
+	if nm.auditLog == nil || nm.DryRun {
+		return
+	}
+
+	err := nm.auditLog.Record(AuditRecord{
+		Time:      time.Now().UTC(),
+		Op:        op,
+		IP:        ip.String(),
+		LinkIndex: linkIndex,
+		Table:     nm.RouteTable,
+	})
+	if err != nil {
+		logger.Error("Failed to write route audit log: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package neighbor
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestAuditLogRecordsRouteOperations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, AuditLog: auditLog})
+	ip := net.ParseIP("10.10.60.1")
+	nm.AddNeighbor(ip, 1, nil)
+	nm.RemoveNeighbor(ip, 1)
+	nm.Cleanup()
+
+	if err := auditLog.Close(); err != nil {
+		t.Fatalf("Failed to close audit log: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	defer f.Close()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Invalid audit line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 audit records, got %+v", records)
+	}
+	for i, op := range []string{AuditOpAdd, AuditOpRemove} {
+		rec := records[i]
+		if rec.Op != op || rec.IP != "10.10.60.1" || rec.LinkIndex != 1 || rec.Table != unix.RT_TABLE_MAIN || rec.Time.IsZero() {
+			t.Errorf("Unexpected record %d: %+v", i, rec)
+		}
+	}
+}
+
+func TestAuditLogSkipsDryRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, AuditLog: auditLog, DryRun: true})
+	nm.AddNeighbor(net.ParseIP("10.10.60.2"), 1, nil)
+	nm.Cleanup()
+	auditLog.Close()
+
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("Expected an empty audit log in dry-run mode, got %q", data)
+	}
+}
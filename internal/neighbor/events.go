@@ -0,0 +1,184 @@
+package neighbor
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	DefaultEventHistorySize = 1000
+	DefaultEventBusCapacity = 1000
+)
+
+type EventType string
+
+const (
+	EventAdd    EventType = "add"
+	EventRemove EventType = "remove"
+)
+
+type Event struct {
+	Type      EventType
+	Neighbor  Neighbor
+	Timestamp time.Time
+}
+
+// eventLog is a fixed-size ring of events with a per-IP index of sequence
+// numbers, so the history of one IP can be read without scanning the ring.
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+	next   uint64
+	byIP   map[string][]uint64
+}
+
+func newEventLog(size int) *eventLog {
+	if size < 1 {
+		size = 1
+	}
+
+	return &eventLog{
+		events: make([]Event, size),
+		byIP:   make(map[string][]uint64),
+	}
+}
+
+func (l *eventLog) record(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	size := uint64(len(l.events))
+	slot := l.next % size
+
+	if l.next >= size {
+		evicted := l.events[slot].Neighbor.IP.String()
+		seqs := l.byIP[evicted]
+		if len(seqs) > 0 && seqs[0] == l.next-size {
+			seqs = seqs[1:]
+		}
+		if len(seqs) == 0 {
+			delete(l.byIP, evicted)
+		} else {
+			l.byIP[evicted] = seqs
+		}
+	}
+
+	key := e.Neighbor.IP.String()
+	l.events[slot] = e
+	l.byIP[key] = append(l.byIP[key], l.next)
+	l.next++
+}
+
+// history returns up to limit events for ip, newest first.
+func (l *eventLog) history(ip string, limit int) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	seqs := l.byIP[ip]
+	size := uint64(len(l.events))
+
+	result := make([]Event, 0, min(limit, len(seqs)))
+	for i := len(seqs) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, l.events[seqs[i]%size])
+	}
+	return result
+}
+
+// recent returns up to limit events across every IP, newest first.
+func (l *eventLog) recent(limit int) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	size := uint64(len(l.events))
+	count := min(l.next, size, uint64(max(limit, 0)))
+
+	result := make([]Event, 0, count)
+	for seq := l.next; uint64(len(result)) < count; seq-- {
+		result = append(result, l.events[(seq-1)%size])
+	}
+	return result
+}
+
+// eventBus fans events out to subscribers. Each subscriber gets a channel
+// buffered to capacity; events for a subscriber whose buffer is full are
+// dropped so a slow reader never blocks neighbor processing.
+type eventBus struct {
+	mu       sync.Mutex
+	capacity int
+	subs     map[chan Event]struct{}
+	dropped  uint64
+}
+
+func newEventBus(capacity int) *eventBus {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &eventBus{
+		capacity: capacity,
+		subs:     make(map[chan Event]struct{}),
+	}
+}
+
+func (b *eventBus) subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, b.capacity)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (b *eventBus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			b.dropped++
+			sampledLog.Warn("Event subscriber buffer full (%d), dropping %s event for %s", b.capacity, e.Type, e.Neighbor.IP)
+		}
+	}
+}
+
+func (nm *NeighborManager) recordEvent(eventType EventType, n Neighbor) {
+	e := Event{
+		Type:      eventType,
+		Neighbor:  n,
+		Timestamp: time.Now(),
+	}
+
+	nm.events.record(e)
+	nm.bus.publish(e)
+}
+
+// WatchNeighbors returns a channel of add/remove events and a function that
+// unsubscribes and closes it. Events are dropped while the channel is full.
+func (nm *NeighborManager) WatchNeighbors() (<-chan Event, func()) {
+	return nm.bus.subscribe()
+}
+
+// History returns up to limit of the most recent events for ip, newest first.
+func (nm *NeighborManager) History(ip string, limit int) []Event {
+	return nm.events.history(ip, limit)
+}
+
+// Events returns up to limit of the most recent events, newest first. A
+// non-empty ip limits them to that neighbor.
+func (nm *NeighborManager) Events(ip string, limit int) []Event {
+	if ip != "" {
+		return nm.events.history(ip, limit)
+	}
+	return nm.events.recent(limit)
+}
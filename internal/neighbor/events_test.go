@@ -0,0 +1,166 @@
+package neighbor
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEventLogHistoryScopedPerIP(t *testing.T) {
+	l := newEventLog(10)
+
+	l.record(Event{Type: EventAdd, Neighbor: Neighbor{IP: net.ParseIP("10.0.0.1")}})
+	l.record(Event{Type: EventAdd, Neighbor: Neighbor{IP: net.ParseIP("10.0.0.2")}})
+	l.record(Event{Type: EventRemove, Neighbor: Neighbor{IP: net.ParseIP("10.0.0.1")}})
+
+	history := l.history("10.0.0.1", 20)
+	if len(history) != 2 {
+		t.Fatalf("Expected 2, got %d", len(history))
+	}
+
+	// Newest first
+	if history[0].Type != EventRemove || history[1].Type != EventAdd {
+		t.Errorf("Expected [remove add], got [%s %s]", history[0].Type, history[1].Type)
+	}
+
+	if len(l.history("10.0.0.3", 20)) != 0 {
+		t.Errorf("Expected no history for unknown IP")
+	}
+}
+
+func TestEventLogHistoryLimit(t *testing.T) {
+	l := newEventLog(10)
+	for i := 0; i < 5; i++ {
+		l.record(Event{Type: EventAdd, Neighbor: Neighbor{IP: net.ParseIP("10.0.0.1"), LinkIndex: i}})
+	}
+
+	history := l.history("10.0.0.1", 2)
+	if len(history) != 2 {
+		t.Fatalf("Expected 2, got %d", len(history))
+	}
+
+	if history[0].Neighbor.LinkIndex != 4 || history[1].Neighbor.LinkIndex != 3 {
+		t.Errorf("Expected link indexes [4 3], got [%d %d]", history[0].Neighbor.LinkIndex, history[1].Neighbor.LinkIndex)
+	}
+}
+
+func TestEventLogEvictsOldest(t *testing.T) {
+	l := newEventLog(3)
+	for i := 0; i < 5; i++ {
+		l.record(Event{Type: EventAdd, Neighbor: Neighbor{IP: net.ParseIP(fmt.Sprintf("10.0.0.%d", i))}})
+	}
+
+	for i := 0; i < 2; i++ {
+		ip := fmt.Sprintf("10.0.0.%d", i)
+		if len(l.history(ip, 20)) != 0 {
+			t.Errorf("Expected %s to be evicted", ip)
+		}
+		if _, ok := l.byIP[ip]; ok {
+			t.Errorf("Expected index entry for %s to be removed", ip)
+		}
+	}
+
+	for i := 2; i < 5; i++ {
+		ip := fmt.Sprintf("10.0.0.%d", i)
+		history := l.history(ip, 20)
+		if len(history) != 1 || !history[0].Neighbor.IP.Equal(net.ParseIP(ip)) {
+			t.Errorf("Expected one event for %s, got %v", ip, history)
+		}
+	}
+}
+
+func TestEventLogRecent(t *testing.T) {
+	l := newEventLog(3)
+	if got := l.recent(10); len(got) != 0 {
+		t.Fatalf("Expected no events in an empty log, got %v", got)
+	}
+
+	for i := 0; i < 5; i++ {
+		l.record(Event{Type: EventAdd, Neighbor: Neighbor{IP: net.ParseIP(fmt.Sprintf("10.0.0.%d", i))}})
+	}
+
+	recent := l.recent(10)
+	if len(recent) != 3 {
+		t.Fatalf("Expected the 3 retained events, got %d", len(recent))
+	}
+
+	// Newest first, oldest two evicted
+	for i, want := range []string{"10.0.0.4", "10.0.0.3", "10.0.0.2"} {
+		if got := recent[i].Neighbor.IP.String(); got != want {
+			t.Errorf("Expected event %d for %s, got %s", i, want, got)
+		}
+	}
+
+	if got := l.recent(1); len(got) != 1 || got[0].Neighbor.IP.String() != "10.0.0.4" {
+		t.Errorf("Expected only the newest event, got %v", got)
+	}
+}
+
+func TestNeighborManagerRecordsEvents(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+
+	ip := net.ParseIP("10.10.30.1")
+	nm.AddNeighbor(ip, 1, nil)
+	nm.RemoveNeighbor(ip, 1)
+
+	history := nm.History(ip.String(), 20)
+	if len(history) != 2 {
+		t.Fatalf("Expected 2, got %d", len(history))
+	}
+
+	if history[0].Timestamp.Before(history[1].Timestamp) {
+		t.Errorf("Expected events in descending timestamp order")
+	}
+}
+
+func TestWatchNeighborsSlowSubscriberDrops(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, EventBusCapacity: 1})
+
+	events, cancel := nm.WatchNeighbors()
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 1; i <= 3; i++ {
+			nm.recordEvent(EventAdd, Neighbor{IP: net.ParseIP(fmt.Sprintf("10.0.0.%d", i))})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected publishing to a full subscriber not to block")
+	}
+
+	e := <-events
+	if e.Neighbor.IP.String() != "10.0.0.1" {
+		t.Errorf("Expected first buffered event for 10.0.0.1, got %s", e.Neighbor.IP)
+	}
+
+	select {
+	case e := <-events:
+		t.Errorf("Expected overflow events to be dropped, got %s", e.Neighbor.IP)
+	default:
+	}
+
+	if nm.bus.dropped != 2 {
+		t.Errorf("Expected 2 dropped events, got %d", nm.bus.dropped)
+	}
+}
+
+func TestWatchNeighborsCancelClosesChannel(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+
+	events, cancel := nm.WatchNeighbors()
+	cancel()
+	cancel()
+
+	if _, ok := <-events; ok {
+		t.Errorf("Expected channel to be closed after cancel")
+	}
+
+	// Publishing after unsubscribe must not panic on the closed channel
+	nm.recordEvent(EventAdd, Neighbor{IP: net.ParseIP("10.0.0.1")})
+}
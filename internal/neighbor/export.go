@@ -0,0 +1,157 @@
+package neighbor
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/hostinger/neigh2route/pkg/netutils"
+)
+
+type neighborRecord struct {
+	IP            string `json:"ip"`
+	MAC           string `json:"mac"`
+	LinkIndex     int    `json:"link_index"`
+	Interface     string `json:"interface,omitempty"`
+	VlanID        int    `json:"vlan_id,omitempty"`
+	Permanent     bool   `json:"permanent,omitempty"`
+	LinkIndexes   []int  `json:"link_indexes,omitempty"`
+	PingTimeoutMS int64  `json:"ping_timeout_ms,omitempty"`
+}
+
+// neighborDump is a neighbor as shown by the API plus the internal fields
+// of the table entry.
+type neighborDump struct {
+	IP          string    `json:"ip"`
+	MAC         string    `json:"mac"`
+	LinkIndex   int       `json:"link_index"`
+	Interface   string    `json:"interface,omitempty"`
+	Afi         string    `json:"afi"`
+	Permanent   bool      `json:"permanent"`
+	VlanID      int       `json:"vlan_id,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastUpdated time.Time `json:"last_updated"`
+}
+
+// DumpJSON writes every entry of the neighbor table to w as a JSON array
+// sorted by IP. Unlike ExportJSON it includes the entries' timestamps.
+func (nm *NeighborManager) DumpJSON(w io.Writer) error {
+	nm.mu.Lock()
+	dumps := make([]neighborDump, 0, len(nm.ReachableNeighbors))
+	for _, n := range nm.ReachableNeighbors {
+		afi := "v4"
+		if n.IP.To4() == nil {
+			afi = "v6"
+		}
+		dumps = append(dumps, neighborDump{
+			IP:          n.IP.String(),
+			MAC:         n.HardwareAddr.String(),
+			LinkIndex:   n.LinkIndex,
+			Afi:         afi,
+			Permanent:   n.Permanent,
+			VlanID:      n.VlanID,
+			FirstSeen:   n.FirstSeen,
+			LastUpdated: n.LastUpdated,
+		})
+	}
+	nm.mu.Unlock()
+
+	for i := range dumps {
+		if iface, err := netutils.InterfaceByIndex(dumps[i].LinkIndex); err == nil {
+			dumps[i].Interface = iface.Name
+		}
+	}
+
+	sort.Slice(dumps, func(i, j int) bool {
+		if dumps[i].IP != dumps[j].IP {
+			return dumps[i].IP < dumps[j].IP
+		}
+		return dumps[i].VlanID < dumps[j].VlanID
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(dumps)
+}
+
+// ExportJSON writes the neighbor table to w as a JSON array sorted by IP.
+func (nm *NeighborManager) ExportJSON(w io.Writer) error {
+	neighbors := nm.ListNeighbors()
+
+	records := make([]neighborRecord, 0, len(neighbors))
+	for _, n := range neighbors {
+		record := neighborRecord{
+			IP:            n.IP.String(),
+			MAC:           n.HardwareAddr.String(),
+			LinkIndex:     n.LinkIndex,
+			VlanID:        n.VlanID,
+			Permanent:     n.Permanent,
+			PingTimeoutMS: n.PingTimeout.Milliseconds(),
+		}
+		if len(n.LinkIndexes) > 1 {
+			record.LinkIndexes = n.LinkIndexes
+		}
+		if iface, err := netutils.InterfaceByIndex(n.LinkIndex); err == nil {
+			record.Interface = iface.Name
+		}
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].IP != records[j].IP {
+			return records[i].IP < records[j].IP
+		}
+		return records[i].VlanID < records[j].VlanID
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// RouteInfo is the route installed for one neighbor.
+type RouteInfo struct {
+	Neighbor     net.IP
+	Dst          *net.IPNet
+	LinkIndexes  []int
+	HardwareAddr net.HardwareAddr
+	Table        int
+	Metric       uint32
+}
+
+// ExportRoutes returns the routes of every known neighbor sorted by
+// neighbor IP.
+func (nm *NeighborManager) ExportRoutes() []RouteInfo {
+	opts := nm.routeOptions()
+	nm.settingsMu.RLock()
+	metric := nm.RouteMetric
+	nm.settingsMu.RUnlock()
+
+	var routes []RouteInfo
+	for _, n := range nm.ListNeighbors() {
+		dst, err := netutils.RouteDst(n.IP, opts...)
+		if err != nil {
+			continue
+		}
+
+		routes = append(routes, RouteInfo{
+			Neighbor:     n.IP,
+			Dst:          dst,
+			LinkIndexes:  n.links(),
+			HardwareAddr: n.HardwareAddr,
+			Table:        nm.RouteTable,
+			Metric:       metric,
+		})
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if c := bytes.Compare(routes[i].Neighbor.To16(), routes[j].Neighbor.To16()); c != 0 {
+			return c < 0
+		}
+		return routes[i].LinkIndexes[0] < routes[j].LinkIndexes[0]
+	})
+	return routes
+}
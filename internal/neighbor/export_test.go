@@ -0,0 +1,83 @@
+package neighbor
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestExportJSON(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	nm.ReachableNeighbors["10.0.0.2"] = Neighbor{IP: net.ParseIP("10.0.0.2"), LinkIndex: 1, HardwareAddr: mac}
+	nm.ReachableNeighbors["10.0.0.1"] = Neighbor{IP: net.ParseIP("10.0.0.1"), LinkIndex: 1}
+
+	var buf bytes.Buffer
+	if err := nm.ExportJSON(&buf); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	var records []neighborRecord
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatalf("Could not unmarshal export: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Expected 2, got %d", len(records))
+	}
+
+	if records[0].IP != "10.0.0.1" || records[1].IP != "10.0.0.2" {
+		t.Errorf("Expected records sorted by IP, got %v", records)
+	}
+
+	if records[1].MAC != "00:11:22:33:44:55" || records[1].Interface != "lo" {
+		t.Errorf("Unexpected record: %+v", records[1])
+	}
+}
+
+func TestDumpJSONIncludesTimestamps(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	firstSeen := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lastUpdated := firstSeen.Add(time.Minute)
+	nm.ReachableNeighbors["2001:db8::1"] = Neighbor{IP: net.ParseIP("2001:db8::1"), LinkIndex: 1, Permanent: true, FirstSeen: firstSeen, LastUpdated: lastUpdated}
+	nm.ReachableNeighbors["10.0.0.1"] = Neighbor{IP: net.ParseIP("10.0.0.1"), LinkIndex: 1}
+
+	var buf bytes.Buffer
+	if err := nm.DumpJSON(&buf); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	var dumps []neighborDump
+	if err := json.Unmarshal(buf.Bytes(), &dumps); err != nil {
+		t.Fatalf("Could not unmarshal dump: %v", err)
+	}
+
+	if len(dumps) != 2 || dumps[0].IP != "10.0.0.1" || dumps[1].IP != "2001:db8::1" {
+		t.Fatalf("Expected both neighbors sorted by IP, got %+v", dumps)
+	}
+
+	got := dumps[1]
+	if got.Afi != "v6" || !got.Permanent || got.Interface != "lo" || !got.FirstSeen.Equal(firstSeen) || !got.LastUpdated.Equal(lastUpdated) {
+		t.Errorf("Expected API and internal fields, got %+v", got)
+	}
+}
+
+func TestExportRoutes(t *testing.T) {
+	nm, _ := NewNeighborManager("lo", WithRouteMetric(7), WithRoutePrefixLen(31, 0))
+	nm.ReachableNeighbors["2001:db8::1"] = Neighbor{IP: net.ParseIP("2001:db8::1"), LinkIndex: 1}
+	nm.ReachableNeighbors["10.0.0.3"] = Neighbor{IP: net.ParseIP("10.0.0.3"), LinkIndex: 1, LinkIndexes: []int{1, 4}}
+
+	routes := nm.ExportRoutes()
+	if len(routes) != 2 {
+		t.Fatalf("Expected 2 routes, got %+v", routes)
+	}
+
+	if routes[0].Dst.String() != "10.0.0.2/31" || len(routes[0].LinkIndexes) != 2 || routes[0].Metric != 7 {
+		t.Errorf("Unexpected IPv4 route %+v", routes[0])
+	}
+	if routes[1].Dst.String() != "2001:db8::1/128" || len(routes[1].LinkIndexes) != 1 || routes[1].LinkIndexes[0] != 1 {
+		t.Errorf("Unexpected IPv6 route %+v", routes[1])
+	}
+}
@@ -1,35 +1,76 @@
 package neighbor
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/vishvananda/netlink"
 )
 
-func neighborStateToString(state int) string {
-	states := []string{}
-	if state&netlink.NUD_INCOMPLETE != 0 {
-		states = append(states, "INCOMPLETE")
-	}
-	if state&netlink.NUD_REACHABLE != 0 {
-		states = append(states, "REACHABLE")
-	}
-	if state&netlink.NUD_STALE != 0 {
-		states = append(states, "STALE")
+var nudStateNames = []struct {
+	state int
+	name  string
+}{
+	{netlink.NUD_INCOMPLETE, "INCOMPLETE"},
+	{netlink.NUD_REACHABLE, "REACHABLE"},
+	{netlink.NUD_STALE, "STALE"},
+	{netlink.NUD_DELAY, "DELAY"},
+	{netlink.NUD_PROBE, "PROBE"},
+	{netlink.NUD_FAILED, "FAILED"},
+	{netlink.NUD_NOARP, "NOARP"},
+	{netlink.NUD_PERMANENT, "PERMANENT"},
+}
+
+// NUDStateString returns the name of a netlink.NUD_* state. Combined states
+// are joined with "|"; values with unrecognized bits yield "UNKNOWN(N)".
+func NUDStateString(state int) string {
+	if state == netlink.NUD_NONE {
+		return "NONE"
 	}
-	if state&netlink.NUD_DELAY != 0 {
-		states = append(states, "DELAY")
+
+	states := []string{}
+	remaining := state
+	for _, s := range nudStateNames {
+		if state&s.state != 0 {
+			states = append(states, s.name)
+			remaining &^= s.state
+		}
 	}
-	if state&netlink.NUD_PROBE != 0 {
-		states = append(states, "PROBE")
+
+	if remaining != 0 {
+		return fmt.Sprintf("UNKNOWN(%d)", state)
 	}
-	if state&netlink.NUD_FAILED != 0 {
-		states = append(states, "FAILED")
+	return strings.Join(states, "|")
+}
+
+// routableStates are the names accepted by ParseNeighborStates.
+var routableStates = map[string]int{
+	"reachable": netlink.NUD_REACHABLE,
+	"stale":     netlink.NUD_STALE,
+	"permanent": netlink.NUD_PERMANENT,
+	"noarp":     netlink.NUD_NOARP,
+}
+
+// ParseNeighborStates turns a comma-separated list of state names
+// (reachable, stale, permanent, noarp) into a NeighborPolicy.StateMask.
+func ParseNeighborStates(s string) (int, error) {
+	mask := 0
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		state, ok := routableStates[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown neighbor state %q, expected reachable, stale, permanent or noarp", name)
+		}
+		mask |= state
 	}
-	if len(states) == 0 {
-		return "UNKNOWN"
+
+	if mask == 0 {
+		return 0, fmt.Errorf("no neighbor states given")
 	}
-	return strings.Join(states, "|")
+	return mask, nil
 }
 
 func neighborFlagsToString(flags int) string {
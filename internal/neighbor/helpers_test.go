@@ -0,0 +1,47 @@
+package neighbor
+
+import (
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestNUDStateString(t *testing.T) {
+	tests := []struct {
+		state int
+		want  string
+	}{
+		{netlink.NUD_REACHABLE, "REACHABLE"},
+		{netlink.NUD_STALE, "STALE"},
+		{netlink.NUD_DELAY, "DELAY"},
+		{netlink.NUD_PROBE, "PROBE"},
+		{netlink.NUD_FAILED, "FAILED"},
+		{netlink.NUD_PERMANENT, "PERMANENT"},
+		{netlink.NUD_NONE, "NONE"},
+		{netlink.NUD_REACHABLE | netlink.NUD_STALE, "REACHABLE|STALE"},
+		{0x100, "UNKNOWN(256)"},
+	}
+
+	for _, tt := range tests {
+		if got := NUDStateString(tt.state); got != tt.want {
+			t.Errorf("NUDStateString(%#x) = %q, want %q", tt.state, got, tt.want)
+		}
+	}
+}
+
+func TestParseNeighborStates(t *testing.T) {
+	mask, err := ParseNeighborStates("reachable, STALE,permanent,noarp")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := netlink.NUD_REACHABLE | netlink.NUD_STALE | netlink.NUD_PERMANENT | netlink.NUD_NOARP
+	if mask != want {
+		t.Errorf("Expected mask %s, got %s", NUDStateString(want), NUDStateString(mask))
+	}
+
+	for _, s := range []string{"", " , ", "reachable,failed"} {
+		if _, err := ParseNeighborStates(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
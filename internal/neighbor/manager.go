@@ -1,123 +1,1105 @@
 package neighbor
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"net"
-	"os"
 	"sync"
 	"time"
 
 	"github.com/hostinger/neigh2route/internal/logger"
 	"github.com/hostinger/neigh2route/pkg/netutils"
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+	"golang.org/x/time/rate"
 )
 
-func NewNeighborManager(targetInterface string) (*NeighborManager, error) {
+var sampledLog = logger.NewSampler(logger.Default(), 10)
+
+var ErrNeighborNotFound = errors.New("neighbor not found")
+
+var (
+	neighSet  = netlink.NeighSet
+	neighDel  = netlink.NeighDel
+	neighList = netlink.NeighList
+	ping      = netutils.Ping
+	sendNS    = netutils.SendNeighborSolicitation
+
+	neighSubscribe      = subscribeLinks
+	monitorRetryBackoff = time.Second
+)
+
+// NewNeighborManager returns a manager for targetInterface, or for every
+// interface when it is empty, with opts applied to the default Config.
+func NewNeighborManager(targetInterface string, opts ...Option) (*NeighborManager, error) {
+	var cfg Config
+	if targetInterface != "" {
+		cfg.TargetInterfaces = []string{targetInterface}
+	}
+	nm, err := NewNeighborManagerFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(nm)
+	}
+	if err := nm.currentPolicy.validate(); err != nil {
+		return nil, err
+	}
+	return nm, nil
+}
+
+func NewNeighborManagerFromConfig(cfg Config) (*NeighborManager, error) {
+	if cfg.RouteRetries <= 0 {
+		cfg.RouteRetries = DefaultRouteRetries
+	}
+	if cfg.RouteRetryBackoff <= 0 {
+		cfg.RouteRetryBackoff = DefaultRouteRetryBackoff
+	}
+	if cfg.MaxPauseBuffer <= 0 {
+		cfg.MaxPauseBuffer = DefaultMaxPauseBuffer
+	}
+	if cfg.EventHistorySize <= 0 {
+		cfg.EventHistorySize = DefaultEventHistorySize
+	}
+	if cfg.EventBusCapacity <= 0 {
+		cfg.EventBusCapacity = DefaultEventBusCapacity
+	}
+	if cfg.AddRateLimit <= 0 {
+		cfg.AddRateLimit = DefaultAddRateLimit
+	}
+	if cfg.AddBurst <= 0 {
+		cfg.AddBurst = DefaultAddBurst
+	}
+	if cfg.PingInterval <= 0 {
+		cfg.PingInterval = DefaultPingInterval
+	}
+	if cfg.PingTimeout <= 0 {
+		cfg.PingTimeout = DefaultPingTimeout
+	}
+	if cfg.InitWorkers <= 0 {
+		cfg.InitWorkers = DefaultInitWorkers
+	}
+	if cfg.RouteTable <= 0 {
+		cfg.RouteTable = unix.RT_TABLE_MAIN
+	}
+	if cfg.Context == nil {
+		cfg.Context = context.Background()
+	}
+	if cfg.Policy.StateMask == 0 {
+		cfg.Policy.StateMask = DefaultStateMask
+	}
+
+	if err := cfg.Policy.validate(); err != nil {
+		return nil, err
+	}
+
 	nm := &NeighborManager{
-		TargetInterface:    targetInterface,
+		TargetInterfaces:   cfg.TargetInterfaces,
 		ReachableNeighbors: make(map[string]Neighbor),
+		RouteRetries:       cfg.RouteRetries,
+		RouteRetryBackoff:  cfg.RouteRetryBackoff,
+		currentPolicy:      cfg.Policy,
+		MaxPauseBuffer:     cfg.MaxPauseBuffer,
+		CleanupOnStart:     cfg.CleanupOnStart,
+		DryRun:             cfg.DryRun,
+		VLANAware:          cfg.VLANAware,
+		ECMP:               cfg.ECMP,
+		FailedHold:         cfg.FailedHold,
+		MigrationGrace:     cfg.MigrationGrace,
+		MaxNeighbors:       cfg.MaxNeighbors,
+		EvictPolicy:        cfg.EvictPolicy,
+		pendingRemovals:    make(map[string]*time.Timer),
+		pendingMigrations:  make(map[string]pendingMigration),
+		addLimiter:         rate.NewLimiter(rate.Limit(cfg.AddRateLimit), cfg.AddBurst),
+		PingInterval:       cfg.PingInterval,
+		PingTimeout:        cfg.PingTimeout,
+		PingBackoff:        cfg.PingBackoff,
+		UseNS:              cfg.UseNS,
+		pingFailureCounts:  make(map[string]int),
+		nextPingAt:         make(map[string]time.Time),
+		pingTimeouts:       make(map[string]time.Duration),
+		ARPTable:           cfg.ARPTable,
+		RouteMetric:        cfg.RouteMetric,
+		RouteTable:         cfg.RouteTable,
+		RouteProtocol:      cfg.RouteProtocol,
+		RouteScope:         cfg.RouteScope,
+		IPv4PrefixLen:      cfg.IPv4PrefixLen,
+		IPv6PrefixLen:      cfg.IPv6PrefixLen,
+		IPv6Gateway:        cfg.IPv6Gateway,
+		InitWorkers:        cfg.InitWorkers,
+		StateFile:          cfg.StateFile,
+		ctx:                cfg.Context,
+		auditLog:           cfg.AuditLog,
+		extLearnedOwner:    cfg.ExtLearnedOwner,
+		events:             newEventLog(cfg.EventHistorySize),
+		bus:                newEventBus(cfg.EventBusCapacity),
+		routeNotifier:      cfg.RouteNotifier,
 	}
 
-	if targetInterface != "" {
-		iface, err := netlink.LinkByName(targetInterface)
+	nm.lastUpdateTime.Store(time.Now())
+
+	for _, name := range cfg.TargetInterfaces {
+		iface, err := netlink.LinkByName(name)
 		if err != nil {
 			return nil, err
 		}
-		nm.TargetInterfaceIndex = iface.Attrs().Index
-	} else {
-		nm.TargetInterfaceIndex = -1
+		nm.TargetInterfaceIndexes = append(nm.TargetInterfaceIndexes, iface.Attrs().Index)
+	}
+
+	return nm, nil
+}
+
+// monitorsLink reports whether updates on linkIndex should be handled.
+func (nm *NeighborManager) monitorsLink(linkIndex int) bool {
+	if len(nm.TargetInterfaceIndexes) == 0 {
+		return true
+	}
+	for _, index := range nm.TargetInterfaceIndexes {
+		if index == linkIndex {
+			return true
+		}
+	}
+	return false
+}
+
+func (n Neighbor) LinkIndexChanged(linkIndex int) bool {
+	return n.LinkIndex != linkIndex
+}
+
+// links returns a copy of the links the route of n goes over.
+func (n Neighbor) links() []int {
+	if len(n.LinkIndexes) == 0 {
+		return []int{n.LinkIndex}
+	}
+	return append([]int(nil), n.LinkIndexes...)
+}
+
+func (n Neighbor) hasLink(linkIndex int) bool {
+	for _, index := range n.links() {
+		if index == linkIndex {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutLink returns n with linkIndex dropped from its ECMP links.
+func (n Neighbor) withoutLink(linkIndex int) Neighbor {
+	var remaining []int
+	for _, index := range n.LinkIndexes {
+		if index != linkIndex {
+			remaining = append(remaining, index)
+		}
+	}
+	n.LinkIndexes = remaining
+	if n.LinkIndex == linkIndex && len(remaining) > 0 {
+		n.LinkIndex = remaining[len(remaining)-1]
+	}
+	return n
+}
+
+func (nm *NeighborManager) AddNeighbor(ip net.IP, linkIndex int, hwAddr net.HardwareAddr) {
+	nm.addNeighbor(ip, linkIndex, hwAddr, 0, false)
+}
+
+// anyVLAN matches the entries of an IP on every VLAN.
+const anyVLAN = -1
+
+// neighborKey returns the ReachableNeighbors key of ip. With VLANAware set
+// the same IP on different VLANs gets separate entries.
+func (nm *NeighborManager) neighborKey(ip net.IP, vlanID int) string {
+	if !nm.VLANAware {
+		return ip.String()
+	}
+	return fmt.Sprintf("%s@vlan%d", ip, vlanID)
+}
+
+// keysLocked returns the keys of ip's entries on vlanID, or on every VLAN
+// for anyVLAN. Callers hold mu.
+func (nm *NeighborManager) keysLocked(ip net.IP, vlanID int) []string {
+	if !nm.VLANAware || vlanID != anyVLAN {
+		key := nm.neighborKey(ip, vlanID)
+		if _, ok := nm.ReachableNeighbors[key]; ok {
+			return []string{key}
+		}
+		return nil
+	}
+
+	var keys []string
+	for key, n := range nm.ReachableNeighbors {
+		if n.IP.Equal(ip) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// addNeighbor adds a route for the neighbor. When limited is set, new routes
+// are subject to addLimiter and dropped once it is exhausted.
+func (nm *NeighborManager) addNeighbor(ip net.IP, linkIndex int, hwAddr net.HardwareAddr, vlanID int, limited bool) {
+	var shouldRemoveRoute, migrating, addingNexthop bool
+
+	if !nm.matchesPrefix(ip) {
+		logger.Debug("Ignoring neighbor %s outside the configured prefixes", ip.String())
+		return
+	}
+
+	now := time.Now()
+	key := nm.neighborKey(ip, vlanID)
+
+	nm.mu.Lock()
+	neighbor, exists := nm.ReachableNeighbors[key]
+	if exists {
+		if neighbor.Permanent {
+			nm.mu.Unlock()
+			return
+		}
+		macChanged := len(hwAddr) > 0 && len(neighbor.HardwareAddr) > 0 && !bytes.Equal(hwAddr, neighbor.HardwareAddr)
+		if macChanged {
+			// Either the address moved to another host, e.g. a migrated
+			// VM, or someone is spoofing it.
+			nm.macChanges.Add(1)
+			logger.WarnFields("Neighbor MAC address changed", map[string]interface{}{
+				"ip":         ip.String(),
+				"link_index": linkIndex,
+				"old_mac":    neighbor.HardwareAddr.String(),
+				"new_mac":    hwAddr.String(),
+			})
+		}
+		if !neighbor.LinkIndexChanged(linkIndex) || (nm.ECMP && neighbor.hasLink(linkIndex)) {
+			neighbor.LastUpdated = now
+			if len(hwAddr) > 0 {
+				neighbor.HardwareAddr = hwAddr
+			}
+			nm.ReachableNeighbors[key] = neighbor
+			nm.mu.Unlock()
+
+			if macChanged {
+				if nm.ARPTable {
+					nm.setKernelNeighbor(neighbor)
+				}
+				nm.persistState()
+			}
+			return
+		}
+		switch {
+		case nm.ECMP:
+			logger.Info("Neighbor %s seen on link index %d, adding ECMP nexthop", ip.String(), linkIndex)
+			addingNexthop = true
+		case nm.MigrationGrace > 0:
+			logger.Info("Neighbor %s link index changed, re-adding neighbor", ip.String())
+			migrating = true
+		default:
+			logger.Info("Neighbor %s link index changed, re-adding neighbor", ip.String())
+			shouldRemoveRoute = true
+		}
+	}
+
+	if limited && nm.addLimiter != nil && !nm.addLimiter.Allow() {
+		nm.mu.Unlock()
+		sampledLog.Warn("Route add rate limit exceeded, dropping update for neighbor %s", ip.String())
+		return
+	}
+
+	var evicted *Neighbor
+	var evictedInUse bool
+	if !exists && nm.MaxNeighbors > 0 && len(nm.ReachableNeighbors) >= nm.MaxNeighbors {
+		if nm.EvictPolicy == EvictLRU {
+			if victimKey, ok := nm.lruKeyLocked(); ok {
+				victim := nm.ReachableNeighbors[victimKey]
+				delete(nm.ReachableNeighbors, victimKey)
+				evicted = &victim
+				evictedInUse = len(nm.keysLocked(victim.IP, anyVLAN)) > 0
+			}
+		}
+		if evicted == nil {
+			nm.mu.Unlock()
+			nm.neighborsRejected.Add(1)
+			sampledLog.Warn("Neighbor table full (%d entries), dropping neighbor %s", nm.MaxNeighbors, ip.String())
+			return
+		}
+	}
+
+	firstSeen := now
+	if exists {
+		firstSeen = neighbor.FirstSeen
+	}
+	oldLinkIndex := neighbor.LinkIndex
+
+	var linkIndexes []int
+	if addingNexthop {
+		linkIndexes = append(neighbor.links(), linkIndex)
+	} else if nm.ECMP {
+		linkIndexes = []int{linkIndex}
+	}
+
+	neighbor = Neighbor{
+		IP:           ip,
+		LinkIndex:    linkIndex,
+		HardwareAddr: hwAddr,
+		VlanID:       vlanID,
+		LinkIndexes:  linkIndexes,
+		PingTimeout:  nm.pingTimeouts[ip.String()],
+		FirstSeen:    firstSeen,
+		LastUpdated:  now,
+	}
+	nm.ReachableNeighbors[key] = neighbor
+	nm.mu.Unlock()
+
+	if evicted != nil {
+		nm.evict(*evicted, evictedInUse)
+	}
+
+	if shouldRemoveRoute {
+		if err := nm.removeRoute(ip, oldLinkIndex); err != nil {
+			logger.Error("Failed to remove old route for neighbor %s: %v", ip.String(), err)
+			return
+		}
+	}
+
+	if addingNexthop {
+		if err := nm.addNexthops(neighbor, []int{linkIndex}); err != nil {
+			logger.Error("Failed to add ECMP nexthop for neighbor %s: %v", ip.String(), err)
+			return
+		}
+	} else if migrating {
+		// The old route stays until the grace period ends, so the new one
+		// goes next to it with a metric that takes the traffic over.
+		opts := append(nm.gatewayOptions(ip, linkIndex, hwAddr), nm.migrationOptions(ip)...)
+		if err := nm.addRoute(ip, linkIndex, opts...); err != nil {
+			logger.Error("Failed to add route for neighbor %s: %v", ip.String(), err)
+			return
+		}
+		nm.scheduleMigration(ip, vlanID, oldLinkIndex, linkIndex)
+	} else if err := nm.addRoute(ip, linkIndex, nm.gatewayOptions(ip, linkIndex, hwAddr)...); err != nil {
+		logger.Error("Failed to add route for neighbor %s: %v", ip.String(), err)
+		return
+	}
+
+	nm.recordEvent(EventAdd, neighbor)
+
+	if nm.ARPTable {
+		nm.setKernelNeighbor(neighbor)
+	}
+
+	logger.InfoFields("Added neighbor", map[string]interface{}{
+		"ip":         ip.String(),
+		"link_index": linkIndex,
+		"mac":        hwAddr.String(),
+	})
+	nm.persistState()
+}
+
+// lruKeyLocked returns the key of the least recently updated non-permanent
+// neighbor. Callers hold mu.
+func (nm *NeighborManager) lruKeyLocked() (string, bool) {
+	var (
+		oldestKey string
+		oldest    time.Time
+		found     bool
+	)
+	for key, n := range nm.ReachableNeighbors {
+		if n.Permanent {
+			continue
+		}
+		if !found || n.LastUpdated.Before(oldest) {
+			oldestKey, oldest, found = key, n.LastUpdated, true
+		}
+	}
+	return oldestKey, found
+}
+
+// evict finishes removing n, which addNeighbor already dropped from the
+// table to make room. inUse keeps the route of an IP still learned on
+// another VLAN.
+func (nm *NeighborManager) evict(n Neighbor, inUse bool) {
+	nm.neighborsEvicted.Add(1)
+	logger.Warn("Neighbor table full (%d entries), evicted least recently updated neighbor %s", nm.MaxNeighbors, n.IP.String())
+	nm.recordEvent(EventRemove, n)
+
+	if nm.ARPTable {
+		nm.deleteKernelNeighbor(n)
+	}
+	if inUse || nm.routeShared(n.IP, n.links()...) {
+		return
+	}
+	if err := nm.removeNeighborRoutes(nm.ctx, n); err != nil {
+		logger.Error("Failed to remove route for evicted neighbor %s: %v", n.IP.String(), err)
+	}
+}
+
+// routeOptions returns the route settings applied to every route this
+// manager adds or removes.
+func (nm *NeighborManager) routeOptions() []netutils.RouteOption {
+	nm.settingsMu.RLock()
+	defer nm.settingsMu.RUnlock()
+
+	return nm.routeOptionsLocked()
+}
+
+func (nm *NeighborManager) routeOptionsLocked() []netutils.RouteOption {
+	opts := []netutils.RouteOption{netutils.WithTable(nm.RouteTable)}
+	if nm.RouteMetric > 0 {
+		opts = append(opts, netutils.WithMetric(nm.RouteMetric))
+	}
+	if nm.RouteProtocol > 0 {
+		opts = append(opts, netutils.WithProtocol(nm.RouteProtocol))
+	}
+	if nm.RouteScope != nil {
+		opts = append(opts, netutils.WithScope(*nm.RouteScope))
+	}
+	if nm.IPv4PrefixLen > 0 || nm.IPv6PrefixLen > 0 {
+		opts = append(opts, netutils.WithPrefixLen(nm.IPv4PrefixLen, nm.IPv6PrefixLen))
+	}
+	if nm.DryRun {
+		opts = append(opts, netutils.WithDryRun())
+	}
+	return opts
+}
+
+// SetRouteMetric changes the metric of future routes and reinstalls the
+// routes of known neighbors with it.
+func (nm *NeighborManager) SetRouteMetric(metric uint32) {
+	nm.settingsMu.Lock()
+	if nm.RouteMetric == metric {
+		nm.settingsMu.Unlock()
+		return
+	}
+	oldOpts := nm.routeOptionsLocked()
+	nm.RouteMetric = metric
+	nm.settingsMu.Unlock()
+
+	logger.Info("Route metric changed to %d, reinstalling neighbor routes", metric)
+
+	// The routes at the two metrics coexist, so add the new one before
+	// removing the old one and the neighbor stays reachable throughout.
+	for _, n := range nm.ListNeighbors() {
+		if len(n.LinkIndexes) > 1 {
+			if err := nm.addNexthops(n, n.LinkIndexes); err != nil {
+				logger.Error("Failed to add route for neighbor %s: %v", n.IP.String(), err)
+				continue
+			}
+			if err := nm.removeNeighborRoutesWith(nm.ctx, n, oldOpts); err != nil {
+				logger.Error("Failed to remove route for neighbor %s: %v", n.IP.String(), err)
+			}
+			continue
+		}
+		if err := nm.addRoute(n.IP, n.LinkIndex, nm.gatewayOptions(n.IP, n.LinkIndex, n.HardwareAddr)...); err != nil {
+			logger.Error("Failed to add route for neighbor %s: %v", n.IP.String(), err)
+			continue
+		}
+		if err := nm.removeRouteWith(nm.ctx, n.IP, n.LinkIndex, oldOpts); err != nil {
+			logger.Error("Failed to remove route for neighbor %s: %v", n.IP.String(), err)
+		}
+	}
+}
+
+func (nm *NeighborManager) addRoute(ip net.IP, linkIndex int, extra ...netutils.RouteOption) error {
+	opts := append(nm.routeOptions(), extra...)
+	if err := netutils.AddRouteWithRetry(nm.ctx, ip, linkIndex, nm.RouteRetries, nm.RouteRetryBackoff, opts...); err != nil {
+		return err
+	}
+	nm.routesAdded.Add(1)
+	nm.auditRoute(AuditOpAdd, ip, linkIndex)
+	return nil
+}
+
+// gatewayOptions routes a global IPv6 neighbor via its own link-local
+// address when IPv6Gateway is set: the kernel entry on linkIndex with the
+// same MAC. It returns no option, leaving the route on-link, when there is
+// no such entry.
+func (nm *NeighborManager) gatewayOptions(ip net.IP, linkIndex int, hwAddr net.HardwareAddr) []netutils.RouteOption {
+	if !nm.IPv6Gateway || ip.To4() != nil || !ip.IsGlobalUnicast() || len(hwAddr) == 0 {
+		return nil
+	}
+
+	neighs, err := neighList(linkIndex, netlink.FAMILY_V6)
+	if err != nil {
+		logger.Warn("Failed to list IPv6 neighbors on link index %d, adding an on-link route for %s: %v", linkIndex, ip.String(), err)
+		return nil
+	}
+	for _, n := range neighs {
+		if n.IP.IsLinkLocalUnicast() && bytes.Equal(n.HardwareAddr, hwAddr) && n.State&(netlink.NUD_INCOMPLETE|netlink.NUD_FAILED) == 0 {
+			return []netutils.RouteOption{netutils.WithGateway(n.IP)}
+		}
+	}
+
+	logger.Debug("No link-local address known for neighbor %s on link index %d, adding an on-link route", ip.String(), linkIndex)
+	return nil
+}
+
+func (nm *NeighborManager) removeRoute(ip net.IP, linkIndex int) error {
+	if nm.routeShared(ip, linkIndex) {
+		logger.Debug("Keeping route for %s on link index %d, still used by another neighbor", ip.String(), linkIndex)
+		return nil
+	}
+	return nm.removeRouteContext(nm.ctx, ip, linkIndex)
+}
+
+// routeShared reports whether a neighbor other than ip still uses the route
+// of ip on one of links. That happens when a prefix length below the host
+// length maps several neighbors to the same destination, so the route may
+// only go with the last of them.
+func (nm *NeighborManager) routeShared(ip net.IP, links ...int) bool {
+	opts := nm.routeOptions()
+	dst, err := netutils.RouteDst(ip, opts...)
+	if err != nil {
+		return false
+	}
+	if ones, bits := dst.Mask.Size(); ones == bits {
+		return false
+	}
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	for _, n := range nm.ReachableNeighbors {
+		if n.IP.Equal(ip) {
+			continue
+		}
+		other, err := netutils.RouteDst(n.IP, opts...)
+		if err != nil || !other.IP.Equal(dst.IP) || other.Mask.String() != dst.Mask.String() {
+			continue
+		}
+		for _, linkIndex := range links {
+			if n.hasLink(linkIndex) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (nm *NeighborManager) removeRouteContext(ctx context.Context, ip net.IP, linkIndex int) error {
+	return nm.removeRouteWith(ctx, ip, linkIndex, nm.routeOptions())
+}
+
+// removeRouteWith removes the route of ip on linkIndex that was installed
+// with opts rather than the current route options.
+func (nm *NeighborManager) removeRouteWith(ctx context.Context, ip net.IP, linkIndex int, opts []netutils.RouteOption) error {
+	if err := netutils.RemoveRoute(ctx, ip, linkIndex, opts...); err != nil {
+		return err
+	}
+	nm.routesRemoved.Add(1)
+	nm.auditRoute(AuditOpRemove, ip, linkIndex)
+	return nil
+}
+
+// addNexthops installs the ECMP route of n over n.LinkIndexes after the
+// links in added joined. The kernel only balances device-only nexthops for
+// IPv4, so IPv6 gets one route per link instead.
+func (nm *NeighborManager) addNexthops(n Neighbor, added []int) error {
+	if n.IP.To4() == nil {
+		for _, linkIndex := range added {
+			opts := append(nm.gatewayOptions(n.IP, linkIndex, n.HardwareAddr), netutils.WithAppend())
+			if err := nm.addRoute(n.IP, linkIndex, opts...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := netutils.SetMultipathRoute(nm.ctx, n.IP, n.LinkIndexes, nm.routeOptions()...); err != nil {
+		return err
+	}
+	nm.routesAdded.Add(1)
+	for _, linkIndex := range added {
+		nm.auditRoute(AuditOpAdd, n.IP, linkIndex)
+	}
+	return nil
+}
+
+// removeNexthop drops linkIndex from the ECMP route of n, which no longer
+// lists it.
+func (nm *NeighborManager) removeNexthop(n Neighbor, linkIndex int) error {
+	if n.IP.To4() == nil {
+		return nm.removeRoute(n.IP, linkIndex)
+	}
+
+	if err := netutils.SetMultipathRoute(nm.ctx, n.IP, n.LinkIndexes, nm.routeOptions()...); err != nil {
+		return err
+	}
+	nm.routesRemoved.Add(1)
+	nm.auditRoute(AuditOpRemove, n.IP, linkIndex)
+	return nil
+}
+
+// removeNeighborRoutes removes the route of n on every one of its links.
+func (nm *NeighborManager) removeNeighborRoutes(ctx context.Context, n Neighbor) error {
+	return nm.removeNeighborRoutesWith(ctx, n, nm.routeOptions())
+}
+
+// removeNeighborRoutesWith removes the routes of n that were installed
+// with opts rather than the current route options.
+func (nm *NeighborManager) removeNeighborRoutesWith(ctx context.Context, n Neighbor, opts []netutils.RouteOption) error {
+	links := n.links()
+	if n.IP.To4() != nil && len(links) > 1 {
+		if err := netutils.RemoveMultipathRoute(ctx, n.IP, links, opts...); err != nil {
+			return err
+		}
+	} else {
+		for _, linkIndex := range links {
+			if err := netutils.RemoveRoute(ctx, n.IP, linkIndex, opts...); err != nil {
+				return err
+			}
+		}
+	}
+
+	nm.routesRemoved.Add(1)
+	for _, linkIndex := range links {
+		nm.auditRoute(AuditOpRemove, n.IP, linkIndex)
+	}
+	return nil
+}
+
+// LastUpdate returns when the last kernel neighbor update was received, or
+// when the manager was created if none has arrived yet.
+func (nm *NeighborManager) LastUpdate() time.Time {
+	return nm.lastUpdateTime.Load().(time.Time)
+}
+
+// Stats returns a snapshot of the neighbor table size and route counters.
+func (nm *NeighborManager) Stats() Stats {
+	nm.mu.Lock()
+	stats := Stats{TotalNeighbors: len(nm.ReachableNeighbors)}
+	for _, n := range nm.ReachableNeighbors {
+		if n.IP.To4() != nil {
+			stats.IPv4Neighbors++
+		} else {
+			stats.IPv6Neighbors++
+		}
+	}
+	nm.mu.Unlock()
+
+	stats.RoutesAdded = nm.routesAdded.Load()
+	stats.RoutesRemoved = nm.routesRemoved.Load()
+	stats.PingFailures = nm.pingFailures.Load()
+	stats.NetlinkReconnects = nm.netlinkReconnects.Load()
+	stats.NeighborsRejected = nm.neighborsRejected.Load()
+	stats.NeighborsEvicted = nm.neighborsEvicted.Load()
+	stats.MACChanges = nm.macChanges.Load()
+	return stats
+}
+
+// AddNeighborPermanent pins ip to mac with a NUD_PERMANENT kernel entry and
+// installs its route. The neighbor is kept until removed explicitly.
+func (nm *NeighborManager) AddNeighborPermanent(ip net.IP, mac net.HardwareAddr, linkIndex int) error {
+	if len(mac) == 0 {
+		return fmt.Errorf("permanent neighbor %s requires a hardware address", ip)
+	}
+
+	now := time.Now()
+	neighbor := Neighbor{
+		IP:           ip,
+		LinkIndex:    linkIndex,
+		HardwareAddr: mac,
+		Permanent:    true,
+		FirstSeen:    now,
+		LastUpdated:  now,
+	}
+
+	if err := neighSet(kernelNeigh(neighbor)); err != nil {
+		return fmt.Errorf("failed to set permanent neighbor entry for %s: %w", ip, err)
+	}
+
+	if old, exists := nm.GetNeighbor(ip); exists && old.LinkIndexChanged(linkIndex) {
+		if err := nm.removeRoute(ip, old.LinkIndex); err != nil {
+			return fmt.Errorf("failed to remove old route for %s: %w", ip, err)
+		}
+	}
+
+	if err := nm.addRoute(ip, linkIndex, nm.gatewayOptions(ip, linkIndex, mac)...); err != nil {
+		return fmt.Errorf("failed to add route for %s: %w", ip, err)
+	}
+
+	key := nm.neighborKey(ip, 0)
+	nm.mu.Lock()
+	if old, exists := nm.ReachableNeighbors[key]; exists {
+		neighbor.FirstSeen = old.FirstSeen
+	}
+	nm.ReachableNeighbors[key] = neighbor
+	nm.mu.Unlock()
+
+	nm.recordEvent(EventAdd, neighbor)
+	nm.persistState()
+
+	logger.InfoFields("Added permanent neighbor", map[string]interface{}{
+		"ip":         ip.String(),
+		"link_index": linkIndex,
+		"mac":        mac.String(),
+	})
+	return nil
+}
+
+// setKernelNeighbor pins n in the kernel ARP/ND table so the first packet
+// routed to it does not wait for address resolution.
+func (nm *NeighborManager) setKernelNeighbor(n Neighbor) {
+	if len(n.HardwareAddr) == 0 {
+		return
+	}
+
+	if err := neighSet(kernelNeigh(n)); err != nil {
+		logger.Error("Failed to set kernel neighbor entry for %s: %v", n.IP.String(), err)
+	}
+}
+
+func (nm *NeighborManager) deleteKernelNeighbor(n Neighbor) {
+	if len(n.HardwareAddr) == 0 {
+		return
+	}
+
+	if err := neighDel(kernelNeigh(n)); err != nil {
+		logger.Error("Failed to delete kernel neighbor entry for %s: %v", n.IP.String(), err)
+	}
+}
+
+func kernelNeigh(n Neighbor) *netlink.Neigh {
+	family := netlink.FAMILY_V4
+	if n.IP.To4() == nil {
+		family = netlink.FAMILY_V6
+	}
+
+	return &netlink.Neigh{
+		LinkIndex:    n.LinkIndex,
+		Family:       family,
+		State:        netlink.NUD_PERMANENT,
+		IP:           n.IP,
+		HardwareAddr: n.HardwareAddr,
+	}
+}
+
+func (nm *NeighborManager) RemoveNeighbor(ip net.IP, linkIndex int) {
+	if _, err := nm.removeNeighbor(ip, linkIndex); err != nil {
+		logger.Error("Failed to remove route for neighbor %s: %v", ip.String(), err)
+	}
+}
+
+// anyLink removes a neighbor from every link it is known on, rather than
+// only dropping one nexthop of an ECMP neighbor.
+const anyLink = -1
+
+// RemoveNeighborAllLinks removes ip and its routes on every link it is
+// known on.
+func (nm *NeighborManager) RemoveNeighborAllLinks(ip net.IP) {
+	nm.RemoveNeighbor(ip, anyLink)
+}
+
+func (nm *NeighborManager) removeNeighbor(ip net.IP, linkIndex int) (bool, error) {
+	return nm.removeVLANNeighbor(ip, anyVLAN, linkIndex)
+}
+
+// removeVLANNeighbor removes ip's entries on vlanID, or on every VLAN for
+// anyVLAN, from linkIndex, or from every link for anyLink. The route is
+// kept while another VLAN still has an entry for ip.
+func (nm *NeighborManager) removeVLANNeighbor(ip net.IP, vlanID int, linkIndex int) (bool, error) {
+	nm.mu.Lock()
+	var removed, shrunk []Neighbor
+	for _, key := range nm.keysLocked(ip, vlanID) {
+		n := nm.ReachableNeighbors[key]
+		if nm.ECMP && linkIndex != anyLink && len(n.LinkIndexes) > 1 && n.hasLink(linkIndex) {
+			// Only this nexthop is gone, the neighbor is still reachable
+			// over its other links.
+			n = n.withoutLink(linkIndex)
+			nm.ReachableNeighbors[key] = n
+			shrunk = append(shrunk, n)
+			continue
+		}
+		removed = append(removed, n)
+		delete(nm.ReachableNeighbors, key)
+	}
+	inUse := len(nm.keysLocked(ip, anyVLAN)) > 0
+	nm.mu.Unlock()
+
+	for _, n := range shrunk {
+		logger.Info("Removed link index %d from ECMP neighbor %s", linkIndex, ip.String())
+		if err := nm.removeNexthop(n, linkIndex); err != nil {
+			return true, err
+		}
+	}
+
+	if len(removed) == 0 {
+		return len(shrunk) > 0, nil
+	}
+
+	for _, neighbor := range removed {
+		logger.InfoFields("Removed neighbor", map[string]interface{}{
+			"ip":         ip.String(),
+			"link_index": neighbor.LinkIndex,
+		})
+		nm.recordEvent(EventRemove, neighbor)
+
+		if nm.ARPTable || neighbor.Permanent {
+			nm.deleteKernelNeighbor(neighbor)
+		}
+	}
+
+	nm.persistState()
+
+	if inUse {
+		logger.Info("Keeping route for %s, still learned on another VLAN", ip.String())
+		return true, nil
+	}
+
+	if nm.ECMP {
+		for _, neighbor := range removed {
+			if nm.routeShared(ip, neighbor.links()...) {
+				logger.Info("Keeping route for %s, still used by another neighbor", ip.String())
+				continue
+			}
+			if err := nm.removeNeighborRoutes(nm.ctx, neighbor); err != nil {
+				return true, err
+			}
+		}
+		return true, nil
+	}
+
+	if linkIndex != anyLink {
+		if err := nm.removeRoute(ip, linkIndex); err != nil {
+			return true, err
+		}
+		return true, nil
+	}
+	seen := make(map[int]bool)
+	for _, neighbor := range removed {
+		if seen[neighbor.LinkIndex] {
+			continue
+		}
+		seen[neighbor.LinkIndex] = true
+		if err := nm.removeRoute(ip, neighbor.LinkIndex); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// BatchRemoveNeighbors removes every IP in ips, continuing past failures.
+// It returns how many neighbors were fully removed and one error per failure.
+func (nm *NeighborManager) BatchRemoveNeighbors(ips []net.IP) (removed int, errs []error) {
+	for _, ip := range ips {
+		if _, ok := nm.GetNeighbor(ip); !ok {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrNeighborNotFound, ip))
+			continue
+		}
+
+		if _, err := nm.removeNeighbor(ip, anyLink); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove route for %s: %w", ip, err))
+			continue
+		}
+		removed++
+	}
+
+	logger.Info("Batch removed %d of %d neighbors", removed, len(ips))
+	return removed, errs
+}
+
+func (nm *NeighborManager) ListNeighbors() map[string]Neighbor {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	copyMap := make(map[string]Neighbor, len(nm.ReachableNeighbors))
+	for k, v := range nm.ReachableNeighbors {
+		copyMap[k] = v
+	}
+	return copyMap
+}
+
+// ListByLinkIndex returns the neighbors learned on linkIndex.
+func (nm *NeighborManager) ListByLinkIndex(linkIndex int) []Neighbor {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	var result []Neighbor
+	for _, n := range nm.ReachableNeighbors {
+		if n.LinkIndex == linkIndex {
+			result = append(result, n)
+		}
 	}
-
-	return nm, nil
+	return result
 }
 
-func (n Neighbor) LinkIndexChanged(linkIndex int) bool {
-	return n.LinkIndex != linkIndex
-}
+func (nm *NeighborManager) GetNeighbor(ip net.IP) (Neighbor, bool) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
 
-func (nm *NeighborManager) AddNeighbor(ip net.IP, linkIndex int, hwAddr net.HardwareAddr) {
-	var shouldRemoveRoute bool
+	var (
+		n     Neighbor
+		found bool
+	)
+	for _, key := range nm.keysLocked(ip, anyVLAN) {
+		if candidate := nm.ReachableNeighbors[key]; !found || candidate.VlanID < n.VlanID {
+			n, found = candidate, true
+		}
+	}
+	return n, found
+}
 
-	nm.mu.Lock()
-	neighbor, exists := nm.ReachableNeighbors[ip.String()]
-	if exists {
-		if !neighbor.LinkIndexChanged(linkIndex) {
-			nm.mu.Unlock()
-			return
+func (p NeighborPolicy) allowsIP(ip net.IP) bool {
+	for _, excluded := range p.ExcludeIPs {
+		if excluded.Equal(ip) {
+			return false
 		}
-		logger.Info("Neighbor %s link index changed, re-adding neighbor", ip.String())
-		shouldRemoveRoute = true
 	}
 
-	if shouldRemoveRoute {
-		err := netutils.RemoveRoute(ip, neighbor.LinkIndex)
-		if err != nil {
-			logger.Error("Failed to remove old route for neighbor %s: %v", ip.String(), err)
-			return
+	return p.matchesPrefix(ip)
+}
+
+// matchesPrefix reports whether ip is outside every ExcludePrefixes entry and
+// inside one of AllowPrefixes. An empty AllowPrefixes list matches every
+// address.
+func (p NeighborPolicy) matchesPrefix(ip net.IP) bool {
+	for _, prefix := range p.ExcludePrefixes {
+		if prefix.Contains(ip) {
+			return false
 		}
 	}
 
-	nm.ReachableNeighbors[ip.String()] = Neighbor{
-		IP:           ip,
-		LinkIndex:    linkIndex,
-		HardwareAddr: hwAddr,
+	if len(p.AllowPrefixes) == 0 {
+		return true
 	}
-	nm.mu.Unlock()
 
-	if err := netutils.AddRoute(ip, linkIndex); err != nil {
-		logger.Error("Failed to add route for neighbor %s: %v", ip.String(), err)
-		return
+	for _, prefix := range p.AllowPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
 	}
+	return false
+}
 
-	logger.Info("Added neighbor %s", ip.String())
+func (nm *NeighborManager) matchesPrefix(ip net.IP) bool {
+	return nm.Policy().matchesPrefix(ip)
 }
 
-func (nm *NeighborManager) RemoveNeighbor(ip net.IP, linkIndex int) {
-	var shouldRemoveRoute bool
+// allowsState reports whether state is in the policy's mask. NUD_DELAY and
+// NUD_PROBE are always allowed: the kernel is re-validating a neighbor that
+// was reachable, so its route must be kept, or added when we started
+// mid-validation and never saw the earlier state.
+func (p NeighborPolicy) allowsState(state int) bool {
+	return state&(p.StateMask|revalidatingStates) != 0
+}
 
-	nm.mu.Lock()
-	if _, exists := nm.ReachableNeighbors[ip.String()]; exists {
-		delete(nm.ReachableNeighbors, ip.String())
-		logger.Info("Removed neighbor %s", ip.String())
-		shouldRemoveRoute = true
+func (p NeighborPolicy) validate() error {
+	if p.StateMask == 0 {
+		return errors.New("policy state mask must not be empty")
 	}
-	nm.mu.Unlock()
 
-	if shouldRemoveRoute {
-		if err := netutils.RemoveRoute(ip, linkIndex); err != nil {
-			logger.Error("Failed to remove route for neighbor %s: %v", ip.String(), err)
-			return
+	for _, prefix := range p.AllowPrefixes {
+		if prefix == nil {
+			return errors.New("policy contains a nil allow prefix")
+		}
+	}
+
+	for _, prefix := range p.ExcludePrefixes {
+		if prefix == nil {
+			return errors.New("policy contains a nil exclude prefix")
+		}
+	}
+
+	for _, ip := range p.ExcludeIPs {
+		if ip == nil {
+			return errors.New("policy contains a nil exclude IP")
 		}
 	}
+	return nil
 }
 
-func (nm *NeighborManager) ListNeighbors() map[string]Neighbor {
+// Policy returns the active neighbor policy.
+func (nm *NeighborManager) Policy() NeighborPolicy {
 	nm.mu.Lock()
 	defer nm.mu.Unlock()
 
-	copyMap := make(map[string]Neighbor, len(nm.ReachableNeighbors))
-	for k, v := range nm.ReachableNeighbors {
-		copyMap[k] = v
+	return nm.currentPolicy
+}
+
+// ApplyPolicy replaces the active policy, removes neighbors the new policy
+// no longer accepts and rescans the kernel table for newly accepted ones.
+func (nm *NeighborManager) ApplyPolicy(policy NeighborPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
 	}
-	return copyMap
+
+	nm.mu.Lock()
+	nm.currentPolicy = policy
+	var rejected []Neighbor
+	for _, n := range nm.ReachableNeighbors {
+		if !policy.allowsIP(n.IP) {
+			rejected = append(rejected, n)
+		}
+	}
+	nm.mu.Unlock()
+
+	logger.Info("Applied new neighbor policy, %d neighbors no longer match", len(rejected))
+
+	for _, n := range rejected {
+		nm.RemoveNeighborAllLinks(n.IP)
+	}
+
+	return nm.scanNeighborTable()
 }
 
-func (nm *NeighborManager) isNeighborExternallyLearned(flags int) bool {
-	return flags&netlink.NTF_EXT_LEARNED != 0
+// isNeighborExternallyLearned reports whether n is an NTF_EXT_LEARNED entry
+// another control plane owns. The ones ExtLearnedOwner added are our own
+// and routed like any other.
+func (nm *NeighborManager) isNeighborExternallyLearned(n netlink.Neigh) bool {
+	if n.Flags&netlink.NTF_EXT_LEARNED == 0 {
+		return false
+	}
+	return nm.extLearnedOwner == nil || !nm.extLearnedOwner.Injected(n.IP, n.LinkIndex)
 }
 
 func (nm *NeighborManager) InitializeNeighborTable() error {
-	interfaceIndex := 0
-	if nm.TargetInterfaceIndex >= 0 {
-		interfaceIndex = nm.TargetInterfaceIndex
+	if nm.CleanupOnStart {
+		for _, linkIndex := range nm.linkIndexes() {
+			if nm.DryRun {
+				logger.Info("[DRY-RUN] Would flush host routes in table %d on link index %d", nm.RouteTable, linkIndex)
+				continue
+			}
+			var flushed []netlink.Route
+			if nm.auditLog != nil {
+				flushed, _ = listHostRoutes(linkIndex, nm.routeOptions()...)
+			}
+			if err := netutils.FlushRoutes(linkIndex, nm.routeOptions()...); err != nil {
+				return err
+			}
+			for _, route := range flushed {
+				nm.auditRoute(AuditOpRemove, route.Dst.IP, route.LinkIndex)
+			}
+		}
 	}
 
-	neighbors, err := netlink.NeighList(interfaceIndex, netlink.FAMILY_ALL)
-	if err != nil {
-		return err
+	return nm.scanNeighborTable()
+}
+
+// linkIndexes returns the monitored links, or a single 0 (every link) when
+// no interface is configured.
+func (nm *NeighborManager) linkIndexes() []int {
+	if len(nm.TargetInterfaceIndexes) == 0 {
+		return []int{0}
+	}
+	return nm.TargetInterfaceIndexes
+}
+
+func (nm *NeighborManager) scanNeighborTable() error {
+	var neighbors []netlink.Neigh
+	for _, linkIndex := range nm.linkIndexes() {
+		linkNeighbors, err := neighList(linkIndex, netlink.FAMILY_ALL)
+		if err != nil {
+			return err
+		}
+		neighbors = append(neighbors, linkNeighbors...)
 	}
 
-	logger.Info("Initializing neighbor table with %d neighbors", len(neighbors))
+	workers := max(nm.InitWorkers, 1)
+	logger.Info("Initializing neighbor table with %d neighbors using %d workers", len(neighbors), workers)
 
+	policy := nm.Policy()
+
+	// Entries of the same IP, e.g. on several links, are added in order by
+	// one worker, since adding them concurrently could race on the route.
+	var order []string
+	byIP := make(map[string][]netlink.Neigh)
 	for _, n := range neighbors {
 		if n.IP == nil {
 			logger.Warn("Skipping neighbor with nil IP during initialization")
@@ -125,44 +1107,123 @@ func (nm *NeighborManager) InitializeNeighborTable() error {
 		}
 
 		if n.IP.IsLinkLocalUnicast() {
-			logger.Debug("Skipping link-local neighbor with IP=%s, LinkIndex=%d", n.IP, n.LinkIndex)
+			sampledLog.Debug("Skipping link-local neighbor with IP=%s, LinkIndex=%d", n.IP, n.LinkIndex)
+			continue
+		}
+
+		if !policy.allowsIP(n.IP) {
+			logger.Debug("Skipping neighbor with IP=%s not allowed by policy", n.IP)
 			continue
 		}
 
-		if (n.State&(netlink.NUD_REACHABLE|netlink.NUD_STALE)) != 0 && !nm.isNeighborExternallyLearned(n.Flags) {
-			logger.Info("Adding neighbor with IP=%s, LinkIndex=%d", n.IP, n.LinkIndex)
-			nm.AddNeighbor(n.IP, n.LinkIndex, n.HardwareAddr)
+		if policy.allowsState(n.State) && !nm.isNeighborExternallyLearned(n) {
+			key := n.IP.String()
+			if _, ok := byIP[key]; !ok {
+				order = append(order, key)
+			}
+			byIP[key] = append(byIP[key], n)
 		}
 	}
 
+	// A failed route add is logged by addNeighbor and does not stop the
+	// other workers. The state file is written once all are added.
+	defer nm.holdPersist()()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for _, key := range order {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(entries []netlink.Neigh) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			for _, n := range entries {
+				logger.Info("Adding neighbor with IP=%s, LinkIndex=%d", n.IP, n.LinkIndex)
+				nm.addNeighbor(n.IP, n.LinkIndex, n.HardwareAddr, n.Vlan, false)
+			}
+		}(byIP[key])
+	}
+	wg.Wait()
+
 	logger.Info("Neighbor table initialized finished")
 
 	return nil
 }
 
-func (nm *NeighborManager) MonitorNeighbors() {
-	for {
+// MonitorNeighbors applies kernel neighbor updates until ctx is cancelled.
+// Failed subscriptions and unexpectedly closed channels are retried with a
+// backoff doubling up to 32s, so it only returns once ctx is cancelled.
+// After a resubscribe the kernel table is scanned again, since the updates
+// sent while no one was subscribed are lost.
+func (nm *NeighborManager) MonitorNeighbors(ctx context.Context) error {
+	backoff := monitorRetryBackoff
+	subscribed := false
+	for attempt := 1; ; attempt++ {
 		updates := make(chan netlink.NeighUpdate)
 		done := make(chan struct{})
 
-		if err := netlink.NeighSubscribe(updates, done); err != nil {
-			logger.Error("Failed to subscribe to neighbor updates: %v (interface: %s, index: %d)",
-				err, nm.TargetInterface, nm.TargetInterfaceIndex)
-			os.Exit(1)
+		if err := neighSubscribe(updates, done, nm.TargetInterfaceIndexes); err != nil {
+			logger.Error("Failed to subscribe to neighbor updates: %v (interfaces: %v, indexes: %v), retrying in %s (attempt %d)",
+				err, nm.TargetInterfaces, nm.TargetInterfaceIndexes, backoff, attempt)
+		} else {
+			backoff, attempt = monitorRetryBackoff, 0
+
+			if subscribed {
+				// The updates queue up on the socket meanwhile and are
+				// applied after the scan.
+				if err := nm.scanNeighborTable(); err != nil {
+					logger.Error("Failed to rescan the neighbor table after resubscribing: %v", err)
+				}
+			}
+			subscribed = true
+
+			if nm.consumeUpdates(ctx, updates) {
+				close(done)
+				// Let the netlink reader exit instead of blocking on a send.
+				go func() {
+					for range updates {
+					}
+				}()
+				return nil
+			}
+
+			close(done)
+			logger.Error("MonitorNeighbors: netlink updates channel unexpectedly closed, reconnecting in %s", backoff)
 		}
 
-		for update := range updates {
-			nm.processNeighborUpdate(update)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
 		}
 
-		close(done)
-		logger.Error("MonitorNeighbors: netlink updates channel unexpectedly closed. Restarting monitor...")
-		time.Sleep(1 * time.Second)
+		nm.netlinkReconnects.Add(1)
+		backoff = min(backoff*2, maxMonitorRetryBackoff)
+	}
+}
+
+// consumeUpdates processes updates until the channel closes or ctx is
+// cancelled, and reports whether it stopped because of ctx.
+func (nm *NeighborManager) consumeUpdates(ctx context.Context, updates <-chan netlink.NeighUpdate) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return true
+		case update, ok := <-updates:
+			if !ok {
+				return false
+			}
+			nm.processNeighborUpdate(update)
+		}
 	}
 }
 
 func (nm *NeighborManager) processNeighborUpdate(update netlink.NeighUpdate) {
-	if nm.TargetInterfaceIndex > 0 && update.Neigh.LinkIndex != nm.TargetInterfaceIndex {
+	nm.lastUpdateTime.Store(time.Now())
+
+	if !nm.monitorsLink(update.Neigh.LinkIndex) {
 		return
 	}
 
@@ -171,40 +1232,420 @@ func (nm *NeighborManager) processNeighborUpdate(update netlink.NeighUpdate) {
 		return
 	}
 
-	if update.Neigh.IP.IsLinkLocalUnicast() {
+	if update.Type == unix.RTM_DELNEIGH && nm.extLearnedOwner != nil {
+		// Handled as ours first, the entry is gone afterwards.
+		defer nm.extLearnedOwner.Forget(update.Neigh.IP, update.Neigh.LinkIndex)
+	}
+
+	if update.Neigh.IP.IsLinkLocalUnicast() || !nm.matchesPrefix(update.Neigh.IP) {
 		return
 	}
 
 	logger.Debug("Received neighbor update: IP=%s, State=%s, Flags=%s, LinkIndex=%d",
-		update.Neigh.IP, neighborStateToString(update.Neigh.State), neighborFlagsToString(update.Neigh.Flags), update.Neigh.LinkIndex)
+		update.Neigh.IP, NUDStateString(update.Neigh.State), neighborFlagsToString(update.Neigh.Flags), update.Neigh.LinkIndex)
+
+	if nm.bufferIfPaused(update) {
+		return
+	}
+
+	nm.applyNeighborUpdate(update)
+}
+
+func (nm *NeighborManager) applyNeighborUpdate(update netlink.NeighUpdate) {
+	policy := nm.Policy()
+	if policy.allowsState(update.Neigh.State) && policy.allowsIP(update.Neigh.IP) && !nm.isNeighborExternallyLearned(update.Neigh) {
+		if update.Neigh.State&revalidatingStates != 0 {
+			logger.Debug("Neighbor %s is being re-validated (%s), keeping its route", update.Neigh.IP, NUDStateString(update.Neigh.State))
+		}
+		nm.cancelPendingRemoval(update.Neigh.IP, update.Neigh.Vlan)
+		nm.addNeighbor(update.Neigh.IP, update.Neigh.LinkIndex, update.Neigh.HardwareAddr, update.Neigh.Vlan, true)
+	}
+
+	if update.Neigh.State == netlink.NUD_FAILED || nm.isNeighborExternallyLearned(update.Neigh) {
+		if n, ok := nm.GetNeighbor(update.Neigh.IP); ok && n.Permanent {
+			return
+		}
+		if update.Neigh.State == netlink.NUD_FAILED && nm.FailedHold > 0 {
+			nm.scheduleRemoval(update.Neigh.IP, update.Neigh.Vlan, update.Neigh.LinkIndex)
+			return
+		}
+		nm.removeUpdatedNeighbor(update.Neigh.IP, update.Neigh.Vlan, update.Neigh.LinkIndex)
+	}
+}
+
+// removeUpdatedNeighbor removes the entry a kernel update refers to: only
+// its VLAN's entry with VLANAware set, every entry for ip otherwise.
+func (nm *NeighborManager) removeUpdatedNeighbor(ip net.IP, vlanID int, linkIndex int) {
+	if !nm.VLANAware {
+		vlanID = anyVLAN
+	}
+	if _, err := nm.removeVLANNeighbor(ip, vlanID, linkIndex); err != nil {
+		logger.Error("Failed to remove route for neighbor %s: %v", ip.String(), err)
+	}
+}
+
+// scheduleRemoval removes a failed neighbor after FailedHold unless it
+// recovers first. A removal already pending for ip is left untouched.
+func (nm *NeighborManager) scheduleRemoval(ip net.IP, vlanID int, linkIndex int) {
+	key := nm.neighborKey(ip, vlanID)
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	if _, ok := nm.ReachableNeighbors[key]; !ok {
+		return
+	}
+	if _, ok := nm.pendingRemovals[key]; ok {
+		return
+	}
+
+	logger.Info("Neighbor %s failed, removing in %s unless it recovers", key, nm.FailedHold)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(nm.FailedHold, func() {
+		nm.mu.Lock()
+		if nm.pendingRemovals[key] != timer {
+			// Cancelled after the timer fired but before we got the lock.
+			nm.mu.Unlock()
+			return
+		}
+		delete(nm.pendingRemovals, key)
+		nm.mu.Unlock()
+
+		nm.removeUpdatedNeighbor(ip, vlanID, linkIndex)
+	})
+	nm.pendingRemovals[key] = timer
+}
+
+func (nm *NeighborManager) cancelPendingRemoval(ip net.IP, vlanID int) {
+	key := nm.neighborKey(ip, vlanID)
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	if timer, ok := nm.pendingRemovals[key]; ok {
+		timer.Stop()
+		delete(nm.pendingRemovals, key)
+		logger.Info("Neighbor %s recovered, cancelled pending removal", key)
+	}
+}
+
+// migrationMetric returns the metric one below RouteMetric that the route
+// of a neighbor that moved links has during MigrationGrace. IPv4 routes
+// without a metric have none below them, and IPv6 routes with metric 1
+// neither, as the kernel turns metric 0 into the default.
+func (nm *NeighborManager) migrationMetric(ip net.IP) (uint32, bool) {
+	nm.settingsMu.RLock()
+	metric := nm.RouteMetric
+	nm.settingsMu.RUnlock()
+
+	if ip.To4() == nil {
+		if metric == 0 {
+			metric = netutils.IPv6DefaultMetric
+		}
+		if metric == 1 {
+			return 0, false
+		}
+	}
+	if metric == 0 {
+		return 0, false
+	}
+	return metric - 1, true
+}
+
+// migrationOptions installs the route of a neighbor that moved links with
+// the migration metric, or appends it at the same metric when there is
+// none.
+func (nm *NeighborManager) migrationOptions(ip net.IP) []netutils.RouteOption {
+	if metric, ok := nm.migrationMetric(ip); ok {
+		return []netutils.RouteOption{netutils.WithMetric(metric)}
+	}
+	return []netutils.RouteOption{netutils.WithAppend()}
+}
+
+// removeMigrationRoute removes the route of ip on linkIndex that has the
+// migration metric, if any.
+func (nm *NeighborManager) removeMigrationRoute(ctx context.Context, ip net.IP, linkIndex int) {
+	metric, ok := nm.migrationMetric(ip)
+	if !ok {
+		return
+	}
+	opts := append(nm.routeOptions(), netutils.WithMetric(metric))
+	if err := netutils.RemoveRoute(ctx, ip, linkIndex, opts...); err != nil {
+		logger.Error("Failed to remove migration route for neighbor %s: %v", ip.String(), err)
+		return
+	}
+	nm.routesRemoved.Add(1)
+	nm.auditRoute(AuditOpRemove, ip, linkIndex)
+}
+
+// scheduleMigration removes the route of ip on the link it moved away from
+// after MigrationGrace and gives the route on newLinkIndex the normal
+// metric back. The route is kept if ip has moved back by then, and the
+// migration from newLinkIndex back to it finishes the job. Callers must
+// not hold mu.
+func (nm *NeighborManager) scheduleMigration(ip net.IP, vlanID int, oldLinkIndex int, newLinkIndex int) {
+	key := routeKey(ip.String(), oldLinkIndex)
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	if pending, ok := nm.pendingMigrations[key]; ok {
+		pending.timer.Stop()
+	}
+
+	logger.Info("Neighbor %s moved from link %d, removing the old route in %s", ip.String(), oldLinkIndex, nm.MigrationGrace)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(nm.MigrationGrace, func() {
+		nm.mu.Lock()
+		if nm.pendingMigrations[key].timer != timer {
+			// Replaced or cancelled after the timer fired.
+			nm.mu.Unlock()
+			return
+		}
+		delete(nm.pendingMigrations, key)
+		current, ok := nm.ReachableNeighbors[nm.neighborKey(ip, vlanID)]
+		nm.mu.Unlock()
+
+		if ok && current.LinkIndex == oldLinkIndex {
+			logger.Info("Neighbor %s moved back to link %d, keeping its route", ip.String(), oldLinkIndex)
+			return
+		}
+		if err := nm.removeRoute(ip, oldLinkIndex); err != nil {
+			logger.Error("Failed to remove old route for neighbor %s: %v", ip.String(), err)
+		}
+		// Left by an earlier migration to oldLinkIndex, if ip moved back.
+		nm.removeMigrationRoute(nm.ctx, ip, oldLinkIndex)
+
+		switch {
+		case !ok:
+			nm.removeMigrationRoute(nm.ctx, ip, newLinkIndex)
+		case current.LinkIndex == newLinkIndex:
+			opts := nm.gatewayOptions(ip, newLinkIndex, current.HardwareAddr)
+			if err := nm.addRoute(ip, newLinkIndex, opts...); err != nil {
+				logger.Error("Failed to restore the route metric for neighbor %s: %v", ip.String(), err)
+				return
+			}
+			nm.removeMigrationRoute(nm.ctx, ip, newLinkIndex)
+		}
+	})
+	nm.pendingMigrations[key] = pendingMigration{ip: ip, vlanID: vlanID, linkIndex: oldLinkIndex, newLinkIndex: newLinkIndex, timer: timer}
+}
+
+func (nm *NeighborManager) bufferIfPaused(update netlink.NeighUpdate) bool {
+	nm.pauseMu.Lock()
+	defer nm.pauseMu.Unlock()
+
+	if !nm.paused {
+		return false
+	}
+
+	if len(nm.pauseBuffer) >= nm.MaxPauseBuffer {
+		sampledLog.Warn("Pause buffer full (%d events), dropping update for %s", nm.MaxPauseBuffer, update.Neigh.IP)
+		return true
+	}
 
-	if (update.Neigh.State&(netlink.NUD_REACHABLE|netlink.NUD_STALE)) != 0 && !nm.isNeighborExternallyLearned(update.Neigh.Flags) {
-		nm.AddNeighbor(update.Neigh.IP, update.Neigh.LinkIndex, update.Neigh.HardwareAddr)
+	nm.pauseBuffer = append(nm.pauseBuffer, update)
+	return true
+}
+
+// Pause stops acting on neighbor updates; they are buffered until Resume.
+func (nm *NeighborManager) Pause() {
+	nm.pauseMu.Lock()
+	defer nm.pauseMu.Unlock()
+
+	if nm.paused {
+		return
+	}
+
+	nm.paused = true
+	logger.Info("Neighbor processing paused")
+}
+
+// Resume replays buffered updates in arrival order. Updates received while
+// the replay is running wait for it to finish, so ordering is preserved.
+func (nm *NeighborManager) Resume() {
+	nm.pauseMu.Lock()
+	defer nm.pauseMu.Unlock()
+
+	if !nm.paused {
+		return
 	}
 
-	if update.Neigh.State == netlink.NUD_FAILED || nm.isNeighborExternallyLearned(update.Neigh.Flags) {
-		nm.RemoveNeighbor(update.Neigh.IP, update.Neigh.LinkIndex)
+	buffered := nm.pauseBuffer
+	nm.pauseBuffer = nil
+
+	logger.Info("Neighbor processing resumed, replaying %d buffered updates", len(buffered))
+	for _, update := range buffered {
+		nm.applyNeighborUpdate(update)
 	}
+
+	nm.paused = false
 }
 
-func (nm *NeighborManager) SendPings() {
+// SendPings pings every non-permanent neighbor every PingInterval until ctx
+// is cancelled. With PingBackoff, a failing neighbor's gap doubles per
+// consecutive failure up to 10x PingInterval.
+func (nm *NeighborManager) SendPings(ctx context.Context) {
 	for {
-		var wg sync.WaitGroup
+		interval := nm.pingInterval()
 
-		neighbors := nm.ListNeighbors()
+		var wg sync.WaitGroup
 
-		for _, n := range neighbors {
+		for _, n := range nm.pingDue(time.Now()) {
 			wg.Add(1)
 			go func(n Neighbor) {
 				defer wg.Done()
-				if err := netutils.Ping(n.IP.String()); err != nil {
+				err := nm.keepalive(n)
+				if err != nil {
+					nm.pingFailures.Add(1)
 					logger.Error("Failed to ping neighbor %s: %v", n.IP.String(), err)
 				}
+				nm.recordPingResult(nm.neighborKey(n.IP, n.VlanID), err == nil, interval)
 			}(n)
 		}
 		wg.Wait()
 
-		<-time.After(30 * time.Second)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// SetPingInterval changes the ping interval, taking effect after the
+// current round of pings.
+func (nm *NeighborManager) SetPingInterval(interval time.Duration) {
+	nm.settingsMu.Lock()
+	defer nm.settingsMu.Unlock()
+
+	nm.PingInterval = interval
+}
+
+func (nm *NeighborManager) pingInterval() time.Duration {
+	nm.settingsMu.RLock()
+	defer nm.settingsMu.RUnlock()
+
+	if nm.PingInterval <= 0 {
+		return DefaultPingInterval
+	}
+	return nm.PingInterval
+}
+
+// keepalive probes n with a Neighbor Solicitation when UseNS is set and n is
+// IPv6, and with an ICMP echo otherwise.
+func (nm *NeighborManager) keepalive(n Neighbor) error {
+	if nm.UseNS && n.IP.To4() == nil {
+		return sendNS(n.IP, n.LinkIndex)
+	}
+	timeout := n.PingTimeout
+	if timeout <= 0 {
+		timeout = nm.PingTimeout
+	}
+	ctx, cancel := context.WithTimeout(nm.ctx, timeout)
+	defer cancel()
+
+	return ping(ctx, n.IP.String())
+}
+
+// SetPingTimeout overrides PingTimeout for ip, now and whenever it is added
+// again. A timeout <= 0 drops the override.
+func (nm *NeighborManager) SetPingTimeout(ip net.IP, timeout time.Duration) {
+	nm.mu.Lock()
+	if timeout <= 0 {
+		timeout = 0
+		delete(nm.pingTimeouts, ip.String())
+	} else {
+		nm.pingTimeouts[ip.String()] = timeout
+	}
+
+	for _, key := range nm.keysLocked(ip, anyVLAN) {
+		n := nm.ReachableNeighbors[key]
+		n.PingTimeout = timeout
+		nm.ReachableNeighbors[key] = n
+	}
+	nm.mu.Unlock()
+
+	nm.persistState()
+}
+
+// pingDue returns the non-permanent neighbors whose next ping is due and
+// forgets the backoff state of neighbors that are gone.
+func (nm *NeighborManager) pingDue(now time.Time) []Neighbor {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	for key := range nm.nextPingAt {
+		if _, ok := nm.ReachableNeighbors[key]; !ok {
+			delete(nm.nextPingAt, key)
+			delete(nm.pingFailureCounts, key)
+		}
+	}
+
+	var due []Neighbor
+	for key, n := range nm.ReachableNeighbors {
+		if n.Permanent || now.Before(nm.nextPingAt[key]) {
+			continue
+		}
+		due = append(due, n)
+	}
+	return due
+}
+
+func (nm *NeighborManager) recordPingResult(key string, ok bool, interval time.Duration) {
+	if !nm.PingBackoff {
+		return
+	}
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	if ok {
+		delete(nm.pingFailureCounts, key)
+		delete(nm.nextPingAt, key)
+		return
+	}
+
+	nm.pingFailureCounts[key]++
+	nm.nextPingAt[key] = time.Now().Add(pingBackoff(interval, nm.pingFailureCounts[key]))
+}
+
+// pingBackoff returns interval doubled once per failure, capped at
+// maxPingBackoffFactor times interval.
+func pingBackoff(interval time.Duration, failures int) time.Duration {
+	limit := interval * maxPingBackoffFactor
+	gap := interval
+	for i := 0; i < failures && gap < limit; i++ {
+		gap *= 2
+	}
+	if gap > limit {
+		gap = limit
+	}
+	return gap
+}
+
+// KeepRoutes is the shutdown alternative to Cleanup that leaves every
+// neighbor route in the kernel. Pending timers are stopped and each kept
+// route is logged.
+func (nm *NeighborManager) KeepRoutes() {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	for key, timer := range nm.pendingRemovals {
+		timer.Stop()
+		delete(nm.pendingRemovals, key)
+	}
+
+	for key, pending := range nm.pendingMigrations {
+		pending.timer.Stop()
+		delete(nm.pendingMigrations, key)
+		logger.Info("Keeping old route for neighbor %s on link index %d", pending.ip.String(), pending.linkIndex)
+	}
+
+	for _, n := range nm.ReachableNeighbors {
+		logger.Info("Keeping route for neighbor %s on link index %d", n.IP.String(), n.LinkIndex)
 	}
 }
 
@@ -212,8 +1653,27 @@ func (nm *NeighborManager) Cleanup() {
 	nm.mu.Lock()
 	defer nm.mu.Unlock()
 
+	// Cleanup usually runs after the root context was cancelled for
+	// shutdown, which must not stop it from removing the routes.
+	ctx := context.WithoutCancel(nm.ctx)
+
+	for key, timer := range nm.pendingRemovals {
+		timer.Stop()
+		delete(nm.pendingRemovals, key)
+	}
+
+	for key, pending := range nm.pendingMigrations {
+		pending.timer.Stop()
+		delete(nm.pendingMigrations, key)
+		if err := nm.removeRouteContext(ctx, pending.ip, pending.linkIndex); err != nil {
+			logger.Error("Failed to remove old route for neighbor %s: %v", pending.ip.String(), err)
+		}
+		nm.removeMigrationRoute(ctx, pending.ip, pending.linkIndex)
+		nm.removeMigrationRoute(ctx, pending.ip, pending.newLinkIndex)
+	}
+
 	for _, n := range nm.ReachableNeighbors {
-		if err := netutils.RemoveRoute(n.IP, n.LinkIndex); err != nil {
+		if err := nm.removeNeighborRoutes(ctx, n); err != nil {
 			logger.Error("Failed to remove route for neighbor %s: %v", n.IP.String(), err)
 			continue
 		}
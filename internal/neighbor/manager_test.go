@@ -1,8 +1,18 @@
 package neighbor
 
 import (
+	"context"
+	"errors"
 	"net"
+	"reflect"
+	"sort"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/hostinger/neigh2route/pkg/netutils"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
 )
 
 // Test NewNeighborManager function
@@ -12,12 +22,45 @@ func TestNewNeighborManager(t *testing.T) {
 		t.Errorf("Expected no error, got %s", err)
 	}
 
-	if nm.TargetInterface != "lo" {
-		t.Errorf("Expected lo, got %s", nm.TargetInterface)
+	if len(nm.TargetInterfaces) != 1 || nm.TargetInterfaces[0] != "lo" {
+		t.Errorf("Expected [lo], got %v", nm.TargetInterfaces)
+	}
+
+	if len(nm.TargetInterfaceIndexes) != 1 || nm.TargetInterfaceIndexes[0] != 1 {
+		t.Errorf("Expected [1], got %v", nm.TargetInterfaceIndexes)
+	}
+}
+
+func TestNewNeighborManagerWithOptions(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR("10.10.0.0/16")
+	nm, err := NewNeighborManager("lo",
+		WithRouteMetric(50),
+		WithRouteTable(100),
+		WithRouteProtocol(252),
+		WithRouteScope(netlink.SCOPE_HOST),
+		WithRoutePrefixes([]*net.IPNet{prefix}),
+		WithDryRun(),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if nm.RouteMetric != 50 || nm.RouteTable != 100 || nm.RouteProtocol != 252 || !nm.DryRun {
+		t.Errorf("Expected metric 50, table 100, proto 252 and dry run, got %d, %d, %d and %v", nm.RouteMetric, nm.RouteTable, nm.RouteProtocol, nm.DryRun)
+	}
+	if nm.RouteScope == nil || *nm.RouteScope != netlink.SCOPE_HOST {
+		t.Errorf("Expected host scope, got %v", nm.RouteScope)
+	}
+	if nm.matchesPrefix(net.ParseIP("10.20.0.1")) || !nm.matchesPrefix(net.ParseIP("10.10.0.1")) {
+		t.Errorf("Expected only neighbors inside %s to match", prefix)
 	}
 
-	if nm.TargetInterfaceIndex != 1 {
-		t.Errorf("Expected 1, got %d", nm.TargetInterfaceIndex)
+	nm, err = NewNeighborManager("lo", WithRouteMetric(-1))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if nm.RouteMetric != 0 {
+		t.Errorf("Expected a negative metric to be ignored, got %d", nm.RouteMetric)
 	}
 }
 
@@ -54,3 +97,1580 @@ func TestRemoveNeighbor(t *testing.T) {
 		t.Errorf("Expected 0, got %d", len(nm.ReachableNeighbors))
 	}
 }
+
+func TestApplyPolicyRemovesRejectedNeighbors(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+
+	nm.AddNeighbor(net.ParseIP("10.10.10.10"), 1, nil)
+	nm.AddNeighbor(net.ParseIP("192.168.100.10"), 1, nil)
+	nm.AddNeighbor(net.ParseIP("10.10.10.20"), 1, nil)
+	defer nm.Cleanup()
+
+	_, allowed, _ := net.ParseCIDR("10.0.0.0/8")
+	err := nm.ApplyPolicy(NeighborPolicy{
+		AllowPrefixes: []*net.IPNet{allowed},
+		ExcludeIPs:    []net.IP{net.ParseIP("10.10.10.20")},
+		StateMask:     DefaultStateMask,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	neighbors := nm.ListNeighbors()
+	if len(neighbors) != 1 {
+		t.Fatalf("Expected 1, got %d", len(neighbors))
+	}
+
+	if _, ok := neighbors["10.10.10.10"]; !ok {
+		t.Errorf("Expected 10.10.10.10 to remain, got %v", neighbors)
+	}
+}
+
+func TestApplyPolicyFiltersUpdates(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+
+	_, allowed, _ := net.ParseCIDR("10.0.0.0/8")
+	if err := nm.ApplyPolicy(NeighborPolicy{AllowPrefixes: []*net.IPNet{allowed}, StateMask: netlink.NUD_REACHABLE}); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	updates := []netlink.NeighUpdate{
+		{Neigh: netlink.Neigh{IP: net.ParseIP("192.168.100.10"), LinkIndex: 1, State: netlink.NUD_REACHABLE}},
+		{Neigh: netlink.Neigh{IP: net.ParseIP("10.10.10.30"), LinkIndex: 1, State: netlink.NUD_STALE}},
+	}
+	for _, u := range updates {
+		nm.processNeighborUpdate(u)
+	}
+
+	if len(nm.ReachableNeighbors) != 0 {
+		t.Errorf("Expected 0, got %d", len(nm.ReachableNeighbors))
+	}
+}
+
+func TestApplyPolicyInvalid(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+
+	if err := nm.ApplyPolicy(NeighborPolicy{}); err == nil {
+		t.Errorf("Expected error for empty state mask, got nil")
+	}
+
+	if err := nm.ApplyPolicy(NeighborPolicy{AllowPrefixes: []*net.IPNet{nil}, StateMask: DefaultStateMask}); err == nil {
+		t.Errorf("Expected error for nil prefix, got nil")
+	}
+}
+
+func reachableUpdate(ip string, state int) netlink.NeighUpdate {
+	return netlink.NeighUpdate{Neigh: netlink.Neigh{IP: net.ParseIP(ip), LinkIndex: 1, State: state}}
+}
+
+func TestMACChangeUpdatesStoredMAC(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	defer nm.Cleanup()
+
+	for _, mac := range []string{"02:00:00:00:20:01", "02:00:00:00:20:01", "02:00:00:00:20:02"} {
+		update := reachableUpdate("10.10.20.9", netlink.NUD_REACHABLE)
+		update.Neigh.HardwareAddr, _ = net.ParseMAC(mac)
+		nm.processNeighborUpdate(update)
+	}
+
+	n, ok := nm.GetNeighbor(net.ParseIP("10.10.20.9"))
+	if !ok {
+		t.Fatalf("Expected neighbor to be added")
+	}
+	if n.HardwareAddr.String() != "02:00:00:00:20:02" {
+		t.Errorf("Expected the stored MAC to be updated, got %s", n.HardwareAddr)
+	}
+	if changes := nm.Stats().MACChanges; changes != 1 {
+		t.Errorf("Expected 1 MAC change, got %d", changes)
+	}
+}
+
+func TestPauseBuffersUpdates(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	defer nm.Cleanup()
+
+	nm.Pause()
+	nm.processNeighborUpdate(reachableUpdate("10.10.20.1", netlink.NUD_REACHABLE))
+	nm.processNeighborUpdate(reachableUpdate("10.10.20.2", netlink.NUD_REACHABLE))
+
+	if len(nm.ReachableNeighbors) != 0 {
+		t.Errorf("Expected 0 while paused, got %d", len(nm.ReachableNeighbors))
+	}
+
+	if len(nm.pauseBuffer) != 2 {
+		t.Errorf("Expected 2 buffered updates, got %d", len(nm.pauseBuffer))
+	}
+
+	nm.Resume()
+
+	if len(nm.ReachableNeighbors) != 2 {
+		t.Errorf("Expected 2 after resume, got %d", len(nm.ReachableNeighbors))
+	}
+
+	if len(nm.pauseBuffer) != 0 {
+		t.Errorf("Expected empty buffer after resume, got %d", len(nm.pauseBuffer))
+	}
+}
+
+func TestResumeReplaysInOrder(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	defer nm.Cleanup()
+
+	nm.Pause()
+	nm.processNeighborUpdate(reachableUpdate("10.10.20.3", netlink.NUD_REACHABLE))
+	nm.processNeighborUpdate(reachableUpdate("10.10.20.3", netlink.NUD_FAILED))
+	nm.processNeighborUpdate(reachableUpdate("10.10.20.4", netlink.NUD_FAILED))
+	nm.processNeighborUpdate(reachableUpdate("10.10.20.4", netlink.NUD_REACHABLE))
+	nm.Resume()
+
+	if _, ok := nm.ReachableNeighbors["10.10.20.3"]; ok {
+		t.Errorf("Expected 10.10.20.3 to be removed by the later FAILED update")
+	}
+
+	if _, ok := nm.ReachableNeighbors["10.10.20.4"]; !ok {
+		t.Errorf("Expected 10.10.20.4 to be added by the later REACHABLE update")
+	}
+}
+
+func TestPauseBufferLimit(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	nm.MaxPauseBuffer = 2
+
+	nm.Pause()
+	for _, ip := range []string{"10.10.20.5", "10.10.20.6", "10.10.20.7"} {
+		nm.processNeighborUpdate(reachableUpdate(ip, netlink.NUD_REACHABLE))
+	}
+
+	if len(nm.pauseBuffer) != 2 {
+		t.Errorf("Expected 2 buffered updates, got %d", len(nm.pauseBuffer))
+	}
+}
+
+func routeOnLoopbackExists(t *testing.T, ip string) bool {
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{
+		LinkIndex: 1,
+		Dst:       &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)},
+	}, netlink.RT_FILTER_DST|netlink.RT_FILTER_OIF)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+	return len(routes) > 0
+}
+
+func TestInitializeNeighborTableCleanupOnStart(t *testing.T) {
+	ip := "192.168.100.150"
+	if err := netutils.AddRoute(context.Background(), net.ParseIP(ip), 1); err != nil {
+		t.Fatalf("failed to add stale route: %v", err)
+	}
+
+	nm, _ := NewNeighborManager("lo")
+	nm.CleanupOnStart = true
+
+	if err := nm.InitializeNeighborTable(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if routeOnLoopbackExists(t, ip) {
+		t.Errorf("Expected stale route %s to be flushed", ip)
+	}
+}
+
+func TestInitializeNeighborTableWithoutCleanup(t *testing.T) {
+	ip := "192.168.100.151"
+	if err := netutils.AddRoute(context.Background(), net.ParseIP(ip), 1); err != nil {
+		t.Fatalf("failed to add stale route: %v", err)
+	}
+	defer netutils.RemoveRoute(context.Background(), net.ParseIP(ip), 1)
+
+	nm, _ := NewNeighborManager("lo")
+
+	if err := nm.InitializeNeighborTable(); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if !routeOnLoopbackExists(t, ip) {
+		t.Errorf("Expected route %s to be kept without cleanup-on-start", ip)
+	}
+}
+
+func TestNewNeighborManagerFromConfig(t *testing.T) {
+	_, allowed, _ := net.ParseCIDR("10.0.0.0/8")
+	cfg := Config{
+		TargetInterfaces:  []string{"lo"},
+		RouteRetries:      5,
+		RouteRetryBackoff: 250 * time.Millisecond,
+		MaxPauseBuffer:    42,
+		CleanupOnStart:    true,
+		EventHistorySize:  7,
+		Policy: NeighborPolicy{
+			AllowPrefixes: []*net.IPNet{allowed},
+			ExcludeIPs:    []net.IP{net.ParseIP("10.0.0.1")},
+			StateMask:     netlink.NUD_REACHABLE,
+		},
+	}
+
+	nm, err := NewNeighborManagerFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if len(nm.TargetInterfaceIndexes) != 1 || nm.TargetInterfaceIndexes[0] != 1 {
+		t.Errorf("Expected [1], got %v", nm.TargetInterfaceIndexes)
+	}
+
+	if nm.RouteRetries != 5 || nm.RouteRetryBackoff != 250*time.Millisecond {
+		t.Errorf("Expected retries 5/250ms, got %d/%s", nm.RouteRetries, nm.RouteRetryBackoff)
+	}
+
+	if nm.MaxPauseBuffer != 42 {
+		t.Errorf("Expected 42, got %d", nm.MaxPauseBuffer)
+	}
+
+	if !nm.CleanupOnStart {
+		t.Errorf("Expected CleanupOnStart to be set")
+	}
+
+	if len(nm.events.events) != 7 {
+		t.Errorf("Expected event history size 7, got %d", len(nm.events.events))
+	}
+
+	policy := nm.Policy()
+	if len(policy.AllowPrefixes) != 1 || len(policy.ExcludeIPs) != 1 || policy.StateMask != netlink.NUD_REACHABLE {
+		t.Errorf("Expected policy from config, got %+v", policy)
+	}
+}
+
+func TestNewNeighborManagerFromConfigDefaults(t *testing.T) {
+	nm, err := NewNeighborManagerFromConfig(Config{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	if len(nm.TargetInterfaceIndexes) != 0 {
+		t.Errorf("Expected no interface indexes, got %v", nm.TargetInterfaceIndexes)
+	}
+
+	if nm.RouteRetries != DefaultRouteRetries || nm.RouteRetryBackoff != DefaultRouteRetryBackoff {
+		t.Errorf("Expected default retries, got %d/%s", nm.RouteRetries, nm.RouteRetryBackoff)
+	}
+
+	if nm.MaxPauseBuffer != DefaultMaxPauseBuffer {
+		t.Errorf("Expected %d, got %d", DefaultMaxPauseBuffer, nm.MaxPauseBuffer)
+	}
+
+	if nm.RouteTable != unix.RT_TABLE_MAIN {
+		t.Errorf("Expected main table, got %d", nm.RouteTable)
+	}
+
+	if nm.Policy().StateMask != DefaultStateMask {
+		t.Errorf("Expected default state mask, got %d", nm.Policy().StateMask)
+	}
+}
+
+func TestNewNeighborManagerFromConfigInvalidPolicy(t *testing.T) {
+	_, err := NewNeighborManagerFromConfig(Config{Policy: NeighborPolicy{AllowPrefixes: []*net.IPNet{nil}}})
+	if err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+}
+
+func TestBatchRemoveNeighborsPartialFailure(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+
+	nm.AddNeighbor(net.ParseIP("10.10.50.1"), 1, nil)
+	nm.AddNeighbor(net.ParseIP("10.10.50.2"), 1, nil)
+	nm.AddNeighbor(net.ParseIP("10.10.50.3"), 1, nil)
+	defer nm.Cleanup()
+
+	removed, errs := nm.BatchRemoveNeighbors([]net.IP{
+		net.ParseIP("10.10.50.1"),
+		net.ParseIP("10.10.50.9"),
+		net.ParseIP("10.10.50.2"),
+	})
+
+	if removed != 2 {
+		t.Errorf("Expected 2 removed, got %d", removed)
+	}
+
+	if len(errs) != 1 || !errors.Is(errs[0], ErrNeighborNotFound) {
+		t.Errorf("Expected a single not found error, got %v", errs)
+	}
+
+	if len(nm.ReachableNeighbors) != 1 {
+		t.Errorf("Expected 1 remaining, got %d", len(nm.ReachableNeighbors))
+	}
+}
+
+func TestListByLinkIndex(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+
+	nm.ReachableNeighbors["10.0.0.1"] = Neighbor{IP: net.ParseIP("10.0.0.1"), LinkIndex: 2}
+	nm.ReachableNeighbors["10.0.0.2"] = Neighbor{IP: net.ParseIP("10.0.0.2"), LinkIndex: 3}
+	nm.ReachableNeighbors["10.0.0.3"] = Neighbor{IP: net.ParseIP("10.0.0.3"), LinkIndex: 2}
+	nm.ReachableNeighbors["2001:db8::1"] = Neighbor{IP: net.ParseIP("2001:db8::1"), LinkIndex: 4}
+
+	tests := []struct {
+		linkIndex int
+		want      int
+	}{
+		{2, 2},
+		{3, 1},
+		{4, 1},
+		{5, 0},
+	}
+
+	for _, tt := range tests {
+		got := nm.ListByLinkIndex(tt.linkIndex)
+		if len(got) != tt.want {
+			t.Errorf("ListByLinkIndex(%d) returned %d neighbors, want %d", tt.linkIndex, len(got), tt.want)
+		}
+		for _, n := range got {
+			if n.LinkIndex != tt.linkIndex {
+				t.Errorf("ListByLinkIndex(%d) returned neighbor on link %d", tt.linkIndex, n.LinkIndex)
+			}
+		}
+	}
+}
+
+// Helper function to record kernel neighbor table calls instead of making them
+func stubNeighTable(t *testing.T) (set, del *[]netlink.Neigh) {
+	origSet, origDel := neighSet, neighDel
+	t.Cleanup(func() {
+		neighSet, neighDel = origSet, origDel
+	})
+
+	set, del = &[]netlink.Neigh{}, &[]netlink.Neigh{}
+	neighSet = func(n *netlink.Neigh) error {
+		*set = append(*set, *n)
+		return nil
+	}
+	neighDel = func(n *netlink.Neigh) error {
+		*del = append(*del, *n)
+		return nil
+	}
+	return set, del
+}
+
+func TestARPTableSetsAndDeletesKernelNeighbor(t *testing.T) {
+	set, del := stubNeighTable(t)
+
+	nm, err := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, ARPTable: true})
+	if err != nil {
+		t.Fatalf("Failed to create neighbor manager: %v", err)
+	}
+
+	ip := net.ParseIP("10.10.70.1")
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+
+	nm.AddNeighbor(ip, 1, mac)
+	defer nm.Cleanup()
+
+	if len(*set) != 1 {
+		t.Fatalf("Expected 1 NeighSet call, got %d", len(*set))
+	}
+
+	got := (*set)[0]
+	if !got.IP.Equal(ip) || got.HardwareAddr.String() != mac.String() || got.LinkIndex != 1 {
+		t.Errorf("Unexpected neighbor entry: %+v", got)
+	}
+	if got.State != netlink.NUD_PERMANENT || got.Family != netlink.FAMILY_V4 {
+		t.Errorf("Expected permanent IPv4 entry, got state %d family %d", got.State, got.Family)
+	}
+
+	nm.RemoveNeighbor(ip, 1)
+
+	if len(*del) != 1 || !(*del)[0].IP.Equal(ip) {
+		t.Errorf("Expected NeighDel for %s, got %+v", ip, *del)
+	}
+}
+
+func TestARPTableDisabledLeavesKernelTable(t *testing.T) {
+	set, del := stubNeighTable(t)
+
+	nm, _ := NewNeighborManager("lo")
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+
+	nm.AddNeighbor(net.ParseIP("10.10.70.2"), 1, mac)
+	nm.RemoveNeighbor(net.ParseIP("10.10.70.2"), 1)
+
+	if len(*set) != 0 || len(*del) != 0 {
+		t.Errorf("Expected no kernel neighbor calls, got %d set and %d del", len(*set), len(*del))
+	}
+}
+
+func TestAddNeighborPermanentSurvivesAgeOut(t *testing.T) {
+	set, del := stubNeighTable(t)
+
+	nm, _ := NewNeighborManager("lo")
+	ip := net.ParseIP("10.10.80.1")
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+
+	if err := nm.AddNeighborPermanent(ip, mac, 1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer nm.Cleanup()
+
+	if len(*set) != 1 || (*set)[0].State != netlink.NUD_PERMANENT {
+		t.Fatalf("Expected a permanent kernel entry, got %+v", *set)
+	}
+
+	if !routeOnLoopbackExists(t, ip.String()) {
+		t.Errorf("Expected route for permanent neighbor")
+	}
+
+	nm.processNeighborUpdate(reachableUpdate(ip.String(), netlink.NUD_FAILED))
+
+	otherMAC, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	nm.AddNeighbor(ip, 2, otherMAC)
+
+	n, ok := nm.GetNeighbor(ip)
+	if !ok || !n.Permanent {
+		t.Fatalf("Expected permanent neighbor to survive a FAILED update")
+	}
+	if n.LinkIndex != 1 || n.HardwareAddr.String() != mac.String() {
+		t.Errorf("Expected permanent neighbor to be left unchanged, got %+v", n)
+	}
+
+	nm.RemoveNeighbor(ip, 1)
+	if len(*del) != 1 {
+		t.Errorf("Expected explicit removal to delete the kernel entry, got %d deletes", len(*del))
+	}
+}
+
+func TestAddNeighborPermanentRequiresMAC(t *testing.T) {
+	stubNeighTable(t)
+	nm, _ := NewNeighborManager("lo")
+
+	if err := nm.AddNeighborPermanent(net.ParseIP("10.10.80.2"), nil, 1); err == nil {
+		t.Errorf("Expected error without a hardware address")
+	}
+
+	if len(nm.ReachableNeighbors) != 0 {
+		t.Errorf("Expected no neighbor to be stored")
+	}
+}
+
+func TestStatsCountsRoutes(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+
+	nm.AddNeighbor(net.ParseIP("10.10.90.1"), 1, nil)
+	nm.AddNeighbor(net.ParseIP("10.10.90.2"), 1, nil)
+	nm.ReachableNeighbors["2001:db8::90"] = Neighbor{IP: net.ParseIP("2001:db8::90"), LinkIndex: 1}
+	nm.RemoveNeighbor(net.ParseIP("10.10.90.1"), 1)
+	defer nm.Cleanup()
+
+	stats := nm.Stats()
+	if stats.TotalNeighbors != 2 || stats.IPv4Neighbors != 1 || stats.IPv6Neighbors != 1 {
+		t.Errorf("Unexpected neighbor counts: %+v", stats)
+	}
+	if stats.RoutesAdded != 2 || stats.RoutesRemoved != 1 {
+		t.Errorf("Expected 2 routes added and 1 removed, got %+v", stats)
+	}
+}
+
+func TestMatchesPrefix(t *testing.T) {
+	_, v4, _ := net.ParseCIDR("10.0.0.0/8")
+	_, v6, _ := net.ParseCIDR("2001:db8::/32")
+	_, v4Excluded, _ := net.ParseCIDR("10.20.0.0/16")
+
+	tests := []struct {
+		name     string
+		prefixes []*net.IPNet
+		excludes []*net.IPNet
+		ip       string
+		want     bool
+	}{
+		{"empty accepts all", nil, nil, "192.168.1.1", true},
+		{"inside v4", []*net.IPNet{v4}, nil, "10.1.2.3", true},
+		{"outside v4", []*net.IPNet{v4}, nil, "192.168.1.1", false},
+		{"inside second prefix", []*net.IPNet{v4, v6}, nil, "2001:db8::1", true},
+		{"outside both", []*net.IPNet{v4, v6}, nil, "2001:db9::1", false},
+		{"exclude wins over include", []*net.IPNet{v4}, []*net.IPNet{v4Excluded}, "10.20.1.1", false},
+		{"outside exclude", []*net.IPNet{v4}, []*net.IPNet{v4Excluded}, "10.21.1.1", true},
+		{"builtin multicast", nil, BuiltinExcludePrefixes(), "ff02::1", false},
+		{"builtin v4 link-local", nil, BuiltinExcludePrefixes(), "169.254.1.1", false},
+		{"builtin v4 multicast", nil, BuiltinExcludePrefixes(), "239.1.1.1", false},
+		{"builtin allows unicast", nil, BuiltinExcludePrefixes(), "2001:db8::1", true},
+	}
+
+	for _, tt := range tests {
+		nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, Policy: NeighborPolicy{AllowPrefixes: tt.prefixes, ExcludePrefixes: tt.excludes}})
+		if got := nm.matchesPrefix(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("%s: matchesPrefix(%s) = %v, want %v", tt.name, tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestAddNeighborOutsidePrefixIgnored(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR("10.10.0.0/16")
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, Policy: NeighborPolicy{AllowPrefixes: []*net.IPNet{prefix}}})
+	defer nm.Cleanup()
+
+	nm.AddNeighbor(net.ParseIP("10.20.0.1"), 1, nil)
+	nm.processNeighborUpdate(reachableUpdate("10.20.0.2", netlink.NUD_REACHABLE))
+
+	if len(nm.ListNeighbors()) != 0 {
+		t.Errorf("Expected neighbors outside the prefix to be ignored")
+	}
+
+	nm.AddNeighbor(net.ParseIP("10.10.0.1"), 1, nil)
+	if _, ok := nm.GetNeighbor(net.ParseIP("10.10.0.1")); !ok {
+		t.Errorf("Expected neighbor inside the prefix to be added")
+	}
+}
+
+func TestMonitorsLink(t *testing.T) {
+	nm := &NeighborManager{}
+	if !nm.monitorsLink(7) {
+		t.Errorf("Expected every link to be monitored without target interfaces")
+	}
+
+	nm.TargetInterfaceIndexes = []int{2, 5}
+	for linkIndex, want := range map[int]bool{2: true, 5: true, 3: false} {
+		if got := nm.monitorsLink(linkIndex); got != want {
+			t.Errorf("monitorsLink(%d) = %v, want %v", linkIndex, got, want)
+		}
+	}
+}
+
+func TestFailedHoldDelaysRemoval(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, FailedHold: 50 * time.Millisecond})
+	defer nm.Cleanup()
+
+	nm.processNeighborUpdate(reachableUpdate("10.10.30.1", netlink.NUD_REACHABLE))
+	nm.processNeighborUpdate(reachableUpdate("10.10.30.1", netlink.NUD_FAILED))
+
+	if _, ok := nm.GetNeighbor(net.ParseIP("10.10.30.1")); !ok {
+		t.Fatalf("Expected neighbor to be kept during the hold time")
+	}
+
+	if !waitFor(t, time.Second, func() bool {
+		_, ok := nm.GetNeighbor(net.ParseIP("10.10.30.1"))
+		return !ok
+	}) {
+		t.Fatalf("Expected neighbor to be removed after the hold time")
+	}
+
+	if routeOnLoopbackExists(t, "10.10.30.1") {
+		t.Errorf("Expected route to be removed after the hold time")
+	}
+}
+
+func TestFailedHoldCancelledOnRecovery(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, FailedHold: 50 * time.Millisecond})
+	defer nm.Cleanup()
+
+	nm.processNeighborUpdate(reachableUpdate("10.10.30.2", netlink.NUD_REACHABLE))
+	nm.processNeighborUpdate(reachableUpdate("10.10.30.2", netlink.NUD_FAILED))
+	nm.processNeighborUpdate(reachableUpdate("10.10.30.2", netlink.NUD_REACHABLE))
+
+	time.Sleep(150 * time.Millisecond)
+
+	if _, ok := nm.GetNeighbor(net.ParseIP("10.10.30.2")); !ok {
+		t.Errorf("Expected recovered neighbor to be kept")
+	}
+
+	nm.mu.Lock()
+	pending := len(nm.pendingRemovals)
+	nm.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("Expected no pending removals, got %d", pending)
+	}
+}
+
+func TestCleanupStopsPendingRemovals(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, FailedHold: time.Hour})
+
+	nm.processNeighborUpdate(reachableUpdate("10.10.30.3", netlink.NUD_REACHABLE))
+	nm.processNeighborUpdate(reachableUpdate("10.10.30.3", netlink.NUD_FAILED))
+	nm.Cleanup()
+
+	if len(nm.pendingRemovals) != 0 {
+		t.Errorf("Expected Cleanup to cancel pending removals, got %d", len(nm.pendingRemovals))
+	}
+}
+
+func TestKeepRoutesLeavesRoutesInstalled(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, FailedHold: time.Hour})
+
+	ip := "10.10.30.4"
+	nm.processNeighborUpdate(reachableUpdate(ip, netlink.NUD_REACHABLE))
+	nm.processNeighborUpdate(reachableUpdate(ip, netlink.NUD_FAILED))
+	nm.KeepRoutes()
+	defer netutils.RemoveRoute(context.Background(), net.ParseIP(ip), 1)
+
+	if len(nm.pendingRemovals) != 0 {
+		t.Errorf("Expected KeepRoutes to cancel pending removals, got %d", len(nm.pendingRemovals))
+	}
+	if !routeOnLoopbackExists(t, ip) {
+		t.Errorf("Expected the route for %s to be kept", ip)
+	}
+}
+
+func TestPingBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 60 * time.Second},
+		{2, 120 * time.Second},
+		{3, 240 * time.Second},
+		{4, 300 * time.Second},
+		{50, 300 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := pingBackoff(30*time.Second, tt.failures); got != tt.want {
+			t.Errorf("pingBackoff(30s, %d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestPingDueBacksOffFailingNeighbors(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{PingBackoff: true})
+	nm.ReachableNeighbors["10.0.0.1"] = Neighbor{IP: net.ParseIP("10.0.0.1")}
+	nm.ReachableNeighbors["10.0.0.2"] = Neighbor{IP: net.ParseIP("10.0.0.2")}
+	nm.ReachableNeighbors["10.0.0.3"] = Neighbor{IP: net.ParseIP("10.0.0.3"), Permanent: true}
+
+	if due := nm.pingDue(time.Now()); len(due) != 2 {
+		t.Fatalf("Expected 2 neighbors due, got %v", due)
+	}
+
+	nm.recordPingResult("10.0.0.1", false, time.Minute)
+	nm.recordPingResult("10.0.0.2", true, time.Minute)
+
+	due := nm.pingDue(time.Now())
+	if len(due) != 1 || !due[0].IP.Equal(net.ParseIP("10.0.0.2")) {
+		t.Fatalf("Expected only 10.0.0.2 due after 10.0.0.1 failed, got %v", due)
+	}
+
+	if due := nm.pingDue(time.Now().Add(2*time.Minute + time.Second)); len(due) != 2 {
+		t.Errorf("Expected 10.0.0.1 due again after the backoff, got %v", due)
+	}
+
+	nm.recordPingResult("10.0.0.1", true, time.Minute)
+	if nm.pingFailureCounts["10.0.0.1"] != 0 {
+		t.Errorf("Expected failure count reset on success, got %d", nm.pingFailureCounts["10.0.0.1"])
+	}
+
+	nm.recordPingResult("10.0.0.2", false, time.Minute)
+	delete(nm.ReachableNeighbors, "10.0.0.2")
+	nm.pingDue(time.Now())
+	if _, ok := nm.pingFailureCounts["10.0.0.2"]; ok {
+		t.Errorf("Expected backoff state of removed neighbor to be dropped")
+	}
+}
+
+func TestSendPingsUsesPingInterval(t *testing.T) {
+	orig := ping
+	t.Cleanup(func() { ping = orig })
+
+	var pings atomic.Int32
+	ping = func(ctx context.Context, ip string) error {
+		pings.Add(1)
+		return nil
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(Config{PingInterval: 10 * time.Millisecond})
+	nm.ReachableNeighbors["10.0.0.1"] = Neighbor{IP: net.ParseIP("10.0.0.1")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		nm.SendPings(ctx)
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-done
+
+	if n := pings.Load(); n < 3 {
+		t.Errorf("Expected several pings at a 10ms interval, got %d", n)
+	}
+}
+
+func TestKeepaliveUsesNSForIPv6(t *testing.T) {
+	origPing, origNS := ping, sendNS
+	t.Cleanup(func() { ping, sendNS = origPing, origNS })
+
+	var pinged, solicited []string
+	ping = func(ctx context.Context, ip string) error {
+		pinged = append(pinged, ip)
+		return nil
+	}
+	sendNS = func(ip net.IP, linkIndex int) error {
+		solicited = append(solicited, ip.String())
+		return nil
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(Config{UseNS: true})
+	nm.keepalive(Neighbor{IP: net.ParseIP("10.0.0.1"), LinkIndex: 1})
+	nm.keepalive(Neighbor{IP: net.ParseIP("2001:db8::1"), LinkIndex: 1})
+
+	nm.UseNS = false
+	nm.keepalive(Neighbor{IP: net.ParseIP("2001:db8::2"), LinkIndex: 1})
+
+	if len(pinged) != 2 || pinged[0] != "10.0.0.1" || pinged[1] != "2001:db8::2" {
+		t.Errorf("Expected echo for IPv4 and for IPv6 without UseNS, got %v", pinged)
+	}
+	if len(solicited) != 1 || solicited[0] != "2001:db8::1" {
+		t.Errorf("Expected NS for the IPv6 neighbor, got %v", solicited)
+	}
+}
+
+func TestKeepaliveUsesPingTimeout(t *testing.T) {
+	orig := ping
+	t.Cleanup(func() { ping = orig })
+
+	var timeouts []time.Duration
+	ping = func(ctx context.Context, ip string) error {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			t.Fatalf("Expected the ping context to have a deadline")
+		}
+		timeouts = append(timeouts, time.Until(deadline).Round(100*time.Millisecond))
+		return nil
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, PingTimeout: 2 * time.Second})
+	defer nm.Cleanup()
+
+	nm.SetPingTimeout(net.ParseIP("10.10.51.1"), 500*time.Millisecond)
+	nm.AddNeighbor(net.ParseIP("10.10.51.1"), 1, nil)
+	nm.AddNeighbor(net.ParseIP("10.10.51.2"), 1, nil)
+
+	for _, ip := range []string{"10.10.51.1", "10.10.51.2"} {
+		n, _ := nm.GetNeighbor(net.ParseIP(ip))
+		nm.keepalive(n)
+	}
+
+	nm.SetPingTimeout(net.ParseIP("10.10.51.1"), 0)
+	n, _ := nm.GetNeighbor(net.ParseIP("10.10.51.1"))
+	if n.PingTimeout != 0 {
+		t.Errorf("Expected the override to be dropped, got %s", n.PingTimeout)
+	}
+	nm.keepalive(n)
+
+	want := []time.Duration{500 * time.Millisecond, 2 * time.Second, 2 * time.Second}
+	if !reflect.DeepEqual(timeouts, want) {
+		t.Errorf("Expected ping timeouts %v, got %v", want, timeouts)
+	}
+}
+
+func TestAddRateLimitDropsExcessUpdates(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, AddRateLimit: 0.001, AddBurst: 2})
+	defer nm.Cleanup()
+
+	for _, ip := range []string{"10.10.50.1", "10.10.50.2", "10.10.50.3"} {
+		nm.processNeighborUpdate(reachableUpdate(ip, netlink.NUD_REACHABLE))
+	}
+
+	if len(nm.ListNeighbors()) != 2 {
+		t.Errorf("Expected 2 neighbors within the burst, got %d", len(nm.ListNeighbors()))
+	}
+	if _, ok := nm.GetNeighbor(net.ParseIP("10.10.50.3")); ok {
+		t.Errorf("Expected 10.10.50.3 to be dropped by the rate limiter")
+	}
+
+	// Direct adds, e.g. from the initial table scan, are not limited.
+	nm.AddNeighbor(net.ParseIP("10.10.50.4"), 1, nil)
+	if _, ok := nm.GetNeighbor(net.ParseIP("10.10.50.4")); !ok {
+		t.Errorf("Expected AddNeighbor to bypass the rate limiter")
+	}
+}
+
+func TestAddNeighborTracksAge(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	ip := net.ParseIP("192.168.100.170")
+	defer nm.RemoveNeighbor(ip, 1)
+
+	nm.AddNeighbor(ip, 1, nil)
+	first, _ := nm.GetNeighbor(ip)
+	if first.FirstSeen.IsZero() || !first.LastUpdated.Equal(first.FirstSeen) {
+		t.Fatalf("Expected FirstSeen and LastUpdated to be set on insert, got %+v", first)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	nm.AddNeighbor(ip, 1, nil)
+	second, _ := nm.GetNeighbor(ip)
+	if !second.FirstSeen.Equal(first.FirstSeen) {
+		t.Errorf("Expected FirstSeen %v to be kept, got %v", first.FirstSeen, second.FirstSeen)
+	}
+	if !second.LastUpdated.After(first.LastUpdated) {
+		t.Errorf("Expected LastUpdated to advance past %v, got %v", first.LastUpdated, second.LastUpdated)
+	}
+}
+
+func TestSetRouteMetricReinstallsRoutes(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	ip := net.ParseIP("192.168.100.171")
+	nm.AddNeighbor(ip, 1, nil)
+	defer nm.RemoveNeighbor(ip, 1)
+
+	nm.SetRouteMetric(321)
+
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{
+		LinkIndex: 1,
+		Dst:       &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)},
+	}, netlink.RT_FILTER_DST|netlink.RT_FILTER_OIF)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+
+	if len(routes) != 1 || routes[0].Priority != 321 {
+		t.Errorf("Expected a single route with metric 321, got %+v", routes)
+	}
+}
+
+func TestSetRouteMetricToDefault(t *testing.T) {
+	for _, ip := range []string{"192.168.100.172", "2001:db8:100::172"} {
+		t.Run(ip, func(t *testing.T) {
+			nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, RouteMetric: 321})
+			nm.AddNeighbor(net.ParseIP(ip), 1, nil)
+			defer nm.RemoveNeighbor(net.ParseIP(ip), 1)
+
+			nm.SetRouteMetric(0)
+
+			if got := routeLinks(t, ip); len(got) != 1 || got[0] != 1 {
+				t.Fatalf("Expected a single route on link 1 after resetting the metric, got %v", got)
+			}
+			if stats := nm.Stats(); stats.RoutesAdded != 2 || stats.RoutesRemoved != 1 {
+				t.Errorf("Expected 2 routes added and 1 removed, got %d and %d", stats.RoutesAdded, stats.RoutesRemoved)
+			}
+		})
+	}
+}
+
+func TestSetPingInterval(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	if got := nm.pingInterval(); got != DefaultPingInterval {
+		t.Errorf("Expected default interval %s, got %s", DefaultPingInterval, got)
+	}
+
+	nm.SetPingInterval(5 * time.Second)
+	if got := nm.pingInterval(); got != 5*time.Second {
+		t.Errorf("Expected 5s, got %s", got)
+	}
+}
+
+func TestDryRunTracksNeighborsWithoutRoutes(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, DryRun: true})
+	ip := "192.168.100.172"
+	nm.AddNeighbor(net.ParseIP(ip), 1, nil)
+
+	if _, ok := nm.GetNeighbor(net.ParseIP(ip)); !ok {
+		t.Errorf("Expected neighbor %s to be tracked in dry-run mode", ip)
+	}
+
+	if routeOnLoopbackExists(t, ip) {
+		t.Errorf("Expected no route for %s in dry-run mode", ip)
+	}
+
+	nm.RemoveNeighbor(net.ParseIP(ip), 1)
+	if _, ok := nm.GetNeighbor(net.ParseIP(ip)); ok {
+		t.Errorf("Expected neighbor %s to be removed", ip)
+	}
+}
+
+func TestRevalidatingStatesKeepAndAddRoutes(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	defer nm.Cleanup()
+
+	// Started mid-validation: the first update seen is PROBE.
+	nm.processNeighborUpdate(reachableUpdate("192.168.100.180", netlink.NUD_PROBE))
+	if _, ok := nm.GetNeighbor(net.ParseIP("192.168.100.180")); !ok {
+		t.Errorf("Expected a neighbor first seen in NUD_PROBE to be added")
+	}
+
+	nm.processNeighborUpdate(reachableUpdate("192.168.100.181", netlink.NUD_REACHABLE))
+	nm.processNeighborUpdate(reachableUpdate("192.168.100.181", netlink.NUD_DELAY))
+	nm.processNeighborUpdate(reachableUpdate("192.168.100.181", netlink.NUD_PROBE))
+	if _, ok := nm.GetNeighbor(net.ParseIP("192.168.100.181")); !ok {
+		t.Errorf("Expected a neighbor in re-validation to be kept")
+	}
+
+	if !routeOnLoopbackExists(t, "192.168.100.180") || !routeOnLoopbackExists(t, "192.168.100.181") {
+		t.Errorf("Expected routes for both neighbors")
+	}
+
+	nm.processNeighborUpdate(reachableUpdate("192.168.100.181", netlink.NUD_FAILED))
+	if _, ok := nm.GetNeighbor(net.ParseIP("192.168.100.181")); ok {
+		t.Errorf("Expected a neighbor that failed re-validation to be removed")
+	}
+}
+
+func TestMonitorNeighborsRetriesSubscribe(t *testing.T) {
+	origSubscribe, origBackoff := neighSubscribe, monitorRetryBackoff
+	t.Cleanup(func() { neighSubscribe, monitorRetryBackoff = origSubscribe, origBackoff })
+	monitorRetryBackoff = time.Millisecond
+
+	var attempts atomic.Int32
+	subscribed := make(chan chan<- netlink.NeighUpdate, 1)
+	neighSubscribe = func(ch chan<- netlink.NeighUpdate, done <-chan struct{}, linkIndexes []int) error {
+		if attempts.Add(1) <= 3 {
+			return errors.New("netlink socket unavailable")
+		}
+		subscribed <- ch
+		return nil
+	}
+
+	nm, _ := NewNeighborManager("lo")
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- nm.MonitorNeighbors(ctx) }()
+
+	select {
+	case <-subscribed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected MonitorNeighbors to keep retrying until subscribe succeeds")
+	}
+
+	if got := nm.Stats().NetlinkReconnects; got != 3 {
+		t.Errorf("Expected 3 reconnects, got %d", got)
+	}
+
+	cancel()
+	if err := <-result; err != nil {
+		t.Errorf("Expected nil after cancellation, got %v", err)
+	}
+}
+
+func TestMonitorNeighborsRescansAfterResubscribe(t *testing.T) {
+	origSubscribe, origBackoff, origList := neighSubscribe, monitorRetryBackoff, neighList
+	t.Cleanup(func() { neighSubscribe, monitorRetryBackoff, neighList = origSubscribe, origBackoff, origList })
+	monitorRetryBackoff = time.Millisecond
+
+	var scans atomic.Int32
+	neighList = func(linkIndex int, family int) ([]netlink.Neigh, error) {
+		scans.Add(1)
+		// Learned while the socket was down.
+		return []netlink.Neigh{{IP: net.ParseIP("10.10.69.1"), LinkIndex: 1, State: netlink.NUD_REACHABLE}}, nil
+	}
+
+	var subscribes atomic.Int32
+	resubscribed := make(chan struct{})
+	neighSubscribe = func(ch chan<- netlink.NeighUpdate, done <-chan struct{}, linkIndexes []int) error {
+		switch subscribes.Add(1) {
+		case 1:
+			if scans.Load() != 0 {
+				t.Errorf("Expected no scan on the first subscribe")
+			}
+			close(ch)
+		case 2:
+			close(resubscribed)
+		}
+		return nil
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, DryRun: true})
+	defer nm.Cleanup()
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- nm.MonitorNeighbors(ctx) }()
+
+	select {
+	case <-resubscribed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected MonitorNeighbors to resubscribe after the channel closed")
+	}
+	if !waitFor(t, time.Second, func() bool {
+		_, ok := nm.GetNeighbor(net.ParseIP("10.10.69.1"))
+		return ok
+	}) {
+		t.Errorf("Expected the neighbor learned while unsubscribed to be added by a rescan")
+	}
+
+	cancel()
+	if err := <-result; err != nil {
+		t.Errorf("Expected nil after cancellation, got %v", err)
+	}
+}
+
+func vlanUpdate(ip string, vlanID int, state int) netlink.NeighUpdate {
+	u := reachableUpdate(ip, state)
+	u.Neigh.Vlan = vlanID
+	return u
+}
+
+func TestVLANAwareTracksNeighborPerVLAN(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, VLANAware: true})
+	defer nm.Cleanup()
+
+	ip := "192.168.100.190"
+	nm.processNeighborUpdate(vlanUpdate(ip, 100, netlink.NUD_REACHABLE))
+	nm.processNeighborUpdate(vlanUpdate(ip, 200, netlink.NUD_REACHABLE))
+
+	for _, key := range []string{ip + "@vlan100", ip + "@vlan200"} {
+		if _, ok := nm.ReachableNeighbors[key]; !ok {
+			t.Errorf("Expected an entry for %s, got %v", key, nm.ReachableNeighbors)
+		}
+	}
+
+	if n, ok := nm.GetNeighbor(net.ParseIP(ip)); !ok || n.VlanID != 100 {
+		t.Errorf("Expected the lowest VLAN entry, got %+v", n)
+	}
+
+	nm.processNeighborUpdate(vlanUpdate(ip, 100, netlink.NUD_FAILED))
+	if _, ok := nm.ReachableNeighbors[ip+"@vlan100"]; ok {
+		t.Errorf("Expected the VLAN 100 entry to be removed")
+	}
+	if !routeOnLoopbackExists(t, ip) {
+		t.Errorf("Expected the route to be kept while VLAN 200 still has the neighbor")
+	}
+
+	nm.processNeighborUpdate(vlanUpdate(ip, 200, netlink.NUD_FAILED))
+	if _, ok := nm.GetNeighbor(net.ParseIP(ip)); ok {
+		t.Errorf("Expected the neighbor to be removed from every VLAN")
+	}
+	if routeOnLoopbackExists(t, ip) {
+		t.Errorf("Expected the route to be removed with the last VLAN entry")
+	}
+}
+
+func TestInitializeNeighborTableKeepsVLAN(t *testing.T) {
+	ip := "192.168.100.191"
+	orig := neighList
+	t.Cleanup(func() { neighList = orig })
+	neighList = func(linkIndex int, family int) ([]netlink.Neigh, error) {
+		return []netlink.Neigh{{IP: net.ParseIP(ip), LinkIndex: 1, Vlan: 100, State: netlink.NUD_REACHABLE}}, nil
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, VLANAware: true})
+	defer nm.Cleanup()
+
+	if err := nm.InitializeNeighborTable(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := nm.ReachableNeighbors[ip+"@vlan100"]; !ok {
+		t.Fatalf("Expected the neighbor keyed by its VLAN, got %v", nm.ReachableNeighbors)
+	}
+
+	nm.processNeighborUpdate(vlanUpdate(ip, 100, netlink.NUD_FAILED))
+	if _, ok := nm.GetNeighbor(net.ParseIP(ip)); ok {
+		t.Errorf("Expected a FAILED update on the VLAN to remove the neighbor")
+	}
+	if routeOnLoopbackExists(t, ip) {
+		t.Errorf("Expected the route to be removed with the neighbor")
+	}
+}
+
+func TestVLANIgnoredByDefault(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	defer nm.Cleanup()
+
+	ip := "192.168.100.191"
+	nm.processNeighborUpdate(vlanUpdate(ip, 100, netlink.NUD_REACHABLE))
+	nm.processNeighborUpdate(vlanUpdate(ip, 200, netlink.NUD_REACHABLE))
+
+	if len(nm.ReachableNeighbors) != 1 {
+		t.Fatalf("Expected a single entry keyed by IP, got %v", nm.ReachableNeighbors)
+	}
+	if _, ok := nm.ReachableNeighbors[ip]; !ok {
+		t.Errorf("Expected the entry to be keyed by %s", ip)
+	}
+
+	nm.processNeighborUpdate(vlanUpdate(ip, 100, netlink.NUD_FAILED))
+	if _, ok := nm.GetNeighbor(net.ParseIP(ip)); ok {
+		t.Errorf("Expected the neighbor to be removed")
+	}
+}
+
+// injectedSet is an ExtLearnedOwner that claims the IPs in it on link 1.
+type injectedSet map[string]bool
+
+func (s injectedSet) Injected(ip net.IP, linkIndex int) bool {
+	return linkIndex == 1 && s[ip.String()]
+}
+
+func (s injectedSet) Forget(ip net.IP, linkIndex int) {
+	if linkIndex == 1 {
+		delete(s, ip.String())
+	}
+}
+
+func TestExtLearnedOwnerRoutesSnifferEntries(t *testing.T) {
+	extLearned := func(ip string) netlink.NeighUpdate {
+		u := reachableUpdate(ip, netlink.NUD_REACHABLE)
+		u.Neigh.Flags = netlink.NTF_EXT_LEARNED
+		return u
+	}
+
+	nm, _ := NewNeighborManager("lo")
+	nm.processNeighborUpdate(extLearned("192.168.100.192"))
+	if _, ok := nm.GetNeighbor(net.ParseIP("192.168.100.192")); ok {
+		t.Errorf("Expected an ext_learned entry to be skipped by default")
+	}
+	nm.Cleanup()
+
+	orig := neighList
+	t.Cleanup(func() { neighList = orig })
+	neighList = func(linkIndex int, family int) ([]netlink.Neigh, error) {
+		return []netlink.Neigh{{IP: net.ParseIP("192.168.100.193"), LinkIndex: 1, State: netlink.NUD_REACHABLE, Flags: netlink.NTF_EXT_LEARNED}}, nil
+	}
+
+	owner := injectedSet{"192.168.100.192": true, "192.168.100.193": true}
+	nm, _ = NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, ExtLearnedOwner: owner})
+	defer nm.Cleanup()
+
+	if err := nm.InitializeNeighborTable(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	nm.processNeighborUpdate(extLearned("192.168.100.192"))
+
+	for _, ip := range []string{"192.168.100.192", "192.168.100.193"} {
+		if _, ok := nm.GetNeighbor(net.ParseIP(ip)); !ok {
+			t.Errorf("Expected the ext_learned neighbor %s to be tracked", ip)
+		}
+		if !routeOnLoopbackExists(t, ip) {
+			t.Errorf("Expected a route for the ext_learned neighbor %s", ip)
+		}
+	}
+
+	// An EVPN entry the sniffer did not add is still left alone.
+	nm.processNeighborUpdate(extLearned("192.168.100.194"))
+	if _, ok := nm.GetNeighbor(net.ParseIP("192.168.100.194")); ok {
+		t.Errorf("Expected an ext_learned entry of another control plane to be skipped")
+	}
+
+	deleted := extLearned("192.168.100.192")
+	deleted.Type = unix.RTM_DELNEIGH
+	deleted.Neigh.State = netlink.NUD_FAILED
+	nm.processNeighborUpdate(deleted)
+	if _, ok := nm.GetNeighbor(net.ParseIP("192.168.100.192")); ok {
+		t.Errorf("Expected the deleted ext_learned neighbor to be removed")
+	}
+	if owner["192.168.100.192"] {
+		t.Errorf("Expected the owner to forget the deleted entry")
+	}
+	if !owner["192.168.100.193"] {
+		t.Errorf("Expected the owner to keep the entries still in the kernel")
+	}
+}
+
+func routeOnLinkExists(t *testing.T, ip string, linkIndex int) bool {
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{
+		LinkIndex: linkIndex,
+		Dst:       &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)},
+	}, netlink.RT_FILTER_DST|netlink.RT_FILTER_OIF)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+	return len(routes) > 0
+}
+
+// Helper function to list the metrics of the IPv4 host routes to ip on linkIndex
+func routeMetrics(t *testing.T, ip string, linkIndex int) []int {
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{
+		LinkIndex: linkIndex,
+		Dst:       &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(32, 32)},
+	}, netlink.RT_FILTER_DST|netlink.RT_FILTER_OIF)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+	var metrics []int
+	for _, r := range routes {
+		metrics = append(metrics, r.Priority)
+	}
+	return metrics
+}
+
+// Helper function to create a bridge link that is deleted when the test ends
+func addBridgeLink(t *testing.T, name string) int {
+	link := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: name}}
+	if err := netlink.LinkAdd(link); err != nil {
+		t.Skipf("cannot create bridge link: %v", err)
+	}
+	t.Cleanup(func() { netlink.LinkDel(link) })
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		t.Fatalf("failed to bring up %s: %v", name, err)
+	}
+	created, err := netlink.LinkByName(name)
+	if err != nil {
+		t.Fatalf("failed to look up %s: %v", name, err)
+	}
+	return created.Attrs().Index
+}
+
+func TestMigrationGraceKeepsOldRoute(t *testing.T) {
+	bridge := addBridgeLink(t, "n2r-mig0")
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, MigrationGrace: 100 * time.Millisecond})
+	defer nm.Cleanup()
+
+	ip := "192.168.100.195"
+	nm.AddNeighbor(net.ParseIP(ip), 1, nil)
+	nm.AddNeighbor(net.ParseIP(ip), bridge, nil)
+
+	if !routeOnLinkExists(t, ip, 1) || !routeOnLinkExists(t, ip, bridge) {
+		t.Fatalf("Expected routes on both links during the grace period")
+	}
+
+	if !waitFor(t, time.Second, func() bool { return !routeOnLinkExists(t, ip, 1) }) {
+		t.Fatalf("Expected the old route to be removed after the grace period")
+	}
+	if !routeOnLinkExists(t, ip, bridge) {
+		t.Errorf("Expected the new route to be kept")
+	}
+}
+
+func TestMigrationGracePrefersNewRoute(t *testing.T) {
+	bridge := addBridgeLink(t, "n2r-mig2")
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, RouteMetric: 200, MigrationGrace: 100 * time.Millisecond})
+	defer nm.Cleanup()
+
+	ip := "192.168.100.198"
+	nm.AddNeighbor(net.ParseIP(ip), 1, nil)
+	nm.AddNeighbor(net.ParseIP(ip), bridge, nil)
+
+	if got := routeMetrics(t, ip, bridge); len(got) != 1 || got[0] != 199 {
+		t.Fatalf("Expected the new route with metric 199 during the grace period, got %v", got)
+	}
+
+	if !waitFor(t, time.Second, func() bool { return !routeOnLinkExists(t, ip, 1) }) {
+		t.Fatalf("Expected the old route to be removed after the grace period")
+	}
+	if got := routeMetrics(t, ip, bridge); len(got) != 1 || got[0] != 200 {
+		t.Errorf("Expected the new route with metric 200 after the grace period, got %v", got)
+	}
+}
+
+func TestMigrationGraceKeepsRouteOnMoveBack(t *testing.T) {
+	bridge := addBridgeLink(t, "n2r-mig1")
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, MigrationGrace: 50 * time.Millisecond})
+	defer nm.Cleanup()
+
+	ip := "192.168.100.196"
+	nm.AddNeighbor(net.ParseIP(ip), 1, nil)
+	nm.AddNeighbor(net.ParseIP(ip), bridge, nil)
+	nm.AddNeighbor(net.ParseIP(ip), 1, nil)
+
+	if !waitFor(t, time.Second, func() bool { return !routeOnLinkExists(t, ip, bridge) }) {
+		t.Fatalf("Expected the route on the bridge link to be removed after the grace period")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if !routeOnLinkExists(t, ip, 1) {
+		t.Errorf("Expected the route on the link the neighbor moved back to to be kept")
+	}
+}
+
+func TestLinkChangeAfterShutdownReleasesLock(t *testing.T) {
+	bridge := addBridgeLink(t, "n2r-mv0")
+	ctx, cancel := context.WithCancel(context.Background())
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, Context: ctx})
+
+	ip := net.ParseIP("192.168.100.197")
+	nm.AddNeighbor(ip, 1, nil)
+	defer netutils.RemoveRoute(context.Background(), ip, 1)
+
+	// Removing the old route fails once the manager's context is done.
+	cancel()
+	nm.AddNeighbor(ip, bridge, nil)
+
+	done := make(chan struct{})
+	go func() {
+		nm.ListNeighbors()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the neighbor table to stay unlocked after a failed route removal")
+	}
+}
+
+// Helper function to list the links of the IPv4 or IPv6 host routes to ip,
+// following the nexthops of multipath routes
+func routeLinks(t *testing.T, ip string) []int {
+	dst := &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(128, 128)}
+	if ip4 := dst.IP.To4(); ip4 != nil {
+		dst = &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, &netlink.Route{Dst: dst}, netlink.RT_FILTER_DST)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+
+	var links []int
+	for _, route := range routes {
+		if len(route.MultiPath) == 0 {
+			links = append(links, route.LinkIndex)
+		}
+		for _, nexthop := range route.MultiPath {
+			links = append(links, nexthop.LinkIndex)
+		}
+	}
+	sort.Ints(links)
+	return links
+}
+
+func TestBatchRemoveNeighborsECMP(t *testing.T) {
+	first := addBridgeLink(t, "n2r-ecmp2")
+	second := addBridgeLink(t, "n2r-ecmp3")
+
+	for _, ip := range []string{"192.168.100.206", "2001:db8:100::206"} {
+		t.Run(ip, func(t *testing.T) {
+			nm, _ := NewNeighborManagerFromConfig(Config{ECMP: true})
+			defer nm.Cleanup()
+
+			nm.AddNeighbor(net.ParseIP(ip), first, nil)
+			nm.AddNeighbor(net.ParseIP(ip), second, nil)
+
+			removed, errs := nm.BatchRemoveNeighbors([]net.IP{net.ParseIP(ip)})
+			if removed != 1 || len(errs) != 0 {
+				t.Fatalf("Expected 1 removed without errors, got %d %v", removed, errs)
+			}
+			if _, ok := nm.GetNeighbor(net.ParseIP(ip)); ok {
+				t.Errorf("Expected the neighbor to be removed from every link")
+			}
+			if got := routeLinks(t, ip); len(got) != 0 {
+				t.Errorf("Expected no routes left, got %v", got)
+			}
+		})
+	}
+}
+
+func TestECMPBalancesOverEveryLink(t *testing.T) {
+	first := addBridgeLink(t, "n2r-ecmp0")
+	second := addBridgeLink(t, "n2r-ecmp1")
+
+	for _, ip := range []string{"192.168.100.198", "2001:db8:100::198"} {
+		t.Run(ip, func(t *testing.T) {
+			nm, _ := NewNeighborManagerFromConfig(Config{ECMP: true})
+			defer nm.Cleanup()
+
+			nm.AddNeighbor(net.ParseIP(ip), first, nil)
+			nm.AddNeighbor(net.ParseIP(ip), second, nil)
+
+			if got := routeLinks(t, ip); !reflect.DeepEqual(got, []int{first, second}) {
+				t.Fatalf("Expected routes over links %v, got %v", []int{first, second}, got)
+			}
+
+			nm.RemoveNeighbor(net.ParseIP(ip), first)
+
+			n, ok := nm.GetNeighbor(net.ParseIP(ip))
+			if !ok {
+				t.Fatalf("Expected the neighbor to stay while it is reachable over another link")
+			}
+			if n.LinkIndex != second || !reflect.DeepEqual(n.LinkIndexes, []int{second}) {
+				t.Errorf("Expected the neighbor on link %d only, got %d %v", second, n.LinkIndex, n.LinkIndexes)
+			}
+			if got := routeLinks(t, ip); !reflect.DeepEqual(got, []int{second}) {
+				t.Fatalf("Expected a route over link %d only, got %v", second, got)
+			}
+
+			nm.RemoveNeighbor(net.ParseIP(ip), second)
+
+			if _, ok := nm.GetNeighbor(net.ParseIP(ip)); ok {
+				t.Errorf("Expected the neighbor to be removed with its last link")
+			}
+			if got := routeLinks(t, ip); len(got) != 0 {
+				t.Errorf("Expected no routes left, got %v", got)
+			}
+		})
+	}
+}
+
+func TestECMPMultipathRouteAuditAndCleanup(t *testing.T) {
+	first := addBridgeLink(t, "n2r-ecmp2")
+	second := addBridgeLink(t, "n2r-ecmp3")
+
+	nm, _ := NewNeighborManagerFromConfig(Config{ECMP: true})
+	ip := "192.168.100.199"
+	nm.AddNeighbor(net.ParseIP(ip), first, nil)
+	nm.AddNeighbor(net.ParseIP(ip), second, nil)
+
+	if restored := nm.auditRoutes(); restored != 0 {
+		t.Errorf("Expected the audit to find the multipath route, restored %d", restored)
+	}
+
+	nm.Cleanup()
+
+	if got := routeLinks(t, ip); len(got) != 0 {
+		t.Errorf("Expected cleanup to remove the multipath route, got links %v", got)
+	}
+}
+
+func TestMaxNeighborsRejectsNewNeighbors(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, MaxNeighbors: 2})
+	defer nm.Cleanup()
+
+	for _, ip := range []string{"192.168.100.200", "192.168.100.201", "192.168.100.202"} {
+		nm.AddNeighbor(net.ParseIP(ip), 1, nil)
+	}
+
+	if len(nm.ReachableNeighbors) != 2 {
+		t.Fatalf("Expected the table to stop at 2 neighbors, got %d", len(nm.ReachableNeighbors))
+	}
+	if _, ok := nm.GetNeighbor(net.ParseIP("192.168.100.202")); ok {
+		t.Errorf("Expected the neighbor over the limit to be rejected")
+	}
+	if routeOnLoopbackExists(t, "192.168.100.202") {
+		t.Errorf("Expected no route for the rejected neighbor")
+	}
+	if got := nm.Stats().NeighborsRejected; got != 1 {
+		t.Errorf("Expected 1 rejected neighbor, got %d", got)
+	}
+
+	// Updates for known neighbors are not additions.
+	nm.AddNeighbor(net.ParseIP("192.168.100.200"), 1, nil)
+	if got := nm.Stats().NeighborsRejected; got != 1 {
+		t.Errorf("Expected refreshing a known neighbor not to be rejected, got %d rejections", got)
+	}
+}
+
+func TestMaxNeighborsEvictsLeastRecentlyUpdated(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, MaxNeighbors: 2, EvictPolicy: EvictLRU})
+	defer nm.Cleanup()
+
+	nm.AddNeighbor(net.ParseIP("192.168.100.203"), 1, nil)
+	time.Sleep(time.Millisecond)
+	nm.AddNeighbor(net.ParseIP("192.168.100.204"), 1, nil)
+	time.Sleep(time.Millisecond)
+	// Refreshing .203 makes .204 the least recently updated.
+	nm.AddNeighbor(net.ParseIP("192.168.100.203"), 1, nil)
+	nm.AddNeighbor(net.ParseIP("192.168.100.205"), 1, nil)
+
+	if _, ok := nm.GetNeighbor(net.ParseIP("192.168.100.204")); ok {
+		t.Errorf("Expected the least recently updated neighbor to be evicted")
+	}
+	if routeOnLoopbackExists(t, "192.168.100.204") {
+		t.Errorf("Expected the route of the evicted neighbor to be removed")
+	}
+	for _, ip := range []string{"192.168.100.203", "192.168.100.205"} {
+		if _, ok := nm.GetNeighbor(net.ParseIP(ip)); !ok {
+			t.Errorf("Expected neighbor %s to be tracked", ip)
+		}
+	}
+	if got := nm.Stats().NeighborsEvicted; got != 1 {
+		t.Errorf("Expected 1 evicted neighbor, got %d", got)
+	}
+}
+
+func TestParseEvictPolicy(t *testing.T) {
+	for _, s := range []string{"none", "lru"} {
+		if p, err := ParseEvictPolicy(s); err != nil || string(p) != s {
+			t.Errorf("ParseEvictPolicy(%q) = %q, %v", s, p, err)
+		}
+	}
+	if _, err := ParseEvictPolicy("fifo"); err == nil {
+		t.Errorf("Expected an error for an unknown policy")
+	}
+}
+
+func TestIPv6GatewayUsesNeighborLinkLocal(t *testing.T) {
+	bridge := addBridgeLink(t, "n2r-gw0")
+	mac, _ := net.ParseMAC("02:00:00:00:78:01")
+	other, _ := net.ParseMAC("02:00:00:00:78:02")
+
+	orig := neighList
+	t.Cleanup(func() { neighList = orig })
+	neighList = func(linkIndex int, family int) ([]netlink.Neigh, error) {
+		if linkIndex != bridge || family != netlink.FAMILY_V6 {
+			t.Errorf("Unexpected neighbor listing for link %d family %d", linkIndex, family)
+		}
+		return []netlink.Neigh{
+			{IP: net.ParseIP("fe80::98"), HardwareAddr: other, State: netlink.NUD_REACHABLE},
+			{IP: net.ParseIP("fe80::99"), HardwareAddr: mac, State: netlink.NUD_STALE},
+		}, nil
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"n2r-gw0"}, IPv6Gateway: true})
+	defer nm.Cleanup()
+
+	var metric int
+	gateway := func(ip string) net.IP {
+		dst := &net.IPNet{IP: net.ParseIP(ip), Mask: net.CIDRMask(128, 128)}
+		routes, err := netlink.RouteListFiltered(netlink.FAMILY_V6, &netlink.Route{Dst: dst}, netlink.RT_FILTER_DST)
+		if err != nil || len(routes) != 1 {
+			t.Fatalf("Expected one route to %s, got %v (%v)", ip, routes, err)
+		}
+		if metric > 0 && routes[0].Priority != metric {
+			t.Errorf("Expected the route to %s with metric %d, got %d", ip, metric, routes[0].Priority)
+		}
+		return routes[0].Gw
+	}
+
+	nm.AddNeighbor(net.ParseIP("2001:db8:78::5"), bridge, mac)
+	if gw := gateway("2001:db8:78::5"); !gw.Equal(net.ParseIP("fe80::99")) {
+		t.Errorf("Expected the route via fe80::99, got %v", gw)
+	}
+
+	unknown, _ := net.ParseMAC("02:00:00:00:78:03")
+	nm.AddNeighbor(net.ParseIP("2001:db8:78::6"), bridge, unknown)
+	if gw := gateway("2001:db8:78::6"); gw != nil {
+		t.Errorf("Expected an on-link route without a known link-local address, got via %s", gw)
+	}
+
+	nm.SetRouteMetric(50)
+	metric = 50
+	if gw := gateway("2001:db8:78::5"); !gw.Equal(net.ParseIP("fe80::99")) {
+		t.Errorf("Expected the route reinstalled via fe80::99 after a metric change, got %v", gw)
+	}
+}
+
+func TestInitializeNeighborTableInParallel(t *testing.T) {
+	var kernel []netlink.Neigh
+	for i := 1; i <= 50; i++ {
+		kernel = append(kernel, netlink.Neigh{
+			IP:        net.IPv4(10, 10, 68, byte(i)),
+			LinkIndex: 1,
+			State:     netlink.NUD_REACHABLE,
+		})
+	}
+	// The same IP twice is added in order by a single worker.
+	kernel = append(kernel, netlink.Neigh{IP: net.IPv4(10, 10, 68, 1), LinkIndex: 1, State: netlink.NUD_STALE})
+	kernel = append(kernel, netlink.Neigh{IP: net.IPv4(10, 10, 68, 99), LinkIndex: 1, State: netlink.NUD_FAILED})
+
+	orig := neighList
+	t.Cleanup(func() { neighList = orig })
+	neighList = func(linkIndex int, family int) ([]netlink.Neigh, error) {
+		return kernel, nil
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, DryRun: true, InitWorkers: 4})
+	defer nm.Cleanup()
+
+	if err := nm.InitializeNeighborTable(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if count := len(nm.ListNeighbors()); count != 50 {
+		t.Errorf("Expected 50 neighbors, got %d", count)
+	}
+	if _, ok := nm.GetNeighbor(net.IPv4(10, 10, 68, 99)); ok {
+		t.Errorf("Expected the FAILED neighbor to be skipped")
+	}
+}
+
+func TestPrefixRouteKeptUntilLastNeighbor(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, IPv4PrefixLen: 31})
+	defer nm.Cleanup()
+
+	dst := &net.IPNet{IP: net.ParseIP("192.168.100.200").To4(), Mask: net.CIDRMask(31, 32)}
+	prefixRouteExists := func() bool {
+		routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{Dst: dst}, netlink.RT_FILTER_DST)
+		if err != nil {
+			t.Fatalf("failed to list routes: %v", err)
+		}
+		return len(routes) > 0
+	}
+
+	first, second := net.ParseIP("192.168.100.200"), net.ParseIP("192.168.100.201")
+	nm.AddNeighbor(first, 1, nil)
+	nm.AddNeighbor(second, 1, nil)
+	if !prefixRouteExists() {
+		t.Fatalf("Expected a route to %s", dst)
+	}
+
+	nm.RemoveNeighbor(first, 1)
+	if !prefixRouteExists() {
+		t.Errorf("Expected the route to %s to stay while %s still uses it", dst, second)
+	}
+
+	nm.RemoveNeighbor(second, 1)
+	if prefixRouteExists() {
+		t.Errorf("Expected the route to %s to be removed with its last neighbor", dst)
+	}
+}
@@ -0,0 +1,24 @@
+package neighbor
+
+import (
+	"net"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+)
+
+// notifyRoute announces a route added, or removed when add is false, if a
+// route notifier is configured. Dry runs change nothing, so they are not
+// announced.
+func (nm *NeighborManager) notifyRoute(add bool, ip net.IP, linkIndex int) {
+	if nm.routeNotifier == nil || nm.DryRun {
+		return
+	}
+
+	notify := nm.routeNotifier.NotifyRemove
+	if add {
+		notify = nm.routeNotifier.NotifyAdd
+	}
+	if err := notify(ip, linkIndex, nm.routeOptions()...); err != nil {
+		logger.Error("Failed to send netlink notification for route to %s: %v", ip.String(), err)
+	}
+}
@@ -0,0 +1,61 @@
+package neighbor
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hostinger/neigh2route/pkg/netutils"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+func TestRouteNotifierAnnouncesRouteOperations(t *testing.T) {
+	listener, err := nl.Subscribe(unix.NETLINK_ROUTE, unix.RTNLGRP_IPV4_ROUTE)
+	if err != nil {
+		t.Skipf("cannot subscribe to route notifications: %v", err)
+	}
+	defer listener.Close()
+	if err := listener.SetReceiveTimeout(&unix.Timeval{Sec: 1}); err != nil {
+		t.Fatalf("failed to set receive timeout: %v", err)
+	}
+
+	notifier, err := netutils.NewRouteNotifier()
+	if err != nil {
+		t.Fatalf("failed to open route notifier: %v", err)
+	}
+	defer notifier.Close()
+
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, RouteNotifier: notifier})
+	ip := net.ParseIP("10.10.60.2")
+	nm.AddNeighbor(ip, 1, nil)
+	nm.RemoveNeighbor(ip, 1)
+	nm.Cleanup()
+
+	var got []uint16
+	for len(got) < 2 {
+		msgs, from, err := listener.Receive()
+		if err != nil {
+			t.Fatalf("Expected two notifications for %s, got %v: %v", ip, got, err)
+		}
+		if from.Pid == nl.PidKernel {
+			// The kernel announces the route changes too.
+			continue
+		}
+		for _, m := range msgs {
+			msg := nl.DeserializeRtMsg(m.Data)
+			attrs, err := nl.ParseRouteAttr(m.Data[msg.Len():])
+			if err != nil {
+				t.Fatalf("failed to parse route attributes: %v", err)
+			}
+			for _, attr := range attrs {
+				if attr.Attr.Type == unix.RTA_DST && net.IP(attr.Value).Equal(ip) {
+					got = append(got, m.Header.Type)
+				}
+			}
+		}
+	}
+
+	if got[0] != unix.RTM_NEWROUTE || got[1] != unix.RTM_DELROUTE {
+		t.Errorf("Expected RTM_NEWROUTE then RTM_DELROUTE, got %v", got)
+	}
+}
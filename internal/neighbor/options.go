@@ -0,0 +1,76 @@
+package neighbor
+
+import (
+	"context"
+	"net"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Option customizes the NeighborManager NewNeighborManager returns.
+type Option func(*NeighborManager)
+
+// WithContext bounds every route operation by ctx. A nil ctx is ignored.
+func WithContext(ctx context.Context) Option {
+	return func(nm *NeighborManager) {
+		if ctx != nil {
+			nm.ctx = ctx
+		}
+	}
+}
+
+// WithRouteMetric sets the priority of installed routes. Negative metrics
+// are ignored and leave the kernel default in place.
+func WithRouteMetric(n int) Option {
+	return func(nm *NeighborManager) {
+		if n >= 0 && int64(n) <= int64(^uint32(0)) {
+			nm.RouteMetric = uint32(n)
+		}
+	}
+}
+
+// WithRouteTable installs routes into table instead of the main table.
+func WithRouteTable(table int) Option {
+	return func(nm *NeighborManager) {
+		if table > 0 {
+			nm.RouteTable = table
+		}
+	}
+}
+
+// WithRouteProtocol marks installed routes with protocol.
+func WithRouteProtocol(protocol int) Option {
+	return func(nm *NeighborManager) {
+		nm.RouteProtocol = protocol
+	}
+}
+
+// WithRouteScope sets the scope of installed routes.
+func WithRouteScope(scope netlink.Scope) Option {
+	return func(nm *NeighborManager) {
+		nm.RouteScope = &scope
+	}
+}
+
+// WithRoutePrefixLen routes the /ipv4 or /ipv6 prefix around each neighbor
+// instead of a host route.
+func WithRoutePrefixLen(ipv4, ipv6 int) Option {
+	return func(nm *NeighborManager) {
+		nm.IPv4PrefixLen = ipv4
+		nm.IPv6PrefixLen = ipv6
+	}
+}
+
+// WithRoutePrefixes only adds routes for neighbors inside prefixes.
+func WithRoutePrefixes(prefixes []*net.IPNet) Option {
+	return func(nm *NeighborManager) {
+		nm.currentPolicy.AllowPrefixes = prefixes
+	}
+}
+
+// WithDryRun logs routes instead of changing the routing table.
+func WithDryRun() Option {
+	return func(nm *NeighborManager) {
+		nm.DryRun = true
+	}
+}
@@ -0,0 +1,207 @@
+package neighbor
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+	"github.com/vishvananda/netlink"
+)
+
+const DefaultPersistInterval = 30 * time.Second
+
+// SaveState writes the neighbor table to StateFile through a temp file and
+// rename, so a crash mid-write never leaves a truncated snapshot behind.
+func (nm *NeighborManager) SaveState() error {
+	nm.saveMu.Lock()
+	defer nm.saveMu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(nm.StateFile), filepath.Base(nm.StateFile)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if err := nm.ExportJSON(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), nm.StateFile)
+}
+
+// PersistRoutes calls SaveState every interval until ctx is cancelled. Failed
+// writes are logged and retried on the next tick.
+func (nm *NeighborManager) PersistRoutes(ctx context.Context, interval time.Duration) {
+	if nm.StateFile == "" {
+		return
+	}
+
+	if interval <= 0 {
+		interval = DefaultPersistInterval
+	}
+
+	logger.Info("Persisting neighbor state to %s every %s", nm.StateFile, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := nm.SaveState(); err != nil {
+				logger.Error("Failed to persist neighbor state to %s: %v", nm.StateFile, err)
+			}
+		}
+	}
+}
+
+// persistState saves the table after a change when StateFile is set. While
+// holdPersist is in effect the save is left to its release.
+func (nm *NeighborManager) persistState() {
+	if nm.StateFile == "" {
+		return
+	}
+	if nm.persistHolds.Load() > 0 {
+		nm.persistDirty.Store(true)
+		return
+	}
+
+	if err := nm.SaveState(); err != nil {
+		logger.Error("Failed to persist neighbor state to %s: %v", nm.StateFile, err)
+	}
+}
+
+// holdPersist holds back the saves of persistState until the returned
+// func is called, which saves once if anything changed meanwhile. Adding N
+// neighbors then writes the table once instead of N times.
+func (nm *NeighborManager) holdPersist() func() {
+	nm.persistHolds.Add(1)
+	return func() {
+		if nm.persistHolds.Add(-1) == 0 && nm.persistDirty.Swap(false) {
+			nm.persistState()
+		}
+	}
+}
+
+// loadState reads the neighbors saved by SaveState. A missing file yields
+// no neighbors and no error.
+func (nm *NeighborManager) loadState() ([]neighborRecord, error) {
+	data, err := os.ReadFile(nm.StateFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []neighborRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// RestoreState re-adds the neighbors saved in StateFile that are still in
+// the kernel neighbor table, using the kernel's current link and MAC, on
+// every saved ECMP link the kernel still has them on. With VLANAware set a
+// neighbor must still be in the table on its saved VLAN. Permanent
+// neighbors are pinned again with their saved link and MAC, since the
+// kernel loses them on reboot.
+func (nm *NeighborManager) RestoreState() error {
+	if nm.StateFile == "" {
+		return nil
+	}
+
+	records, err := nm.loadState()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	kernel, err := neighList(0, netlink.FAMILY_ALL)
+	if err != nil {
+		return err
+	}
+
+	present := make(map[string][]netlink.Neigh, len(kernel))
+	for _, n := range kernel {
+		if n.IP != nil {
+			key := nm.neighborKey(n.IP, n.Vlan)
+			present[key] = append(present[key], n)
+		}
+	}
+
+	defer nm.holdPersist()()
+
+	restored := 0
+	for _, record := range records {
+		ip := net.ParseIP(record.IP)
+		if ip == nil {
+			logger.Warn("Skipping invalid IP %q in state file", record.IP)
+			continue
+		}
+
+		if record.PingTimeoutMS > 0 {
+			nm.SetPingTimeout(ip, time.Duration(record.PingTimeoutMS)*time.Millisecond)
+		}
+
+		if record.Permanent {
+			mac, err := net.ParseMAC(record.MAC)
+			if err != nil || !nm.monitorsLink(record.LinkIndex) {
+				logger.Warn("Not restoring permanent neighbor %s with MAC %q on link %d", record.IP, record.MAC, record.LinkIndex)
+				continue
+			}
+			if err := nm.AddNeighborPermanent(ip, mac, record.LinkIndex); err != nil {
+				logger.Error("Failed to restore permanent neighbor %s: %v", record.IP, err)
+				continue
+			}
+			restored++
+			continue
+		}
+
+		var entries []netlink.Neigh
+		for _, n := range present[nm.neighborKey(ip, record.VlanID)] {
+			if nm.monitorsLink(n.LinkIndex) {
+				entries = append(entries, n)
+			}
+		}
+		if len(entries) == 0 {
+			logger.Debug("Not restoring %s, no longer in the kernel neighbor table", record.IP)
+			continue
+		}
+
+		// An ECMP neighbor comes back on every saved link the kernel still
+		// has it on, any other on the link the kernel has it on now.
+		added := false
+		if len(record.LinkIndexes) > 1 {
+			saved := Neighbor{LinkIndexes: record.LinkIndexes}
+			for _, n := range entries {
+				if saved.hasLink(n.LinkIndex) {
+					nm.addNeighbor(ip, n.LinkIndex, n.HardwareAddr, n.Vlan, false)
+					added = true
+				}
+			}
+		}
+		if !added {
+			nm.addNeighbor(ip, entries[0].LinkIndex, entries[0].HardwareAddr, entries[0].Vlan, false)
+		}
+		restored++
+	}
+
+	logger.Info("Restored %d of %d neighbors from %s", restored, len(records), nm.StateFile)
+	return nil
+}
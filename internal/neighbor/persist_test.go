@@ -0,0 +1,348 @@
+package neighbor
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Helper function to read the persisted records, nil if the file is missing
+func readState(t *testing.T, path string) []neighborRecord {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("Could not read state file: %v", err)
+	}
+
+	var records []neighborRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		t.Fatalf("State file is not valid JSON: %v", err)
+	}
+	return records
+}
+
+// Helper function to poll until cond holds or the deadline passes
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return cond()
+}
+
+func TestPersistRoutesWritesEachTick(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, StateFile: path})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go nm.PersistRoutes(ctx, 20*time.Millisecond)
+
+	if !waitFor(t, time.Second, func() bool { return readState(t, path) != nil }) {
+		t.Fatalf("Expected state file to be written")
+	}
+
+	nm.mu.Lock()
+	nm.ReachableNeighbors["10.0.0.1"] = Neighbor{IP: net.ParseIP("10.0.0.1"), LinkIndex: 1}
+	nm.mu.Unlock()
+
+	if !waitFor(t, time.Second, func() bool { return len(readState(t, path)) == 1 }) {
+		t.Fatalf("Expected update to be captured on a later tick")
+	}
+
+	leftovers, _ := filepath.Glob(path + ".tmp-*")
+	if len(leftovers) != 0 {
+		t.Errorf("Expected no temp files, got %v", leftovers)
+	}
+}
+
+func TestPersistRoutesContinuesAfterFailure(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+	path := filepath.Join(dir, "state.json")
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, StateFile: path})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go nm.PersistRoutes(ctx, 20*time.Millisecond)
+
+	time.Sleep(60 * time.Millisecond)
+	if readState(t, path) != nil {
+		t.Fatalf("Expected writes to fail while the directory is missing")
+	}
+
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("Could not create state dir: %v", err)
+	}
+
+	if !waitFor(t, time.Second, func() bool { return readState(t, path) != nil }) {
+		t.Errorf("Expected a write to succeed after the failure cleared")
+	}
+}
+
+func TestPersistRoutesDisabledWithoutStateFile(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+
+	done := make(chan struct{})
+	go func() {
+		nm.PersistRoutes(context.Background(), time.Millisecond)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected PersistRoutes to return without a state file")
+	}
+}
+
+func TestSaveStateLoadStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, StateFile: path})
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+	nm.ReachableNeighbors["10.0.0.1"] = Neighbor{IP: net.ParseIP("10.0.0.1"), LinkIndex: 1, HardwareAddr: mac}
+	nm.ReachableNeighbors["2001:db8::1"] = Neighbor{IP: net.ParseIP("2001:db8::1"), LinkIndex: 1}
+	nm.ReachableNeighbors["10.0.0.2"] = Neighbor{IP: net.ParseIP("10.0.0.2"), LinkIndex: 1, HardwareAddr: mac, Permanent: true}
+	nm.ReachableNeighbors["10.0.0.3"] = Neighbor{IP: net.ParseIP("10.0.0.3"), LinkIndex: 1, LinkIndexes: []int{2, 1}, PingTimeout: 250 * time.Millisecond}
+
+	if err := nm.SaveState(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	records, err := nm.loadState()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := []neighborRecord{
+		{IP: "10.0.0.1", MAC: "00:11:22:33:44:55", LinkIndex: 1, Interface: "lo"},
+		{IP: "10.0.0.2", MAC: "00:11:22:33:44:55", LinkIndex: 1, Interface: "lo", Permanent: true},
+		{IP: "10.0.0.3", MAC: "", LinkIndex: 1, Interface: "lo", LinkIndexes: []int{2, 1}, PingTimeoutMS: 250},
+		{IP: "2001:db8::1", MAC: "", LinkIndex: 1, Interface: "lo"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("Expected %d records, got %v", len(want), records)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(records[i], want[i]) {
+			t.Errorf("Record %d: expected %+v, got %+v", i, want[i], records[i])
+		}
+	}
+}
+
+func TestLoadStateMissingFile(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{StateFile: filepath.Join(t.TempDir(), "missing.json")})
+
+	records, err := nm.loadState()
+	if err != nil || records != nil {
+		t.Errorf("Expected no records and no error, got %v, %v", records, err)
+	}
+}
+
+func TestAddAndRemoveNeighborPersistState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, StateFile: path})
+	defer nm.Cleanup()
+
+	nm.AddNeighbor(net.ParseIP("10.10.40.1"), 1, nil)
+	if records := readState(t, path); len(records) != 1 || records[0].IP != "10.10.40.1" {
+		t.Fatalf("Expected state written on add, got %v", records)
+	}
+
+	nm.RemoveNeighbor(net.ParseIP("10.10.40.1"), 1)
+	if records := readState(t, path); len(records) != 0 {
+		t.Errorf("Expected state written on remove, got %v", records)
+	}
+}
+
+func TestHoldPersistSavesOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, StateFile: path, DryRun: true})
+	defer nm.Cleanup()
+
+	release := nm.holdPersist()
+	for i := 1; i <= 3; i++ {
+		nm.AddNeighbor(net.IPv4(10, 10, 41, byte(i)), 1, nil)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected no state written while held, got %v", err)
+	}
+
+	release()
+	if records := readState(t, path); len(records) != 3 {
+		t.Errorf("Expected the state saved once on release with 3 neighbors, got %v", records)
+	}
+}
+
+func TestInitializeNeighborTablePersistsOnce(t *testing.T) {
+	orig := neighList
+	t.Cleanup(func() { neighList = orig })
+	neighList = func(linkIndex int, family int) ([]netlink.Neigh, error) {
+		var kernel []netlink.Neigh
+		for i := 1; i <= 20; i++ {
+			kernel = append(kernel, netlink.Neigh{IP: net.IPv4(10, 10, 42, byte(i)), LinkIndex: 1, State: netlink.NUD_REACHABLE})
+		}
+		return kernel, nil
+	}
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, StateFile: path, DryRun: true})
+	defer nm.Cleanup()
+
+	if err := nm.InitializeNeighborTable(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if records := readState(t, path); len(records) != 20 {
+		t.Errorf("Expected every neighbor in the state file after initialization, got %d", len(records))
+	}
+	if nm.persistDirty.Load() {
+		t.Errorf("Expected no pending save after initialization")
+	}
+}
+
+func TestRestoreStateOnlyRestoresKernelNeighbors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	saved, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, StateFile: path})
+	saved.ReachableNeighbors["10.10.40.2"] = Neighbor{IP: net.ParseIP("10.10.40.2"), LinkIndex: 1}
+	saved.ReachableNeighbors["10.10.40.3"] = Neighbor{IP: net.ParseIP("10.10.40.3"), LinkIndex: 1}
+	if err := saved.SaveState(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	orig := neighList
+	t.Cleanup(func() { neighList = orig })
+	neighList = func(linkIndex int, family int) ([]netlink.Neigh, error) {
+		return []netlink.Neigh{{IP: net.ParseIP("10.10.40.2"), LinkIndex: 1, State: netlink.NUD_STALE}}, nil
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, StateFile: path})
+	defer nm.Cleanup()
+
+	if err := nm.RestoreState(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, ok := nm.GetNeighbor(net.ParseIP("10.10.40.2")); !ok {
+		t.Errorf("Expected 10.10.40.2 to be restored")
+	}
+	if _, ok := nm.GetNeighbor(net.ParseIP("10.10.40.3")); ok {
+		t.Errorf("Expected 10.10.40.3 to be skipped, it is gone from the kernel table")
+	}
+	if !routeOnLoopbackExists(t, "10.10.40.2") {
+		t.Errorf("Expected route for restored neighbor 10.10.40.2")
+	}
+}
+
+func TestRestoreStateKeepsVLAN(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	saved, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, StateFile: path, VLANAware: true})
+	saved.ReachableNeighbors["10.10.40.4@vlan100"] = Neighbor{IP: net.ParseIP("10.10.40.4"), LinkIndex: 1, VlanID: 100}
+	saved.ReachableNeighbors["10.10.40.5@vlan100"] = Neighbor{IP: net.ParseIP("10.10.40.5"), LinkIndex: 1, VlanID: 100}
+	if err := saved.SaveState(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	orig := neighList
+	t.Cleanup(func() { neighList = orig })
+	neighList = func(linkIndex int, family int) ([]netlink.Neigh, error) {
+		return []netlink.Neigh{
+			{IP: net.ParseIP("10.10.40.4"), LinkIndex: 1, Vlan: 100, State: netlink.NUD_STALE},
+			{IP: net.ParseIP("10.10.40.5"), LinkIndex: 1, Vlan: 200, State: netlink.NUD_STALE},
+		}, nil
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, StateFile: path, VLANAware: true})
+	defer nm.Cleanup()
+
+	if err := nm.RestoreState(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, ok := nm.ReachableNeighbors["10.10.40.4@vlan100"]; !ok {
+		t.Errorf("Expected 10.10.40.4 restored on VLAN 100, got %v", nm.ReachableNeighbors)
+	}
+	if _, ok := nm.GetNeighbor(net.ParseIP("10.10.40.5")); ok {
+		t.Errorf("Expected 10.10.40.5 to be skipped, it is gone from VLAN 100")
+	}
+}
+
+func TestRestoreStateKeepsPermanentAndPingTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	mac, _ := net.ParseMAC("02:00:00:00:40:06")
+	saved, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, StateFile: path})
+	saved.ReachableNeighbors["10.10.40.6"] = Neighbor{IP: net.ParseIP("10.10.40.6"), LinkIndex: 1, HardwareAddr: mac, Permanent: true}
+	saved.ReachableNeighbors["10.10.40.7"] = Neighbor{IP: net.ParseIP("10.10.40.7"), LinkIndex: 1, PingTimeout: 250 * time.Millisecond}
+	if err := saved.SaveState(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	set, _ := stubNeighTable(t)
+	orig := neighList
+	t.Cleanup(func() { neighList = orig })
+	// The reboot lost the permanent entry.
+	neighList = func(linkIndex int, family int) ([]netlink.Neigh, error) {
+		return []netlink.Neigh{{IP: net.ParseIP("10.10.40.7"), LinkIndex: 1, State: netlink.NUD_STALE}}, nil
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}, StateFile: path})
+	defer nm.Cleanup()
+
+	if err := nm.RestoreState(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	n, ok := nm.GetNeighbor(net.ParseIP("10.10.40.6"))
+	if !ok || !n.Permanent || n.HardwareAddr.String() != mac.String() {
+		t.Errorf("Expected 10.10.40.6 restored as permanent with %s, got %+v", mac, n)
+	}
+	if len(*set) != 1 || (*set)[0].State != netlink.NUD_PERMANENT {
+		t.Errorf("Expected the permanent kernel entry pinned again, got %v", *set)
+	}
+	if n, ok := nm.GetNeighbor(net.ParseIP("10.10.40.7")); !ok || n.PingTimeout != 250*time.Millisecond {
+		t.Errorf("Expected 10.10.40.7 restored with its 250ms ping timeout, got %+v", n)
+	}
+}
+
+func TestRestoreStateKeepsECMPLinks(t *testing.T) {
+	first := addBridgeLink(t, "n2r-rs0")
+	second := addBridgeLink(t, "n2r-rs1")
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	cfg := Config{TargetInterfaces: []string{"n2r-rs0", "n2r-rs1"}, StateFile: path, ECMP: true, DryRun: true}
+	saved, _ := NewNeighborManagerFromConfig(cfg)
+	saved.ReachableNeighbors["10.10.40.8"] = Neighbor{IP: net.ParseIP("10.10.40.8"), LinkIndex: second, LinkIndexes: []int{first, second}}
+	if err := saved.SaveState(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	orig := neighList
+	t.Cleanup(func() { neighList = orig })
+	neighList = func(linkIndex int, family int) ([]netlink.Neigh, error) {
+		return []netlink.Neigh{
+			{IP: net.ParseIP("10.10.40.8"), LinkIndex: first, State: netlink.NUD_STALE},
+			{IP: net.ParseIP("10.10.40.8"), LinkIndex: second, State: netlink.NUD_STALE},
+		}, nil
+	}
+
+	nm, _ := NewNeighborManagerFromConfig(cfg)
+	defer nm.Cleanup()
+
+	if err := nm.RestoreState(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if n, _ := nm.GetNeighbor(net.ParseIP("10.10.40.8")); !n.hasLink(first) || !n.hasLink(second) {
+		t.Errorf("Expected 10.10.40.8 restored on links %d and %d, got %+v", first, second, n)
+	}
+}
@@ -0,0 +1,146 @@
+package neighbor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+	"github.com/vishvananda/netlink"
+)
+
+// Seed is one neighbor of a --seed-file, expected to show up before the
+// kernel learned it.
+type Seed struct {
+	IP        string `json:"ip"`
+	MAC       string `json:"mac"`
+	Interface string `json:"interface"`
+
+	// PingTimeoutMs optionally overrides the manager's PingTimeout for
+	// this neighbor.
+	PingTimeoutMs int `json:"ping_timeout_ms,omitempty"`
+}
+
+// LoadSeeds reads the JSON array of seeds at path and validates every
+// entry. Errors name the file and the line or entry at fault.
+func LoadSeeds(path string) ([]Seed, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var seeds []Seed
+	if err := dec.Decode(&seeds); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			return nil, fmt.Errorf("%s:%d: %v", path, lineAt(data, syntaxErr.Offset), err)
+		case errors.As(err, &typeErr) && typeErr.Field == "":
+			return nil, fmt.Errorf("%s: expected an array of {\"ip\", \"mac\", \"interface\"} objects", path)
+		case errors.As(err, &typeErr):
+			// Field is the path to the value, e.g. "3.ip".
+			entry, field, _ := strings.Cut(typeErr.Field, ".")
+			kind := "a string"
+			if typeErr.Type.Kind() == reflect.Int {
+				kind = "a number"
+			}
+			return nil, fmt.Errorf("%s:%d: entry %s: %s must be %s", path, lineAt(data, typeErr.Offset), entry, field, kind)
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("%s: unexpected data after the seed array", path)
+	}
+
+	for i, seed := range seeds {
+		if err := seed.validate(); err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", path, i, err)
+		}
+	}
+	return seeds, nil
+}
+
+func (s Seed) validate() error {
+	if s.IP == "" {
+		return errors.New(`missing "ip"`)
+	}
+	if ip := net.ParseIP(s.IP); ip == nil || ip.IsUnspecified() {
+		return fmt.Errorf("invalid ip %q", s.IP)
+	}
+	if s.MAC == "" {
+		return errors.New(`missing "mac"`)
+	}
+	if _, err := net.ParseMAC(s.MAC); err != nil {
+		return fmt.Errorf("invalid mac %q", s.MAC)
+	}
+	if s.Interface == "" {
+		return errors.New(`missing "interface"`)
+	}
+	if s.PingTimeoutMs < 0 {
+		return fmt.Errorf("ping_timeout_ms must not be negative, got %d", s.PingTimeoutMs)
+	}
+	return nil
+}
+
+// lineAt returns the 1-based line of data that offset falls on.
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// ApplySeeds applies the ping timeout overrides of seeds.
+func (nm *NeighborManager) ApplySeeds(seeds []Seed) {
+	for _, seed := range seeds {
+		if seed.PingTimeoutMs > 0 {
+			nm.SetPingTimeout(net.ParseIP(seed.IP), time.Duration(seed.PingTimeoutMs)*time.Millisecond)
+		}
+	}
+}
+
+// SeedKernel injects seeds into the kernel neighbor table as STALE entries,
+// so the kernel confirms them on first use and the usual monitoring adds
+// their routes. It continues past failures and returns how many entries
+// were injected and one error per failure.
+func SeedKernel(seeds []Seed) (seeded int, errs []error) {
+	for _, seed := range seeds {
+		link, err := netlink.LinkByName(seed.Interface)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("seed %s: interface %q: %w", seed.IP, seed.Interface, err))
+			continue
+		}
+
+		ip := net.ParseIP(seed.IP)
+		mac, _ := net.ParseMAC(seed.MAC)
+		family := netlink.FAMILY_V4
+		if ip.To4() == nil {
+			family = netlink.FAMILY_V6
+		}
+
+		if err := neighSet(&netlink.Neigh{
+			LinkIndex:    link.Attrs().Index,
+			Family:       family,
+			State:        netlink.NUD_STALE,
+			IP:           ip,
+			HardwareAddr: mac,
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("seed %s: failed to set kernel neighbor entry: %w", seed.IP, err))
+			continue
+		}
+		seeded++
+	}
+
+	logger.Info("Seeded %d of %d neighbors into the kernel neighbor table", seeded, len(seeds))
+	return seeded, errs
+}
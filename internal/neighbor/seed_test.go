@@ -0,0 +1,105 @@
+package neighbor
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Helper function to write a seed file into a temp dir
+func writeSeeds(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "seeds.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("failed to write seeds: %v", err)
+	}
+	return path
+}
+
+func TestLoadSeeds(t *testing.T) {
+	path := writeSeeds(t, `[
+  {"ip": "10.10.50.1", "mac": "02:00:00:00:50:01", "interface": "lo"},
+  {"ip": "2001:db8::50", "mac": "02:00:00:00:50:02", "interface": "lo"}
+]`)
+
+	seeds, err := LoadSeeds(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(seeds) != 2 || seeds[0].IP != "10.10.50.1" || seeds[1].MAC != "02:00:00:00:50:02" {
+		t.Errorf("Unexpected seeds: %+v", seeds)
+	}
+}
+
+func TestLoadSeedsRejectsMalformedFiles(t *testing.T) {
+	for content, want := range map[string]string{
+		"[\n  {\"ip\": \"10.10.50.1\",}\n]": ":2: invalid character",
+		`{"ip": "10.10.50.1"}`:              "expected an array",
+		"[\n  {\"ip\": 1}\n]":               ":2: entry 0: ip must be a string",
+		`[{"ip": "10.10.50.1", "mac": "02:00:00:00:50:01", "iface": "lo"}]`:                              `unknown field "iface"`,
+		`[{"mac": "02:00:00:00:50:01", "interface": "lo"}]`:                                              `entry 0: missing "ip"`,
+		`[{"ip": "10.10.50", "mac": "02:00:00:00:50:01", "interface": "lo"}]`:                            `entry 0: invalid ip "10.10.50"`,
+		`[{"ip": "10.10.50.1", "mac": "02:00", "interface": "lo"}]`:                                      `entry 0: invalid mac "02:00"`,
+		`[{"ip": "10.10.50.1", "mac": "02:00:00:00:50:01", "interface": "lo", "ping_timeout_ms": "1s"}]`: `entry 0: ping_timeout_ms must be a number`,
+		`[{"ip": "10.10.50.1", "mac": "02:00:00:00:50:01", "interface": "lo", "ping_timeout_ms": -1}]`:   `entry 0: ping_timeout_ms must not be negative`,
+		`[{"ip": "10.10.50.1", "mac": "02:00:00:00:50:01"}]`:                                             `entry 0: missing "interface"`,
+		`[{"ip": "10.10.50.1", "mac": "02:00:00:00:50:01", "interface": "lo"}] []`:                       "unexpected data",
+	} {
+		_, err := LoadSeeds(writeSeeds(t, content))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", content, want, err)
+		}
+	}
+}
+
+func TestApplySeedsSetsPingTimeout(t *testing.T) {
+	nm, _ := NewNeighborManagerFromConfig(Config{TargetInterfaces: []string{"lo"}})
+	defer nm.Cleanup()
+
+	nm.ApplySeeds([]Seed{
+		{IP: "10.10.50.1", MAC: "02:00:00:00:50:01", Interface: "lo", PingTimeoutMs: 250},
+		{IP: "10.10.50.2", MAC: "02:00:00:00:50:02", Interface: "lo"},
+	})
+	nm.AddNeighbor(net.ParseIP("10.10.50.1"), 1, nil)
+	nm.AddNeighbor(net.ParseIP("10.10.50.2"), 1, nil)
+
+	for ip, want := range map[string]time.Duration{"10.10.50.1": 250 * time.Millisecond, "10.10.50.2": 0} {
+		n, _ := nm.GetNeighbor(net.ParseIP(ip))
+		if n.PingTimeout != want {
+			t.Errorf("%s: expected ping timeout %s, got %s", ip, want, n.PingTimeout)
+		}
+	}
+}
+
+func TestSeedKernel(t *testing.T) {
+	set, _ := stubNeighTable(t)
+
+	seeded, errs := SeedKernel([]Seed{
+		{IP: "10.10.50.1", MAC: "02:00:00:00:50:01", Interface: "lo"},
+		{IP: "10.10.50.2", MAC: "02:00:00:00:50:02", Interface: "n2r-missing0"},
+		{IP: "2001:db8::50", MAC: "02:00:00:00:50:03", Interface: "lo"},
+	})
+
+	if seeded != 2 || len(errs) != 1 {
+		t.Fatalf("Expected 2 seeded and 1 error, got %d and %v", seeded, errs)
+	}
+	if !strings.Contains(errs[0].Error(), `interface "n2r-missing0"`) {
+		t.Errorf("Expected the error to name the missing interface, got %v", errs[0])
+	}
+
+	if len(*set) != 2 {
+		t.Fatalf("Expected 2 kernel entries, got %d", len(*set))
+	}
+	for _, n := range *set {
+		if n.LinkIndex != 1 || n.State != netlink.NUD_STALE {
+			t.Errorf("Expected a STALE entry on lo, got %+v", n)
+		}
+	}
+	if (*set)[0].Family != netlink.FAMILY_V4 || (*set)[1].Family != netlink.FAMILY_V6 {
+		t.Errorf("Expected IPv4 then IPv6 entries, got families %d and %d", (*set)[0].Family, (*set)[1].Family)
+	}
+}
@@ -0,0 +1,108 @@
+package neighbor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+	"github.com/hostinger/neigh2route/pkg/netutils"
+	"github.com/vishvananda/netlink"
+)
+
+const selfTestPingTimeout = 2 * time.Second
+
+var (
+	selfTestRange     = &net.IPNet{IP: net.IPv4(127, 128, 0, 0).To4(), Mask: net.CIDRMask(17, 32)}
+	ErrNoSelfTestAddr = errors.New("no free self-test address in 127.128.0.0/17")
+)
+
+// selfTestSteps are the operations TestConnectivity performs, split out so
+// tests can replace them.
+type selfTestSteps struct {
+	pick   func() (net.IP, int, error)
+	add    func(ip net.IP, linkIndex int) error
+	ping   func(ip net.IP) error
+	remove func(ip net.IP, linkIndex int) error
+}
+
+// TestConnectivity adds a temporary neighbor on loopback, checks its route
+// by pinging it and removes it again.
+func (nm *NeighborManager) TestConnectivity(ctx context.Context) error {
+	return nm.testConnectivity(ctx, nm.defaultSelfTestSteps())
+}
+
+func (nm *NeighborManager) defaultSelfTestSteps() selfTestSteps {
+	return selfTestSteps{
+		pick: nm.pickSelfTestAddr,
+		add: func(ip net.IP, linkIndex int) error {
+			nm.AddNeighbor(ip, linkIndex, nil)
+			if _, ok := nm.GetNeighbor(ip); !ok {
+				return fmt.Errorf("neighbor %s was not added", ip)
+			}
+			return nil
+		},
+		ping: func(ip net.IP) error {
+			return netutils.PingOnce(ip.String(), selfTestPingTimeout)
+		},
+		remove: func(ip net.IP, linkIndex int) error {
+			_, err := nm.removeNeighbor(ip, linkIndex)
+			return err
+		},
+	}
+}
+
+func (nm *NeighborManager) testConnectivity(ctx context.Context, steps selfTestSteps) error {
+	ip, linkIndex, err := steps.pick()
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Running self-test with %s on link index %d", ip.String(), linkIndex)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := steps.add(ip, linkIndex); err != nil {
+		return fmt.Errorf("self-test add failed: %w", err)
+	}
+
+	pingErr := ctx.Err()
+	if pingErr == nil {
+		pingErr = steps.ping(ip)
+	}
+
+	if err := steps.remove(ip, linkIndex); err != nil {
+		return fmt.Errorf("self-test remove failed: %w", err)
+	}
+
+	if pingErr != nil {
+		return fmt.Errorf("self-test ping failed: %w", pingErr)
+	}
+
+	logger.Info("Self-test passed")
+	return nil
+}
+
+func (nm *NeighborManager) pickSelfTestAddr() (net.IP, int, error) {
+	lo, err := netlink.LinkByName("lo")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	base := selfTestRange.IP.To4()
+	ones, bits := selfTestRange.Mask.Size()
+	hosts := 1 << (bits - ones)
+
+	for i := 1; i < hosts-1; i++ {
+		ip := net.IPv4(base[0], base[1], base[2]+byte(i>>8), base[3]+byte(i))
+		if _, taken := nm.GetNeighbor(ip); !taken {
+			return ip, lo.Attrs().Index, nil
+		}
+	}
+
+	return nil, 0, ErrNoSelfTestAddr
+}
@@ -0,0 +1,74 @@
+package neighbor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// Helper function to build self-test steps that record their call order
+func recordingSteps(calls *[]string, pingErr error) selfTestSteps {
+	return selfTestSteps{
+		pick: func() (net.IP, int, error) {
+			*calls = append(*calls, "pick")
+			return net.ParseIP("127.128.0.1"), 1, nil
+		},
+		add: func(ip net.IP, linkIndex int) error {
+			*calls = append(*calls, "add")
+			return nil
+		},
+		ping: func(ip net.IP) error {
+			*calls = append(*calls, "ping")
+			return pingErr
+		},
+		remove: func(ip net.IP, linkIndex int) error {
+			*calls = append(*calls, "remove")
+			return nil
+		},
+	}
+}
+
+func TestConnectivitySequence(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+
+	var calls []string
+	if err := nm.testConnectivity(context.Background(), recordingSteps(&calls, nil)); err != nil {
+		t.Fatalf("Expected self-test to pass, got %v", err)
+	}
+
+	want := []string{"pick", "add", "ping", "remove"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected %v, got %v", want, calls)
+	}
+}
+
+func TestConnectivityPingFailureStillRemoves(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	pingErr := errors.New("no reply")
+
+	var calls []string
+	err := nm.testConnectivity(context.Background(), recordingSteps(&calls, pingErr))
+	if !errors.Is(err, pingErr) {
+		t.Fatalf("Expected ping error, got %v", err)
+	}
+
+	if calls[len(calls)-1] != "remove" {
+		t.Errorf("Expected neighbor to be removed after failed ping, got %v", calls)
+	}
+}
+
+func TestPickSelfTestAddrSkipsKnownNeighbors(t *testing.T) {
+	nm, _ := NewNeighborManager("lo")
+	nm.ReachableNeighbors["127.128.0.1"] = Neighbor{IP: net.ParseIP("127.128.0.1"), LinkIndex: 1}
+
+	ip, _, err := nm.pickSelfTestAddr()
+	if err != nil {
+		t.Fatalf("Expected a free address, got %v", err)
+	}
+
+	if !ip.Equal(net.ParseIP("127.128.0.2")) {
+		t.Errorf("Expected 127.128.0.2, got %s", ip)
+	}
+}
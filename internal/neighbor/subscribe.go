@@ -0,0 +1,116 @@
+package neighbor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// ndmIfindexOffset is the offset of ndm_ifindex in a neighbor message: the
+// 16 byte nlmsghdr followed by ndm_family and three bytes of padding.
+const ndmIfindexOffset = unix.SizeofNlMsghdr + 4
+
+// maxFilterLinks is the most links linkFilter can compare: a conditional
+// jump skips at most 255 instructions.
+const maxFilterLinks = math.MaxUint8
+
+// linkFilter returns a socket filter that only accepts neighbor messages
+// for linkIndexes. BPF loads words in network byte order while the kernel
+// writes ndm_ifindex in host byte order, so the indexes are converted.
+func linkFilter(linkIndexes []int) ([]bpf.RawInstruction, error) {
+	if len(linkIndexes) > maxFilterLinks {
+		return nil, fmt.Errorf("%d links exceed the %d a link filter can match", len(linkIndexes), maxFilterLinks)
+	}
+
+	prog := []bpf.Instruction{
+		bpf.LoadAbsolute{Off: ndmIfindexOffset, Size: 4},
+	}
+	for i, linkIndex := range linkIndexes {
+		var b [4]byte
+		binary.NativeEndian.PutUint32(b[:], uint32(linkIndex))
+		// On a match, skip the remaining comparisons and the drop.
+		prog = append(prog, bpf.JumpIf{
+			Cond:     bpf.JumpEqual,
+			Val:      binary.BigEndian.Uint32(b[:]),
+			SkipTrue: uint8(len(linkIndexes) - i),
+		})
+	}
+	prog = append(prog,
+		bpf.RetConstant{Val: 0},
+		bpf.RetConstant{Val: math.MaxUint32},
+	)
+	return bpf.Assemble(prog)
+}
+
+// subscribeLinks works like netlink.NeighSubscribe, but with linkIndexes set
+// the kernel drops updates for every other link before they are queued on
+// the socket, so busy hosts with many links do not wake us up for them.
+// With more than maxFilterLinks links every update is received and left to
+// the link check in processNeighborUpdate.
+func subscribeLinks(ch chan<- netlink.NeighUpdate, done <-chan struct{}, linkIndexes []int) error {
+	if len(linkIndexes) == 0 {
+		return netlink.NeighSubscribe(ch, done)
+	}
+	if len(linkIndexes) > maxFilterLinks {
+		logger.Info("Monitoring %d links, more than a kernel link filter supports (%d), filtering neighbor updates in userspace", len(linkIndexes), maxFilterLinks)
+		return netlink.NeighSubscribe(ch, done)
+	}
+
+	raw, err := linkFilter(linkIndexes)
+	if err != nil {
+		return fmt.Errorf("failed to assemble link filter: %w", err)
+	}
+	filter := make([]unix.SockFilter, len(raw))
+	for i, ins := range raw {
+		filter[i] = unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+	}
+
+	s, err := nl.Subscribe(unix.NETLINK_ROUTE, unix.RTNLGRP_NEIGH)
+	if err != nil {
+		return err
+	}
+
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.SetsockoptSockFprog(s.GetFd(), unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &prog); err != nil {
+		s.Close()
+		return fmt.Errorf("failed to attach link filter: %w", err)
+	}
+
+	if done != nil {
+		go func() {
+			<-done
+			s.Close()
+		}()
+	}
+
+	go func() {
+		defer close(ch)
+		for {
+			msgs, from, err := s.Receive()
+			if err != nil {
+				return
+			}
+			if from.Pid != nl.PidKernel {
+				continue
+			}
+			for _, m := range msgs {
+				if m.Header.Type == unix.NLMSG_DONE || m.Header.Type == unix.NLMSG_ERROR {
+					continue
+				}
+				neigh, err := netlink.NeighDeserialize(m.Data)
+				if err != nil {
+					return
+				}
+				ch <- netlink.NeighUpdate{Type: m.Header.Type, Neigh: *neigh}
+			}
+		}
+	}()
+
+	return nil
+}
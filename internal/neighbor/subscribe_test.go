@@ -0,0 +1,202 @@
+package neighbor
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// Helper function to serialize an RTM_NEWNEIGH message like the kernel does
+func neighborMessage(linkIndex int, ip net.IP, state int) []byte {
+	ndm := make([]byte, 12)
+	ndm[0] = unix.AF_INET
+	binary.NativeEndian.PutUint32(ndm[4:8], uint32(linkIndex))
+	binary.NativeEndian.PutUint16(ndm[8:10], uint16(state))
+	body := append(ndm, nl.NewRtAttr(unix.NDA_DST, ip.To4()).Serialize()...)
+
+	hdr := make([]byte, unix.SizeofNlMsghdr)
+	binary.NativeEndian.PutUint32(hdr[0:4], uint32(len(hdr)+len(body)))
+	binary.NativeEndian.PutUint16(hdr[4:6], unix.RTM_NEWNEIGH)
+	return append(hdr, body...)
+}
+
+// Helper function to load linkFilter into a userspace BPF VM
+func linkFilterVM(t testing.TB, linkIndexes []int) *bpf.VM {
+	raw, err := linkFilter(linkIndexes)
+	if err != nil {
+		t.Fatalf("failed to assemble filter: %v", err)
+	}
+	prog, ok := bpf.Disassemble(raw)
+	if !ok {
+		t.Fatalf("failed to disassemble filter")
+	}
+	vm, err := bpf.NewVM(prog)
+	if err != nil {
+		t.Fatalf("failed to load filter: %v", err)
+	}
+	return vm
+}
+
+func TestLinkFilter(t *testing.T) {
+	vm := linkFilterVM(t, []int{1, 3, 300})
+
+	for linkIndex, want := range map[int]bool{1: true, 2: false, 3: true, 4: false, 300: true, 256: false} {
+		n, err := vm.Run(neighborMessage(linkIndex, net.ParseIP("10.0.0.1"), netlink.NUD_REACHABLE))
+		if err != nil {
+			t.Fatalf("filter failed for link %d: %v", linkIndex, err)
+		}
+		if got := n > 0; got != want {
+			t.Errorf("link %d: expected accept=%v, got %v", linkIndex, want, got)
+		}
+	}
+}
+
+func TestSubscribeLinksFiltersUpdates(t *testing.T) {
+	target := addBridgeLink(t, "n2r-sub0")
+	other := addBridgeLink(t, "n2r-sub1")
+
+	updates := make(chan netlink.NeighUpdate, 8)
+	done := make(chan struct{})
+	defer close(done)
+	if err := subscribeLinks(updates, done, []int{target}); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	for _, linkIndex := range []int{other, target} {
+		neigh := &netlink.Neigh{
+			LinkIndex:    linkIndex,
+			Family:       netlink.FAMILY_V4,
+			State:        netlink.NUD_PERMANENT,
+			IP:           net.ParseIP("192.168.100.197"),
+			HardwareAddr: mac,
+		}
+		if err := netlink.NeighSet(neigh); err != nil {
+			t.Fatalf("failed to set neighbor on link %d: %v", linkIndex, err)
+		}
+		defer netlink.NeighDel(neigh)
+	}
+
+	select {
+	case u := <-updates:
+		if u.Neigh.LinkIndex != target {
+			t.Errorf("Expected only updates for link %d, got link %d", target, u.Neigh.LinkIndex)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected an update for link %d", target)
+	}
+}
+
+func TestLinkFilterLinkLimit(t *testing.T) {
+	linkIndexes := make([]int, maxFilterLinks)
+	for i := range linkIndexes {
+		linkIndexes[i] = i + 1
+	}
+
+	vm := linkFilterVM(t, linkIndexes)
+	for linkIndex, want := range map[int]bool{1: true, maxFilterLinks: true, maxFilterLinks + 1: false} {
+		n, err := vm.Run(neighborMessage(linkIndex, net.ParseIP("10.0.0.1"), netlink.NUD_REACHABLE))
+		if err != nil {
+			t.Fatalf("filter failed for link %d: %v", linkIndex, err)
+		}
+		if got := n > 0; got != want {
+			t.Errorf("link %d: expected accept=%v, got %v", linkIndex, want, got)
+		}
+	}
+
+	if _, err := linkFilter(append(linkIndexes, maxFilterLinks+1)); err == nil {
+		t.Errorf("Expected an error for more than %d links", maxFilterLinks)
+	}
+}
+
+func TestSubscribeLinksWithoutFilterBeyondLimit(t *testing.T) {
+	target := addBridgeLink(t, "n2r-sub2")
+
+	linkIndexes := []int{target}
+	for i := 0; len(linkIndexes) <= maxFilterLinks; i++ {
+		linkIndexes = append(linkIndexes, 100000+i)
+	}
+
+	updates := make(chan netlink.NeighUpdate, 8)
+	done := make(chan struct{})
+	defer close(done)
+	if err := subscribeLinks(updates, done, linkIndexes); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+
+	mac, _ := net.ParseMAC("02:00:00:00:00:02")
+	neigh := &netlink.Neigh{
+		LinkIndex:    target,
+		Family:       netlink.FAMILY_V4,
+		State:        netlink.NUD_PERMANENT,
+		IP:           net.ParseIP("192.168.100.198"),
+		HardwareAddr: mac,
+	}
+	if err := netlink.NeighSet(neigh); err != nil {
+		t.Fatalf("failed to set neighbor: %v", err)
+	}
+	defer netlink.NeighDel(neigh)
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case u := <-updates:
+			if u.Neigh.LinkIndex == target {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Expected an update for link %d", target)
+		}
+	}
+}
+
+// BenchmarkNeighborUpdates compares the userspace work for a burst of
+// updates spread over 100 links, one of them monitored, with and without
+// the link filter dropping the others in the kernel.
+func BenchmarkNeighborUpdates(b *testing.B) {
+	const links = 100
+	ip := net.ParseIP("10.10.40.1")
+
+	var msgs [][]byte
+	for linkIndex := 1; linkIndex <= links; linkIndex++ {
+		msgs = append(msgs, neighborMessage(linkIndex, ip, netlink.NUD_REACHABLE))
+	}
+
+	vm := linkFilterVM(b, []int{1})
+	var filtered [][]byte
+	for _, msg := range msgs {
+		if n, _ := vm.Run(msg); n > 0 {
+			filtered = append(filtered, msg)
+		}
+	}
+
+	for _, bc := range []struct {
+		name string
+		msgs [][]byte
+	}{
+		{"unfiltered", msgs},
+		{"filtered", filtered},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			nm, _ := NewNeighborManager("lo")
+			defer nm.Cleanup()
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, msg := range bc.msgs {
+					neigh, err := netlink.NeighDeserialize(msg[unix.SizeofNlMsghdr:])
+					if err != nil {
+						b.Fatalf("failed to deserialize: %v", err)
+					}
+					nm.processNeighborUpdate(netlink.NeighUpdate{Type: unix.RTM_NEWNEIGH, Neigh: *neigh})
+				}
+			}
+		})
+	}
+}
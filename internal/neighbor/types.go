@@ -1,19 +1,278 @@
 package neighbor
 
 import (
+	"context"
+	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hostinger/neigh2route/pkg/netutils"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/time/rate"
+)
+
+const (
+	DefaultRouteRetries      = 3
+	DefaultRouteRetryBackoff = 100 * time.Millisecond
+	DefaultStateMask         = netlink.NUD_REACHABLE | netlink.NUD_STALE
+	revalidatingStates       = netlink.NUD_DELAY | netlink.NUD_PROBE
+	DefaultMaxPauseBuffer    = 10000
+	DefaultPingInterval      = 30 * time.Second
+	DefaultPingTimeout       = time.Second
+	DefaultAddRateLimit      = 100
+	DefaultAddBurst          = 20
+	DefaultInitWorkers       = 8
+	maxPingBackoffFactor     = 10
+	maxMonitorRetryBackoff   = 32 * time.Second
 )
 
+// Config holds every NeighborManager setting. Zero values fall back to the
+// package defaults.
+type Config struct {
+	// Context bounds every route operation. Cancelling it aborts in-flight
+	// netlink calls, except the route removal done by Cleanup.
+	Context           context.Context
+	TargetInterfaces  []string
+	RouteRetries      int
+	RouteRetryBackoff time.Duration
+	RouteMetric       uint32
+	RouteTable        int
+	RouteProtocol     int
+	RouteScope        *netlink.Scope
+	IPv4PrefixLen     int
+	IPv6PrefixLen     int
+	IPv6Gateway       bool
+	InitWorkers       int
+	MaxPauseBuffer    int
+	CleanupOnStart    bool
+	DryRun            bool
+	VLANAware         bool
+	ECMP              bool
+	FailedHold        time.Duration
+	MigrationGrace    time.Duration
+	MaxNeighbors      int
+	EvictPolicy       EvictPolicy
+	AddRateLimit      float64
+	AddBurst          int
+	PingInterval      time.Duration
+	PingTimeout       time.Duration
+	PingBackoff       bool
+	UseNS             bool
+	ARPTable          bool
+	StateFile         string
+	EventHistorySize  int
+	EventBusCapacity  int
+	Policy            NeighborPolicy
+
+	// AuditLog, when set, records every route added or removed.
+	AuditLog *AuditLog
+
+	// RouteNotifier, when set, announces every route added or removed on
+	// the rtnetlink route multicast groups.
+	RouteNotifier *netutils.RouteNotifier
+
+	// ExtLearnedOwner, when set, claims the NTF_EXT_LEARNED neighbor
+	// entries it added, which are routed like any other. The rest are left
+	// to the control plane that learned them. Set it to the sniffer when it
+	// adds its entries with that flag.
+	ExtLearnedOwner ExtLearnedOwner
+}
+
+// ExtLearnedOwner reports whether it added the NTF_EXT_LEARNED neighbor
+// entry of ip on linkIndex. Forget is called once the kernel deleted an
+// entry, so the owner need not keep every entry it ever added.
+type ExtLearnedOwner interface {
+	Injected(ip net.IP, linkIndex int) bool
+	Forget(ip net.IP, linkIndex int)
+}
+
 type NeighborManager struct {
-	mu                   sync.Mutex
-	ReachableNeighbors   map[string]Neighbor
-	TargetInterface      string
-	TargetInterfaceIndex int
+	mu                 sync.Mutex
+	ReachableNeighbors map[string]Neighbor
+	TargetInterfaces   []string
+	RouteRetries       int
+	RouteRetryBackoff  time.Duration
+	RouteMetric        uint32
+	RouteTable         int
+	RouteProtocol      int
+	RouteScope         *netlink.Scope
+	IPv4PrefixLen      int
+	IPv6PrefixLen      int
+	IPv6Gateway        bool
+	InitWorkers        int
+	currentPolicy      NeighborPolicy
+	MaxPauseBuffer     int
+	CleanupOnStart     bool
+	DryRun             bool
+	VLANAware          bool
+	ECMP               bool
+	FailedHold         time.Duration
+	MigrationGrace     time.Duration
+	MaxNeighbors       int
+	EvictPolicy        EvictPolicy
+	PingInterval       time.Duration
+	PingTimeout        time.Duration
+	PingBackoff        bool
+	UseNS              bool
+	ARPTable           bool
+	StateFile          string
+	saveMu             sync.Mutex
+
+	// persistHolds counts the bulk updates, such as the initial scan, that
+	// hold back persistState; persistDirty records a change made meanwhile.
+	persistHolds atomic.Int32
+	persistDirty atomic.Bool
+
+	// ctx is Config.Context, passed to every route operation.
+	ctx context.Context
+
+	auditLog *AuditLog
+
+	extLearnedOwner ExtLearnedOwner
+
+	// settingsMu guards RouteMetric and PingInterval, which may be changed
+	// at runtime through SetRouteMetric and SetPingInterval.
+	settingsMu sync.RWMutex
+
+	// pingFailureCounts and nextPingAt track consecutive ping failures and
+	// the backed-off time of the next ping per IP. Guarded by mu.
+	pingFailureCounts map[string]int
+	nextPingAt        map[string]time.Time
+
+	// pingTimeouts holds the per-IP PingTimeout overrides set with
+	// SetPingTimeout. Guarded by mu.
+	pingTimeouts map[string]time.Duration
+
+	// addLimiter bounds how fast kernel updates may add new routes.
+	addLimiter *rate.Limiter
+
+	// pendingRemovals holds the FailedHold timers of failed neighbors,
+	// keyed by IP. Guarded by mu.
+	pendingRemovals map[string]*time.Timer
+
+	// pendingMigrations holds the MigrationGrace timers removing the old
+	// route of a neighbor that moved links, keyed by routeKey of the old
+	// route. Guarded by mu.
+	pendingMigrations map[string]pendingMigration
+
+	// TargetInterfaceIndexes lists the monitored links. Empty means every
+	// link is monitored.
+	TargetInterfaceIndexes []int
+
+	pauseMu     sync.Mutex
+	paused      bool
+	pauseBuffer []netlink.NeighUpdate
+
+	events *eventLog
+	bus    *eventBus
+
+	// lastUpdateTime holds the time.Time of the latest kernel neighbor update.
+	lastUpdateTime atomic.Value
+
+	routesAdded   atomic.Uint64
+	routesRemoved atomic.Uint64
+	pingFailures  atomic.Uint64
+
+	netlinkReconnects atomic.Uint64
+	neighborsRejected atomic.Uint64
+	neighborsEvicted  atomic.Uint64
+	macChanges        atomic.Uint64
+
+	routeNotifier *netutils.RouteNotifier
+}
+
+// pendingMigration is the old route of a neighbor that moved links, kept
+// until its MigrationGrace timer fires. The route on newLinkIndex has the
+// preferred metric until then.
+type pendingMigration struct {
+	ip           net.IP
+	vlanID       int
+	linkIndex    int
+	newLinkIndex int
+	timer        *time.Timer
+}
+
+type Stats struct {
+	TotalNeighbors    int
+	IPv4Neighbors     int
+	IPv6Neighbors     int
+	RoutesAdded       uint64
+	RoutesRemoved     uint64
+	PingFailures      uint64
+	NetlinkReconnects uint64
+	NeighborsRejected uint64
+	NeighborsEvicted  uint64
+	MACChanges        uint64
+}
+
+// EvictPolicy decides what happens to a new neighbor once MaxNeighbors is
+// reached.
+type EvictPolicy string
+
+const (
+	// EvictNone rejects the new neighbor.
+	EvictNone EvictPolicy = "none"
+	// EvictLRU evicts the least recently updated non-permanent neighbor to
+	// make room for the new one.
+	EvictLRU EvictPolicy = "lru"
+)
+
+// ParseEvictPolicy returns the EvictPolicy named s.
+func ParseEvictPolicy(s string) (EvictPolicy, error) {
+	switch p := EvictPolicy(s); p {
+	case EvictNone, EvictLRU:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown evict policy %q, expected %q or %q", s, EvictNone, EvictLRU)
+}
+
+// NeighborPolicy describes which kernel neighbors get a route. An empty
+// AllowPrefixes list accepts every address; ExcludePrefixes win over
+// AllowPrefixes.
+type NeighborPolicy struct {
+	AllowPrefixes   []*net.IPNet
+	ExcludePrefixes []*net.IPNet
+	ExcludeIPs      []net.IP
+	StateMask       int
+}
+
+// BuiltinExcludePrefixes returns the link-local and multicast ranges that
+// should never get a host route.
+func BuiltinExcludePrefixes() []*net.IPNet {
+	var prefixes []*net.IPNet
+	for _, cidr := range []string{"fe80::/10", "ff00::/8", "169.254.0.0/16", "224.0.0.0/4"} {
+		_, prefix, _ := net.ParseCIDR(cidr)
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
 }
 
 type Neighbor struct {
 	IP           net.IP
 	LinkIndex    int
 	HardwareAddr net.HardwareAddr
+
+	// Permanent neighbors are pinned by the operator: kernel updates never
+	// replace or remove them and they are not pinged.
+	Permanent bool
+
+	// VlanID is the 802.1Q VLAN of the kernel entry, if any. With
+	// VLANAware set it is part of the ReachableNeighbors key.
+	VlanID int
+
+	// LinkIndexes lists every link the neighbor was learned on when ECMP
+	// is set, and LinkIndex is the latest of them. Its route balances over
+	// all of them.
+	LinkIndexes []int
+
+	// PingTimeout overrides the manager's PingTimeout for this neighbor
+	// when set.
+	PingTimeout time.Duration
+
+	// FirstSeen is when the neighbor was first added and LastUpdated when
+	// it was last added or refreshed by an update.
+	FirstSeen   time.Time
+	LastUpdated time.Time
 }
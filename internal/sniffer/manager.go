@@ -0,0 +1,294 @@
+package sniffer
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+)
+
+// SnifferManager runs one NA sniffer per tap interface and inserts the
+// learned neighbors on TargetInterface. With IPv4 set it also sniffs ARP
+// replies on each tap. An interface matching any of TapPatterns is sniffed;
+// without patterns DefaultTapPattern is used. BPFFilter overrides DefaultNAFilter,
+// ARPFilter overrides DefaultARPFilter and ScanInterval overrides
+// DefaultScanInterval. With PcapDump set every packet the NA sniffers
+// receive is written to it.
+type SnifferManager struct {
+	TargetInterface string
+	IPv4            bool
+	TapPatterns     []*regexp.Regexp
+	BPFFilter       string
+	ARPFilter       string
+	ScanInterval    time.Duration
+	PcapDump        *PcapDump
+
+	// NeighFlags are set on every neighbor entry the sniffers add, see
+	// ParseNeighFlags.
+	NeighFlags int
+
+	mu       sync.Mutex
+	sniffers map[string]*SnifferInfo
+	wg       sync.WaitGroup
+
+	// retiredPackets counts the packets received by stopped sniffers.
+	// Guarded by mu.
+	retiredPackets uint64
+
+	// ctx is the context Run was started with, which every sniffer context
+	// derives from, and stopped is set once stopAll ran. Guarded by mu.
+	ctx     context.Context
+	stopped bool
+
+	injected *injectedNeighbors
+}
+
+func NewSnifferManager(targetIface string) *SnifferManager {
+	return &SnifferManager{
+		TargetInterface: targetIface,
+		sniffers:        make(map[string]*SnifferInfo),
+		injected:        newInjectedNeighbors(),
+	}
+}
+
+func (sm *SnifferManager) ListActiveSniffers() map[string]time.Time {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	result := make(map[string]time.Time)
+	for iface, info := range sm.sniffers {
+		result[iface] = info.StartedAt
+	}
+	return result
+}
+
+// ListSnifferStatus returns the state of every known sniffer, including paused ones.
+func (sm *SnifferManager) ListSnifferStatus() map[string]SnifferStatus {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	result := make(map[string]SnifferStatus)
+	for iface, info := range sm.sniffers {
+		result[iface] = SnifferStatus{
+			StartedAt: info.StartedAt,
+			Paused:    info.paused,
+			PausedAt:  info.pausedAt,
+			Stats:     info.Snapshot(),
+		}
+	}
+	return result
+}
+
+// Pause stops packet capture on sniffIface while keeping it registered, so
+// the manager does not restart it on its next scan.
+func (sm *SnifferManager) Pause(sniffIface string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	info, exists := sm.sniffers[sniffIface]
+	if !exists {
+		return ErrSnifferNotFound
+	}
+	if info.paused {
+		return ErrSnifferPaused
+	}
+
+	info.CancelFunc()
+	info.paused = true
+	info.pausedAt = time.Now()
+
+	logger.InfoFields("[Sniffer-Event] Paused sniffer", map[string]interface{}{"interface": sniffIface})
+	return nil
+}
+
+// Resume restarts packet capture on a sniffer previously stopped by Pause,
+// once its old capture goroutines exited. It fails with ErrSnifferStopped
+// once the manager has stopped.
+func (sm *SnifferManager) Resume(sniffIface string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.stopped {
+		return ErrSnifferStopped
+	}
+
+	info, exists := sm.sniffers[sniffIface]
+	if !exists {
+		return ErrSnifferNotFound
+	}
+	if !info.paused {
+		return ErrSnifferNotPaused
+	}
+
+	// Capture goroutines hold no lock, so waiting here cannot deadlock,
+	// and it keeps two pcap handles from being open on sniffIface.
+	if info.done != nil {
+		<-info.done
+	}
+
+	ctx, cancel := context.WithCancel(sm.baseContextLocked())
+	info.CancelFunc = cancel
+	info.paused = false
+	info.pausedAt = time.Time{}
+	sm.launch(ctx, sniffIface, info)
+
+	logger.InfoFields("[Sniffer-Event] Resumed sniffer", map[string]interface{}{"interface": sniffIface})
+	return nil
+}
+
+// launch runs startSniffer, and startARPSniffer when IPv4 is set, in
+// goroutines tracked by sm.wg, and closes info.done once both exited.
+// Callers hold mu.
+func (sm *SnifferManager) launch(ctx context.Context, sniffIface string, info *SnifferInfo) {
+	done := make(chan struct{})
+	info.done = done
+
+	var running sync.WaitGroup
+	run := func(start func(context.Context, string, string, *SnifferInfo)) {
+		running.Add(1)
+		sm.wg.Add(1)
+		go func() {
+			defer sm.wg.Done()
+			defer running.Done()
+			start(ctx, sniffIface, info.insertIface, info)
+		}()
+	}
+
+	run(startSniffer)
+	if sm.IPv4 {
+		run(startARPSniffer)
+	}
+
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+}
+
+// retireLocked drops the stopped sniffer on sniffIface, keeping its packets
+// in PacketsReceivedTotal. Callers hold mu.
+func (sm *SnifferManager) retireLocked(sniffIface string, info *SnifferInfo) {
+	sm.retiredPackets += info.PacketsReceived.Load()
+	delete(sm.sniffers, sniffIface)
+}
+
+// PacketsReceivedTotal returns how many packets every sniffer received
+// since the manager started, including sniffers that have since stopped.
+func (sm *SnifferManager) PacketsReceivedTotal() uint64 {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	total := sm.retiredPackets
+	for _, info := range sm.sniffers {
+		total += info.PacketsReceived.Load()
+	}
+	return total
+}
+
+// baseContextLocked returns the context sniffers derive theirs from.
+// Callers hold mu.
+func (sm *SnifferManager) baseContextLocked() context.Context {
+	if sm.ctx == nil {
+		return context.Background()
+	}
+	return sm.ctx
+}
+
+// stopAll cancels every sniffer and waits for their goroutines to exit. No
+// sniffer starts afterwards.
+func (sm *SnifferManager) stopAll() {
+	sm.mu.Lock()
+	sm.stopped = true
+	for sniffIface, info := range sm.sniffers {
+		info.CancelFunc()
+		sm.retireLocked(sniffIface, info)
+	}
+	sm.mu.Unlock()
+
+	sm.wg.Wait()
+}
+
+// ReloadInterfaces rescans tap interfaces immediately, starting sniffers on
+// new ones and stopping those whose interface is gone. It does nothing once
+// the manager has stopped.
+func (sm *SnifferManager) ReloadInterfaces() (started int, stopped int) {
+	patterns := sm.TapPatterns
+	if len(patterns) == 0 {
+		patterns = []*regexp.Regexp{defaultTapRegexp}
+	}
+
+	currentSet := make(map[string]bool)
+	for _, sniffIface := range listTapInterfaces(patterns) {
+		currentSet[sniffIface] = true
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.stopped {
+		return 0, 0
+	}
+
+	for sniffIface := range currentSet {
+		if _, exists := sm.sniffers[sniffIface]; !exists {
+			logger.InfoFields("[Sniffer-Event] New tap detected, starting sniffer", map[string]interface{}{"interface": sniffIface})
+			ctx, cancel := context.WithCancel(sm.baseContextLocked())
+			info := &SnifferInfo{
+				CancelFunc:   cancel,
+				StartedAt:    time.Now(),
+				insertIface:  sm.TargetInterface,
+				naFilter:     sm.BPFFilter,
+				arpFilter:    sm.ARPFilter,
+				dump:         sm.PcapDump,
+				neighFlags:   sm.NeighFlags,
+				injected:     sm.injected,
+				SnifferStats: &SnifferStats{},
+			}
+			sm.sniffers[sniffIface] = info
+			sm.launch(ctx, sniffIface, info)
+			started++
+		}
+	}
+
+	for sniffIface, info := range sm.sniffers {
+		if !currentSet[sniffIface] {
+			logger.InfoFields("[Sniffer-Event] Tap removed, stopping sniffer", map[string]interface{}{"interface": sniffIface})
+			info.CancelFunc()
+			sm.retireLocked(sniffIface, info)
+			stopped++
+		}
+	}
+
+	return started, stopped
+}
+
+// Run scans for tap interfaces every ScanInterval until ctx is cancelled,
+// then stops all sniffers and waits for them before returning.
+func (sm *SnifferManager) Run(ctx context.Context) {
+	scanInterval := sm.ScanInterval
+	if scanInterval <= 0 {
+		scanInterval = DefaultScanInterval
+	}
+	logger.Info("Starting NA sniffer. Scanning for tap interfaces every %s...", scanInterval)
+
+	sm.mu.Lock()
+	sm.ctx = ctx
+	sm.mu.Unlock()
+
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+
+	for {
+		sm.ReloadInterfaces()
+
+		select {
+		case <-ctx.Done():
+			logger.Info("[Sniffer-Event] Shutting down sniffer manager")
+			sm.stopAll()
+			return
+		case <-ticker.C:
+		}
+	}
+}
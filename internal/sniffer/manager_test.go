@@ -0,0 +1,434 @@
+package sniffer
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Helper function to replace capture with a loop that blocks until cancelled
+// and reports each start on the returned channel
+func stubCapture(t *testing.T, taps func() []string) chan string {
+	origStart, origList := startSniffer, listTapInterfaces
+	t.Cleanup(func() {
+		startSniffer, listTapInterfaces = origStart, origList
+	})
+
+	started := make(chan string, 8)
+	startSniffer = func(ctx context.Context, sniffIface string, insertIface string, info *SnifferInfo) {
+		started <- sniffIface
+		<-ctx.Done()
+	}
+	listTapInterfaces = func([]*regexp.Regexp) []string { return taps() }
+	return started
+}
+
+func TestPauseResumeLifecycle(t *testing.T) {
+	started := stubCapture(t, func() []string { return []string{"tap-pause"} })
+	sm := NewSnifferManager("lo")
+	sm.ReloadInterfaces()
+	defer sm.stopAll()
+	<-started
+
+	if err := sm.Pause("tap-pause"); err != nil {
+		t.Fatalf("Expected pause to succeed, got %v", err)
+	}
+
+	status := sm.ListSnifferStatus()["tap-pause"]
+	if !status.Paused || status.PausedAt.IsZero() {
+		t.Errorf("Expected sniffer to be paused with a timestamp, got %+v", status)
+	}
+
+	if err := sm.Pause("tap-pause"); !errors.Is(err, ErrSnifferPaused) {
+		t.Errorf("Expected ErrSnifferPaused on double pause, got %v", err)
+	}
+
+	if err := sm.Resume("tap-pause"); err != nil {
+		t.Fatalf("Expected resume to succeed, got %v", err)
+	}
+
+	select {
+	case iface := <-started:
+		if iface != "tap-pause" {
+			t.Errorf("Expected tap-pause to restart, got %s", iface)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected capture to restart after resume")
+	}
+
+	status = sm.ListSnifferStatus()["tap-pause"]
+	if status.Paused || !status.PausedAt.IsZero() {
+		t.Errorf("Expected sniffer to be running, got %+v", status)
+	}
+
+	if err := sm.Resume("tap-pause"); !errors.Is(err, ErrSnifferNotPaused) {
+		t.Errorf("Expected ErrSnifferNotPaused on double resume, got %v", err)
+	}
+}
+
+func TestResumeWaitsForPausedCapture(t *testing.T) {
+	stubCapture(t, func() []string { return []string{"tap-quick"} })
+
+	var running, overlaps atomic.Int32
+	started := make(chan struct{}, 2)
+	startSniffer = func(ctx context.Context, sniffIface string, insertIface string, info *SnifferInfo) {
+		if running.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		started <- struct{}{}
+		<-ctx.Done()
+		// A pcap handle takes a while to close.
+		time.Sleep(50 * time.Millisecond)
+		running.Add(-1)
+	}
+
+	sm := NewSnifferManager("lo")
+	sm.ReloadInterfaces()
+	defer sm.stopAll()
+	<-started
+
+	if err := sm.Pause("tap-quick"); err != nil {
+		t.Fatalf("Expected pause to succeed, got %v", err)
+	}
+	if err := sm.Resume("tap-quick"); err != nil {
+		t.Fatalf("Expected resume to succeed, got %v", err)
+	}
+	<-started
+
+	if n := overlaps.Load(); n != 0 {
+		t.Errorf("Expected the old capture to exit before the new one started, got %d overlaps", n)
+	}
+}
+
+func TestResumeAfterStop(t *testing.T) {
+	started := stubCapture(t, func() []string { return []string{"tap-stop"} })
+
+	sm := NewSnifferManager("lo")
+	sm.ReloadInterfaces()
+	<-started
+	if err := sm.Pause("tap-stop"); err != nil {
+		t.Fatalf("Expected pause to succeed, got %v", err)
+	}
+	sm.stopAll()
+
+	if err := sm.Resume("tap-stop"); !errors.Is(err, ErrSnifferStopped) {
+		t.Errorf("Expected ErrSnifferStopped after stop, got %v", err)
+	}
+	select {
+	case iface := <-started:
+		t.Errorf("Expected no sniffer to start after stop, got %s", iface)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPauseResumeUnknownInterface(t *testing.T) {
+	sm := NewSnifferManager("lo")
+
+	if err := sm.Pause("tap-missing"); !errors.Is(err, ErrSnifferNotFound) {
+		t.Errorf("Expected ErrSnifferNotFound, got %v", err)
+	}
+	if err := sm.Resume("tap-missing"); !errors.Is(err, ErrSnifferNotFound) {
+		t.Errorf("Expected ErrSnifferNotFound, got %v", err)
+	}
+}
+
+func TestReloadInterfacesStartsAndStops(t *testing.T) {
+	var taps atomic.Value
+	taps.Store([]string{"tap0", "tap1"})
+	stubCapture(t, func() []string { return taps.Load().([]string) })
+
+	sm := NewSnifferManager("lo")
+	defer sm.stopAll()
+
+	started, stopped := sm.ReloadInterfaces()
+	if started != 2 || stopped != 0 {
+		t.Fatalf("Expected 2 started and 0 stopped, got %d and %d", started, stopped)
+	}
+
+	taps.Store([]string{"tap1", "tap2"})
+	started, stopped = sm.ReloadInterfaces()
+	if started != 1 || stopped != 1 {
+		t.Errorf("Expected 1 started and 1 stopped, got %d and %d", started, stopped)
+	}
+
+	active := sm.ListActiveSniffers()
+	if _, ok := active["tap0"]; ok {
+		t.Errorf("Expected tap0 to be stopped")
+	}
+	if _, ok := active["tap2"]; !ok || len(active) != 2 {
+		t.Errorf("Expected tap1 and tap2 to be active, got %v", active)
+	}
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	started := stubCapture(t, func() []string { return []string{"tap-ctx0", "tap-ctx1"} })
+
+	sm := NewSnifferManager("lo")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sm.Run(ctx)
+		close(done)
+	}()
+
+	<-started
+	<-started
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected sniffer manager to stop within 2 seconds")
+	}
+
+	if n := len(sm.ListActiveSniffers()); n != 0 {
+		t.Errorf("Expected no active sniffers after shutdown, got %d", n)
+	}
+}
+
+func TestReloadInterfacesAfterRunReturned(t *testing.T) {
+	started := stubCapture(t, func() []string { return []string{"tap-late"} })
+
+	sm := NewSnifferManager("lo")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sm.Run(ctx)
+		close(done)
+	}()
+
+	<-started
+	cancel()
+	<-done
+
+	if n, _ := sm.ReloadInterfaces(); n != 0 {
+		t.Errorf("Expected no sniffer to start after Run returned, got %d", n)
+	}
+	select {
+	case iface := <-started:
+		t.Errorf("Expected no sniffer to start after Run returned, got %s", iface)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if n := len(sm.ListActiveSniffers()); n != 0 {
+		t.Errorf("Expected no active sniffers, got %d", n)
+	}
+}
+
+func TestReloadInterfacesStartsARPSniffer(t *testing.T) {
+	started := stubCapture(t, func() []string { return []string{"tap-arp"} })
+
+	origARP := startARPSniffer
+	t.Cleanup(func() { startARPSniffer = origARP })
+	arpStarted := make(chan string, 1)
+	startARPSniffer = func(ctx context.Context, sniffIface string, insertIface string, info *SnifferInfo) {
+		arpStarted <- sniffIface
+		<-ctx.Done()
+	}
+
+	sm := NewSnifferManager("lo")
+	sm.IPv4 = true
+	sm.ReloadInterfaces()
+	defer sm.stopAll()
+
+	for _, ch := range []chan string{started, arpStarted} {
+		select {
+		case iface := <-ch:
+			if iface != "tap-arp" {
+				t.Errorf("Expected tap-arp, got %s", iface)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected both NA and ARP sniffers to start")
+		}
+	}
+}
+
+func TestListSnifferStatusIncludesStats(t *testing.T) {
+	started := stubCapture(t, func() []string { return []string{"tap-stats"} })
+	sm := NewSnifferManager("lo")
+	sm.ReloadInterfaces()
+	defer sm.stopAll()
+	<-started
+
+	sm.mu.Lock()
+	info := sm.sniffers["tap-stats"]
+	sm.mu.Unlock()
+	info.PacketsReceived.Add(4)
+	info.PacketsMatched.Add(3)
+	info.PacketsSkipped.Add(2)
+	info.NeighborsAdded.Add(1)
+
+	got := sm.ListSnifferStatus()["tap-stats"].Stats
+	want := SnifferStatsSnapshot{PacketsReceived: 4, PacketsMatched: 3, PacketsSkipped: 2, NeighborsAdded: 1}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestPacketsReceivedTotalSurvivesRemovedSniffers(t *testing.T) {
+	var mu sync.Mutex
+	taps := []string{"tap-a", "tap-b"}
+	started := stubCapture(t, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return taps
+	})
+	sm := NewSnifferManager("lo")
+	sm.ReloadInterfaces()
+	defer sm.stopAll()
+	<-started
+	<-started
+
+	sm.mu.Lock()
+	sm.sniffers["tap-a"].PacketsReceived.Add(5)
+	sm.sniffers["tap-b"].PacketsReceived.Add(2)
+	sm.mu.Unlock()
+
+	mu.Lock()
+	taps = []string{"tap-b"}
+	mu.Unlock()
+	sm.ReloadInterfaces()
+
+	if got := sm.PacketsReceivedTotal(); got != 7 {
+		t.Errorf("Expected 7 packets after tap-a was removed, got %d", got)
+	}
+}
+
+func TestDefaultTapPattern(t *testing.T) {
+	for name, want := range map[string]bool{
+		"tap0":       true,
+		"tap123":     true,
+		"tap123.100": true,
+		"tap100i0":   true,
+		"tap":        false,
+		"eth0":       false,
+		"br-tap1":    false,
+	} {
+		if got := defaultTapRegexp.MatchString(name); got != want {
+			t.Errorf("MatchString(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestReloadInterfacesUsesTapPatterns(t *testing.T) {
+	stubCapture(t, nil)
+
+	var used []*regexp.Regexp
+	listTapInterfaces = func(patterns []*regexp.Regexp) []string {
+		used = patterns
+		return nil
+	}
+
+	sm := NewSnifferManager("lo")
+	sm.ReloadInterfaces()
+	if len(used) != 1 || used[0] != defaultTapRegexp {
+		t.Errorf("Expected the default pattern, got %v", used)
+	}
+
+	sm.TapPatterns = []*regexp.Regexp{defaultTapRegexp, regexp.MustCompile(`^vnet\d+$`)}
+	sm.ReloadInterfaces()
+	if len(used) != 2 || used[1] != sm.TapPatterns[1] {
+		t.Errorf("Expected the configured patterns, got %v", used)
+	}
+}
+
+func TestMatchesAny(t *testing.T) {
+	patterns := []*regexp.Regexp{defaultTapRegexp, regexp.MustCompile(`^vnet\d+$`)}
+
+	for name, want := range map[string]bool{
+		"tap0":  true,
+		"vnet3": true,
+		"eth0":  false,
+		"vnet":  false,
+	} {
+		if got := matchesAny(patterns, name); got != want {
+			t.Errorf("matchesAny(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	if matchesAny(nil, "tap0") {
+		t.Errorf("Expected no match without patterns")
+	}
+}
+
+func TestReloadInterfacesUsesBPFFilter(t *testing.T) {
+	stubCapture(t, func() []string { return []string{"tap-bpf"} })
+
+	filters := make(chan string, 1)
+	startSniffer = func(ctx context.Context, sniffIface string, insertIface string, info *SnifferInfo) {
+		filters <- info.naFilter
+		<-ctx.Done()
+	}
+
+	sm := NewSnifferManager("lo")
+	sm.BPFFilter = "icmp6 and ip6[40] == 136"
+	sm.ReloadInterfaces()
+	defer sm.stopAll()
+
+	select {
+	case filter := <-filters:
+		if filter != sm.BPFFilter {
+			t.Errorf("Expected filter %q, got %q", sm.BPFFilter, filter)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the sniffer to start")
+	}
+}
+
+func TestReloadInterfacesUsesARPFilter(t *testing.T) {
+	stubCapture(t, func() []string { return []string{"tap-arp"} })
+
+	origARP := startARPSniffer
+	t.Cleanup(func() { startARPSniffer = origARP })
+	filters := make(chan string, 1)
+	startARPSniffer = func(ctx context.Context, sniffIface string, insertIface string, info *SnifferInfo) {
+		filters <- info.arpFilter
+		<-ctx.Done()
+	}
+
+	sm := NewSnifferManager("lo")
+	sm.IPv4 = true
+	sm.ARPFilter = "arp[6:2] == 2"
+	sm.ReloadInterfaces()
+	defer sm.stopAll()
+
+	select {
+	case filter := <-filters:
+		if filter != sm.ARPFilter {
+			t.Errorf("Expected filter %q, got %q", sm.ARPFilter, filter)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the ARP sniffer to start")
+	}
+}
+
+func TestRunRescansEveryScanInterval(t *testing.T) {
+	var scans atomic.Int32
+	stubCapture(t, func() []string {
+		scans.Add(1)
+		return nil
+	})
+
+	sm := NewSnifferManager("lo")
+	sm.ScanInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sm.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for scans.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if n := scans.Load(); n < 3 {
+		t.Errorf("Expected at least 3 scans with a 10ms interval, got %d", n)
+	}
+}
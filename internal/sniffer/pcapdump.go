@@ -0,0 +1,102 @@
+package sniffer
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/hostinger/neigh2route/internal/logger"
+)
+
+// PcapDump writes the packets received by every NA sniffer to one pcap
+// file for debugging. It is safe for concurrent use.
+type PcapDump struct {
+	path string
+
+	mu     sync.Mutex
+	file   *os.File
+	writer *pcapgo.Writer
+}
+
+// NewPcapDump creates the pcap file at path, replacing any existing file.
+func NewPcapDump(path string) (*PcapDump, error) {
+	d := &PcapDump{path: path}
+	if err := d.open(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// open starts a new file at d.path. Callers hold mu, except NewPcapDump.
+func (d *PcapDump) open() error {
+	f, err := os.Create(d.path)
+	if err != nil {
+		return err
+	}
+
+	w := pcapgo.NewWriter(f)
+	if err := w.WriteFileHeader(snapLen, layers.LinkTypeEthernet); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write pcap header to %s: %w", d.path, err)
+	}
+
+	d.file, d.writer = f, w
+	return nil
+}
+
+// WritePacket appends pkt to the dump. Write errors are logged, not
+// returned, so a full disk does not stop the sniffers.
+func (d *PcapDump) WritePacket(pkt gopacket.Packet) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.writer == nil {
+		return
+	}
+	if err := d.writer.WritePacket(pkt.Metadata().CaptureInfo, pkt.Data()); err != nil {
+		sampledLog.Error("[Sniffer-Event] Failed to write packet to %s: %v", d.path, err)
+	}
+}
+
+// Rotate moves the current file aside with a timestamp suffix and starts a
+// new one at the original path.
+func (d *PcapDump) Rotate() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return nil
+	}
+	if err := d.file.Close(); err != nil {
+		logger.Warn("[Sniffer-Event] Failed to close %s: %v", d.path, err)
+	}
+	d.file, d.writer = nil, nil
+
+	rotated := d.path + "." + time.Now().Format("20060102T150405.000000000")
+	if err := os.Rename(d.path, rotated); err != nil {
+		return err
+	}
+	if err := d.open(); err != nil {
+		return err
+	}
+
+	logger.Info("[Sniffer-Event] Rotated packet dump to %s", rotated)
+	return nil
+}
+
+// Close closes the file. Packets written afterwards are dropped.
+func (d *PcapDump) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file, d.writer = nil, nil
+	return err
+}
@@ -0,0 +1,97 @@
+package sniffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// Helper function to give a built packet the capture info of a live one
+func captured(pkt gopacket.Packet) gopacket.Packet {
+	pkt.Metadata().CaptureInfo = gopacket.CaptureInfo{
+		Timestamp:     time.Now(),
+		CaptureLength: len(pkt.Data()),
+		Length:        len(pkt.Data()),
+	}
+	return pkt
+}
+
+// Helper function to count the packets in a pcap file
+func countPackets(t *testing.T, path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	r, err := pcapgo.NewReader(f)
+	if err != nil {
+		t.Fatalf("failed to read pcap header of %s: %v", path, err)
+	}
+
+	n := 0
+	for {
+		if _, _, err := r.ReadPacketData(); err != nil {
+			return n
+		}
+		n++
+	}
+}
+
+func TestHandlePacketWritesPcapDump(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "na.pcap")
+	dump, err := NewPcapDump(path)
+	if err != nil {
+		t.Fatalf("failed to create dump: %v", err)
+	}
+
+	info := &SnifferInfo{StartedAt: time.Now(), SnifferStats: &SnifferStats{}, dump: dump}
+	for i := 0; i < 3; i++ {
+		handlePacket(captured(nonNAPacket(t)), "tap0", "lo", info)
+	}
+
+	if err := dump.Close(); err != nil {
+		t.Fatalf("failed to close dump: %v", err)
+	}
+	if got := countPackets(t, path); got != 3 {
+		t.Errorf("Expected 3 packets in the dump, got %d", got)
+	}
+
+	// Packets after Close are dropped without error.
+	dump.WritePacket(captured(nonNAPacket(t)))
+}
+
+func TestPcapDumpRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "na.pcap")
+	dump, err := NewPcapDump(path)
+	if err != nil {
+		t.Fatalf("failed to create dump: %v", err)
+	}
+	defer dump.Close()
+
+	dump.WritePacket(captured(nonNAPacket(t)))
+	dump.WritePacket(captured(nonNAPacket(t)))
+	if err := dump.Rotate(); err != nil {
+		t.Fatalf("failed to rotate: %v", err)
+	}
+	dump.WritePacket(captured(nonNAPacket(t)))
+	if err := dump.Close(); err != nil {
+		t.Fatalf("failed to close dump: %v", err)
+	}
+
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 1 {
+		t.Fatalf("Expected one rotated file, got %v", rotated)
+	}
+	if got := countPackets(t, rotated[0]); got != 2 {
+		t.Errorf("Expected 2 packets in the rotated file, got %d", got)
+	}
+	if got := countPackets(t, path); got != 1 {
+		t.Errorf("Expected 1 packet in the new file, got %d", got)
+	}
+}
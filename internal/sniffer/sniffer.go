@@ -2,42 +2,126 @@ package sniffer
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
 	"os"
 	"regexp"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
 	"github.com/hostinger/neigh2route/internal/logger"
+	"github.com/hostinger/neigh2route/internal/neighbor"
 	"github.com/vishvananda/netlink"
 )
 
+// SnifferStats counts the packets seen by one sniffer. Matched packets are
+// NAs (or ARP replies); skipped ones are matched packets that did not lead
+// to a neighbor entry, e.g. link-local or already known targets.
+type SnifferStats struct {
+	PacketsReceived atomic.Uint64
+	PacketsMatched  atomic.Uint64
+	PacketsSkipped  atomic.Uint64
+	NeighborsAdded  atomic.Uint64
+}
+
+type SnifferStatsSnapshot struct {
+	PacketsReceived uint64
+	PacketsMatched  uint64
+	PacketsSkipped  uint64
+	NeighborsAdded  uint64
+}
+
+func (s *SnifferStats) Snapshot() SnifferStatsSnapshot {
+	return SnifferStatsSnapshot{
+		PacketsReceived: s.PacketsReceived.Load(),
+		PacketsMatched:  s.PacketsMatched.Load(),
+		PacketsSkipped:  s.PacketsSkipped.Load(),
+		NeighborsAdded:  s.NeighborsAdded.Load(),
+	}
+}
+
 type SnifferInfo struct {
 	CancelFunc context.CancelFunc
 	StartedAt  time.Time
+
+	// Guarded by SnifferManager.mu.
+	insertIface string
+	naFilter    string
+	arpFilter   string
+	paused      bool
+	pausedAt    time.Time
+
+	// done is closed once the capture goroutines of the last launch exited.
+	done chan struct{}
+
+	// dump receives every packet handlePacket sees when set.
+	dump *PcapDump
+
+	// neighFlags are the NTF_* flags of the neighbor entries this sniffer
+	// adds.
+	neighFlags int
+
+	// injected records the ext_learned entries this sniffer adds.
+	injected *injectedNeighbors
+
+	// Stats are updated from the capture goroutine without holding
+	// SnifferManager.mu, so they must only be accessed atomically.
+	*SnifferStats
+}
+
+type SnifferStatus struct {
+	StartedAt time.Time
+	Paused    bool
+	PausedAt  time.Time
+	Stats     SnifferStatsSnapshot
 }
 
+const snapLen = 1600
+
+// DefaultNAFilter captures inbound Neighbor Advertisements. Drivers without
+// direction support need the filter without "inbound".
+const DefaultNAFilter = "inbound and icmp6 and ip6[40] == 136"
+
+// DefaultARPFilter captures inbound ARP replies. Drivers without direction
+// support need the filter without "inbound".
+const DefaultARPFilter = "inbound and arp[6:2] == 2"
+
+// DefaultTapPattern matches tap interfaces such as tap123, Proxmox taps
+// such as tap100i0 and VLAN subinterfaces such as tap123.100.
+const DefaultTapPattern = `^tap\d+`
+
+// DefaultScanInterval is how often the sniffer manager rescans for tap
+// interfaces when ScanInterval is unset.
+const DefaultScanInterval = 30 * time.Second
+
 var (
-	activeSniffersMu sync.Mutex
-	activeSniffers   = make(map[string]SnifferInfo)
-)
+	defaultTapRegexp = regexp.MustCompile(DefaultTapPattern)
 
-func ListActiveSniffers() map[string]time.Time {
-	activeSniffersMu.Lock()
-	defer activeSniffersMu.Unlock()
+	ErrSnifferNotFound  = errors.New("sniffer not found")
+	ErrSnifferPaused    = errors.New("sniffer already paused")
+	ErrSnifferNotPaused = errors.New("sniffer not paused")
+	ErrSnifferStopped   = errors.New("sniffer manager stopped")
+	sampledLog          = logger.NewSampler(logger.Default(), 10)
+	startSniffer        = sniffNAWithContext
+	startARPSniffer     = sniffARPWithContext
+	listTapInterfaces   = getTapInterfaces
+)
 
-	result := make(map[string]time.Time)
-	for iface, info := range activeSniffers {
-		result[iface] = info.StartedAt
+func neighborFamily(ip net.IP) int {
+	if ip.To4() != nil {
+		return netlink.FAMILY_V4
 	}
-	return result
+	return netlink.FAMILY_V6
 }
 
 func neighborAlreadyValid(ip net.IP) (bool, string) {
-	neighbors, err := netlink.NeighList(0, netlink.FAMILY_V6)
+	neighbors, err := netlink.NeighList(0, neighborFamily(ip))
 	if err != nil {
 		logger.Error("[Sniffer-Event] Failed to get neighbor list: %v", err)
 		return false, ""
@@ -46,21 +130,86 @@ func neighborAlreadyValid(ip net.IP) (bool, string) {
 	for _, neigh := range neighbors {
 		if neigh.IP.Equal(ip) {
 			switch neigh.State {
-			case netlink.NUD_REACHABLE:
-				return true, "REACHABLE"
-			case netlink.NUD_STALE:
-				return true, "STALE"
-			case netlink.NUD_DELAY:
-				return true, "DELAY"
-			case netlink.NUD_PROBE:
-				return true, "PROBE"
+			case netlink.NUD_REACHABLE, netlink.NUD_STALE, netlink.NUD_DELAY, netlink.NUD_PROBE:
+				return true, neighbor.NUDStateString(neigh.State)
 			}
 		}
 	}
 	return false, ""
 }
 
-func addNeighborEntry(ip net.IP, mac net.HardwareAddr, sniffIface string) {
+// neighFlags are the names accepted by ParseNeighFlags.
+var neighFlags = map[string]int{
+	"none":        0,
+	"ext_learned": netlink.NTF_EXT_LEARNED,
+	"router":      netlink.NTF_ROUTER,
+}
+
+// ParseNeighFlags turns none, ext_learned or router into the flags set on
+// the neighbor entries added by sniffers. The neighbor manager skips
+// the ext_learned entries the sniffers did not add, see Injected.
+func ParseNeighFlags(s string) (int, error) {
+	flags, ok := neighFlags[strings.ToLower(strings.TrimSpace(s))]
+	if !ok {
+		return 0, fmt.Errorf("unknown neighbor flags %q, expected none, ext_learned or router", s)
+	}
+	return flags, nil
+}
+
+// ExtLearned reports whether the sniffers flag their entries
+// NTF_EXT_LEARNED.
+func (sm *SnifferManager) ExtLearned() bool {
+	return sm.NeighFlags&netlink.NTF_EXT_LEARNED != 0
+}
+
+// Injected reports whether a sniffer added the ext_learned neighbor entry
+// of ip on linkIndex, telling it apart from the entries of other control
+// planes such as EVPN.
+func (sm *SnifferManager) Injected(ip net.IP, linkIndex int) bool {
+	return sm.injected.contains(ip, linkIndex)
+}
+
+// Forget drops the entry of ip on linkIndex from the ones Injected
+// reports, once the kernel deleted it.
+func (sm *SnifferManager) Forget(ip net.IP, linkIndex int) {
+	sm.injected.remove(ip, linkIndex)
+}
+
+// injectedNeighbors is the set of ext_learned neighbor entries the sniffers
+// added, keyed by IP and link.
+type injectedNeighbors struct {
+	mu      sync.Mutex
+	entries map[string]struct{}
+}
+
+func newInjectedNeighbors() *injectedNeighbors {
+	return &injectedNeighbors{entries: make(map[string]struct{})}
+}
+
+func injectedKey(ip net.IP, linkIndex int) string {
+	return fmt.Sprintf("%s@%d", ip, linkIndex)
+}
+
+func (in *injectedNeighbors) add(ip net.IP, linkIndex int) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.entries[injectedKey(ip, linkIndex)] = struct{}{}
+}
+
+func (in *injectedNeighbors) remove(ip net.IP, linkIndex int) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	delete(in.entries, injectedKey(ip, linkIndex))
+}
+
+func (in *injectedNeighbors) contains(ip net.IP, linkIndex int) bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	_, ok := in.entries[injectedKey(ip, linkIndex)]
+	return ok
+}
+
+func addNeighborEntry(ip net.IP, mac net.HardwareAddr, sniffIface string, info *SnifferInfo) {
 	link, err := netlink.LinkByName(sniffIface)
 	if err != nil {
 		logger.Error("[Sniffer-Event] Could not find interface %s: %v", sniffIface, err)
@@ -72,17 +221,35 @@ func addNeighborEntry(ip net.IP, mac net.HardwareAddr, sniffIface string) {
 		IP:           ip,
 		HardwareAddr: mac,
 		State:        netlink.NUD_REACHABLE,
-		Family:       netlink.FAMILY_V6,
+		Flags:        info.neighFlags,
+		Family:       neighborFamily(ip),
+	}
+
+	// Recorded first, the neighbor manager may see the entry before
+	// NeighSet returns.
+	if neigh.Flags&netlink.NTF_EXT_LEARNED != 0 && info.injected != nil {
+		info.injected.add(ip, neigh.LinkIndex)
 	}
 
 	if err := netlink.NeighSet(neigh); err != nil {
 		logger.Error("[Sniffer-Event] Failed to set neighbor entry for %s: %v", ip.String(), err)
-	} else {
-		logger.Info("[Sniffer-Event] Added neighbor entry: %s → %s on %s", ip.String(), mac.String(), sniffIface)
+		return
 	}
+
+	logger.InfoFields("[Sniffer-Event] Added neighbor entry", map[string]interface{}{
+		"ip":        ip.String(),
+		"mac":       mac.String(),
+		"interface": sniffIface,
+	})
+	info.NeighborsAdded.Add(1)
 }
 
-func handlePacket(packet gopacket.Packet, sniffIface string, insertIface string) {
+func handlePacket(packet gopacket.Packet, sniffIface string, insertIface string, info *SnifferInfo) {
+	info.PacketsReceived.Add(1)
+	if info.dump != nil {
+		info.dump.WritePacket(packet)
+	}
+
 	ipv6Layer := packet.Layer(layers.LayerTypeIPv6)
 	icmpv6Layer := packet.Layer(layers.LayerTypeICMPv6NeighborAdvertisement)
 	ethLayer := packet.Layer(layers.LayerTypeEthernet)
@@ -90,6 +257,7 @@ func handlePacket(packet gopacket.Packet, sniffIface string, insertIface string)
 	if ipv6Layer == nil || icmpv6Layer == nil {
 		return
 	}
+	info.PacketsMatched.Add(1)
 
 	ipv6 := ipv6Layer.(*layers.IPv6)
 	icmpv6 := icmpv6Layer.(*layers.ICMPv6NeighborAdvertisement)
@@ -97,11 +265,13 @@ func handlePacket(packet gopacket.Packet, sniffIface string, insertIface string)
 	targetIP := icmpv6.TargetAddress
 
 	if srcIP.IsLinkLocalUnicast() || targetIP.IsLinkLocalUnicast() {
+		info.PacketsSkipped.Add(1)
 		return
 	}
 
 	if exists, state := neighborAlreadyValid(targetIP); exists {
-		logger.Debug("[Sniffer-Event] [%s] Skipping %s — neighbor already exists with state %s", sniffIface, targetIP.String(), state)
+		sampledLog.Debug("[Sniffer-Event] [%s] Skipping %s — neighbor already exists with state %s", sniffIface, targetIP.String(), state)
+		info.PacketsSkipped.Add(1)
 		return
 	}
 
@@ -114,13 +284,75 @@ func handlePacket(packet gopacket.Packet, sniffIface string, insertIface string)
 		logger.Debug("[Sniffer-Event] [%s] No DLO in NA, using Ethernet src MAC: %s", sniffIface, mac.String())
 	} else {
 		logger.Debug("[Sniffer-Event] [%s] NA received but no MAC info available", sniffIface)
+		info.PacketsSkipped.Add(1)
 		return
 	}
 
-	addNeighborEntry(targetIP, mac, insertIface)
+	addNeighborEntry(targetIP, mac, insertIface, info)
 }
 
-func sniffNAWithContext(ctx context.Context, sniffIface string, insertIface string) {
+func handleARPPacket(packet gopacket.Packet, sniffIface string, insertIface string, info *SnifferInfo) {
+	info.PacketsReceived.Add(1)
+
+	arpLayer := packet.Layer(layers.LayerTypeARP)
+	if arpLayer == nil {
+		return
+	}
+
+	arp := arpLayer.(*layers.ARP)
+	if arp.Operation != layers.ARPReply || len(arp.SourceProtAddress) != net.IPv4len {
+		return
+	}
+	info.PacketsMatched.Add(1)
+
+	senderIP := net.IP(arp.SourceProtAddress)
+	senderMAC := net.HardwareAddr(arp.SourceHwAddress)
+
+	if senderIP.IsUnspecified() || senderIP.IsLinkLocalUnicast() {
+		info.PacketsSkipped.Add(1)
+		return
+	}
+
+	if exists, state := neighborAlreadyValid(senderIP); exists {
+		sampledLog.Debug("[Sniffer-Event] [%s] Skipping %s — neighbor already exists with state %s", sniffIface, senderIP.String(), state)
+		info.PacketsSkipped.Add(1)
+		return
+	}
+
+	addNeighborEntry(senderIP, senderMAC, insertIface, info)
+}
+
+// ValidateBPFFilter reports whether filter compiles for Ethernet captures.
+func ValidateBPFFilter(filter string) error {
+	_, err := pcap.CompileBPFFilter(layers.LinkTypeEthernet, snapLen, filter)
+	return err
+}
+
+func sniffNAWithContext(ctx context.Context, sniffIface string, insertIface string, info *SnifferInfo) {
+	filter := info.naFilter
+	if filter == "" {
+		filter = DefaultNAFilter
+	}
+
+	capturePackets(ctx, sniffIface, filter, "NA", func(pkt gopacket.Packet) {
+		handlePacket(pkt, sniffIface, insertIface, info)
+	})
+}
+
+func sniffARPWithContext(ctx context.Context, sniffIface string, insertIface string, info *SnifferInfo) {
+	filter := info.arpFilter
+	if filter == "" {
+		filter = DefaultARPFilter
+	}
+
+	capturePackets(ctx, sniffIface, filter, "ARP reply", func(pkt gopacket.Packet) {
+		handleARPPacket(pkt, sniffIface, insertIface, info)
+	})
+}
+
+// capturePackets waits for sniffIface to come up, then passes every packet
+// matching filter to handle until ctx is cancelled.
+func capturePackets(ctx context.Context, sniffIface string, filter string, kind string, handle func(gopacket.Packet)) {
 	for attempt := 0; attempt < 10; attempt++ {
 		link, err := netlink.LinkByName(sniffIface)
 		if err == nil && (link.Attrs().Flags&net.FlagUp) != 0 {
@@ -135,87 +367,57 @@ func sniffNAWithContext(ctx context.Context, sniffIface string, insertIface stri
 		}
 	}
 
-	handle, err := pcap.OpenLive(sniffIface, 1600, true, pcap.BlockForever)
+	pcapHandle, err := pcap.OpenLive(sniffIface, snapLen, true, pcap.BlockForever)
 	if err != nil {
 		logger.Error("[Sniffer-Event] Error opening interface %s: %v", sniffIface, err)
 		return
 	}
-	defer handle.Close()
+	defer pcapHandle.Close()
 
-	filter := "inbound and icmp6 and ip6[40] == 136"
-	if err := handle.SetBPFFilter(filter); err != nil {
+	if err := pcapHandle.SetBPFFilter(filter); err != nil {
 		logger.Error("[Sniffer-Event] Error setting BPF filter on %s: %v", sniffIface, err)
 		return
 	}
 
-	logger.Info("[Sniffer-Event] Listening for NA packets on %s", sniffIface)
-	packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
+	logger.Info("[Sniffer-Event] Listening for %s packets on %s with filter %q", kind, sniffIface, filter)
+	packetSource := gopacket.NewPacketSource(pcapHandle, pcapHandle.LinkType())
 	packetChan := packetSource.Packets()
 
 	for {
 		select {
 		case <-ctx.Done():
-			logger.Info("[Sniffer-Event] Stopping sniffer on %s", sniffIface)
+			logger.Info("[Sniffer-Event] Stopping %s sniffer on %s", kind, sniffIface)
 			return
 		case pkt := <-packetChan:
 			if pkt == nil {
 				return
 			}
-			handlePacket(pkt, sniffIface, insertIface)
+			handle(pkt)
 		}
 	}
 }
 
-func getTapInterfaces() []string {
+// getTapInterfaces lists the interfaces whose name matches any of patterns.
+func getTapInterfaces(patterns []*regexp.Regexp) []string {
 	entries, err := os.ReadDir("/sys/class/net/")
 	if err != nil {
 		logger.Fatal("[Sniffer-Event] Failed to list interfaces: %v", err)
 	}
 
 	var tapIfaces []string
-	re := regexp.MustCompile(`^tap\d+`)
 	for _, entry := range entries {
-		if re.MatchString(entry.Name()) {
+		if matchesAny(patterns, entry.Name()) {
 			tapIfaces = append(tapIfaces, entry.Name())
 		}
 	}
 	return tapIfaces
 }
 
-func StartSnifferManager(targetIface string) {
-	logger.Info("Starting NA sniffer. Scanning for tap interfaces every 30 seconds...")
-
-	for {
-		currentIfaces := getTapInterfaces()
-		currentSet := make(map[string]bool)
-		for _, sniffIface := range currentIfaces {
-			currentSet[sniffIface] = true
-		}
-
-		for sniffIface := range currentSet {
-			if _, exists := activeSniffers[sniffIface]; !exists {
-				logger.Info("[Sniffer-Event] New tap detected: %s — starting sniffer", sniffIface)
-				ctx, cancel := context.WithCancel(context.Background())
-				activeSniffersMu.Lock()
-				activeSniffers[sniffIface] = SnifferInfo{
-					CancelFunc: cancel,
-					StartedAt:  time.Now(),
-				}
-				activeSniffersMu.Unlock()
-				go sniffNAWithContext(ctx, sniffIface, targetIface)
-			}
+func matchesAny(patterns []*regexp.Regexp, name string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(name) {
+			return true
 		}
-
-		for sniffIface, info := range activeSniffers {
-			if !currentSet[sniffIface] {
-				logger.Info("[Sniffer-Event] Tap removed: %s — stopping sniffer", sniffIface)
-				info.CancelFunc()
-				activeSniffersMu.Lock()
-				delete(activeSniffers, sniffIface)
-				activeSniffersMu.Unlock()
-			}
-		}
-
-		time.Sleep(30 * time.Second)
 	}
+	return false
 }
@@ -0,0 +1,203 @@
+package sniffer
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/vishvananda/netlink"
+)
+
+// Helper function to build an Ethernet frame that is not a Neighbor Advertisement
+func nonNAPacket(t testing.TB) gopacket.Packet {
+	buf := gopacket.NewSerializeBuffer()
+	eth := &layers.Ethernet{
+		SrcMAC:       []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		DstMAC:       []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		EthernetType: layers.EthernetTypeARP,
+	}
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, eth, gopacket.Payload(make([]byte, 28))); err != nil {
+		t.Fatalf("failed to serialize packet: %v", err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func TestHandlePacketCountsReceived(t *testing.T) {
+	info := &SnifferInfo{StartedAt: time.Now(), SnifferStats: &SnifferStats{}}
+	pkt := nonNAPacket(t)
+
+	for i := 0; i < 5; i++ {
+		handlePacket(pkt, "tap0", "lo", info)
+	}
+
+	if got := info.PacketsReceived.Load(); got != 5 {
+		t.Errorf("Expected 5 packets received, got %d", got)
+	}
+
+	if got := info.PacketsMatched.Load(); got != 0 {
+		t.Errorf("Expected 0 packets matched, got %d", got)
+	}
+
+	if got := info.NeighborsAdded.Load(); got != 0 {
+		t.Errorf("Expected 0 neighbors added, got %d", got)
+	}
+}
+
+// TestSnifferInfoConcurrentAccess is meant to be run with -race
+func TestSnifferInfoConcurrentAccess(t *testing.T) {
+	info := &SnifferInfo{StartedAt: time.Now(), SnifferStats: &SnifferStats{}}
+	sm := NewSnifferManager("lo")
+	sm.sniffers["tap-race"] = info
+
+	pkt := nonNAPacket(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 250; j++ {
+				handlePacket(pkt, "tap-race", "lo", info)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 250; j++ {
+				sm.ListActiveSniffers()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := info.PacketsReceived.Load(); got != 1000 {
+		t.Errorf("Expected 1000 packets received, got %d", got)
+	}
+}
+
+// mutexSnifferInfo mirrors SnifferInfo with mutex-guarded counters for benchmarking
+type mutexSnifferInfo struct {
+	mu              sync.Mutex
+	packetsReceived int64
+}
+
+func BenchmarkSnifferStatsMutex(b *testing.B) {
+	info := &mutexSnifferInfo{}
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			info.mu.Lock()
+			info.packetsReceived++
+			info.mu.Unlock()
+		}
+	})
+}
+
+func BenchmarkSnifferStatsAtomic(b *testing.B) {
+	var packetsReceived atomic.Int64
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			packetsReceived.Add(1)
+		}
+	})
+}
+
+// Helper function to build an ARP packet with the given operation
+func arpPacket(t testing.TB, operation uint16) gopacket.Packet {
+	buf := gopacket.NewSerializeBuffer()
+	eth := &layers.Ethernet{
+		SrcMAC:       []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		DstMAC:       []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		EthernetType: layers.EthernetTypeARP,
+	}
+	arp := &layers.ARP{
+		AddrType:          layers.LinkTypeEthernet,
+		Protocol:          layers.EthernetTypeIPv4,
+		HwAddressSize:     6,
+		ProtAddressSize:   4,
+		Operation:         operation,
+		SourceHwAddress:   []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		SourceProtAddress: []byte{169, 254, 0, 1},
+		DstHwAddress:      []byte{0, 0, 0, 0, 0, 0},
+		DstProtAddress:    []byte{169, 254, 0, 2},
+	}
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, eth, arp); err != nil {
+		t.Fatalf("failed to serialize packet: %v", err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func TestHandleARPPacketIgnoresNonReplies(t *testing.T) {
+	info := &SnifferInfo{StartedAt: time.Now(), SnifferStats: &SnifferStats{}}
+
+	handleARPPacket(arpPacket(t, layers.ARPRequest), "tap0", "lo", info)
+	handleARPPacket(nonNAPacket(t), "tap0", "lo", info)
+	// Link-local senders are skipped like link-local NA targets.
+	handleARPPacket(arpPacket(t, layers.ARPReply), "tap0", "lo", info)
+
+	if got := info.PacketsReceived.Load(); got != 3 {
+		t.Errorf("Expected 3 packets received, got %d", got)
+	}
+
+	if got := info.PacketsMatched.Load(); got != 1 {
+		t.Errorf("Expected 1 packet matched, got %d", got)
+	}
+
+	if got := info.PacketsSkipped.Load(); got != 1 {
+		t.Errorf("Expected 1 packet skipped, got %d", got)
+	}
+
+	if got := info.NeighborsAdded.Load(); got != 0 {
+		t.Errorf("Expected 0 neighbors added, got %d", got)
+	}
+}
+
+func TestValidateBPFFilterRejectsInvalid(t *testing.T) {
+	if err := ValidateBPFFilter("icmp6 and and"); err == nil {
+		t.Error("Expected an error for an invalid filter")
+	}
+}
+
+func TestParseNeighFlags(t *testing.T) {
+	tests := map[string]int{
+		"none":        0,
+		"ext_learned": netlink.NTF_EXT_LEARNED,
+		"Router":      netlink.NTF_ROUTER,
+	}
+	for input, want := range tests {
+		got, err := ParseNeighFlags(input)
+		if err != nil {
+			t.Errorf("ParseNeighFlags(%q): unexpected error %v", input, err)
+		} else if got != want {
+			t.Errorf("ParseNeighFlags(%q) = %#x, want %#x", input, got, want)
+		}
+	}
+
+	if _, err := ParseNeighFlags("managed"); err == nil {
+		t.Error("Expected an error for an unknown flag")
+	}
+}
+
+func TestInjectedRecordsExtLearnedEntries(t *testing.T) {
+	sm := NewSnifferManager("lo")
+	sm.NeighFlags = netlink.NTF_EXT_LEARNED
+	info := &SnifferInfo{neighFlags: sm.NeighFlags, injected: sm.injected, SnifferStats: &SnifferStats{}}
+
+	ip := net.ParseIP("2001:db8:71::1")
+	mac, _ := net.ParseMAC("02:00:00:00:71:01")
+	addNeighborEntry(ip, mac, "lo", info)
+	defer netlink.NeighDel(&netlink.Neigh{LinkIndex: 1, IP: ip, Family: netlink.FAMILY_V6})
+
+	if !sm.Injected(ip, 1) {
+		t.Errorf("Expected the ext_learned entry of %s on lo to be recorded", ip)
+	}
+	if sm.Injected(ip, 2) || sm.Injected(net.ParseIP("2001:db8:71::2"), 1) {
+		t.Errorf("Expected only the added entry to be recorded")
+	}
+
+	sm.Forget(ip, 1)
+	if sm.Injected(ip, 1) || len(sm.injected.entries) != 0 {
+		t.Errorf("Expected the entry of %s to be forgotten", ip)
+	}
+}
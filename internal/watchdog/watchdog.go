@@ -0,0 +1,76 @@
+package watchdog
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+)
+
+const (
+	DefaultInterval = 60 * time.Second
+
+	// FatalAfter is the number of consecutive over-threshold checks that
+	// terminate the process.
+	FatalAfter = 3
+)
+
+var (
+	numGoroutine = runtime.NumGoroutine
+	fatal        = logger.Fatal
+)
+
+// GoroutineWatchdog warns when the goroutine count exceeds Max and exits
+// once it has stayed above Max for FatalAfter consecutive checks.
+type GoroutineWatchdog struct {
+	Max      int
+	Interval time.Duration
+
+	consecutive int
+}
+
+func New(max int, interval time.Duration) *GoroutineWatchdog {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &GoroutineWatchdog{Max: max, Interval: interval}
+}
+
+// Run checks the goroutine count every Interval until ctx is cancelled.
+// A Max of 0 or less disables the watchdog.
+func (w *GoroutineWatchdog) Run(ctx context.Context) {
+	if w.Max <= 0 {
+		return
+	}
+
+	logger.Info("Goroutine watchdog enabled: max %d, checking every %s", w.Max, w.Interval)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+func (w *GoroutineWatchdog) check() {
+	count := numGoroutine()
+	if count <= w.Max {
+		w.consecutive = 0
+		return
+	}
+
+	w.consecutive++
+	if w.consecutive >= FatalAfter {
+		fatal("Goroutine count %d exceeded %d for %d consecutive checks", count, w.Max, w.consecutive)
+		return
+	}
+
+	logger.Warn("Goroutine count %d exceeds threshold %d (%d/%d)", count, w.Max, w.consecutive, FatalAfter)
+}
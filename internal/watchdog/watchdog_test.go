@@ -0,0 +1,103 @@
+package watchdog
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Helper function to replace the goroutine counter and fatal hook
+func stubWatchdog(t *testing.T, counts []int) *int {
+	origCount, origFatal := numGoroutine, fatal
+	t.Cleanup(func() {
+		numGoroutine, fatal = origCount, origFatal
+	})
+
+	i := 0
+	numGoroutine = func() int {
+		c := counts[i]
+		if i < len(counts)-1 {
+			i++
+		}
+		return c
+	}
+
+	fatals := 0
+	fatal = func(format string, v ...interface{}) {
+		fatals++
+	}
+	return &fatals
+}
+
+func TestWatchdogFatalAfterConsecutiveChecks(t *testing.T) {
+	fatals := stubWatchdog(t, []int{150, 150, 150})
+	w := New(100, time.Second)
+
+	w.check()
+	w.check()
+	if *fatals != 0 {
+		t.Fatalf("Expected no fatal after 2 checks, got %d", *fatals)
+	}
+
+	w.check()
+	if *fatals != 1 {
+		t.Errorf("Expected fatal after %d checks, got %d", FatalAfter, *fatals)
+	}
+}
+
+func TestWatchdogResetsBelowThreshold(t *testing.T) {
+	fatals := stubWatchdog(t, []int{150, 150, 50, 150, 150})
+	w := New(100, time.Second)
+
+	for i := 0; i < 5; i++ {
+		w.check()
+	}
+
+	if *fatals != 0 {
+		t.Errorf("Expected no fatal when count dips below threshold, got %d", *fatals)
+	}
+	if w.consecutive != 2 {
+		t.Errorf("Expected 2 consecutive over-threshold checks, got %d", w.consecutive)
+	}
+}
+
+func TestWatchdogRunStopsOnCancel(t *testing.T) {
+	fatals := stubWatchdog(t, []int{150})
+	w := New(100, 5*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Run to return after cancel")
+	}
+
+	if *fatals == 0 {
+		t.Errorf("Expected fatal to fire while running over threshold")
+	}
+}
+
+func TestWatchdogDisabled(t *testing.T) {
+	w := New(0, time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		w.Run(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected disabled watchdog to return immediately")
+	}
+}
@@ -0,0 +1,229 @@
+// Package neigh2route embeds the neigh2route daemon's neighbor-to-route
+// logic: it watches the kernel neighbor table and installs a host route to
+// every reachable neighbor on the link it was learned on.
+package neigh2route
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+	"github.com/hostinger/neigh2route/internal/neighbor"
+	"github.com/hostinger/neigh2route/pkg/netutils"
+)
+
+// ErrStarted is returned by Start when the Manager is already running or
+// was stopped.
+var ErrStarted = errors.New("neigh2route: manager already started")
+
+// Route is a route installed for one neighbor.
+type Route struct {
+	// Neighbor is the neighbor's IP and Dst the route destination, the
+	// neighbor's host route unless a prefix length was set.
+	Neighbor net.IP
+	Dst      *net.IPNet
+
+	// LinkIndexes lists the links the route goes out of; more than one
+	// with WithECMP.
+	LinkIndexes  []int
+	HardwareAddr net.HardwareAddr
+	Table        int
+	Metric       uint32
+}
+
+// Option customizes a Manager.
+type Option func(*options)
+
+type options struct {
+	cfg        neighbor.Config
+	keepRoutes bool
+}
+
+// WithInterfaces only follows neighbors on the named interfaces instead of
+// every interface.
+func WithInterfaces(names ...string) Option {
+	return func(o *options) {
+		o.cfg.TargetInterfaces = append(o.cfg.TargetInterfaces, names...)
+	}
+}
+
+// WithRouteTable installs routes into table instead of the main table.
+func WithRouteTable(table int) Option {
+	return func(o *options) {
+		o.cfg.RouteTable = table
+	}
+}
+
+// WithRouteMetric sets the priority of installed routes.
+func WithRouteMetric(metric uint32) Option {
+	return func(o *options) {
+		o.cfg.RouteMetric = metric
+	}
+}
+
+// WithRouteProtocol marks installed routes with protocol, so they can be
+// told apart from routes of other daemons.
+func WithRouteProtocol(protocol int) Option {
+	return func(o *options) {
+		o.cfg.RouteProtocol = protocol
+	}
+}
+
+// WithPrefixLen routes the /ipv4 or /ipv6 prefix around each neighbor
+// instead of a host route. Zero keeps the host route for that family.
+func WithPrefixLen(ipv4, ipv6 int) Option {
+	return func(o *options) {
+		o.cfg.IPv4PrefixLen = ipv4
+		o.cfg.IPv6PrefixLen = ipv6
+	}
+}
+
+// WithAllowPrefixes only routes neighbors inside prefixes.
+func WithAllowPrefixes(prefixes ...*net.IPNet) Option {
+	return func(o *options) {
+		o.cfg.Policy.AllowPrefixes = append(o.cfg.Policy.AllowPrefixes, prefixes...)
+	}
+}
+
+// WithECMP keeps a neighbor seen on several links reachable over all of them
+// instead of moving its route to the latest link.
+func WithECMP() Option {
+	return func(o *options) {
+		o.cfg.ECMP = true
+	}
+}
+
+// WithPingInterval sets how often every neighbor is pinged to keep its
+// kernel entry fresh.
+func WithPingInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.cfg.PingInterval = interval
+	}
+}
+
+// WithDryRun logs routes instead of changing the routing table.
+func WithDryRun() Option {
+	return func(o *options) {
+		o.cfg.DryRun = true
+	}
+}
+
+// WithKeepRoutes leaves the installed routes in place on Stop instead of
+// removing them.
+func WithKeepRoutes() Option {
+	return func(o *options) {
+		o.keepRoutes = true
+	}
+}
+
+// Manager follows the kernel neighbor table and keeps a route to every
+// reachable neighbor.
+type Manager struct {
+	nm         *neighbor.NeighborManager
+	keepRoutes bool
+
+	// ctx bounds every route operation; cancel is called by Stop or once
+	// the context passed to Start is done.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	started  bool
+	stopped  bool
+	wg       sync.WaitGroup
+	stopWait func() bool
+}
+
+// New returns a Manager configured by opts. It does not touch the routing
+// table until Start.
+func New(opts ...Option) (*Manager, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cfg.Context = ctx
+
+	nm, err := neighbor.NewNeighborManagerFromConfig(o.cfg)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &Manager{nm: nm, keepRoutes: o.keepRoutes, ctx: ctx, cancel: cancel}, nil
+}
+
+// Start adds routes for the neighbors already in the kernel table, then
+// follows neighbor updates in the background until ctx is done or Stop is
+// called. A Manager can only be started once.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.started || m.stopped {
+		return ErrStarted
+	}
+	m.started = true
+	m.stopWait = context.AfterFunc(ctx, m.cancel)
+
+	if err := m.nm.InitializeNeighborTable(); err != nil {
+		return err
+	}
+
+	m.wg.Add(3)
+	go func() {
+		defer m.wg.Done()
+		if err := netutils.WatchInterfaceCache(m.ctx); err != nil {
+			logger.Error("Interface cache can go stale after link changes: %v", err)
+		}
+	}()
+	go func() {
+		defer m.wg.Done()
+		m.nm.MonitorNeighbors(m.ctx)
+	}()
+	go func() {
+		defer m.wg.Done()
+		m.nm.SendPings(m.ctx)
+	}()
+	return nil
+}
+
+// Stop ends neighbor monitoring and removes the installed routes, unless
+// WithKeepRoutes was given. Calling it more than once is a no-op.
+func (m *Manager) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopped {
+		return nil
+	}
+	m.stopped = true
+
+	m.cancel()
+	if m.stopWait != nil {
+		m.stopWait()
+	}
+	m.wg.Wait()
+
+	if m.keepRoutes {
+		m.nm.KeepRoutes()
+	} else {
+		m.nm.Cleanup()
+	}
+	return nil
+}
+
+// Routes returns the routes of every known neighbor sorted by neighbor IP.
+func (m *Manager) Routes() []Route {
+	infos := m.nm.ExportRoutes()
+
+	routes := make([]Route, 0, len(infos))
+	for _, info := range infos {
+		routes = append(routes, Route(info))
+	}
+	return routes
+}
@@ -0,0 +1,69 @@
+package neigh2route
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewRejectsMissingInterface(t *testing.T) {
+	if _, err := New(WithInterfaces("n2r-missing0")); err == nil {
+		t.Errorf("Expected an error for a missing interface")
+	}
+}
+
+func TestManagerLifecycle(t *testing.T) {
+	m, err := New(WithInterfaces("lo"), WithDryRun())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Expected Start to succeed, got %v", err)
+	}
+	if err := m.Start(context.Background()); !errors.Is(err, ErrStarted) {
+		t.Errorf("Expected ErrStarted on a second Start, got %v", err)
+	}
+	if routes := m.Routes(); len(routes) != 0 {
+		t.Errorf("Expected no routes on lo, got %+v", routes)
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- m.Stop() }()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("Expected Stop to succeed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+
+	if err := m.Stop(); err != nil {
+		t.Errorf("Expected a second Stop to be a no-op, got %v", err)
+	}
+	if err := m.Start(context.Background()); !errors.Is(err, ErrStarted) {
+		t.Errorf("Expected ErrStarted after Stop, got %v", err)
+	}
+}
+
+func TestManagerStopsWithStartContext(t *testing.T) {
+	m, err := New(WithInterfaces("lo"), WithDryRun())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Expected Start to succeed, got %v", err)
+	}
+	cancel()
+
+	select {
+	case <-m.ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected cancelling the Start context to stop the manager")
+	}
+	m.Stop()
+}
@@ -0,0 +1,113 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hostinger/neigh2route/internal/logger"
+	"github.com/vishvananda/netlink"
+)
+
+var (
+	interfaceCache  sync.Map
+	lookupInterface = net.InterfaceByIndex
+	linkSubscribe   = netlink.LinkSubscribe
+
+	// cacheGeneration changes on every eviction, so a lookup that raced
+	// with one does not store what may be the old link.
+	cacheGeneration atomic.Uint64
+)
+
+// InterfaceByIndex is a cached net.InterfaceByIndex. Run
+// WatchInterfaceCache to evict entries when links are renamed, deleted or
+// their index is reused.
+func InterfaceByIndex(linkIndex int) (*net.Interface, error) {
+	if cached, ok := interfaceCache.Load(linkIndex); ok {
+		return cached.(*net.Interface), nil
+	}
+
+	generation := cacheGeneration.Load()
+	iface, err := lookupInterface(linkIndex)
+	if err != nil {
+		return nil, err
+	}
+	if cacheGeneration.Load() != generation {
+		return iface, nil
+	}
+
+	actual, _ := interfaceCache.LoadOrStore(linkIndex, iface)
+	return actual.(*net.Interface), nil
+}
+
+func FlushInterfaceCache() {
+	cacheGeneration.Add(1)
+	interfaceCache.Range(func(key, _ any) bool {
+		interfaceCache.Delete(key)
+		return true
+	})
+}
+
+// evictInterface drops the cached interface of linkIndex.
+func evictInterface(linkIndex int) {
+	cacheGeneration.Add(1)
+	interfaceCache.Delete(linkIndex)
+}
+
+// WatchInterfaceCache evicts the cached interface of every link the kernel
+// reports as added, changed or deleted until ctx is done. It fails if the
+// link subscription cannot be set up or closes early.
+func WatchInterfaceCache(ctx context.Context) error {
+	updates := make(chan netlink.LinkUpdate)
+	done := make(chan struct{})
+	if err := linkSubscribe(updates, done); err != nil {
+		return fmt.Errorf("failed to subscribe to link updates: %w", err)
+	}
+	defer func() {
+		close(done)
+		// Let the netlink reader exit instead of blocking on a send.
+		go func() {
+			for range updates {
+			}
+		}()
+	}()
+
+	// Links may have changed before the subscription started.
+	FlushInterfaceCache()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update, ok := <-updates:
+			if !ok {
+				return errors.New("link updates channel closed")
+			}
+			logger.Debug("Link %d changed, evicting it from the interface cache", update.Index)
+			evictInterface(int(update.Index))
+		}
+	}
+}
+
+// getInterfaceLinkLocal returns the first fe80::/10 address configured on
+// iface, the source address Neighbor Solicitations must be sent from.
+func getInterfaceLinkLocal(iface string) (net.IP, error) {
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", iface, err)
+	}
+
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_V6)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IPv6 addresses of %s: %w", iface, err)
+	}
+	for _, addr := range addrs {
+		if addr.IP.IsLinkLocalUnicast() {
+			return addr.IP, nil
+		}
+	}
+	return nil, fmt.Errorf("interface %s has no IPv6 link-local address", iface)
+}
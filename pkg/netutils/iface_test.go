@@ -0,0 +1,184 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+)
+
+func TestInterfaceByIndexCachesLookups(t *testing.T) {
+	FlushInterfaceCache()
+	defer FlushInterfaceCache()
+
+	origLookup := lookupInterface
+	defer func() { lookupInterface = origLookup }()
+
+	lookups := 0
+	lookupInterface = func(index int) (*net.Interface, error) {
+		lookups++
+		return origLookup(index)
+	}
+
+	first, err := InterfaceByIndex(1)
+	if err != nil {
+		t.Fatalf("failed to look up loopback: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		iface, err := InterfaceByIndex(1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if iface != first || iface.Name != first.Name {
+			t.Errorf("expected cached interface %s, got %s", first.Name, iface.Name)
+		}
+	}
+
+	if lookups != 1 {
+		t.Errorf("expected 1 underlying lookup, got %d", lookups)
+	}
+
+	FlushInterfaceCache()
+	if _, err := InterfaceByIndex(1); err != nil {
+		t.Fatalf("unexpected error after flush: %v", err)
+	}
+	if lookups != 2 {
+		t.Errorf("expected a fresh lookup after flush, got %d lookups", lookups)
+	}
+}
+
+func TestInterfaceByIndexDoesNotCacheErrors(t *testing.T) {
+	FlushInterfaceCache()
+	defer FlushInterfaceCache()
+
+	origLookup := lookupInterface
+	defer func() { lookupInterface = origLookup }()
+
+	lookups := 0
+	lookupInterface = func(index int) (*net.Interface, error) {
+		lookups++
+		return nil, errors.New("no such interface")
+	}
+
+	InterfaceByIndex(9999)
+	if _, err := InterfaceByIndex(9999); err == nil {
+		t.Fatalf("expected error for unknown index")
+	}
+
+	if lookups != 2 {
+		t.Errorf("expected failed lookups to be retried, got %d lookups", lookups)
+	}
+}
+
+func BenchmarkInterfaceByIndexUncached(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		net.InterfaceByIndex(1)
+	}
+}
+
+func BenchmarkInterfaceByIndexCached(b *testing.B) {
+	FlushInterfaceCache()
+	defer FlushInterfaceCache()
+
+	for i := 0; i < b.N; i++ {
+		InterfaceByIndex(1)
+	}
+}
+
+func TestWatchInterfaceCacheEvictsChangedLinks(t *testing.T) {
+	FlushInterfaceCache()
+	defer FlushInterfaceCache()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- WatchInterfaceCache(ctx) }()
+	defer func() {
+		cancel()
+		if err := <-stopped; err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}()
+
+	link := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: "n2r-ic0"}}
+	if err := netlink.LinkAdd(link); err != nil {
+		t.Skipf("cannot create bridge link: %v", err)
+	}
+	defer func() { netlink.LinkDel(link) }()
+	index := link.Attrs().Index
+
+	// The watcher flushes the cache once it is subscribed, so wait for a
+	// lookup to stick before renaming.
+	waitCached := func(name string) bool {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			iface, err := InterfaceByIndex(index)
+			if err == nil && iface.Name == name {
+				if _, ok := interfaceCache.Load(index); ok {
+					return true
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+	if !waitCached("n2r-ic0") {
+		t.Fatalf("Expected n2r-ic0 to be cached")
+	}
+
+	if err := netlink.LinkSetName(link, "n2r-ic1"); err != nil {
+		t.Fatalf("failed to rename link: %v", err)
+	}
+	if !waitCached("n2r-ic1") {
+		t.Errorf("Expected the renamed link to be looked up again")
+	}
+
+	if err := netlink.LinkDel(link); err != nil {
+		t.Fatalf("failed to delete link: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := interfaceCache.Load(index); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the deleted link to be evicted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGetInterfaceLinkLocal(t *testing.T) {
+	link := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: "n2r-ll0"}}
+	if err := netlink.LinkAdd(link); err != nil {
+		t.Skipf("cannot create bridge link: %v", err)
+	}
+	defer netlink.LinkDel(link)
+
+	global, _ := netlink.ParseAddr("2001:db8::1/64")
+	linkLocal, _ := netlink.ParseAddr("fe80::1234/64")
+	for _, addr := range []*netlink.Addr{global, linkLocal} {
+		if err := netlink.AddrAdd(link, addr); err != nil {
+			t.Fatalf("failed to add %s: %v", addr, err)
+		}
+	}
+
+	ip, err := getInterfaceLinkLocal("n2r-ll0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !ip.Equal(net.ParseIP("fe80::1234")) {
+		t.Errorf("Expected fe80::1234, got %s", ip)
+	}
+
+	if _, err := getInterfaceLinkLocal("lo"); err == nil || !strings.Contains(err.Error(), "no IPv6 link-local address") {
+		t.Errorf("Expected a missing link-local error for lo, got %v", err)
+	}
+	if _, err := getInterfaceLinkLocal("n2r-missing0"); err == nil {
+		t.Errorf("Expected an error for a missing interface")
+	}
+}
@@ -0,0 +1,87 @@
+package netutils
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencySamples is how many of the most recent durations a latencyRing
+// keeps for quantiles.
+const latencySamples = 1000
+
+// LatencyQuantiles are the quantiles reported by LatencySnapshot.
+var LatencyQuantiles = []float64{0.5, 0.95, 0.99}
+
+// LatencySnapshot summarizes route operation durations in seconds. Count
+// and Sum cover every operation since startup; Quantiles only the most
+// recent latencySamples.
+type LatencySnapshot struct {
+	Count     uint64
+	Sum       float64
+	Quantiles map[float64]float64
+}
+
+// latencyRing is a bounded ring buffer of durations in seconds.
+type latencyRing struct {
+	mu      sync.Mutex
+	samples []float64
+	next    int
+	count   uint64
+	sum     float64
+}
+
+var (
+	routeAddLatency    = &latencyRing{}
+	routeRemoveLatency = &latencyRing{}
+)
+
+func (r *latencyRing) observe(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seconds := d.Seconds()
+	if len(r.samples) < latencySamples {
+		r.samples = append(r.samples, seconds)
+	} else {
+		r.samples[r.next] = seconds
+	}
+	r.next = (r.next + 1) % latencySamples
+	r.count++
+	r.sum += seconds
+}
+
+func (r *latencyRing) snapshot() LatencySnapshot {
+	r.mu.Lock()
+	sorted := append([]float64(nil), r.samples...)
+	snap := LatencySnapshot{Count: r.count, Sum: r.sum, Quantiles: make(map[float64]float64, len(LatencyQuantiles))}
+	r.mu.Unlock()
+
+	sort.Float64s(sorted)
+	for _, q := range LatencyQuantiles {
+		snap.Quantiles[q] = quantile(sorted, q)
+	}
+	return snap
+}
+
+// quantile returns the nearest-rank q-quantile of sorted, or NaN when it is
+// empty.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+// RouteAddLatency returns the durations of the kernel route add calls.
+func RouteAddLatency() LatencySnapshot {
+	return routeAddLatency.snapshot()
+}
+
+// RouteRemoveLatency returns the durations of the kernel route delete calls.
+func RouteRemoveLatency() LatencySnapshot {
+	return routeRemoveLatency.snapshot()
+}
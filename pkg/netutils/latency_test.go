@@ -0,0 +1,69 @@
+package netutils
+
+import (
+	"context"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLatencyRingQuantiles(t *testing.T) {
+	r := &latencyRing{}
+	if snap := r.snapshot(); snap.Count != 0 || !math.IsNaN(snap.Quantiles[0.5]) {
+		t.Fatalf("Expected an empty snapshot with NaN quantiles, got %+v", snap)
+	}
+
+	for i := 1; i <= 100; i++ {
+		r.observe(time.Duration(i) * time.Millisecond)
+	}
+
+	snap := r.snapshot()
+	if snap.Count != 100 {
+		t.Errorf("Expected count 100, got %d", snap.Count)
+	}
+
+	for q, want := range map[float64]float64{0.5: 0.050, 0.95: 0.095, 0.99: 0.099} {
+		if got := snap.Quantiles[q]; math.Abs(got-want) > 1e-9 {
+			t.Errorf("Expected p%v %v, got %v", q*100, want, got)
+		}
+	}
+}
+
+func TestLatencyRingKeepsRecentSamples(t *testing.T) {
+	r := &latencyRing{}
+	for i := 0; i < latencySamples; i++ {
+		r.observe(time.Second)
+	}
+	for i := 0; i < latencySamples; i++ {
+		r.observe(time.Millisecond)
+	}
+
+	snap := r.snapshot()
+	if snap.Count != 2*latencySamples {
+		t.Errorf("Expected count %d, got %d", 2*latencySamples, snap.Count)
+	}
+	if got := snap.Quantiles[0.99]; got != 0.001 {
+		t.Errorf("Expected old samples to be evicted, got p99 %v", got)
+	}
+}
+
+func TestRouteLatencyRecordedIntegration(t *testing.T) {
+	before := RouteAddLatency().Count
+	beforeRemove := RouteRemoveLatency().Count
+
+	ip := net.ParseIP("192.168.100.105")
+	if err := AddRoute(context.Background(), ip, 1); err != nil {
+		t.Fatalf("failed to add route: %v", err)
+	}
+	if err := RemoveRoute(context.Background(), ip, 1); err != nil {
+		t.Fatalf("failed to remove route: %v", err)
+	}
+
+	if got := RouteAddLatency().Count; got != before+1 {
+		t.Errorf("Expected one more add sample, got %d -> %d", before, got)
+	}
+	if got := RouteRemoveLatency().Count; got != beforeRemove+1 {
+		t.Errorf("Expected one more remove sample, got %d -> %d", beforeRemove, got)
+	}
+}
@@ -0,0 +1,117 @@
+package netutils
+
+import (
+	"errors"
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+// RouteNotifier announces routes as RTM_NEWROUTE/RTM_DELROUTE messages on the
+// RTNLGRP_IPV4_ROUTE and RTNLGRP_IPV6_ROUTE netlink multicast groups, where
+// tools like `ip monitor route` listen.
+type RouteNotifier struct {
+	sock *nl.NetlinkSocket
+}
+
+// NewRouteNotifier opens the NETLINK_ROUTE socket the notifications are sent
+// on. Sending to rtnetlink multicast groups requires CAP_NET_ADMIN.
+func NewRouteNotifier() (*RouteNotifier, error) {
+	sock, err := nl.Subscribe(unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, err
+	}
+	return &RouteNotifier{sock: sock}, nil
+}
+
+// NotifyAdd announces the route AddRoute installs to ip on linkIndex.
+func (rn *RouteNotifier) NotifyAdd(ip net.IP, linkIndex int, opts ...RouteOption) error {
+	return rn.notify(unix.RTM_NEWROUTE, ip, linkIndex, opts)
+}
+
+// NotifyRemove announces the removal of the route to ip on linkIndex.
+func (rn *RouteNotifier) NotifyRemove(ip net.IP, linkIndex int, opts ...RouteOption) error {
+	return rn.notify(unix.RTM_DELROUTE, ip, linkIndex, opts)
+}
+
+func (rn *RouteNotifier) notify(msgType int, ip net.IP, linkIndex int, opts []RouteOption) error {
+	dst, err := RouteDst(ip, opts...)
+	if err != nil {
+		return err
+	}
+
+	msg, err := routeMessage(msgType, newRoute(dst, linkIndex, opts...))
+	if err != nil {
+		return err
+	}
+
+	group := uint32(unix.RTNLGRP_IPV6_ROUTE)
+	if dst.IP.To4() != nil {
+		group = unix.RTNLGRP_IPV4_ROUTE
+	}
+	fd := rn.sock.GetFd()
+	if fd < 0 {
+		return errors.New("route notifier is closed")
+	}
+	return unix.Sendto(fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: 1 << (group - 1)})
+}
+
+// routeMessage serializes route the way the kernel announces it. The
+// message lacks NLM_F_REQUEST, so the kernel does not act on it and only the
+// multicast listeners receive it.
+func routeMessage(msgType int, route *netlink.Route) ([]byte, error) {
+	req := nl.NewNetlinkRequest(msgType, 0)
+	req.Flags = 0
+
+	family := nl.GetIPFamily(route.Dst.IP)
+	dstIP := route.Dst.IP.To4()
+	if family == nl.FAMILY_V6 {
+		dstIP = route.Dst.IP.To16()
+	}
+	if dstIP == nil {
+		return nil, errors.New("route has no destination")
+	}
+	ones, _ := route.Dst.Mask.Size()
+
+	msg := nl.NewRtMsg()
+	msg.Family = uint8(family)
+	msg.Dst_len = uint8(ones)
+	msg.Scope = uint8(route.Scope)
+	if route.Protocol > 0 {
+		// Otherwise keep RTPROT_BOOT, which the kernel gives routes added
+		// without a protocol.
+		msg.Protocol = uint8(route.Protocol)
+	}
+	table := route.Table
+	if table == 0 {
+		table = unix.RT_TABLE_MAIN
+	}
+	if table < 256 {
+		msg.Table = uint8(table)
+	} else {
+		msg.Table = unix.RT_TABLE_UNSPEC
+	}
+	req.AddData(msg)
+
+	req.AddData(nl.NewRtAttr(unix.RTA_TABLE, nl.Uint32Attr(uint32(table))))
+	req.AddData(nl.NewRtAttr(unix.RTA_DST, dstIP))
+	req.AddData(nl.NewRtAttr(unix.RTA_OIF, nl.Uint32Attr(uint32(route.LinkIndex))))
+	if route.Priority > 0 {
+		req.AddData(nl.NewRtAttr(unix.RTA_PRIORITY, nl.Uint32Attr(uint32(route.Priority))))
+	}
+	if route.Gw != nil {
+		gw := route.Gw.To4()
+		if family == nl.FAMILY_V6 {
+			gw = route.Gw.To16()
+		}
+		req.AddData(nl.NewRtAttr(unix.RTA_GATEWAY, gw))
+	}
+	return req.Serialize(), nil
+}
+
+// Close closes the socket.
+func (rn *RouteNotifier) Close() {
+	rn.sock.Close()
+}
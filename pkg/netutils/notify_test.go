@@ -0,0 +1,83 @@
+package netutils
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+func TestRouteNotifierReachesMulticastListeners(t *testing.T) {
+	listener, err := nl.Subscribe(unix.NETLINK_ROUTE, unix.RTNLGRP_IPV4_ROUTE)
+	if err != nil {
+		t.Skipf("cannot subscribe to route notifications: %v", err)
+	}
+	defer listener.Close()
+	if err := listener.SetReceiveTimeout(&unix.Timeval{Sec: 1}); err != nil {
+		t.Fatalf("failed to set receive timeout: %v", err)
+	}
+
+	notifier, err := NewRouteNotifier()
+	if err != nil {
+		t.Fatalf("failed to open route notifier: %v", err)
+	}
+	defer notifier.Close()
+
+	// Nothing routes 192.0.2.0/24, so only the notifier announces it.
+	ip := net.ParseIP("192.0.2.77")
+	if err := notifier.NotifyAdd(ip, 1, WithTable(100), WithMetric(10)); err != nil {
+		t.Fatalf("failed to send notification: %v", err)
+	}
+	if err := notifier.NotifyRemove(ip, 1, WithTable(100), WithMetric(10)); err != nil {
+		t.Fatalf("failed to send notification: %v", err)
+	}
+
+	var got []uint16
+	for len(got) < 2 {
+		msgs, _, err := listener.Receive()
+		if err != nil {
+			t.Fatalf("Expected two notifications for %s, got %d: %v", ip, len(got), err)
+		}
+		for _, m := range msgs {
+			if m.Header.Type != unix.RTM_NEWROUTE && m.Header.Type != unix.RTM_DELROUTE {
+				continue
+			}
+			if m.Header.Flags&unix.NLM_F_REQUEST != 0 {
+				t.Errorf("Expected the notification not to be a request")
+			}
+			msg := nl.DeserializeRtMsg(m.Data)
+			attrs, err := nl.ParseRouteAttr(m.Data[msg.Len():])
+			if err != nil {
+				t.Fatalf("failed to parse route attributes: %v", err)
+			}
+
+			var dst net.IP
+			var oif, table, metric uint32
+			for _, attr := range attrs {
+				switch attr.Attr.Type {
+				case unix.RTA_DST:
+					dst = net.IP(attr.Value)
+				case unix.RTA_OIF:
+					oif = nl.NativeEndian().Uint32(attr.Value)
+				case unix.RTA_TABLE:
+					table = nl.NativeEndian().Uint32(attr.Value)
+				case unix.RTA_PRIORITY:
+					metric = nl.NativeEndian().Uint32(attr.Value)
+				}
+			}
+			if !dst.Equal(ip) {
+				continue
+			}
+			if msg.Dst_len != 32 || oif != 1 || table != 100 || metric != 10 {
+				t.Errorf("Expected %s/32 on link 1 in table 100 with metric 10, got /%d on link %d in table %d with metric %d",
+					ip, msg.Dst_len, oif, table, metric)
+			}
+			got = append(got, m.Header.Type)
+		}
+	}
+
+	if got[0] != unix.RTM_NEWROUTE || got[1] != unix.RTM_DELROUTE {
+		t.Errorf("Expected RTM_NEWROUTE then RTM_DELROUTE, got %v", got)
+	}
+}
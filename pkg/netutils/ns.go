@@ -0,0 +1,87 @@
+package netutils
+
+import (
+	"errors"
+	"net"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+// solicitedNodeAddr returns the ff02::1:ffXX:XXXX multicast address that
+// Neighbor Solicitations for ip are sent to.
+func solicitedNodeAddr(ip net.IP) net.IP {
+	ip16 := ip.To16()
+	addr := net.ParseIP("ff02::1:ff00:0")
+	copy(addr[13:], ip16[13:])
+	return addr
+}
+
+// neighborSolicitation builds an NS message for target, carrying a source
+// link-layer address option when hwAddr is set.
+func neighborSolicitation(target net.IP, hwAddr net.HardwareAddr) ([]byte, error) {
+	data := make([]byte, 4, 4+net.IPv6len+8)
+	data = append(data, target.To16()...)
+
+	if len(hwAddr) > 0 {
+		units := (2 + len(hwAddr) + 7) / 8
+		option := make([]byte, units*8)
+		option[0] = 1 // Source Link-Layer Address
+		option[1] = byte(units)
+		copy(option[2:], hwAddr)
+		data = append(data, option...)
+	}
+
+	msg := icmp.Message{
+		Type: ipv6.ICMPTypeNeighborSolicitation,
+		Body: &icmp.RawBody{Data: data},
+	}
+	// The kernel fills in the ICMPv6 checksum on raw sockets.
+	return msg.Marshal(nil)
+}
+
+// SendNeighborSolicitation sends an NS for ip to its solicited-node multicast
+// address on the given link, from the link's IPv6 link-local address. It
+// fails if the link has none. It does not wait for the advertisement; the
+// kernel updates the neighbor entry when one arrives.
+func SendNeighborSolicitation(ip net.IP, linkIndex int) error {
+	if ip == nil || ip.To4() != nil {
+		return errors.New("neighbor solicitation requires an IPv6 address")
+	}
+
+	iface, err := InterfaceByIndex(linkIndex)
+	if err != nil {
+		return err
+	}
+
+	payload, err := neighborSolicitation(ip, iface.HardwareAddr)
+	if err != nil {
+		return err
+	}
+
+	// Binding to the link-local address makes it the source of the NS, as
+	// RFC 4861 expects for solicitations sent from an interface.
+	src, err := getInterfaceLinkLocal(iface.Name)
+	if err != nil {
+		return err
+	}
+
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", src.String()+"%"+iface.Name)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// RFC 4861 requires a hop limit of 255 so receivers can tell the
+	// message was not forwarded.
+	pc := conn.IPv6PacketConn()
+	if err := pc.SetMulticastHopLimit(255); err != nil {
+		return err
+	}
+	if err := pc.SetMulticastInterface(iface); err != nil {
+		return err
+	}
+
+	_, err = conn.WriteTo(payload, &net.IPAddr{IP: solicitedNodeAddr(ip), Zone: iface.Name})
+	return err
+}
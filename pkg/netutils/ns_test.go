@@ -0,0 +1,49 @@
+package netutils
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestSolicitedNodeAddr(t *testing.T) {
+	got := solicitedNodeAddr(net.ParseIP("2001:db8::12:3456:789a"))
+	if want := net.ParseIP("ff02::1:ff56:789a"); !got.Equal(want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestNeighborSolicitationMessage(t *testing.T) {
+	target := net.ParseIP("2001:db8::1")
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+
+	msg, err := neighborSolicitation(target, mac)
+	if err != nil {
+		t.Fatalf("failed to build message: %v", err)
+	}
+
+	// type, code, checksum, reserved, target, 8 byte SLLA option
+	if len(msg) != 4+4+16+8 {
+		t.Fatalf("expected 32 bytes, got %d", len(msg))
+	}
+	if msg[0] != 135 || msg[1] != 0 {
+		t.Errorf("expected NS type 135 code 0, got %d/%d", msg[0], msg[1])
+	}
+	if !net.IP(msg[8:24]).Equal(target) {
+		t.Errorf("expected target %s, got %s", target, net.IP(msg[8:24]))
+	}
+	if msg[24] != 1 || msg[25] != 1 || !bytes.Equal(msg[26:32], mac) {
+		t.Errorf("unexpected source link-layer option % x", msg[24:])
+	}
+
+	msg, err = neighborSolicitation(target, nil)
+	if err != nil || len(msg) != 24 {
+		t.Errorf("expected 24 bytes without a MAC, got %d (%v)", len(msg), err)
+	}
+}
+
+func TestSendNeighborSolicitationRejectsIPv4(t *testing.T) {
+	if err := SendNeighborSolicitation(net.ParseIP("10.0.0.1"), 1); err == nil {
+		t.Errorf("expected error for an IPv4 address")
+	}
+}
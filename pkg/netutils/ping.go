@@ -1,23 +1,45 @@
 package netutils
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/go-ping/ping"
 	"github.com/hostinger/neigh2route/internal/logger"
 )
 
-func Ping(ip string) error {
+// DefaultPingTimeout bounds Ping when ctx has no deadline.
+const DefaultPingTimeout = time.Second
+
+// Ping sends up to three echo requests to ip, spread over the time left
+// until ctx's deadline, to refresh its kernel neighbor entry. It stops early
+// on the first reply. Like before the timeout was configurable, a host that
+// drops ICMP is not an error; only a pinger that fails to run or a
+// cancelled ctx is. Use PingOnce to check reachability.
+func Ping(ctx context.Context, ip string) error {
 	pinger, err := ping.NewPinger(ip)
 	if err != nil {
 		logger.Error("failed to create pinger: %v", err)
 		return err
 	}
 
+	timeout := DefaultPingTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
 	pinger.Count = 3
-	pinger.Timeout = time.Second * 5
-	pinger.Interval = time.Second * 1
+	pinger.Timeout = timeout
+	pinger.Interval = timeout / time.Duration(pinger.Count)
 	pinger.SetPrivileged(true)
+	pinger.OnRecv = func(*ping.Packet) {
+		pinger.Stop()
+	}
+
+	stop := context.AfterFunc(ctx, pinger.Stop)
+	defer stop()
 
 	err = pinger.Run()
 	if err != nil {
@@ -25,5 +47,32 @@ func Ping(ip string) error {
 		return err
 	}
 
+	if err := ctx.Err(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	return nil
+}
+
+// PingOnce sends a single echo request to ip and fails unless a reply
+// arrives within timeout.
+func PingOnce(ip string, timeout time.Duration) error {
+	pinger, err := ping.NewPinger(ip)
+	if err != nil {
+		return err
+	}
+
+	pinger.Count = 1
+	pinger.Timeout = timeout
+	pinger.SetPrivileged(true)
+
+	if err := pinger.Run(); err != nil {
+		return err
+	}
+
+	if pinger.Statistics().PacketsRecv == 0 {
+		return fmt.Errorf("no reply from %s within %s", ip, timeout)
+	}
+
 	return nil
 }
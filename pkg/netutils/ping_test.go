@@ -0,0 +1,48 @@
+package netutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestPingLoopback expects the loopback address to answer well within the timeout
+func TestPingLoopback(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if err := Ping(ctx, "127.0.0.1"); err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected Ping to return on the first reply, took %s", elapsed)
+	}
+}
+
+// TestPingTimeout pings a TEST-NET-2 address that never answers. A missing
+// reply is not an error, the ping only refreshes the neighbor entry.
+func TestPingTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := Ping(ctx, "198.51.100.1"); err != nil {
+		t.Fatalf("expected no error without a reply, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Ping to give up after the timeout, took %s", elapsed)
+	}
+}
+
+func TestPingCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := Ping(ctx, "198.51.100.1"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -1,73 +1,389 @@
 package netutils
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net"
+	"time"
 
 	"github.com/hostinger/neigh2route/internal/logger"
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
 )
 
-func routeExists(dst *net.IPNet, linkIndex int) (bool, error) {
-	routes, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, &netlink.Route{
-		LinkIndex: linkIndex,
-		Dst:       dst,
-	}, netlink.RT_FILTER_DST|netlink.RT_FILTER_OIF)
+// runContext runs fn and returns its error, or ctx.Err() if ctx is done
+// first. netlink calls cannot be interrupted, so an abandoned fn keeps
+// running in the background until the kernel answers.
+func runContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- fn()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// routeExists reports whether existingRoutes finds any route for route,
+// whatever its gateway.
+func routeExists(ctx context.Context, route *netlink.Route) (bool, error) {
+	routes, err := existingRoutes(ctx, route)
+	return len(routes) > 0, err
+}
+
+// existingRoutes looks for route's destination on its link, in its table
+// when one is set and in the main table otherwise. When route has a
+// protocol, only a route with the same protocol and scope counts, so a
+// static route to the same destination is not taken for ours, and only a
+// route with its metric does. The kernel reports every IPv6 route with
+// scope universe, so the scope only counts for IPv4.
+func existingRoutes(ctx context.Context, route *netlink.Route) ([]netlink.Route, error) {
+	dst, linkIndex := route.Dst, route.LinkIndex
+
+	filterMask := netlink.RT_FILTER_DST | netlink.RT_FILTER_OIF
+	if route.Table > 0 {
+		filterMask |= netlink.RT_FILTER_TABLE
+	}
+	if route.Protocol > 0 {
+		filterMask |= netlink.RT_FILTER_PROTOCOL
+		if dst.IP.To4() != nil {
+			filterMask |= netlink.RT_FILTER_SCOPE
+		}
+	}
+
+	var routes []netlink.Route
+	err := runContext(ctx, func() error {
+		var err error
+		routes, err = netlink.RouteListFiltered(netlink.FAMILY_ALL, &netlink.Route{
+			LinkIndex: linkIndex,
+			Dst:       dst,
+			Table:     route.Table,
+			Protocol:  route.Protocol,
+			Scope:     route.Scope,
+		}, filterMask)
+		return err
+	})
 	if err != nil {
 		logger.Error("Failed to list routes for dst %s on link %d: %v", dst.String(), linkIndex, err)
-		return false, err
+		return nil, err
+	}
+
+	// RouteListFiltered ignores RT_FILTER_PRIORITY.
+	matched := routes[:0]
+	for _, r := range routes {
+		if r.Priority == route.Priority {
+			matched = append(matched, r)
+		}
 	}
+	routes = matched
 
 	if len(routes) == 0 {
 		logger.Info("No routes found for dst %s on link index %d", dst.String(), linkIndex)
-		return false, nil
+		return nil, nil
 	}
 
 	logger.Info("Found %d routes for dst %s on link index %d", len(routes), dst.String(), linkIndex)
-	return true, nil
+	return routes, nil
 }
 
-func AddRoute(ip net.IP, linkIndex int) error {
-	mask := net.CIDRMask(32, 32)
-	if ip.To4() == nil {
-		mask = net.CIDRMask(128, 128)
+var ErrInvalidRouteIP = errors.New("invalid route destination")
+
+// IPv6DefaultMetric is the metric the kernel gives IPv6 routes added
+// without one.
+const IPv6DefaultMetric = 1024
+
+// hostRouteDst returns the host route destination for ip: a /32 for IPv4
+// and IPv4-mapped IPv6 addresses, a /128 for any other IPv6 address.
+func hostRouteDst(ip net.IP) (*net.IPNet, error) {
+	if ip == nil || ip.IsUnspecified() {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRouteIP, ip)
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRouteIP, ip)
+	}
+	return &net.IPNet{IP: ip16, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// RouteDst returns the destination AddRoute installs for ip with opts: the
+// host route, or the prefix around ip set with WithPrefixLen.
+func RouteDst(ip net.IP, opts ...RouteOption) (*net.IPNet, error) {
+	dst, err := hostRouteDst(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	_, bits := dst.Mask.Size()
+	if ones := applyRouteOptions(opts).prefixLen(bits); ones != bits {
+		mask := net.CIDRMask(ones, bits)
+		dst = &net.IPNet{IP: dst.IP.Mask(mask), Mask: mask}
+	}
+	return dst, nil
+}
+
+type routeOptions struct {
+	metric   uint32
+	table    int
+	protocol int
+	scope    netlink.Scope
+	dryRun   bool
+	append   bool
+	gateway  net.IP
+
+	prefixLen4 int
+	prefixLen6 int
+}
+
+// prefixLen returns the route prefix length for an address of bits bits.
+func (o routeOptions) prefixLen(bits int) int {
+	if bits == 32 && o.prefixLen4 > 0 {
+		return o.prefixLen4
+	}
+	if bits == 128 && o.prefixLen6 > 0 {
+		return o.prefixLen6
+	}
+	return bits
+}
+
+// RouteOption customizes the routes installed and removed by AddRoute and
+// RemoveRoute.
+type RouteOption func(*routeOptions)
+
+// WithMetric sets the route priority, which the kernel shows as "metric".
+func WithMetric(metric uint32) RouteOption {
+	return func(o *routeOptions) {
+		o.metric = metric
+	}
+}
+
+// WithTable installs the route into the given routing table instead of the
+// main table (254).
+func WithTable(table int) RouteOption {
+	return func(o *routeOptions) {
+		o.table = table
+	}
+}
+
+// WithProtocol marks the route with the given protocol, which the kernel
+// shows as "proto", so the routes can be told apart from static ones.
+func WithProtocol(protocol int) RouteOption {
+	return func(o *routeOptions) {
+		o.protocol = protocol
+	}
+}
+
+// WithScope sets the route scope, which defaults to link.
+func WithScope(scope netlink.Scope) RouteOption {
+	return func(o *routeOptions) {
+		o.scope = scope
+	}
+}
+
+// ParseScope returns the route scope named s: link, host or universe.
+func ParseScope(s string) (netlink.Scope, error) {
+	switch s {
+	case "link":
+		return netlink.SCOPE_LINK, nil
+	case "host":
+		return netlink.SCOPE_HOST, nil
+	case "universe":
+		return netlink.SCOPE_UNIVERSE, nil
+	}
+	return 0, fmt.Errorf("unknown route scope %q, expected link, host or universe", s)
+}
+
+// WithAppend adds the route next to an existing route to the same
+// destination on another link instead of failing with EEXIST.
+func WithAppend() RouteOption {
+	return func(o *routeOptions) {
+		o.append = true
+	}
+}
+
+// WithGateway routes through gw instead of installing an on-link route.
+func WithGateway(gw net.IP) RouteOption {
+	return func(o *routeOptions) {
+		o.gateway = gw
+	}
+}
+
+// WithPrefixLen routes the /ipv4 or /ipv6 prefix around the address instead
+// of the address alone, e.g. a /31 covering a point-to-point link. Zero
+// keeps the host route for that family.
+func WithPrefixLen(ipv4, ipv6 int) RouteOption {
+	return func(o *routeOptions) {
+		o.prefixLen4 = ipv4
+		o.prefixLen6 = ipv6
+	}
+}
+
+// WithDryRun logs the route instead of adding or removing it.
+func WithDryRun() RouteOption {
+	return func(o *routeOptions) {
+		o.dryRun = true
+	}
+}
+
+func applyRouteOptions(opts []RouteOption) routeOptions {
+	o := routeOptions{scope: netlink.SCOPE_LINK}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func newRoute(dst *net.IPNet, linkIndex int, opts ...RouteOption) *netlink.Route {
+	o := applyRouteOptions(opts)
+
+	route := &netlink.Route{
+		LinkIndex: linkIndex,
+		Scope:     o.scope,
+		Dst:       dst,
+		Priority:  int(o.metric),
+		Table:     o.table,
+		Protocol:  netlink.RouteProtocol(o.protocol),
+		Gw:        o.gateway,
+	}
+	if route.Priority == 0 && dst.IP.To4() == nil {
+		// Spell out the metric the kernel gives IPv6 routes without one,
+		// so routeExists matches them and a delete does not take the
+		// lowest-metric route instead.
+		route.Priority = IPv6DefaultMetric
+	}
+	return route
+}
+
+func describeRoute(route *netlink.Route) string {
+	desc := fmt.Sprintf("dst=%s link_index=%d table=%d metric=%d scope=%s proto=%d",
+		route.Dst.String(), route.LinkIndex, route.Table, route.Priority, route.Scope.String(), route.Protocol)
+	if route.Gw != nil {
+		desc += " via=" + route.Gw.String()
+	}
+	return desc
+}
+
+// AddRoute installs a host route to ip on linkIndex, or a route to the
+// prefix set with WithPrefixLen, unless it exists. A route that only
+// differs in its gateway, such as an on-link route when WithGateway is set,
+// is replaced. It gives up with ctx.Err() once ctx is done.
+func AddRoute(ctx context.Context, ip net.IP, linkIndex int, opts ...RouteOption) error {
+	routeDst, err := RouteDst(ip, opts...)
+	if err != nil {
+		logger.Error("Failed to add route: %v", err)
+		return err
 	}
 
-	routeDst := &net.IPNet{IP: ip, Mask: mask}
+	route := newRoute(routeDst, linkIndex, opts...)
 
-	exists, err := routeExists(routeDst, linkIndex)
+	routes, err := existingRoutes(ctx, route)
 	if err != nil {
 		logger.Error("Failed to check if route exists for %s: %v", ip.String(), err)
 		return err
 	}
 
-	if exists {
+	for _, r := range routes {
+		if r.Gw.Equal(route.Gw) {
+			return nil
+		}
+	}
+
+	o := applyRouteOptions(opts)
+	if o.dryRun {
+		logger.Info("[DRY-RUN] Would add route %s", describeRoute(route))
 		return nil
 	}
 
-	route := &netlink.Route{
-		LinkIndex: linkIndex,
-		Scope:     netlink.SCOPE_LINK,
-		Dst:       &net.IPNet{IP: ip, Mask: mask},
+	add := netlink.RouteAdd
+	switch {
+	case len(routes) > 0:
+		logger.Info("Replacing route for %s with a different gateway", ip.String())
+		add = netlink.RouteReplace
+	case o.append:
+		add = netlink.RouteAppend
 	}
 
-	if err := netlink.RouteAdd(route); err != nil {
+	start := time.Now()
+	err = runContext(ctx, func() error { return add(route) })
+	routeAddLatency.observe(time.Since(start))
+	if err != nil {
 		logger.Error("Failed to add route for %s: %v", ip.String(), err)
 		return err
 	}
 
-	logger.Info("Added route for %s on link index %d", ip.String(), linkIndex)
+	logger.InfoFields("Added route", map[string]interface{}{
+		"ip":         ip.String(),
+		"link_index": linkIndex,
+		"table":      route.Table,
+		"metric":     route.Priority,
+	})
 	return nil
 }
 
-func RemoveRoute(ip net.IP, linkIndex int) error {
-	mask := net.CIDRMask(32, 32)
-	if ip.To4() == nil {
-		mask = net.CIDRMask(128, 128)
+var addRouteFunc = AddRoute
+
+// AddRouteWithRetry calls AddRoute up to retries times, doubling backoff
+// between attempts, and returns the last error if every attempt fails. It
+// stops retrying once ctx is done.
+func AddRouteWithRetry(ctx context.Context, ip net.IP, linkIndex int, retries int, backoff time.Duration, opts ...RouteOption) error {
+	if retries < 1 {
+		retries = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= retries; attempt++ {
+		if err = addRouteFunc(ctx, ip, linkIndex, opts...); err == nil {
+			return nil
+		}
+
+		if attempt < retries {
+			logger.Warn("Failed to add route for %s (attempt %d/%d): %v, retrying in %s", ip.String(), attempt, retries, err, backoff)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
 	}
 
-	routeDst := &net.IPNet{IP: ip, Mask: mask}
+	return err
+}
 
-	exists, err := routeExists(routeDst, linkIndex)
+// RemoveRoute deletes the host route to ip on linkIndex if it exists. It
+// gives up with ctx.Err() once ctx is done.
+func RemoveRoute(ctx context.Context, ip net.IP, linkIndex int, opts ...RouteOption) error {
+	routeDst, err := RouteDst(ip, opts...)
+	if err != nil {
+		logger.Error("Failed to remove route: %v", err)
+		return err
+	}
+
+	route := newRoute(routeDst, linkIndex, opts...)
+
+	// A dry run never installed the route, so don't look for it.
+	if applyRouteOptions(opts).dryRun {
+		logger.Info("[DRY-RUN] Would remove route %s", describeRoute(route))
+		return nil
+	}
+
+	exists, err := routeExists(ctx, route)
 	if err != nil {
 		logger.Error("Failed to check if route exists for %s: %v", ip.String(), err)
 		return err
@@ -77,17 +393,180 @@ func RemoveRoute(ip net.IP, linkIndex int) error {
 		return nil
 	}
 
-	route := &netlink.Route{
+	start := time.Now()
+	err = runContext(ctx, func() error { return netlink.RouteDel(route) })
+	routeRemoveLatency.observe(time.Since(start))
+	if err != nil {
+		logger.Error("Failed to remove route for %s: %v", ip.String(), err)
+		return err
+	}
+
+	logger.InfoFields("Removed route", map[string]interface{}{
+		"ip":         ip.String(),
+		"link_index": linkIndex,
+		"table":      route.Table,
+		"metric":     route.Priority,
+	})
+	return nil
+}
+
+// ErrMultipathIPv6 is returned for IPv6 multipath routes: the kernel only
+// accepts device-only nexthops for IPv4, so IPv6 needs one route per link.
+var ErrMultipathIPv6 = errors.New("device-only multipath routes are not supported for IPv6")
+
+// newMultipathRoute returns the IPv4 host route to ip over every link in
+// linkIndexes, or a plain host route when there is only one.
+func newMultipathRoute(ip net.IP, linkIndexes []int, opts ...RouteOption) (*netlink.Route, error) {
+	routeDst, err := RouteDst(ip, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if ip.To4() == nil {
+		return nil, ErrMultipathIPv6
+	}
+	if len(linkIndexes) == 0 {
+		return nil, fmt.Errorf("multipath route for %s requires at least one link", ip)
+	}
+
+	route := newRoute(routeDst, linkIndexes[0], opts...)
+	if len(linkIndexes) > 1 {
+		route.LinkIndex = 0
+		for _, linkIndex := range linkIndexes {
+			route.MultiPath = append(route.MultiPath, &netlink.NexthopInfo{LinkIndex: linkIndex})
+		}
+	}
+	return route, nil
+}
+
+// SetMultipathRoute installs an IPv4 host route to ip that the kernel
+// balances over every link in linkIndexes, replacing the current route to
+// ip in the same table. It gives up with ctx.Err() once ctx is done.
+func SetMultipathRoute(ctx context.Context, ip net.IP, linkIndexes []int, opts ...RouteOption) error {
+	route, err := newMultipathRoute(ip, linkIndexes, opts...)
+	if err != nil {
+		logger.Error("Failed to set multipath route: %v", err)
+		return err
+	}
+
+	if applyRouteOptions(opts).dryRun {
+		logger.Info("[DRY-RUN] Would set route %s nexthops=%v", describeRoute(route), linkIndexes)
+		return nil
+	}
+
+	start := time.Now()
+	err = runContext(ctx, func() error { return netlink.RouteReplace(route) })
+	routeAddLatency.observe(time.Since(start))
+	if err != nil {
+		logger.Error("Failed to set multipath route for %s: %v", ip.String(), err)
+		return err
+	}
+
+	logger.InfoFields("Set multipath route", map[string]interface{}{
+		"ip":           ip.String(),
+		"link_indexes": linkIndexes,
+		"table":        route.Table,
+		"metric":       route.Priority,
+	})
+	return nil
+}
+
+// RemoveMultipathRoute deletes the route SetMultipathRoute installed for
+// linkIndexes, if it exists. It gives up with ctx.Err() once ctx is done.
+func RemoveMultipathRoute(ctx context.Context, ip net.IP, linkIndexes []int, opts ...RouteOption) error {
+	route, err := newMultipathRoute(ip, linkIndexes, opts...)
+	if err != nil {
+		logger.Error("Failed to remove multipath route: %v", err)
+		return err
+	}
+
+	if applyRouteOptions(opts).dryRun {
+		logger.Info("[DRY-RUN] Would remove route %s nexthops=%v", describeRoute(route), linkIndexes)
+		return nil
+	}
+
+	start := time.Now()
+	err = runContext(ctx, func() error { return netlink.RouteDel(route) })
+	routeRemoveLatency.observe(time.Since(start))
+	if errors.Is(err, unix.ESRCH) {
+		return nil
+	}
+	if err != nil {
+		logger.Error("Failed to remove multipath route for %s: %v", ip.String(), err)
+		return err
+	}
+
+	logger.InfoFields("Removed multipath route", map[string]interface{}{
+		"ip":           ip.String(),
+		"link_indexes": linkIndexes,
+		"table":        route.Table,
+		"metric":       route.Priority,
+	})
+	return nil
+}
+
+// HostRoutes lists the routes AddRoute installs with opts: those with its
+// prefix length in the same table and scope and, when a protocol is set,
+// with that protocol. The kernel reports every IPv6 route with scope
+// universe, so the scope only counts for IPv4.
+// A linkIndex <= 0 lists them on every link.
+func HostRoutes(linkIndex int, opts ...RouteOption) ([]netlink.Route, error) {
+	o := applyRouteOptions(opts)
+	if o.table <= 0 {
+		o.table = unix.RT_TABLE_MAIN
+	}
+
+	filter := &netlink.Route{
+		Table:     o.table,
 		LinkIndex: linkIndex,
-		Scope:     netlink.SCOPE_LINK,
-		Dst:       routeDst,
+		Protocol:  netlink.RouteProtocol(o.protocol),
+	}
+	filterMask := netlink.RT_FILTER_TABLE
+	if linkIndex > 0 {
+		filterMask |= netlink.RT_FILTER_OIF
+	}
+	if o.protocol > 0 {
+		filterMask |= netlink.RT_FILTER_PROTOCOL
 	}
 
-	if err := netlink.RouteDel(route); err != nil {
-		logger.Error("Failed to remove route for %s: %v", ip.String(), err)
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, filter, filterMask)
+	if err != nil {
+		return nil, err
+	}
+
+	var hostRoutes []netlink.Route
+	for _, route := range routes {
+		if route.Dst == nil {
+			continue
+		}
+		if route.Dst.IP.To4() != nil && route.Scope != o.scope {
+			continue
+		}
+
+		if ones, bits := route.Dst.Mask.Size(); ones != o.prefixLen(bits) {
+			continue
+		}
+		hostRoutes = append(hostRoutes, route)
+	}
+	return hostRoutes, nil
+}
+
+// FlushRoutes deletes the routes HostRoutes lists for linkIndex and opts.
+func FlushRoutes(linkIndex int, opts ...RouteOption) error {
+	routes, err := HostRoutes(linkIndex, opts...)
+	if err != nil {
+		logger.Error("Failed to list routes on link index %d: %v", linkIndex, err)
 		return err
 	}
 
-	logger.Info("Removed route for %s on link index %d", ip.String(), linkIndex)
+	flushed := 0
+	for _, route := range routes {
+		if err := netlink.RouteDel(&route); err != nil {
+			logger.Error("Failed to flush route for %s: %v", route.Dst.String(), err)
+			return err
+		}
+		flushed++
+	}
+
+	logger.Info("Flushed %d routes on link index %d", flushed, linkIndex)
 	return nil
 }
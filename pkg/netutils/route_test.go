@@ -1,9 +1,17 @@
 package netutils
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"net"
+	"os"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/hostinger/neigh2route/internal/logger"
 	"github.com/vishvananda/netlink"
 )
 
@@ -12,7 +20,7 @@ func TestAddRouteIntegration(t *testing.T) {
 	ip := net.ParseIP("192.168.100.100")
 	linkIndex := 1
 
-	err := AddRoute(ip, linkIndex)
+	err := AddRoute(context.Background(), ip, linkIndex)
 	if err != nil {
 		t.Fatalf("failed to add route: %v", err)
 	}
@@ -35,7 +43,7 @@ func TestRemoveRouteIntegration(t *testing.T) {
 	ip := net.ParseIP("192.168.100.100")
 	linkIndex := 1
 
-	err := RemoveRoute(ip, linkIndex)
+	err := RemoveRoute(context.Background(), ip, linkIndex)
 	if err != nil {
 		t.Fatalf("failed to remove route: %v", err)
 	}
@@ -52,3 +60,568 @@ func TestRemoveRouteIntegration(t *testing.T) {
 		t.Fatalf("expected route to be removed but found")
 	}
 }
+
+func TestAddRouteWithRetryEventualSuccess(t *testing.T) {
+	calls := 0
+	addRouteFunc = func(ctx context.Context, ip net.IP, linkIndex int, opts ...RouteOption) error {
+		calls++
+		if calls < 3 {
+			return syscall.EBUSY
+		}
+		return nil
+	}
+	defer func() { addRouteFunc = AddRoute }()
+
+	err := AddRouteWithRetry(context.Background(), net.ParseIP("192.168.100.101"), 1, 3, time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+}
+
+func TestAddRouteWithRetryExhausted(t *testing.T) {
+	calls := 0
+	addRouteFunc = func(ctx context.Context, ip net.IP, linkIndex int, opts ...RouteOption) error {
+		calls++
+		if calls == 3 {
+			return syscall.EAGAIN
+		}
+		return syscall.EBUSY
+	}
+	defer func() { addRouteFunc = AddRoute }()
+
+	start := time.Now()
+	err := AddRouteWithRetry(context.Background(), net.ParseIP("192.168.100.101"), 1, 3, 10*time.Millisecond)
+	if !errors.Is(err, syscall.EAGAIN) {
+		t.Fatalf("expected last error EAGAIN, got %v", err)
+	}
+
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+
+	// 10ms + 20ms of backoff between the three attempts
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Fatalf("expected backoff to double between attempts, finished in %s", elapsed)
+	}
+}
+
+func TestAddRouteWithRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	addRouteFunc = func(ctx context.Context, ip net.IP, linkIndex int, opts ...RouteOption) error {
+		calls++
+		cancel()
+		return syscall.EBUSY
+	}
+	defer func() { addRouteFunc = AddRoute }()
+
+	err := AddRouteWithRetry(ctx, net.ParseIP("192.168.100.101"), 1, 3, time.Hour)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected 1 attempt, got %d", calls)
+	}
+}
+
+func TestRunContextReturnsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+
+	go cancel()
+	err := runContext(ctx, func() error {
+		<-release
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if err := runContext(ctx, func() error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a done context to skip the call, got %v", err)
+	}
+}
+
+func TestHostRouteDst(t *testing.T) {
+	tests := []struct {
+		name    string
+		ip      net.IP
+		wantIP  string
+		wantLen int
+		wantErr bool
+	}{
+		{"ipv4", net.ParseIP("192.0.2.10"), "192.0.2.10", 32, false},
+		{"ipv6", net.ParseIP("2001:db8::10"), "2001:db8::10", 128, false},
+		{"ipv4-mapped-ipv6", net.ParseIP("::ffff:192.0.2.10"), "192.0.2.10", 32, false},
+		{"unspecified-v4", net.IPv4zero, "", 0, true},
+		{"unspecified-v6", net.IPv6unspecified, "", 0, true},
+		{"nil", nil, "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst, err := hostRouteDst(tt.ip)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRouteIP) {
+					t.Fatalf("expected ErrInvalidRouteIP, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			ones, bits := dst.Mask.Size()
+			if ones != tt.wantLen || bits != tt.wantLen {
+				t.Errorf("expected /%d mask, got /%d of %d bits", tt.wantLen, ones, bits)
+			}
+			if dst.IP.String() != tt.wantIP {
+				t.Errorf("expected IP %s, got %s", tt.wantIP, dst.IP)
+			}
+		})
+	}
+}
+
+func TestRouteDstPrefixLen(t *testing.T) {
+	tests := []struct {
+		ip   string
+		opts []RouteOption
+		want string
+	}{
+		{"192.0.2.11", nil, "192.0.2.11/32"},
+		{"192.0.2.11", []RouteOption{WithPrefixLen(31, 126)}, "192.0.2.10/31"},
+		{"2001:db8::13", []RouteOption{WithPrefixLen(31, 126)}, "2001:db8::10/126"},
+		{"2001:db8::13", []RouteOption{WithPrefixLen(31, 0)}, "2001:db8::13/128"},
+	}
+
+	for _, tt := range tests {
+		dst, err := RouteDst(net.ParseIP(tt.ip), tt.opts...)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.ip, err)
+		}
+		if dst.String() != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.ip, tt.want, dst)
+		}
+	}
+}
+
+func TestAddRouteRejectsUnspecified(t *testing.T) {
+	if err := AddRoute(context.Background(), net.IPv4zero, 1); !errors.Is(err, ErrInvalidRouteIP) {
+		t.Errorf("expected ErrInvalidRouteIP, got %v", err)
+	}
+	if err := RemoveRoute(context.Background(), net.IPv6unspecified, 1); !errors.Is(err, ErrInvalidRouteIP) {
+		t.Errorf("expected ErrInvalidRouteIP, got %v", err)
+	}
+}
+
+func TestNewMultipathRoute(t *testing.T) {
+	route, err := newMultipathRoute(net.ParseIP("192.0.2.10"), []int{3, 5}, WithMetric(7))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if route.LinkIndex != 0 || len(route.MultiPath) != 2 || route.MultiPath[0].LinkIndex != 3 || route.MultiPath[1].LinkIndex != 5 {
+		t.Errorf("expected nexthops on links 3 and 5, got %s", route)
+	}
+	if route.Priority != 7 {
+		t.Errorf("expected metric 7, got %d", route.Priority)
+	}
+
+	route, err = newMultipathRoute(net.ParseIP("192.0.2.10"), []int{3})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if route.LinkIndex != 3 || len(route.MultiPath) != 0 {
+		t.Errorf("expected a plain route on link 3, got %s", route)
+	}
+
+	if _, err := newMultipathRoute(net.ParseIP("2001:db8::10"), []int{3, 5}); !errors.Is(err, ErrMultipathIPv6) {
+		t.Errorf("expected ErrMultipathIPv6, got %v", err)
+	}
+	if _, err := newMultipathRoute(net.ParseIP("192.0.2.10"), nil); err == nil {
+		t.Errorf("expected an error without links")
+	}
+}
+
+func TestNewRouteMetric(t *testing.T) {
+	dst := &net.IPNet{IP: net.ParseIP("192.0.2.10").To4(), Mask: net.CIDRMask(32, 32)}
+
+	if route := newRoute(dst, 1); route.Priority != 0 {
+		t.Errorf("expected kernel default priority 0, got %d", route.Priority)
+	}
+
+	route := newRoute(dst, 1, WithMetric(4294967295))
+	if route.Priority != 4294967295 {
+		t.Errorf("expected priority 4294967295, got %d", route.Priority)
+	}
+	if route.LinkIndex != 1 || route.Scope != netlink.SCOPE_LINK || route.Dst != dst {
+		t.Errorf("unexpected route fields: %+v", route)
+	}
+}
+
+func TestAddRouteWithMetricIntegration(t *testing.T) {
+	ip := net.ParseIP("192.168.100.102")
+	if err := AddRoute(context.Background(), ip, 1, WithMetric(500)); err != nil {
+		t.Fatalf("failed to add route: %v", err)
+	}
+	defer RemoveRoute(context.Background(), ip, 1, WithMetric(500))
+
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, &netlink.Route{
+		LinkIndex: 1,
+		Dst:       &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)},
+	}, netlink.RT_FILTER_DST|netlink.RT_FILTER_OIF)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+
+	if len(routes) != 1 || routes[0].Priority != 500 {
+		t.Fatalf("expected one route with metric 500, got %+v", routes)
+	}
+}
+
+func TestNewRouteTable(t *testing.T) {
+	dst := &net.IPNet{IP: net.ParseIP("192.0.2.10").To4(), Mask: net.CIDRMask(32, 32)}
+
+	if route := newRoute(dst, 1); route.Table != 0 {
+		t.Errorf("expected kernel default table 0, got %d", route.Table)
+	}
+
+	if route := newRoute(dst, 1, WithTable(100)); route.Table != 100 {
+		t.Errorf("expected table 100, got %d", route.Table)
+	}
+}
+
+func TestAddRouteWithTableIntegration(t *testing.T) {
+	ip := net.ParseIP("192.168.100.103")
+	if err := AddRoute(context.Background(), ip, 1, WithTable(100)); err != nil {
+		t.Fatalf("failed to add route: %v", err)
+	}
+
+	filter := &netlink.Route{
+		LinkIndex: 1,
+		Dst:       &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)},
+		Table:     100,
+	}
+	mask := netlink.RT_FILTER_DST | netlink.RT_FILTER_OIF | netlink.RT_FILTER_TABLE
+
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, filter, mask)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+	if len(routes) != 1 {
+		t.Fatalf("expected one route in table 100, got %+v", routes)
+	}
+
+	if err := RemoveRoute(context.Background(), ip, 1, WithTable(100)); err != nil {
+		t.Fatalf("failed to remove route: %v", err)
+	}
+
+	routes, err = netlink.RouteListFiltered(netlink.FAMILY_V4, filter, mask)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+	if len(routes) != 0 {
+		t.Errorf("expected route removed from table 100, got %+v", routes)
+	}
+}
+
+func TestDryRunIntegration(t *testing.T) {
+	ip := net.ParseIP("192.168.100.104")
+	filter := &netlink.Route{
+		LinkIndex: 1,
+		Dst:       &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)},
+	}
+	mask := netlink.RT_FILTER_DST | netlink.RT_FILTER_OIF
+
+	if err := AddRoute(context.Background(), ip, 1, WithDryRun()); err != nil {
+		t.Fatalf("failed to dry-run route add: %v", err)
+	}
+
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, filter, mask)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+	if len(routes) != 0 {
+		t.Fatalf("expected no route after a dry run, got %+v", routes)
+	}
+
+	if err := AddRoute(context.Background(), ip, 1); err != nil {
+		t.Fatalf("failed to add route: %v", err)
+	}
+	defer RemoveRoute(context.Background(), ip, 1)
+
+	if err := RemoveRoute(context.Background(), ip, 1, WithDryRun()); err != nil {
+		t.Fatalf("failed to dry-run route removal: %v", err)
+	}
+
+	routes, err = netlink.RouteListFiltered(netlink.FAMILY_V4, filter, mask)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+	if len(routes) != 1 {
+		t.Errorf("expected the route to survive a dry-run removal, got %+v", routes)
+	}
+}
+
+func TestAddRouteWithProtocolIntegration(t *testing.T) {
+	ip := net.ParseIP("192.168.100.105")
+	if err := AddRoute(context.Background(), ip, 1, WithProtocol(252)); err != nil {
+		t.Fatalf("failed to add route: %v", err)
+	}
+	defer RemoveRoute(context.Background(), ip, 1, WithProtocol(252))
+
+	routes, err := HostRoutes(1, WithProtocol(252))
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+
+	var found bool
+	for _, route := range routes {
+		if route.Dst.IP.Equal(ip) {
+			found = route.Protocol == 252
+		}
+	}
+	if !found {
+		t.Fatalf("expected route %s with proto 252, got %+v", ip, routes)
+	}
+
+	routes, err = HostRoutes(1, WithProtocol(253))
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+	for _, route := range routes {
+		if route.Dst.IP.Equal(ip) {
+			t.Errorf("expected route %s to be filtered out by protocol", ip)
+		}
+	}
+}
+
+func TestIPv6RouteWithProtocolIntegration(t *testing.T) {
+	ip := net.ParseIP("fd00:100::105")
+	dst := &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+	listRoutes := func() []netlink.Route {
+		routes, err := netlink.RouteListFiltered(netlink.FAMILY_V6, &netlink.Route{LinkIndex: 1, Dst: dst}, netlink.RT_FILTER_DST|netlink.RT_FILTER_OIF)
+		if err != nil {
+			t.Fatalf("failed to list routes: %v", err)
+		}
+		return routes
+	}
+
+	if err := AddRoute(context.Background(), ip, 1, WithProtocol(252)); err != nil {
+		t.Fatalf("failed to add route: %v", err)
+	}
+	defer netlink.RouteDel(&netlink.Route{LinkIndex: 1, Dst: dst, Priority: IPv6DefaultMetric})
+
+	// The kernel reports the route with scope universe, which must not
+	// hide it from routeExists.
+	if err := AddRoute(context.Background(), ip, 1, WithProtocol(252)); err != nil {
+		t.Fatalf("expected adding an existing route to succeed, got %v", err)
+	}
+	if routes := listRoutes(); len(routes) != 1 {
+		t.Fatalf("expected a single route to %s, got %+v", ip, routes)
+	}
+
+	if err := RemoveRoute(context.Background(), ip, 1, WithProtocol(252)); err != nil {
+		t.Fatalf("failed to remove route: %v", err)
+	}
+	if routes := listRoutes(); len(routes) != 0 {
+		t.Errorf("expected the route to %s to be removed, got %+v", ip, routes)
+	}
+}
+
+func TestAddRouteIgnoresOtherProtocolIntegration(t *testing.T) {
+	ip := net.ParseIP("192.168.100.106")
+	static := newRoute(&net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}, 1, WithMetric(5))
+	if err := netlink.RouteAdd(static); err != nil {
+		t.Fatalf("failed to add static route: %v", err)
+	}
+	defer netlink.RouteDel(static)
+
+	if err := AddRoute(context.Background(), ip, 1, WithProtocol(252), WithMetric(10)); err != nil {
+		t.Fatalf("failed to add route: %v", err)
+	}
+	defer RemoveRoute(context.Background(), ip, 1, WithProtocol(252), WithMetric(10))
+
+	routes, err := HostRoutes(1, WithProtocol(252))
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+	var found bool
+	for _, route := range routes {
+		if route.Dst.IP.Equal(ip) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected the static route not to stand in for the proto 252 route to %s", ip)
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	for name, want := range map[string]netlink.Scope{
+		"link":     netlink.SCOPE_LINK,
+		"host":     netlink.SCOPE_HOST,
+		"universe": netlink.SCOPE_UNIVERSE,
+	} {
+		if got, err := ParseScope(name); err != nil || got != want {
+			t.Errorf("ParseScope(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+
+	if _, err := ParseScope("site"); err == nil {
+		t.Errorf("expected an error for an unknown scope")
+	}
+}
+
+func TestAddRouteWithScopeIntegration(t *testing.T) {
+	ip := net.ParseIP("192.168.100.106")
+	if err := AddRoute(context.Background(), ip, 1, WithScope(netlink.SCOPE_HOST)); err != nil {
+		t.Fatalf("failed to add route: %v", err)
+	}
+	defer RemoveRoute(context.Background(), ip, 1, WithScope(netlink.SCOPE_HOST))
+
+	hasRoute := func(opts ...RouteOption) bool {
+		routes, err := HostRoutes(1, opts...)
+		if err != nil {
+			t.Fatalf("failed to list routes: %v", err)
+		}
+		for _, route := range routes {
+			if route.Dst.IP.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !hasRoute(WithScope(netlink.SCOPE_HOST)) {
+		t.Errorf("expected a host-scoped route for %s", ip)
+	}
+	if hasRoute() {
+		t.Errorf("expected the host-scoped route not to be listed as link-scoped")
+	}
+}
+
+func TestAddRouteWithPrefixLenIntegration(t *testing.T) {
+	ip := net.ParseIP("192.168.100.109")
+	opts := []RouteOption{WithPrefixLen(31, 0)}
+	if err := AddRoute(context.Background(), ip, 1, opts...); err != nil {
+		t.Fatalf("failed to add route: %v", err)
+	}
+	defer RemoveRoute(context.Background(), ip, 1, opts...)
+
+	routes, err := HostRoutes(1, opts...)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+	found := false
+	for _, route := range routes {
+		if route.Dst.String() == "192.168.100.108/31" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a route to 192.168.100.108/31, got %v", routes)
+	}
+
+	if err := RemoveRoute(context.Background(), ip, 1, opts...); err != nil {
+		t.Fatalf("failed to remove route: %v", err)
+	}
+	routes, _ = HostRoutes(1, opts...)
+	for _, route := range routes {
+		if route.Dst.String() == "192.168.100.108/31" {
+			t.Errorf("expected the /31 route to be removed")
+		}
+	}
+}
+
+func TestHostRoutesIPv6Integration(t *testing.T) {
+	ip := net.ParseIP("fd00:100::106")
+	// A table of its own keeps the flush away from other tests' routes.
+	opts := []RouteOption{WithTable(1006)}
+	if err := AddRoute(context.Background(), ip, 1, opts...); err != nil {
+		t.Fatalf("failed to add route: %v", err)
+	}
+	defer RemoveRoute(context.Background(), ip, 1, opts...)
+
+	routes, err := HostRoutes(1, opts...)
+	if err != nil {
+		t.Fatalf("failed to list routes: %v", err)
+	}
+	if len(routes) != 1 || !routes[0].Dst.IP.Equal(ip) {
+		t.Fatalf("expected the IPv6 route to %s to be listed, got %v", ip, routes)
+	}
+
+	if err := FlushRoutes(1, opts...); err != nil {
+		t.Fatalf("failed to flush routes: %v", err)
+	}
+	if routes, _ = HostRoutes(1, opts...); len(routes) != 0 {
+		t.Errorf("expected the IPv6 route to %s to be flushed, got %v", ip, routes)
+	}
+}
+
+func TestAddRouteReplacesOnLinkRouteWithGatewayIntegration(t *testing.T) {
+	link := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: "n2r-rtgw0"}}
+	if err := netlink.LinkAdd(link); err != nil {
+		t.Skipf("cannot create bridge link: %v", err)
+	}
+	defer netlink.LinkDel(link)
+	if err := netlink.LinkSetUp(link); err != nil {
+		t.Fatalf("failed to bring up bridge: %v", err)
+	}
+	bridge, err := netlink.LinkByName("n2r-rtgw0")
+	if err != nil {
+		t.Fatalf("failed to look up bridge: %v", err)
+	}
+	linkIndex := bridge.Attrs().Index
+
+	ip := net.ParseIP("2001:db8:67::1")
+	gw := net.ParseIP("fe80::67")
+	listRoutes := func() []netlink.Route {
+		routes, err := netlink.RouteListFiltered(netlink.FAMILY_V6, &netlink.Route{
+			Dst: &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)},
+		}, netlink.RT_FILTER_DST)
+		if err != nil {
+			t.Fatalf("failed to list routes: %v", err)
+		}
+		return routes
+	}
+
+	if err := AddRoute(context.Background(), ip, linkIndex); err != nil {
+		t.Fatalf("failed to add on-link route: %v", err)
+	}
+	defer RemoveRoute(context.Background(), ip, linkIndex)
+
+	if err := AddRoute(context.Background(), ip, linkIndex, WithGateway(gw)); err != nil {
+		t.Fatalf("failed to add gateway route: %v", err)
+	}
+	if routes := listRoutes(); len(routes) != 1 || !routes[0].Gw.Equal(gw) {
+		t.Fatalf("expected a single route via %s, got %+v", gw, routes)
+	}
+
+	// Adding it again leaves the gateway route alone.
+	if err := AddRoute(context.Background(), ip, linkIndex, WithGateway(gw)); err != nil {
+		t.Fatalf("failed to re-add gateway route: %v", err)
+	}
+	if routes := listRoutes(); len(routes) != 1 || !routes[0].Gw.Equal(gw) {
+		t.Errorf("expected the route via %s to be kept, got %+v", gw, routes)
+	}
+}
+
+func TestDryRunRemoveLogsMissingRoute(t *testing.T) {
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	defer logger.SetOutput(os.Stderr)
+
+	ip := net.ParseIP("192.168.100.105")
+	if err := RemoveRoute(context.Background(), ip, 1, WithDryRun()); err != nil {
+		t.Fatalf("failed to dry-run route removal: %v", err)
+	}
+	if !strings.Contains(buf.String(), "[DRY-RUN] Would remove route") {
+		t.Errorf("expected a dry-run removal to be logged for a route that was never installed, got %q", buf.String())
+	}
+}
@@ -0,0 +1,160 @@
+package netutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const tracerouteHopTimeout = time.Second
+
+type Hop struct {
+	TTL     int
+	IP      net.IP
+	Latency time.Duration
+}
+
+// Traceroute returns the address of every hop towards dst. Hops that did not
+// answer within the timeout are returned as nil.
+func Traceroute(dst net.IP, maxHops int) ([]net.IP, error) {
+	hops, err := TracerouteHops(dst, maxHops)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]net.IP, 0, len(hops))
+	for _, hop := range hops {
+		ips = append(ips, hop.IP)
+	}
+	return ips, nil
+}
+
+// TracerouteHops walks the TTL from 1 to maxHops with ICMP echo requests and
+// stops at the first echo reply or destination unreachable message.
+func TracerouteHops(dst net.IP, maxHops int) ([]Hop, error) {
+	if dst == nil {
+		return nil, errors.New("destination must not be nil")
+	}
+
+	if maxHops < 1 {
+		return nil, errors.New("maxHops must be positive")
+	}
+
+	isV4 := dst.To4() != nil
+	network, listenAddr, proto := "ip6:ipv6-icmp", "::", 58
+	var echoType icmp.Type = ipv6.ICMPTypeEchoRequest
+	if isV4 {
+		network, listenAddr, proto = "ip4:icmp", "0.0.0.0", 1
+		echoType = ipv4.ICMPTypeEcho
+	}
+
+	conn, err := icmp.ListenPacket(network, listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	id := os.Getpid() & 0xffff
+	buf := make([]byte, 1500)
+	var hops []Hop
+
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		if isV4 {
+			err = conn.IPv4PacketConn().SetTTL(ttl)
+		} else {
+			err = conn.IPv6PacketConn().SetHopLimit(ttl)
+		}
+		if err != nil {
+			return hops, err
+		}
+
+		msg := icmp.Message{
+			Type: echoType,
+			Body: &icmp.Echo{ID: id, Seq: ttl, Data: []byte("neigh2route")},
+		}
+		payload, err := msg.Marshal(nil)
+		if err != nil {
+			return hops, err
+		}
+
+		start := time.Now()
+		if _, err := conn.WriteTo(payload, &net.IPAddr{IP: dst}); err != nil {
+			return hops, err
+		}
+
+		hop := Hop{TTL: ttl}
+		done := false
+		deadline := start.Add(tracerouteHopTimeout)
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return hops, err
+		}
+
+		for {
+			n, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return hops, err
+			}
+
+			reply, err := icmp.ParseMessage(proto, buf[:n])
+			if err != nil {
+				continue
+			}
+
+			matched := false
+			switch body := reply.Body.(type) {
+			case *icmp.Echo:
+				if reply.Type == ipv4.ICMPTypeEchoReply || reply.Type == ipv6.ICMPTypeEchoReply {
+					matched = body.ID == id && body.Seq == ttl
+					done = matched
+				}
+			case *icmp.TimeExceeded:
+				matched = probeMatches(body.Data, isV4, id, ttl)
+			case *icmp.DstUnreach:
+				matched = probeMatches(body.Data, isV4, id, ttl)
+				done = matched
+			}
+
+			if matched {
+				hop.IP = peer.(*net.IPAddr).IP
+				hop.Latency = time.Since(start)
+				break
+			}
+		}
+
+		hops = append(hops, hop)
+		if done {
+			break
+		}
+	}
+
+	return hops, nil
+}
+
+// probeMatches checks whether the original datagram quoted in an ICMP error
+// is the echo request we sent for this TTL.
+func probeMatches(data []byte, isV4 bool, id, seq int) bool {
+	offset := 40
+	if isV4 {
+		if len(data) < 1 {
+			return false
+		}
+		offset = int(data[0]&0x0f) * 4
+	}
+
+	if len(data) < offset+8 {
+		return false
+	}
+
+	echo := data[offset:]
+	return int(binary.BigEndian.Uint16(echo[4:6])) == id && int(binary.BigEndian.Uint16(echo[6:8])) == seq
+}
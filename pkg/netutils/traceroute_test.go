@@ -0,0 +1,47 @@
+package netutils
+
+import (
+	"net"
+	"testing"
+)
+
+// TestTracerouteLoopback walks to the loopback address, which answers on the first hop
+func TestTracerouteLoopback(t *testing.T) {
+	hops, err := Traceroute(net.ParseIP("127.0.0.1"), 5)
+	if err != nil {
+		t.Fatalf("traceroute failed: %v", err)
+	}
+
+	if len(hops) != 1 {
+		t.Fatalf("expected 1 hop, got %d: %v", len(hops), hops)
+	}
+
+	if !hops[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("expected hop 127.0.0.1, got %v", hops[0])
+	}
+}
+
+func TestTracerouteLoopbackIPv6(t *testing.T) {
+	hops, err := TracerouteHops(net.ParseIP("::1"), 5)
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+
+	if len(hops) != 1 || !hops[0].IP.Equal(net.IPv6loopback) {
+		t.Fatalf("expected a single ::1 hop, got %v", hops)
+	}
+
+	if hops[0].TTL != 1 {
+		t.Fatalf("expected TTL 1, got %d", hops[0].TTL)
+	}
+}
+
+func TestTracerouteInvalidArguments(t *testing.T) {
+	if _, err := Traceroute(nil, 5); err == nil {
+		t.Fatalf("expected error for nil destination")
+	}
+
+	if _, err := Traceroute(net.ParseIP("127.0.0.1"), 0); err == nil {
+		t.Fatalf("expected error for zero maxHops")
+	}
+}